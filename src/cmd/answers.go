@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"plexrenamer/internal/cli"
+)
+
+// defaultAnswersPath returns the saved answers location next to the config file
+func defaultAnswersPath(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), "answers.json")
+}
+
+// loadAnswers reads the answers given at the prompts of an earlier session
+// that did not finish. It returns nil if there are none for database.
+func loadAnswers(path, database string) (*cli.ApprovalState, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read saved answers: %w", err)
+	}
+	state := &cli.ApprovalState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse saved answers %s: %w", path, err)
+	}
+	if !sameDatabase(state.Database, database) {
+		return nil, nil
+	}
+	return state, nil
+}
+
+// saveAnswers writes the answers given so far to path
+func saveAnswers(path string, state *cli.ApprovalState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode answers: %w", err)
+	}
+	if err := writeFileAtomic(path, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to save answers: %w", err)
+	}
+	return nil
+}
+
+// forgetAnswers removes the saved answers once a session has finished
+func forgetAnswers(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove saved answers: %w", err)
+	}
+	return nil
+}
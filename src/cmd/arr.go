@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"plexrenamer/internal/arr"
+	"plexrenamer/internal/renamer"
+	"plexrenamer/pkg/planner"
+)
+
+// arrConfig is a Sonarr or Radarr instance from the config file, told about
+// moved files so it keeps tracking them
+type arrConfig struct {
+	URL      string            `json:"url"`
+	APIKey   string            `json:"api_key"`
+	PathMaps []renamer.PathMap `json:"path_maps,omitempty"` // From: path as the app sees it, To: path here
+}
+
+// notifyArr tells the configured Sonarr and Radarr instances about the
+// series and movies whose files were moved: their folder is updated if it
+// changed, then they are rescanned
+func notifyArr(ctx context.Context, config *Config, results []renamer.Result) {
+	if config.Mode != renamer.ModeMove || config.DryRun || config.Sandbox != "" || ctx.Err() != nil {
+		return
+	}
+	for _, app := range []struct {
+		kind arr.Kind
+		conf *arrConfig
+	}{
+		{arr.Sonarr, config.Sonarr},
+		{arr.Radarr, config.Radarr},
+	} {
+		if app.conf != nil {
+			notifyArrApp(ctx, app.kind, app.conf, results)
+		}
+	}
+}
+
+// notifyArrApp updates the items of one app whose files were moved
+func notifyArrApp(ctx context.Context, kind arr.Kind, conf *arrConfig, results []renamer.Result) {
+	client, err := arr.NewClient(kind, conf.URL, conf.APIKey)
+	if err != nil {
+		pterm.Warning.Println(err)
+		return
+	}
+	items, err := client.Items(ctx)
+	if err != nil {
+		pterm.Warning.Printf("Not updating %s: %v\n", kind, err)
+		return
+	}
+
+	normalize := func(path string) string {
+		return strings.TrimSuffix(strings.ReplaceAll(planner.NormalizePath(path), `\`, "/"), "/")
+	}
+	inside := func(path, root string) bool {
+		return path == root || strings.HasPrefix(path, root+"/")
+	}
+
+	// Group the destinations of moved files by the item whose folder held them
+	roots := make([]string, len(items))
+	for i, item := range items {
+		roots[i] = normalize(renamer.MapPath(item.Path, conf.PathMaps))
+	}
+	moved := map[int][]string{}
+	var order []int
+	for _, r := range results {
+		if !r.Success || r.Skipped {
+			continue
+		}
+		source := normalize(r.Operation.Source)
+		best := -1
+		for i, root := range roots {
+			if root != "" && inside(source, root) && (best < 0 || len(root) > len(roots[best])) {
+				best = i
+			}
+		}
+		if best < 0 {
+			continue
+		}
+		if moved[best] == nil {
+			order = append(order, best)
+		}
+		moved[best] = append(moved[best], r.Operation.Destination)
+	}
+	if len(order) == 0 {
+		return
+	}
+
+	updated := 0
+	for _, i := range order {
+		item := items[i]
+		folder := itemFolder(moved[i])
+
+		if normalize(folder) != roots[i] {
+			// A folder shared with other items (e.g. movies placed straight
+			// in the library root) can't become the item's folder
+			shared := false
+			for j, destinations := range moved {
+				if j != i && inside(normalize(filepath.Dir(destinations[0])), normalize(folder)) {
+					shared = true
+				}
+			}
+			for j, root := range roots {
+				if j != i && inside(root, normalize(folder)) {
+					shared = true
+				}
+			}
+			if shared {
+				pterm.Warning.Printf("Not changing the folder of %s in %s: its files were moved to %s, which holds other items too\n", item.Title, kind, folder)
+			} else if err := client.SetPath(ctx, item, renamer.UnmapPath(folder, conf.PathMaps)); err != nil {
+				pterm.Warning.Printf("Failed to change the folder of %s in %s: %v\n", item.Title, kind, err)
+				continue
+			}
+		}
+
+		if err := client.Rescan(ctx, item); err != nil {
+			pterm.Warning.Printf("Failed to rescan %s in %s: %v\n", item.Title, kind, err)
+			continue
+		}
+		updated++
+	}
+	if updated > 0 {
+		pterm.Info.Printf("Updated %d item(s) in %s\n", updated, kind)
+	}
+}
+
+// itemFolder returns the folder holding all destinations of a series or
+// movie, leaving out a season folder
+func itemFolder(destinations []string) string {
+	folder := filepath.Dir(destinations[0])
+	for _, d := range destinations[1:] {
+		for !strings.HasPrefix(d, folder+string(filepath.Separator)) && filepath.Dir(folder) != folder {
+			folder = filepath.Dir(folder)
+		}
+	}
+	if renamer.SeasonFolder.MatchString(filepath.Base(folder)) {
+		folder = filepath.Dir(folder)
+	}
+	return folder
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"plexrenamer/internal/cli"
+	"plexrenamer/internal/database"
+	"plexrenamer/internal/renamer"
+)
+
+// addPlexPaths records the path Plex has for each file of content, by the
+// path it has on this machine
+func addPlexPaths(plexPaths map[string]string, content *database.LibraryContent, maps []renamer.PathMap) {
+	add := func(files []database.MediaPart) {
+		for _, f := range files {
+			plexPaths[renamer.MapPath(f.File, maps)] = f.File
+		}
+	}
+	for _, movie := range content.Movies {
+		add(movie.Files)
+	}
+	for _, show := range content.Shows {
+		for _, season := range show.Seasons {
+			for _, episode := range season.Episodes {
+				add(episode.Files)
+			}
+		}
+	}
+}
+
+// auditPaths writes the files whose path in Plex differs from where the
+// formats put them to the --audit CSV file, leaving the files where they
+// are. With --audit-db, it also writes a copy of the database with the
+// paths of the layout. Sidecars and folders aren't in Plex's files and are
+// left out.
+func auditPaths(ctx context.Context, db *database.PlexDB, config *Config, prompter *cli.Prompter, plexPaths map[string]string, operations []renamer.Operation) error {
+	file, err := os.Create(config.Audit)
+	if err != nil {
+		return fmt.Errorf("failed to create audit file: %w", err)
+	}
+	defer file.Close()
+	w := csv.NewWriter(file)
+	w.Write([]string{"title", "plex_path", "layout_path"})
+
+	changes := map[string]string{}
+	matching := 0
+	for _, op := range operations {
+		plexPath, ok := plexPaths[op.Source]
+		if !ok {
+			continue
+		}
+		layoutPath := plexLayoutPath(op.Destination, plexPath, config.PathMaps)
+		if layoutPath == plexPath {
+			matching++
+			continue
+		}
+		changes[plexPath] = layoutPath
+		w.Write([]string{op.Title, plexPath, layoutPath})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write audit file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write audit file: %w", err)
+	}
+
+	fmt.Println()
+	pterm.Info.Printf("%d file(s) in Plex differ from the layout and %d match it; wrote the differences to %s\n", len(changes), matching, config.Audit)
+	if config.AuditDB == "" || len(changes) == 0 {
+		return nil
+	}
+
+	if !config.AutoApprove {
+		proceed, err := prompter.ConfirmWriteDB(len(changes), config.AuditDB)
+		if err != nil {
+			return err
+		}
+		if !proceed {
+			pterm.Info.Println("Operation cancelled.")
+			return nil
+		}
+	}
+	changed, err := db.WriteCopy(ctx, config.AuditDB, changes)
+	if err != nil {
+		return err
+	}
+	pterm.Success.Printf("Wrote a copy of the database with %d corrected path(s) to %s\n", changed, config.AuditDB)
+	return nil
+}
+
+// plexLayoutPath returns destination, a path on this machine, as Plex would
+// see it, with the separators of plexPath
+func plexLayoutPath(destination, plexPath string, maps []renamer.PathMap) string {
+	path := renamer.UnmapPath(destination, maps)
+	if strings.Contains(plexPath, `\`) && !strings.Contains(plexPath, "/") {
+		return strings.ReplaceAll(path, "/", `\`)
+	}
+	return path
+}
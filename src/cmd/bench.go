@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/pterm/pterm"
+	"plexrenamer/internal/cli"
+	"plexrenamer/internal/database"
+	"plexrenamer/internal/renamer"
+	"plexrenamer/pkg/planner"
+)
+
+// benchResult is what was measured for one library
+type benchResult struct {
+	name       string
+	counts     planner.Counts
+	operations int
+	load       time.Duration // Fastest of the runs
+	plan       time.Duration // Fastest of the runs
+	allocated  uint64        // Bytes allocated by a load and plan
+	retained   uint64        // Bytes of the loaded library still in use after them
+}
+
+// runBench implements the `bench` subcommand, which times loading each
+// library from the database and planning its operations, and measures the
+// memory it takes, without executing anything
+func runBench(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	tvFormat := fs.String("tv-format", renamer.DefaultTVFormat, "Format for TV show filenames")
+	movieFormat := fs.String("movie-format", renamer.DefaultMovieFormat, "Format for movie filenames")
+	outputDir := fs.String("output", "", "Output directory to plan for (default: the root of each library location)")
+	runs := fs.Int("runs", 1, "Number of times to load and plan each library; the fastest time is shown")
+	sections := fs.String("sections", "", "Comma-separated library section IDs to measure (default: all)")
+	var sectionNames stringList
+	fs.Var(&sectionNames, "section-name", "Library section name to measure (repeatable, case-insensitive)")
+	configPath := fs.String("config", defaultConfigPath(), "Config file with per-show formats")
+	dbOpenMode := fs.String("db-open-mode", "", "How to open the database while Plex may be writing to it: immutable, ro, or snapshot (default: snapshot if it has a write-ahead log, immutable otherwise)")
+	plexSQLite := fs.String("plex-sqlite", "", "Plex SQLite program used to read databases that need Plex's own SQLite (default: the one installed with Plex, if found)")
+	inMemory := fs.Bool("load-into-memory", false, "Copy the database into memory before reading it")
+	noColor := fs.Bool("no-color", false, "Disable colored output (also disabled by NO_COLOR, TERM=dumb, or when output is not a terminal)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s bench [options] <database-path>\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Time loading and planning each library, and measure the memory it takes.")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Options:")
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr, "\nExample:")
+		fmt.Fprintln(os.Stderr, "  plexrenamer bench --runs 3 ./plex.db")
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || *runs <= 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	cli.ConfigureColor(*noColor)
+
+	sectionIDs, err := parseSectionIDs(*sections)
+	if err != nil {
+		return err
+	}
+	fc, err := loadConfigFile(*configPath)
+	if err != nil {
+		return err
+	}
+	if err := fc.registerTokenProviders(); err != nil {
+		return err
+	}
+	defer renamer.CloseTokenProviders()
+
+	mode, err := database.ParseOpenMode(*dbOpenMode)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	db, err := openPlex(ctx, fs.Arg(0), mode, *plexSQLite, false)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+	opened := time.Since(start)
+	if *inMemory {
+		start = time.Now()
+		if err := db.LoadIntoMemory(ctx); err != nil {
+			return err
+		}
+		pterm.Info.Printf("Loaded the database into memory in %s\n", benchDuration(time.Since(start)))
+	}
+
+	all, err := db.GetLibrarySections(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get library sections: %w", err)
+	}
+	if len(sectionIDs) > 0 || len(sectionNames) > 0 {
+		all = filterSections(all, sectionIDs, sectionNames)
+	}
+	if len(all) == 0 {
+		pterm.Warning.Println("No library sections found in database.")
+		return nil
+	}
+
+	formatter := renamer.NewFormatter(*tvFormat, *movieFormat)
+	formatter.ShowFormats = fc.showFormats()
+	opts := &planner.Options{Formatter: formatter, Mode: renamer.ModeMove, OutputDir: *outputDir}
+
+	var results []benchResult
+	for _, section := range all {
+		spinner, _ := cli.CreateSpinner(fmt.Sprintf("Measuring %s...", section.Name))
+		result, err := benchLibrary(ctx, db, opts, section, *runs)
+		if spinner != nil {
+			spinner.Stop()
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			pterm.Warning.Printf("Failed to get content for library %s: %v\n", section.Name, err)
+			continue
+		}
+		results = append(results, result)
+	}
+	if err := renamer.TokenProviderErr(); err != nil {
+		return fmt.Errorf("failed to get placeholder values: %w", err)
+	}
+
+	fmt.Println()
+	pterm.Info.Printf("Opened the database in %s (schema %s)\n", benchDuration(opened), orUnknown(db.SchemaVersion()))
+	return showBenchResults(results)
+}
+
+// benchLibrary loads and plans a library runs times
+func benchLibrary(ctx context.Context, db *database.PlexDB, opts *planner.Options, section database.LibrarySection, runs int) (benchResult, error) {
+	result := benchResult{name: section.Name}
+	for run := range runs {
+		runtime.GC()
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+
+		start := time.Now()
+		content, err := db.GetLibraryContent(ctx, section)
+		if err != nil {
+			return result, err
+		}
+		load := time.Since(start)
+
+		start = time.Now()
+		plan := opts.PlanLibrary(content, nil, nil)
+		operations := plan.Operations(opts.Mode)
+		planned := time.Since(start)
+
+		// What the library holds is what is left after collecting the rest
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		if run == 0 || load < result.load {
+			result.load = load
+		}
+		if run == 0 || planned < result.plan {
+			result.plan = planned
+		}
+		result.allocated = after.TotalAlloc - before.TotalAlloc
+		result.retained = 0
+		if after.HeapAlloc > before.HeapAlloc {
+			result.retained = after.HeapAlloc - before.HeapAlloc
+		}
+		result.counts = planner.CountIn(content, content.Locations)
+		result.operations = len(operations)
+		runtime.KeepAlive(content)
+		runtime.KeepAlive(operations)
+	}
+	return result, nil
+}
+
+// showBenchResults prints a table of the results, with their totals
+func showBenchResults(results []benchResult) error {
+	table := [][]string{{"Library", "Items", "Files", "Operations", "Load", "Plan", "Allocated", "Retained"}}
+	var total benchResult
+	for _, r := range results {
+		table = append(table, benchRow(r))
+		total.counts.Movies += r.counts.Movies
+		total.counts.Episodes += r.counts.Episodes
+		total.counts.Files += r.counts.Files
+		total.operations += r.operations
+		total.load += r.load
+		total.plan += r.plan
+		total.allocated += r.allocated
+		total.retained += r.retained
+	}
+	if len(results) > 1 {
+		total.name = "Total"
+		table = append(table, benchRow(total))
+	}
+	if err := pterm.DefaultTable.WithHasHeader().WithData(table).Render(); err != nil {
+		return err
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	pterm.Info.Printf("Memory obtained from the system: %s\n", cli.FormatBytes(int64(mem.Sys)))
+	return nil
+}
+
+// benchRow returns the table row of a result. Items are movies, or
+// episodes for TV libraries.
+func benchRow(r benchResult) []string {
+	return []string{
+		r.name,
+		strconv.Itoa(r.counts.Movies + r.counts.Episodes),
+		strconv.Itoa(r.counts.Files),
+		strconv.Itoa(r.operations),
+		benchDuration(r.load),
+		benchDuration(r.plan),
+		cli.FormatBytes(int64(r.allocated)),
+		cli.FormatBytes(int64(r.retained)),
+	}
+}
+
+// benchDuration rounds d for showing, keeping three significant digits of
+// short times
+func benchDuration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	default:
+		return d.Round(time.Microsecond).String()
+	}
+}
+
+// orUnknown returns s, or "unknown" if it is empty
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"plexrenamer/internal/renamer"
+)
+
+// fileConfig is the persistent configuration stored in config.json
+type fileConfig struct {
+	PathMaps []renamer.PathMap `json:"path_maps,omitempty"`
+	SMB      *smbCredentials   `json:"smb,omitempty"`
+
+	// ShowFormats overrides the TV format for single shows, keyed by title
+	// (case-insensitive) or GUID
+	ShowFormats map[string]string `json:"show_formats,omitempty"`
+
+	Hooks *hookConfig `json:"hooks,omitempty"`
+
+	// TokenProviders are programs that supply placeholders of their own
+	TokenProviders []tokenProviderConfig `json:"token_providers,omitempty"`
+
+	// Sonarr and Radarr are updated after files of their series or movies
+	// were moved
+	Sonarr *arrConfig `json:"sonarr,omitempty"`
+	Radarr *arrConfig `json:"radarr,omitempty"`
+
+	// UNCShares are the shares drive letters are mapped to, for --to-unc,
+	// e.g. {"Z:": "\\\\nas\\media"}
+	UNCShares map[string]string `json:"unc_shares,omitempty"`
+}
+
+// showFormats returns the per-show formats keyed by lowercase title or GUID,
+// as the formatter looks them up
+func (fc *fileConfig) showFormats() map[string]string {
+	formats := map[string]string{}
+	for key, format := range fc.ShowFormats {
+		formats[strings.ToLower(strings.TrimSpace(key))] = format
+	}
+	return formats
+}
+
+// tokenProviderConfig is a program run as a renamer.ExecTokenProvider
+type tokenProviderConfig struct {
+	Command string   `json:"command"`
+	Tokens  []string `json:"tokens"` // The placeholders it supplies
+}
+
+// registerTokenProviders adds the placeholders of the token providers of
+// the config file to the formats
+func (fc *fileConfig) registerTokenProviders() error {
+	for _, tp := range fc.TokenProviders {
+		if tp.Command == "" || len(tp.Tokens) == 0 {
+			return fmt.Errorf("token_providers entries need a command and tokens")
+		}
+		if err := renamer.RegisterTokenProvider(renamer.NewExecTokenProvider(tp.Command, tp.Tokens)); err != nil {
+			return fmt.Errorf("token provider %s: %w", tp.Command, err)
+		}
+	}
+	return nil
+}
+
+// smbCredentials are used to log in to SMB shares given with --smb
+type smbCredentials struct {
+	User     string `json:"user,omitempty"`
+	Password string `json:"password,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+}
+
+// defaultConfigPath returns the config file location in the user's config
+// directory, e.g. ~/.config/plexrenamer/config.json
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "plexrenamer.json"
+	}
+	return filepath.Join(dir, "plexrenamer", "config.json")
+}
+
+// defaultCacheDir returns the directory for cached library content, e.g.
+// ~/.cache/plexrenamer
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "plexrenamer-cache")
+	}
+	return filepath.Join(dir, "plexrenamer")
+}
+
+// loadConfigFile reads the config file at path. A missing file is an empty config.
+func loadConfigFile(path string) (*fileConfig, error) {
+	fc := &fileConfig{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fc, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err := json.Unmarshal(data, fc); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return fc, nil
+}
+
+// saveConfigFile writes fc to path, creating the directory if needed
+func saveConfigFile(path string, fc *fileConfig) error {
+	data, err := json.MarshalIndent(fc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it over path, so an interrupted run never leaves a truncated file behind
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// savePathMaps adds maps to the config file at path, replacing existing
+// mappings for the same Plex path
+func savePathMaps(path string, maps []renamer.PathMap) error {
+	fc, err := loadConfigFile(path)
+	if err != nil {
+		return err
+	}
+	for _, m := range maps {
+		replaced := false
+		for i := range fc.PathMaps {
+			if fc.PathMaps[i].From == m.From {
+				fc.PathMaps[i] = m
+				replaced = true
+			}
+		}
+		if !replaced {
+			fc.PathMaps = append(fc.PathMaps, m)
+		}
+	}
+	return saveConfigFile(path, fc)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"plexrenamer/internal/renamer"
+)
+
+// dockerMount is a bind mount as reported by `docker inspect`
+type dockerMount struct {
+	Type        string
+	Source      string // Path on the host
+	Destination string // Path inside the container
+}
+
+// parseDockerMap turns a --docker-map value into path mappings. It is either
+// "container:NAME", reading the bind mounts of a running container with
+// `docker inspect`, or "PRESET:HOSTDIR" for one of the built-in presets.
+func parseDockerMap(spec string) ([]renamer.PathMap, error) {
+	name, arg, ok := strings.Cut(spec, ":")
+	if !ok || arg == "" {
+		return nil, fmt.Errorf("invalid docker map %q, use container:NAME or PRESET:HOSTDIR", spec)
+	}
+
+	if name == "container" {
+		return inspectDockerMounts(arg)
+	}
+
+	preset, ok := renamer.LookupDockerPreset(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown docker preset: %s (use container:NAME or one of: %s)", name, strings.Join(renamer.DockerPresetNames(), ", "))
+	}
+	return preset.PathMaps(arg), nil
+}
+
+// inspectDockerMounts maps the bind mounts of a container back to their host paths
+func inspectDockerMounts(container string) ([]renamer.PathMap, error) {
+	out, err := exec.Command("docker", "inspect", "--format", "{{json .Mounts}}", container).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("failed to inspect container %s: %s", container, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("failed to inspect container %s: %w", container, err)
+	}
+
+	var mounts []dockerMount
+	if err := json.Unmarshal(out, &mounts); err != nil {
+		return nil, fmt.Errorf("failed to parse mounts of container %s: %w", container, err)
+	}
+
+	var maps []renamer.PathMap
+	for _, m := range mounts {
+		if m.Type == "bind" && m.Source != "" && m.Destination != "" {
+			maps = append(maps, renamer.PathMap{From: m.Destination, To: m.Source})
+		}
+	}
+	if len(maps) == 0 {
+		return nil, fmt.Errorf("container %s has no bind mounts", container)
+	}
+	return maps, nil
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+	"plexrenamer/internal/cli"
+	"plexrenamer/internal/renamer"
+	"plexrenamer/pkg/planner"
+)
+
+// execFlags are the options of subcommands that execute operations
+type execFlags struct {
+	dryRun     *bool
+	noColor    *bool
+	remoteHost *string
+	lang       *string
+	reflink    *string
+	retries    *int
+	retryWait  *time.Duration
+	preserve   *string
+	onExists   *string
+	fsync      *bool
+	lowPrio    *bool
+}
+
+// addExecFlags defines the options of subcommands that execute operations
+func addExecFlags(fs *flag.FlagSet) *execFlags {
+	return &execFlags{
+		dryRun:     fs.Bool("dry-run", false, "Preview changes without applying them"),
+		noColor:    fs.Bool("no-color", false, "Disable colored output (also disabled by NO_COLOR, TERM=dumb, or when output is not a terminal)"),
+		remoteHost: fs.String("remote", "", "Perform the operations on this host over SSH (e.g. user@nas)"),
+		lang:       fs.String("lang", "", "Language for output: "+strings.Join(cli.Languages(), ", ")+" (default: from LANG)"),
+		reflink:    fs.String("reflink", "auto", "Copy-on-write clones on btrfs/XFS: auto (clone when supported), always, or never"),
+		retries:    fs.Int("retries", 0, "Retry operations that fail with transient errors (busy files, dropped network shares) up to N times"),
+		retryWait:  fs.Duration("retry-wait", 10*time.Second, "Wait before the first retry; doubled for each further retry"),
+		preserve:   fs.String("preserve", "mode", "Attributes to keep when copying: mode, times, owner, xattr, all, or none (comma-separated)"),
+		onExists:   fs.String("on-exists", "skip", "When a destination exists: skip, or overwrite-backup to rename it to <name>.bak-<timestamp> and write the file"),
+		lowPrio:    fs.Bool("low-priority", false, "Run with the lowest CPU and disk priority, also for operations over --remote"),
+		fsync:      fs.Bool("fsync", false, "Flush each copied or moved file and its directory to disk before the operation counts as done"),
+	}
+}
+
+// options sets up the output and returns the execution options. The
+// returned function closes the remote connection, if any.
+func (f *execFlags) options() (renamer.ExecOptions, func(), error) {
+	cli.ConfigureColor(*f.noColor)
+	if err := cli.SetLanguage(*f.lang); err != nil {
+		return renamer.ExecOptions{}, nil, err
+	}
+
+	if *f.lowPrio {
+		if err := renamer.LowerPriority(); err != nil {
+			pterm.Warning.Println(err)
+		}
+	}
+
+	preserve, err := renamer.ParsePreserve(*f.preserve)
+	if err != nil {
+		return renamer.ExecOptions{}, nil, fmt.Errorf("invalid preserve list: %w", err)
+	}
+	reflinkMode, err := renamer.ParseReflinkMode(*f.reflink)
+	if err != nil {
+		return renamer.ExecOptions{}, nil, err
+	}
+	onExists, err := renamer.ParseExistsPolicy(*f.onExists)
+	if err != nil {
+		return renamer.ExecOptions{}, nil, err
+	}
+
+	opts := renamer.ExecOptions{DryRun: *f.dryRun, Preserve: preserve, Reflink: reflinkMode, Retries: *f.retries, RetryWait: *f.retryWait, OnExists: onExists, Fsync: *f.fsync}
+	if *f.remoteHost == "" {
+		return opts, func() {}, nil
+	}
+	if onExists == renamer.ExistsBackup {
+		return opts, nil, fmt.Errorf("--on-exists overwrite-backup can't be combined with --remote")
+	}
+	remote, err := connectRemote(*f.remoteHost)
+	if err != nil {
+		return opts, nil, err
+	}
+	remote.LowPriority = *f.lowPrio
+	opts.Executor = remote
+	return opts, func() { remote.Close() }, nil
+}
+
+// runExec implements the `exec` subcommand, which executes the operations
+// stored in a manifest written with --manifest
+func runExec(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("exec", flag.ExitOnError)
+	ef := addExecFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s exec [options] <manifest>\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Execute the operations in a manifest written with --manifest.")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Options:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	opts, closeExec, err := ef.options()
+	if err != nil {
+		return err
+	}
+	defer closeExec()
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to open manifest: %w", err)
+	}
+	defer file.Close()
+
+	operations, err := renamer.ReadManifest(file)
+	if err != nil {
+		return err
+	}
+	if len(operations) == 0 {
+		pterm.Info.Println("No operations to perform.")
+		return nil
+	}
+
+	pterm.Info.Printf("Loaded %d operations from %s\n", len(operations), fs.Arg(0))
+	if *ef.dryRun {
+		pterm.Warning.Println("DRY RUN MODE - No files will be modified")
+	}
+
+	if staged := renamer.StageOverlaps(operations); staged > 0 {
+		pterm.Info.Printf("%d move(s) overlap with other operations and go through a temporary name\n", staged)
+	}
+
+	fmt.Println()
+	start := time.Now()
+	results := executeOperations(ctx, operations, opts, nil)
+	cli.ShowResults(results, nil, time.Since(start))
+	if ctx.Err() != nil {
+		pterm.Warning.Printf("Cancelled after %d of %d operations\n", len(results), len(operations))
+		return ctx.Err()
+	}
+
+	for _, r := range results {
+		if r.Error != nil {
+			return fmt.Errorf("some operations failed")
+		}
+	}
+	return nil
+}
+
+// connectRemote opens an SSH connection to host for remote operations
+func connectRemote(host string) (*renamer.Remote, error) {
+	pterm.Info.Printf("Connecting to %s...\n", host)
+	remote := renamer.NewRemote(host)
+	if err := remote.Check(); err != nil {
+		return nil, err
+	}
+	return remote, nil
+}
+
+// executeOperations runs operations in order with a progress bar that shows
+// the current file and the time left, calling done (if not nil) with the
+// result of each as it completes. Files that were in use are retried at the
+// end, and only passed to done then. If ctx is cancelled, it stops and
+// returns the results of the operations attempted so far.
+func executeOperations(ctx context.Context, operations []renamer.Operation, opts renamer.ExecOptions, done func(renamer.Result)) []renamer.Result {
+	progress := cli.StartProgress(operations)
+	var results []renamer.Result
+	if done != nil {
+		results = planner.Execute(ctx, operations, opts, doneProgress{progress, done})
+	} else {
+		results = planner.Execute(ctx, operations, opts, progress)
+	}
+	progress.Stop()
+
+	retryLockedFiles(ctx, results, opts, done)
+	return results
+}
+
+// doneProgress is a progress bar that also passes each result to done,
+// except for files in use, which are retried
+type doneProgress struct {
+	*cli.Progress
+	done func(renamer.Result)
+}
+
+func (p doneProgress) Done(op renamer.Operation, result renamer.Result) {
+	p.Progress.Done(op, result)
+	if result.Error == nil || !renamer.IsLocked(result.Error) {
+		p.done(result)
+	}
+}
+
+// lockedRetryWait is how long to wait before retrying locked files when no
+// retry wait is configured
+const lockedRetryWait = 10 * time.Second
+
+// retryLockedFiles tries the operations that failed because their file was in
+// use once more, at the end of the run, replacing their results and passing
+// each to done (if not nil). Once the run is cancelled they aren't retried,
+// and their results aren't passed on either.
+func retryLockedFiles(ctx context.Context, results []renamer.Result, opts renamer.ExecOptions, done func(renamer.Result)) {
+	var locked []int
+	for i, r := range results {
+		if r.Error != nil && renamer.IsLocked(r.Error) {
+			locked = append(locked, i)
+		}
+	}
+	if len(locked) == 0 || ctx.Err() != nil {
+		return
+	}
+
+	wait := opts.RetryWait
+	if wait <= 0 {
+		wait = lockedRetryWait
+	}
+	fmt.Println()
+	pterm.Info.Printf("%d files were in use by another program, retrying them in %s...\n", len(locked), wait)
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(wait):
+	}
+
+	opts.Retries = 0
+	for _, i := range locked {
+		if ctx.Err() != nil {
+			return
+		}
+		attempts := results[i].Attempts
+		results[i] = results[i].Operation.Execute(ctx, opts)
+		results[i].Attempts += attempts
+		if done != nil {
+			done(results[i])
+		}
+	}
+}
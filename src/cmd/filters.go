@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"plexrenamer/internal/database"
+	"plexrenamer/internal/renamer"
+)
+
+// fileFilter leaves files out of the plan by their age, watch state, or
+// resolution
+type fileFilter struct {
+	MinAge        time.Duration // Only files at least this old (0 = no limit)
+	MaxAge        time.Duration // Only files at most this old (0 = no limit)
+	AgeByMtime    bool          // Age from the file's modification time instead of when Plex added it
+	OnlyWatched   bool          // Only watched movies and shows with every episode watched
+	OnlyUnwatched bool          // Only unwatched movies and shows with no episode watched
+	MinRes        int           // Only files of at least this resolution, e.g. 1080 (0 = no limit)
+
+	watched map[string]bool                 // GUIDs of watched items, see loadWatched
+	shows   map[int64]database.ShowProgress // Episodes watched per show, see loadWatched
+	left    map[string]renamer.Operation    // Files left out by path, see leftOut
+}
+
+// loadWatched reads the watch state the filter needs from the database
+func (f *fileFilter) loadWatched(ctx context.Context, db *database.PlexDB) error {
+	if !f.OnlyWatched && !f.OnlyUnwatched {
+		return nil
+	}
+	var err error
+	if f.watched, err = db.GetWatched(ctx); err != nil {
+		return err
+	}
+	f.shows, err = db.GetShowProgress(ctx)
+	return err
+}
+
+// keep reports whether file, found at srcPath, of item passes the filter,
+// noting those that don't for the results
+func (f *fileFilter) keep(srcPath string, file database.MediaPart, item, show *database.MetadataItem) bool {
+	if f.passes(srcPath, file, item, show) {
+		return true
+	}
+	if f.left == nil {
+		f.left = map[string]renamer.Operation{}
+	}
+	title := item.Title
+	if show != nil {
+		title = show.Title
+	}
+	f.left[srcPath] = renamer.Operation{Source: srcPath, Title: title, Size: file.Size}
+	return false
+}
+
+// leftOut returns the files the filter left out since it was last asked,
+// as skipped results
+func (f *fileFilter) leftOut() []renamer.Result {
+	var results []renamer.Result
+	for _, path := range slices.Sorted(maps.Keys(f.left)) {
+		results = append(results, renamer.Result{Operation: f.left[path], Skipped: true, Success: true, Reason: renamer.SkipFiltered, Message: "left out by the filters"})
+	}
+	f.left = nil
+	return results
+}
+
+// passes reports whether file, found at srcPath, of item passes the filter.
+// Episodes pass by the watch state of their show.
+func (f *fileFilter) passes(srcPath string, file database.MediaPart, item, show *database.MetadataItem) bool {
+	if f.MinRes > 0 && renamer.Resolution(file.Width, file.Height) < f.MinRes {
+		return false
+	}
+
+	if f.OnlyWatched || f.OnlyUnwatched {
+		var watched, unwatched bool
+		if show != nil {
+			p := f.shows[show.ID]
+			watched, unwatched = p.Episodes > 0 && p.Watched == p.Episodes, p.Watched == 0
+		} else {
+			watched = f.watched[item.GUID]
+			unwatched = !watched
+		}
+		if (f.OnlyWatched && !watched) || (f.OnlyUnwatched && !unwatched) {
+			return false
+		}
+	}
+
+	if f.MinAge == 0 && f.MaxAge == 0 {
+		return true
+	}
+	added := item.AddedAt
+	if f.AgeByMtime {
+		info, err := os.Stat(srcPath)
+		if err != nil {
+			return false
+		}
+		added = info.ModTime()
+	}
+	if added.IsZero() {
+		// Without a date the age is unknown, so it can't pass a limit
+		return false
+	}
+	age := time.Since(added)
+	return (f.MinAge == 0 || age >= f.MinAge) && (f.MaxAge == 0 || age <= f.MaxAge)
+}
+
+// ageUnits are the units parseAge accepts besides those of time.ParseDuration
+var ageUnits = map[string]time.Duration{
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+	"y": 365 * 24 * time.Hour,
+}
+
+// parseAge parses an age such as 30d, 2w, 1y, or 36h
+func parseAge(s string) (time.Duration, error) {
+	text := strings.ToLower(strings.TrimSpace(s))
+	if unit, ok := ageUnits[text[max(len(text)-1, 0):]]; ok {
+		if n, err := strconv.ParseFloat(text[:len(text)-1], 64); err == nil && n > 0 {
+			return time.Duration(n * float64(unit)), nil
+		}
+	} else if d, err := time.ParseDuration(text); err == nil && d > 0 {
+		return d, nil
+	}
+	return 0, fmt.Errorf("invalid age: %s (use e.g. 30d, 2w, or 1y)", s)
+}
+
+// resolutionNames are the names parseResolution accepts besides numbers
+var resolutionNames = map[string]int{
+	"4k":  2160,
+	"uhd": 2160,
+	"fhd": 1080,
+	"hd":  720,
+	"sd":  480,
+}
+
+// parseResolution parses a resolution such as 1080, 720p, or 4k
+func parseResolution(s string) (int, error) {
+	text := strings.ToLower(strings.TrimSpace(s))
+	if r, ok := resolutionNames[text]; ok {
+		return r, nil
+	}
+	if r, err := strconv.Atoi(strings.TrimSuffix(text, "p")); err == nil && r > 0 {
+		return r, nil
+	}
+	return 0, fmt.Errorf("invalid resolution: %s (use e.g. 720, 1080, or 4k)", s)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"plexrenamer/internal/renamer"
+)
+
+// folderJournal records the folder each show or movie was last written to,
+// keyed by the stable ID in its name, so a folder can follow a title change
+type folderJournal map[string]string
+
+// defaultFoldersPath returns the folder journal location next to the config file
+func defaultFoldersPath(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), "folders.json")
+}
+
+// loadFolders reads the folder journal at path. A missing file is an empty journal.
+func loadFolders(path string) (folderJournal, error) {
+	journal := folderJournal{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return journal, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read folder journal: %w", err)
+	}
+	if err := json.Unmarshal(data, &journal); err != nil {
+		return nil, fmt.Errorf("failed to parse folder journal %s: %w", path, err)
+	}
+	return journal, nil
+}
+
+// saveFolders writes the folder journal to path
+func saveFolders(path string, journal folderJournal) error {
+	data, err := json.MarshalIndent(journal, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode folder journal: %w", err)
+	}
+	if err := writeFileAtomic(path, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write folder journal: %w", err)
+	}
+	return nil
+}
+
+// folderRenames returns operations that rename the folders in the journal
+// whose show or movie is now planned under another name in the same place,
+// so the files already there move along instead of being written twice.
+// Folders that hold sources of the plan are left to the file operations.
+func folderRenames(journal folderJournal, operations []renamer.Operation) []renamer.Operation {
+	var renames []renamer.Operation
+	done := map[string]bool{}
+	for _, op := range operations {
+		folder, id := renamer.FolderID(op.Destination)
+		old := journal[id]
+		if id == "" || done[id] || old == "" || old == folder || filepath.Dir(old) != filepath.Dir(folder) {
+			continue
+		}
+		done[id] = true
+
+		if info, err := os.Stat(old); err != nil || !info.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(folder); err == nil {
+			continue
+		}
+		if holdsSources(old, operations) {
+			continue
+		}
+		renames = append(renames, renamer.Operation{
+			Source:      old,
+			Destination: folder,
+			Mode:        renamer.ModeMove,
+			Title:       op.Title,
+		})
+	}
+	return renames
+}
+
+// holdsSources reports whether a source of the operations is in dir
+func holdsSources(dir string, operations []renamer.Operation) bool {
+	prefix := dir + string(filepath.Separator)
+	for _, op := range operations {
+		if strings.HasPrefix(op.Source, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordFolders updates the journal with the folders that operations wrote
+// to, and reports whether anything changed
+func recordFolders(journal folderJournal, results []renamer.Result) bool {
+	changed := false
+	for _, r := range results {
+		if r.Error != nil {
+			continue
+		}
+		folder, id := renamer.FolderID(r.Operation.Destination)
+		if id != "" && journal[id] != folder {
+			journal[id] = folder
+			changed = true
+		}
+	}
+	return changed
+}
+
+// updateFolderJournal records the folders written by a run with --folder-ids
+func updateFolderJournal(config *Config, results []renamer.Result) {
+	if !config.FolderIDs || config.DryRun || config.Sandbox != "" {
+		return
+	}
+	path := defaultFoldersPath(config.ConfigPath)
+	journal, err := loadFolders(path)
+	if err != nil {
+		pterm.Warning.Println(err)
+		return
+	}
+	if recordFolders(journal, results) {
+		if err := saveFolders(path, journal); err != nil {
+			pterm.Warning.Println(err)
+		}
+	}
+}
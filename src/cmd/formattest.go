@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"plexrenamer/internal/cli"
+	"plexrenamer/internal/database"
+	"plexrenamer/internal/renamer"
+)
+
+// runFormatTest implements the `format-test` subcommand, which shows how
+// sample items from each library are named with the given formats, without
+// planning or executing any operations
+func runFormatTest(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("format-test", flag.ExitOnError)
+	tvFormat := fs.String("tv-format", renamer.DefaultTVFormat, "Format for TV show filenames")
+	movieFormat := fs.String("movie-format", renamer.DefaultMovieFormat, "Format for movie filenames")
+	preset := fs.String("preset", "", "Naming preset: "+strings.Join(renamer.PresetNames(), ", ")+" (explicit formats take precedence)")
+	samples := fs.Int("samples", 5, "Number of items to show from each library")
+	sections := fs.String("sections", "", "Comma-separated library section IDs to show (default: all)")
+	var sectionNames stringList
+	fs.Var(&sectionNames, "section-name", "Library section name to show (repeatable, case-insensitive)")
+	configPath := fs.String("config", defaultConfigPath(), "Config file with per-show formats")
+	dbOpenMode := fs.String("db-open-mode", "", "How to open the database while Plex may be writing to it: immutable, ro, or snapshot (default: snapshot if it has a write-ahead log, immutable otherwise)")
+	plexSQLite := fs.String("plex-sqlite", "", "Plex SQLite program used to read databases that need Plex's own SQLite (default: the one installed with Plex, if found)")
+	interactive := fs.Bool("interactive", false, "After showing the samples, ask for new formats and show them again")
+	noColor := fs.Bool("no-color", false, "Disable colored output (also disabled by NO_COLOR, TERM=dumb, or when output is not a terminal)")
+	lang := fs.String("lang", "", "Language for output: "+strings.Join(cli.Languages(), ", ")+" (default: from LANG)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s format-test [options] <database-path>\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Show how sample items from each library are named with the given formats.")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Options:")
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr, "\nExample:")
+		fmt.Fprintln(os.Stderr, "  plexrenamer format-test --tv-format '{show}/{season_folder}/{show} S{snum}E{enum}{ext}' ./plex.db")
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || *samples <= 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	cli.ConfigureColor(*noColor)
+	if err := cli.SetLanguage(*lang); err != nil {
+		return err
+	}
+
+	if *preset != "" {
+		p, ok := renamer.LookupPreset(*preset)
+		if !ok {
+			return fmt.Errorf("unknown preset: %s (use one of: %s)", *preset, strings.Join(renamer.PresetNames(), ", "))
+		}
+		explicit := map[string]bool{}
+		fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+		if !explicit["tv-format"] {
+			*tvFormat = p.TVFormat
+		}
+		if !explicit["movie-format"] {
+			*movieFormat = p.MovieFormat
+		}
+	}
+
+	sectionIDs, err := parseSectionIDs(*sections)
+	if err != nil {
+		return err
+	}
+
+	fc, err := loadConfigFile(*configPath)
+	if err != nil {
+		return err
+	}
+	if err := fc.registerTokenProviders(); err != nil {
+		return err
+	}
+	defer renamer.CloseTokenProviders()
+
+	mode, err := database.ParseOpenMode(*dbOpenMode)
+	if err != nil {
+		return err
+	}
+	db, err := openPlex(ctx, fs.Arg(0), mode, *plexSQLite, false)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	all, err := db.GetLibrarySections(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get library sections: %w", err)
+	}
+	if len(sectionIDs) > 0 || len(sectionNames) > 0 {
+		all = filterSections(all, sectionIDs, sectionNames)
+	}
+
+	// Load each library once; only the formats change between rounds
+	var contents []*database.LibraryContent
+	for _, section := range all {
+		content, err := db.GetLibraryContent(ctx, section)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			pterm.Warning.Printf("Failed to get content for library %s: %v\n", section.Name, err)
+			continue
+		}
+		contents = append(contents, content)
+	}
+	if len(contents) == 0 {
+		pterm.Warning.Println("No library sections found in database.")
+		return nil
+	}
+
+	prompter := cli.NewPrompter(ctx)
+	for {
+		formatter := renamer.NewFormatter(*tvFormat, *movieFormat)
+		formatter.ShowFormats = fc.showFormats()
+
+		hasShows, hasMovies := false, false
+		for _, content := range contents {
+			fmt.Println()
+			cli.PrintHeader(content.Section.Name)
+			if content.Section.SectionType == database.SectionTypeShow {
+				hasShows = true
+				cli.ShowFormatSamples(*tvFormat, episodeSamples(formatter.ForLibrary(content.Section.Name), content, *samples), renamer.ValidateTVFormat(*tvFormat))
+			} else {
+				hasMovies = true
+				cli.ShowFormatSamples(*movieFormat, movieSamples(formatter.ForLibrary(content.Section.Name), content, *samples), renamer.ValidateMovieFormat(*movieFormat))
+			}
+		}
+
+		if err := renamer.TokenProviderErr(); err != nil {
+			return fmt.Errorf("failed to get placeholder values: %w", err)
+		}
+		if !*interactive {
+			return nil
+		}
+
+		fmt.Println()
+		cli.PrintDim(cli.T("format.hint"))
+		changed := false
+		for _, f := range []struct {
+			use    bool
+			kind   string
+			format *string
+		}{
+			{hasShows, "format.tv", tvFormat},
+			{hasMovies, "format.movie", movieFormat},
+		} {
+			if !f.use {
+				continue
+			}
+			format, err := prompter.PromptFormat(f.kind, *f.format)
+			if errors.Is(err, io.EOF) {
+				fmt.Println()
+				break
+			}
+			if err != nil {
+				return err
+			}
+			changed = changed || format != *f.format
+			*f.format = format
+		}
+		if !changed {
+			break
+		}
+	}
+
+	fmt.Println()
+	pterm.Info.Printf("Use these formats with: --tv-format %s --movie-format %s\n", bashQuote(*tvFormat), bashQuote(*movieFormat))
+	return nil
+}
+
+// sampleIndexes returns up to n indexes spread evenly over a list of length
+func sampleIndexes(length, n int) []int {
+	if length <= n {
+		n = length
+	}
+	indexes := make([]int, n)
+	for i := range indexes {
+		indexes[i] = i * length / n
+	}
+	return indexes
+}
+
+// movieSamples returns the current and formatted name of up to n movies
+func movieSamples(formatter *renamer.Formatter, content *database.LibraryContent, n int) []cli.PathPreview {
+	var movies []*database.MovieInfo
+	for i := range content.Movies {
+		if len(content.Movies[i].Files) > 0 {
+			movies = append(movies, &content.Movies[i])
+		}
+	}
+
+	var samples []cli.PathPreview
+	for _, i := range sampleIndexes(len(movies), n) {
+		file := movies[i].Files[0]
+		version := renamer.Versions(movies[i].Files)[file.MediaItemID]
+		samples = append(samples, cli.PathPreview{
+			Source:      file.File,
+			Destination: formatter.FormatMovie(movies[i], file, version, renamer.GetExtension(file.File)),
+		})
+	}
+	return samples
+}
+
+// episodeSamples returns the current and formatted name of up to n
+// episodes, spread over the shows of the library
+func episodeSamples(formatter *renamer.Formatter, content *database.LibraryContent, n int) []cli.PathPreview {
+	type episodeRef struct {
+		show, season *database.MetadataItem
+		episode      *database.EpisodeInfo
+	}
+	var episodes []episodeRef
+	for i := range content.Shows {
+		show := &content.Shows[i]
+		for j := range show.Seasons {
+			season := &show.Seasons[j]
+			for k := range season.Episodes {
+				if len(season.Episodes[k].Files) > 0 {
+					episodes = append(episodes, episodeRef{&show.Metadata, &season.Metadata, &season.Episodes[k]})
+				}
+			}
+		}
+	}
+
+	var samples []cli.PathPreview
+	for _, i := range sampleIndexes(len(episodes), n) {
+		e := episodes[i]
+		file := e.episode.Files[0]
+		version := renamer.Versions(e.episode.Files)[file.MediaItemID]
+		samples = append(samples, cli.PathPreview{
+			Source:      file.File,
+			Destination: formatter.ForShow(e.show).FormatEpisode(e.show, e.season, e.episode, file, version, renamer.GetExtension(file.File)),
+		})
+	}
+	return samples
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+	"plexrenamer/internal/cli"
+	"plexrenamer/internal/renamer"
+)
+
+// maxHistory is how many runs the history keeps; older ones are removed
+const maxHistory = 500
+
+// historyRun is the summary of a run kept in the history
+type historyRun struct {
+	ID         string           `json:"id"`
+	Start      time.Time        `json:"start"`
+	Duration   string           `json:"duration"`
+	Database   string           `json:"database"`
+	Mode       string           `json:"mode"`
+	Args       []string         `json:"args"` // Command line, which holds the settings of the run
+	Cancelled  bool             `json:"cancelled,omitempty"`
+	Operations int              `json:"operations"`
+	Succeeded  int              `json:"succeeded"`
+	Skipped    int              `json:"skipped"`
+	Declined   int              `json:"declined,omitempty"` // Libraries, shows, and movies declined at the prompts
+	Failed     int              `json:"failed"`
+	Failures   []historyFailure `json:"failures,omitempty"`
+	Backups    []historyBackup  `json:"backups,omitempty"` // Destinations that were replaced, to restore them
+}
+
+// historyFailure is an operation that failed in a run
+type historyFailure struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Error       string `json:"error"`
+}
+
+// historyBackup is a destination that --on-exists overwrite-backup replaced,
+// and where the file that was there before was kept
+type historyBackup struct {
+	Destination string `json:"destination"`
+	Backup      string `json:"backup"`
+}
+
+// defaultHistoryDir returns the run history location next to the config file
+func defaultHistoryDir(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), "history")
+}
+
+// newHistoryRun summarizes the results of a run that took elapsed and ended
+// now, counting what was left out of the plan as the results summary does
+func newHistoryRun(config *Config, results, leftOut []renamer.Result, elapsed time.Duration, cancelled bool) historyRun {
+	start := time.Now().Add(-elapsed)
+	entry := historyRun{
+		ID:         start.Format("20060102-150405"),
+		Start:      start,
+		Duration:   elapsed.Round(time.Second).String(),
+		Database:   planSource(config),
+		Mode:       string(config.Mode),
+		Args:       redactArgs(os.Args[1:]),
+		Cancelled:  cancelled,
+		Operations: len(results),
+	}
+	if abs, err := filepath.Abs(planSource(config)); err == nil {
+		entry.Database = abs
+	}
+	for _, r := range results {
+		if r.Backup != "" {
+			entry.Backups = append(entry.Backups, historyBackup{Destination: r.Operation.Destination, Backup: r.Backup})
+		}
+		switch {
+		case r.Error != nil:
+			entry.Failed++
+			entry.Failures = append(entry.Failures, historyFailure{
+				Source:      r.Operation.Source,
+				Destination: r.Operation.Destination,
+				Error:       r.Error.Error(),
+			})
+		case r.Skipped:
+			entry.Skipped++
+		case r.Success:
+			entry.Succeeded++
+		}
+	}
+	for _, r := range leftOut {
+		if r.Reason == renamer.SkipDeclined {
+			entry.Declined++
+		} else {
+			entry.Skipped++
+		}
+	}
+	return entry
+}
+
+// secretFlags are the flags whose values are never written to the history
+var secretFlags = map[string]bool{"plex-token": true}
+
+// urlPassword matches the password of a user:password@ in a URL
+var urlPassword = regexp.MustCompile(`(://[^/:@\s]*:)[^/@\s]*@`)
+
+// redactArgs returns args with the values of secret flags, and passwords in
+// URLs such as those of --smb, replaced by "***"
+func redactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	secret := false // The previous argument is a secret flag
+	for i, arg := range args {
+		switch {
+		case secret:
+			arg = "***"
+			secret = false
+		case strings.HasPrefix(arg, "-") && arg != "--":
+			name, _, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+			if secretFlags[name] {
+				if hasValue {
+					arg = arg[:strings.Index(arg, "=")+1] + "***"
+				} else {
+					secret = true
+				}
+			}
+		}
+		redacted[i] = urlPassword.ReplaceAllString(arg, "${1}***@")
+	}
+	return redacted
+}
+
+// saveHistoryRun writes entry to the history in dir, under an ID that isn't
+// taken yet, and removes the oldest runs beyond maxHistory
+func saveHistoryRun(dir string, entry historyRun) error {
+	id := entry.ID
+	for n := 2; ; n++ {
+		if _, err := os.Stat(filepath.Join(dir, id+".json")); os.IsNotExist(err) {
+			break
+		}
+		id = fmt.Sprintf("%s-%d", entry.ID, n)
+	}
+	entry.ID = id
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run history: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(dir, id+".json"), append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write run history: %w", err)
+	}
+
+	ids, err := historyIDs(dir)
+	if err != nil {
+		return err
+	}
+	for _, old := range ids[:max(len(ids)-maxHistory, 0)] {
+		os.Remove(filepath.Join(dir, old+".json"))
+	}
+	return nil
+}
+
+// recordHistory adds a run that executed operations to the history
+func recordHistory(config *Config, results, leftOut []renamer.Result, elapsed time.Duration, cancelled bool) {
+	if config.NoHistory || config.DryRun || config.Validate || config.Sandbox != "" {
+		return
+	}
+	entry := newHistoryRun(config, results, leftOut, elapsed, cancelled)
+	if err := saveHistoryRun(defaultHistoryDir(config.ConfigPath), entry); err != nil {
+		pterm.Warning.Println(err)
+	}
+}
+
+// historyIDs returns the IDs of the runs in the history, oldest first
+func historyIDs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run history: %w", err)
+	}
+	var ids []string
+	for _, e := range entries {
+		if id, ok := strings.CutSuffix(e.Name(), ".json"); ok && !e.IsDir() {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// loadHistoryRun reads the run with the given ID from the history in dir
+func loadHistoryRun(dir, id string) (historyRun, error) {
+	var entry historyRun
+	data, err := os.ReadFile(filepath.Join(dir, id+".json"))
+	if os.IsNotExist(err) {
+		return entry, fmt.Errorf("no run %s in %s (list them with the history subcommand)", id, dir)
+	}
+	if err != nil {
+		return entry, fmt.Errorf("failed to read run %s: %w", id, err)
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return entry, fmt.Errorf("failed to parse run %s: %w", id, err)
+	}
+	return entry, nil
+}
+
+// runHistory implements the `history` subcommand, which lists past runs
+func runHistory(args []string) error {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfigPath(), "Config file the history is kept next to")
+	limit := fs.Int("limit", 20, "Number of runs to list, newest last (0 = all)")
+	noColor := fs.Bool("no-color", false, "Disable colored output (also disabled by NO_COLOR, TERM=dumb, or when output is not a terminal)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s history [options]\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "List past runs. Show one in detail with show-run <id>.")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Options:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	cli.ConfigureColor(*noColor)
+
+	dir := defaultHistoryDir(*configPath)
+	ids, err := historyIDs(dir)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		pterm.Info.Printf("No runs recorded in %s yet\n", dir)
+		return nil
+	}
+	if *limit > 0 && len(ids) > *limit {
+		ids = ids[len(ids)-*limit:]
+	}
+
+	table := [][]string{{"ID", "Started", "Mode", "Operations", "Succeeded", "Skipped", "Declined", "Failed", "Duration"}}
+	for _, id := range ids {
+		entry, err := loadHistoryRun(dir, id)
+		if err != nil {
+			pterm.Warning.Println(err)
+			continue
+		}
+		duration := entry.Duration
+		if entry.Cancelled {
+			duration += " (cancelled)"
+		}
+		table = append(table, []string{
+			entry.ID,
+			entry.Start.Local().Format("2006-01-02 15:04"),
+			entry.Mode,
+			fmt.Sprint(entry.Operations),
+			fmt.Sprint(entry.Succeeded),
+			fmt.Sprint(entry.Skipped),
+			fmt.Sprint(entry.Declined),
+			fmt.Sprint(entry.Failed),
+			duration,
+		})
+	}
+	return pterm.DefaultTable.WithHasHeader().WithData(table).Render()
+}
+
+// runShowRun implements the `show-run` subcommand, which shows a past run
+// with its settings and failures
+func runShowRun(args []string) error {
+	fs := flag.NewFlagSet("show-run", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfigPath(), "Config file the history is kept next to")
+	noColor := fs.Bool("no-color", false, "Disable colored output (also disabled by NO_COLOR, TERM=dumb, or when output is not a terminal)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s show-run [options] <id|last|run.json>\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Show the settings, results, and failures of a past run.")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Options:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	cli.ConfigureColor(*noColor)
+
+	entry, err := findHistoryRun(defaultHistoryDir(*configPath), fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	cli.PrintHeader("Run " + entry.ID)
+	fmt.Printf("  Started:    %s\n", entry.Start.Local().Format("2006-01-02 15:04:05"))
+	fmt.Printf("  Duration:   %s\n", entry.Duration)
+	fmt.Printf("  Database:   %s\n", entry.Database)
+	fmt.Printf("  Mode:       %s\n", entry.Mode)
+	fmt.Printf("  Arguments:  %s\n", strings.Join(redactArgs(entry.Args), " "))
+	fmt.Println()
+	cli.PrintResultsBox(entry.Succeeded, entry.Skipped, entry.Declined, entry.Failed, 0)
+	if entry.Cancelled {
+		pterm.Warning.Printf("Cancelled after %d operations\n", entry.Operations)
+	}
+	if len(entry.Failures) > 0 {
+		fmt.Println()
+		pterm.Error.Println("Failed operations:")
+		for _, f := range entry.Failures {
+			fmt.Printf("  %s\n", f.Source)
+			fmt.Printf("    -> %s\n", f.Destination)
+			fmt.Printf("    %s %s\n", pterm.FgRed.Sprint("Error:"), f.Error)
+		}
+	}
+	if len(entry.Backups) > 0 {
+		fmt.Println()
+		pterm.Info.Println("Replaced files, kept as backups:")
+		for _, b := range entry.Backups {
+			fmt.Printf("  %s\n", b.Destination)
+			fmt.Printf("    <- %s\n", b.Backup)
+		}
+	}
+	return nil
+}
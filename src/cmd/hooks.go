@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+
+	"github.com/pterm/pterm"
+	"plexrenamer/internal/renamer"
+)
+
+// hookConfig holds commands from the config file that are run by the shell at
+// points of a run, e.g. to start a Plex scan or send a notification. Hooks
+// don't run on dry runs, or when only a script or manifest is written.
+type hookConfig struct {
+	PreRun        string `json:"pre_run,omitempty"`        // Before the first operation; failing aborts the run
+	PostOperation string `json:"post_operation,omitempty"` // As each operation completes, with SRC, DST, TITLE, MODE, STATUS, and MESSAGE set
+	PostRun       string `json:"post_run,omitempty"`       // After the run, with STATUS and the OPERATIONS, SUCCEEDED, SKIPPED, and FAILED counts set
+}
+
+// runHook runs command with the shell, adding env to the environment. Its
+// output goes to ours.
+func runHook(ctx context.Context, command string, env ...string) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// runPreRunHook runs the pre_run hook before operations are executed
+func runPreRunHook(ctx context.Context, config *Config) error {
+	if config.Hooks == nil || config.Hooks.PreRun == "" || config.DryRun || config.Sandbox != "" {
+		return nil
+	}
+	if err := runHook(ctx, config.Hooks.PreRun); err != nil {
+		return fmt.Errorf("pre_run hook failed, no operations were run: %w", err)
+	}
+	return nil
+}
+
+// postOperationHook returns a function that runs the post_operation hook
+// for a result, as its operation completes, or nil if there is no hook to
+// run. A failure is reported but doesn't stop the run.
+func postOperationHook(ctx context.Context, config *Config) func(renamer.Result) {
+	if config.Hooks == nil || config.Hooks.PostOperation == "" || config.DryRun || config.Sandbox != "" {
+		return nil
+	}
+	return func(r renamer.Result) {
+		if ctx.Err() != nil {
+			return
+		}
+		status, message := resultStatus(r)
+		err := runHook(ctx, config.Hooks.PostOperation,
+			"SRC="+r.Operation.Source,
+			"DST="+r.Operation.Destination,
+			"TITLE="+r.Operation.Title,
+			"MODE="+string(r.Operation.Mode),
+			"STATUS="+status,
+			"MESSAGE="+message)
+		if err != nil && ctx.Err() == nil {
+			pterm.Warning.Printf("post_operation hook failed for %s: %v\n", r.Operation.Source, err)
+		}
+	}
+}
+
+// runPostRunHook runs the post_run hook with the counts of results. A
+// failure is reported but doesn't fail the run.
+func runPostRunHook(ctx context.Context, config *Config, results []renamer.Result) {
+	if config.Hooks == nil || config.Hooks.PostRun == "" || config.DryRun || config.Sandbox != "" || ctx.Err() != nil {
+		return
+	}
+
+	var succeeded, skipped, failed int
+	for _, r := range results {
+		switch status, _ := resultStatus(r); status {
+		case "succeeded":
+			succeeded++
+		case "skipped":
+			skipped++
+		case "failed":
+			failed++
+		}
+	}
+	status := "succeeded"
+	if failed > 0 {
+		status = "failed"
+	}
+	err := runHook(ctx, config.Hooks.PostRun,
+		"STATUS="+status,
+		"OPERATIONS="+strconv.Itoa(len(results)),
+		"SUCCEEDED="+strconv.Itoa(succeeded),
+		"SKIPPED="+strconv.Itoa(skipped),
+		"FAILED="+strconv.Itoa(failed))
+	if err != nil && ctx.Err() == nil {
+		pterm.Warning.Printf("post_run hook failed: %v\n", err)
+	}
+}
+
+// resultStatus returns "succeeded", "skipped", or "failed" for a result, and
+// its message or error
+func resultStatus(r renamer.Result) (string, string) {
+	switch {
+	case r.Error != nil:
+		return "failed", r.Error.Error()
+	case r.Skipped:
+		return "skipped", r.Message
+	default:
+		return "succeeded", r.Message
+	}
+}
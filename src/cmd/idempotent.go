@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"plexrenamer/internal/cli"
+	"plexrenamer/internal/database"
+	"plexrenamer/internal/renamer"
+)
+
+// plannedLibrary is what the operations of a library were planned from,
+// kept to plan it again for --assert-idempotent
+type plannedLibrary struct {
+	content *database.LibraryContent
+	outputs []cli.LocationWithOutput
+}
+
+// assertIdempotent plans the libraries again as if Plex saw the files the
+// run wrote where they were written, and returns an error listing what a
+// second run would still do to them. Unless it was a dry run, the names on
+// disk must also be the ones planned, which filesystems that drop trailing
+// dots or spaces, or fold case, may have changed.
+func assertIdempotent(config *Config, formatter *renamer.Formatter, planned []plannedLibrary, results []renamer.Result) error {
+	written := map[string]string{} // Destination of each source written
+	wrote := map[string]bool{}     // The destinations
+	for _, r := range results {
+		if r.Error == nil && !r.Skipped && r.Operation.Source != "" && r.Operation.Destination != "" {
+			written[filepath.Clean(r.Operation.Source)] = r.Operation.Destination
+			wrote[filepath.Clean(r.Operation.Destination)] = true
+		}
+	}
+
+	var diffs []string
+	if !config.DryRun {
+		listings := map[string]map[string]bool{}
+		for _, r := range results {
+			dst := filepath.Clean(r.Operation.Destination)
+			if !wrote[dst] {
+				continue
+			}
+			if found, ok := onDisk(dst, listings); !ok && found == "" {
+				diffs = append(diffs, fmt.Sprintf("  %s is missing", dst))
+			} else if !ok {
+				diffs = append(diffs, fmt.Sprintf("  %s is on disk as %s", dst, found))
+			}
+		}
+	}
+
+	options := plannerOptions(config, formatter)
+	options.Keep = nil // What the filters left out wasn't written
+	for _, lib := range planned {
+		content := movedContent(lib.content, written, config.PathMaps)
+		for _, op := range options.PlanLibrary(content, nil, lib.outputs).Operations(config.Mode) {
+			src := filepath.Clean(op.Source)
+			if wrote[src] && src != filepath.Clean(op.Destination) {
+				diffs = append(diffs, fmt.Sprintf("- %s\n+ %s", op.Source, op.Destination))
+			}
+		}
+	}
+
+	if len(diffs) > 0 {
+		return fmt.Errorf("not idempotent, %d file(s) differ from a second run's plan:\n%s", len(diffs), strings.Join(diffs, "\n"))
+	}
+	return nil
+}
+
+// movedContent returns a copy of content with the files in written, by
+// their path here, at their destination as Plex would see it
+func movedContent(content *database.LibraryContent, written map[string]string, maps []renamer.PathMap) *database.LibraryContent {
+	move := func(files []database.MediaPart) []database.MediaPart {
+		files = slices.Clone(files)
+		for i, f := range files {
+			if dst, ok := written[filepath.Clean(renamer.MapPath(f.File, maps))]; ok {
+				files[i].File = renamer.UnmapPath(dst, maps)
+			}
+		}
+		return files
+	}
+
+	moved := *content
+	moved.Movies = slices.Clone(content.Movies)
+	for i := range moved.Movies {
+		moved.Movies[i].Files = move(moved.Movies[i].Files)
+	}
+	moved.Shows = slices.Clone(content.Shows)
+	for i := range moved.Shows {
+		show := &moved.Shows[i]
+		show.Seasons = slices.Clone(show.Seasons)
+		for j := range show.Seasons {
+			season := &show.Seasons[j]
+			season.Episodes = slices.Clone(season.Episodes)
+			for k := range season.Episodes {
+				season.Episodes[k].Files = move(season.Episodes[k].Files)
+			}
+		}
+	}
+	return &moved
+}
+
+// onDisk reports whether path is on disk under exactly that name, and so
+// are its folders. If not, it returns the path as it is on disk when the
+// name only differs by case or trailing dots and spaces, or "". Directory
+// listings are kept in listings; those that can't be read pass.
+func onDisk(path string, listings map[string]map[string]bool) (string, bool) {
+	dir, name := filepath.Dir(path), filepath.Base(path)
+	if dir == path {
+		return path, true
+	}
+	if found, ok := onDisk(dir, listings); !ok {
+		if found == "" {
+			return "", false
+		}
+		return filepath.Join(found, name), false
+	}
+
+	names, listed := listings[dir]
+	if !listed {
+		if entries, err := os.ReadDir(dir); err == nil {
+			names = make(map[string]bool, len(entries))
+			for _, e := range entries {
+				names[e.Name()] = true
+			}
+		}
+		listings[dir] = names
+	}
+	if names == nil || names[name] {
+		return path, true
+	}
+	trimmed := strings.TrimRight(name, ". ")
+	for n := range names {
+		if strings.EqualFold(strings.TrimRight(n, ". "), trimmed) {
+			return filepath.Join(dir, n), false
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pterm/pterm"
+	"plexrenamer/internal/database"
+)
+
+// kodiLibraries reads the movies and TV shows of a Kodi video database,
+// narrowed down to the libraries selected, for normalizing file names with
+// Kodi's matched metadata before moving to Plex
+func kodiLibraries(ctx context.Context, config *Config) ([]library, error) {
+	if !config.ScriptMode {
+		pterm.Info.Printf("Opening Kodi database: %s\n", config.DatabasePath)
+	}
+	db, err := database.OpenKodi(config.DatabasePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	sections, err := db.GetLibrarySections(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get library sections: %w", err)
+	}
+	if sections, err = selectSections(config, sections); err != nil {
+		return nil, err
+	}
+
+	var libraries []library
+	for _, section := range sections {
+		content, err := db.GetLibraryContent(ctx, section)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get content for library %s: %w", section.Name, err)
+		}
+		libraries = append(libraries, library{section: section, content: content, source: -1})
+	}
+	if !config.ScriptMode {
+		pterm.Success.Printf("Found %d library section(s)\n", len(libraries))
+	}
+	return libraries, nil
+}
@@ -1,65 +1,300 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"maps"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/pterm/pterm"
 	"plexrenamer/internal/cli"
 	"plexrenamer/internal/database"
+	"plexrenamer/internal/plexapi"
 	"plexrenamer/internal/renamer"
+	"plexrenamer/internal/schedule"
+	"plexrenamer/pkg/planner"
 )
 
 // Config holds the application configuration
 type Config struct {
 	DatabasePath string
+	Merged       []mergedSource // Further databases whose libraries are merged into the plan
+	MatchDir     string         // Plan the files here that match the database's by size and name
+	ScanDir      string         // Plan the video files here by their names, without a database
+	Lookup       bool           // With ScanDir, look the names up in Sonarr and Radarr
 	OutputDir    string
 	DryRun       bool
+	Validate     bool // With DryRun: check sources, destinations, and permissions
+	PreviewLimit int  // Operations shown before confirming (0 = all)
+	Idempotent   bool // After running, plan again and fail if anything would be done
 	ScriptMode   bool
-	ScriptShell  string // "cmd", "powershell", or "bash"
+	ScriptShell  string // "cmd", "powershell", "bash", or "python"
 	ScriptOutput string // Output file for script
+	ChunkSize    int    // Max operations per script file (0 = single script)
+	Manifest     string // Write a NUL-delimited manifest here instead of executing
+	HTMLReport   string // Write the plan, then the results, to this HTML file
+	CSVReport    string // The same, to this CSV file
+	Audit        string // Write how Plex's paths differ from the layout to this CSV file instead of executing
+	AuditDB      string // With Audit, write a copy of the database with the paths of the layout here
 	Mode         renamer.OperationMode
+	Preserve     renamer.Preserve     // Attributes carried over when copying
+	Reflink      renamer.ReflinkMode  // Copy-on-write clones: auto, always, or never
+	Retries      int                  // Retries after transient I/O errors
+	RetryWait    time.Duration        // Wait before the first retry, doubled after each
+	OnExists     renamer.ExistsPolicy // Skip existing destinations, or back them up and write (always, or if better)
+	Fsync        bool                 // Flush each destination and its directory to disk before moving on
+	SetMtime     renamer.MtimeMode    // Set the modification time of destinations to that of their source or their air date
+	Sandbox      string               // Execute on zero-byte stand-ins of the files in this directory instead of the files
+	LowPriority  bool                 // Run with the lowest CPU and disk priority, as should scripts
 	TVFormat     string
 	MovieFormat  string
-	PathMapSrc   string
-	PathMapDst   string
+	ShowFormats  map[string]string      // TV formats for single shows, by lowercase title or GUID (from the config file)
+	Hooks        *hookConfig            // Commands run before and after operations (from the config file)
+	Sonarr       *arrConfig             // Told about moved episodes (from the config file)
+	Radarr       *arrConfig             // Told about moved movies (from the config file)
+	Leftovers    []renamer.LeftoverRule // Report/handle files left in source directories (nil = off)
+	CleanupDirs  bool                   // Remove source directories emptied by moves
+	Protect      []string               // Directories never removed by CleanupDirs
+	PathMaps     []renamer.PathMap      // From --path-map, then the config file
+	UNCShares    map[string]string      // With --to-unc: the share each drive letter is mapped to
+	ConfigPath   string                 // Config file holding saved path mappings
 	AutoApprove  bool
+	SkippedFile  string             // What was declined at the prompts is saved here
+	OnlySkipped  bool               // Only process what SkippedFile lists
+	NoResume     bool               // Ask again instead of reusing answers from an interrupted session
+	SkipUnavail  bool               // Skip locations that are unavailable in Plex or unreachable from here
+	Sections     []int64            // Only process these library section IDs (empty = all)
+	SectionNames []string           // Only process library sections with these names (empty = all)
+	Language     string             // Output language; empty = detect from the environment
+	NoColor      bool               // Disable colored output
+	Remote       string             // Perform operations on this SSH host (e.g. user@nas)
+	SMB          *renamer.SMBShare  // Write destinations to this SMB share instead of locally
+	Schedule     *schedule.Schedule // Run repeatedly on this cron schedule, without confirmation
+	Journal      string             // Scheduled runs are appended here as JSON lines
+	NoHistory    bool               // Don't add the run to the history
+	Stream       bool               // Execute operations while reading the library, without a plan
+	NoCache      bool               // Always query the database instead of using cached content
+	WithMissing  bool               // Also plan files Plex marks as deleted
+	PlexSQLite   string             // Plex SQLite program, for databases that need Plex's own SQLite
+	DBOpenMode   database.OpenMode  // How the database is opened while Plex may be writing to it
+	InMemory     bool               // Copy the database into memory before reading it
+	CheckSources bool               // Leave operations whose source can't be reached or is empty out of the plan
+	CheckFormats bool               // Also leave out sources that aren't the container their extension says
+	Budget       renamer.Budget     // Stop planning once this much would be written
+	Filter       fileFilter         // Leave files out of the plan by age, watch state, or resolution
+	Output4K     string             // 2160p files go here instead of the output directory
+	Volumes      []string           // Output directories on separate disks, filled by free space
+	FolderIDs    bool               // Tag show and movie folders with stable IDs and follow title changes
+	InPlace      bool               // Only rename files, keeping them in their directory
+	FoldersOnly  bool               // Only move files into their folders, keeping their names
+	Sidecars     bool               // Move subtitles and other files named after a video along with it
+	SubFormat    string             // Names of the subtitles moved with their video
+	SideFormat   string             // Names of the other sidecars moved with their video
+	Reserved     string             // Appended to names Windows reserves, such as CON ("" = off)
+	LongNames    renamer.LengthMode // Shorten titles of destinations too long for the filesystem, or stop
+	SortFolders  renamer.SortMode   // Name show and movie folders by sort title or without the article
+	Subtitles    subtitleIndex      // External subtitles Plex knows, with Sidecars
+	PlexURL      string             // Ask this Plex server to scan the destination folders after the run
+	PlexToken    string             // X-Plex-Token for PlexURL
+	WatchState   string             // Export the watch state of renamed files to this bundle
 }
 
 func main() {
+	// Ctrl+C cancels the context: prompts return, copies in progress stop and
+	// remove their partial files, and a summary of what was done is shown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if len(os.Args) > 1 && os.Args[1] == "exec" {
+		if err := runExec(ctx, os.Args[2:]); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "format-test" {
+		if err := runFormatTest(ctx, os.Args[2:]); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "import-watchstate" {
+		if err := runImportWatchState(ctx, os.Args[2:]); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		if err := runBench(ctx, os.Args[2:]); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "history" {
+		if err := runHistory(os.Args[2:]); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "show-run" {
+		if err := runShowRun(os.Args[2:]); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "retry-failures" {
+		if err := runRetryFailures(ctx, os.Args[2:]); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "service" {
+		if err := runService(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	config := parseFlags()
 
-	if config.DatabasePath == "" {
+	if config.DatabasePath == "" && config.ScanDir == "" {
 		fmt.Fprintln(os.Stderr, "Error: database path is required")
 		flag.Usage()
 		os.Exit(1)
 	}
 
-	if err := run(config); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+	var err error
+	if config.Schedule != nil {
+		err = runScheduled(ctx, config)
+	} else {
+		_, err = run(ctx, config)
+	}
+	if err != nil {
+		exitWithError(err)
+	}
+}
+
+// exitWithError reports err and exits, with status 130 if the run was cancelled
+func exitWithError(err error) {
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintln(os.Stderr, "Cancelled.")
+		os.Exit(130)
 	}
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(1)
 }
 
 func parseFlags() *Config {
 	config := &Config{}
 
-	flag.StringVar(&config.OutputDir, "output", "", "Output directory for renamed files (default: source location root)")
+	flag.StringVar(&config.OutputDir, "output", "", "Output directory for renamed files (default: source location root); several directories separated by "+string(filepath.ListSeparator)+" spread the files over those disks by free space")
 	flag.BoolVar(&config.DryRun, "dry-run", false, "Preview changes without applying them")
+	flag.BoolVar(&config.Idempotent, "assert-idempotent", false, "After running, plan again as if Plex saw the files where they were written, and fail with the differences if a second run would still rename any of them or their names on disk differ from the plan")
+	flag.BoolVar(&config.Validate, "validate", false, "With --dry-run, check sources, destination conflicts, permissions, and path lengths")
+	flag.IntVar(&config.PreviewLimit, "preview-limit", 10, "Show this many of the planned operations, grouped by show or movie, before asking to proceed (0 = all)")
+	flag.StringVar(&config.Remote, "remote", "", "Perform the copy/move operations on this host over SSH (e.g. user@nas); use --path-map to translate to its paths")
+	smbURL := flag.String("smb", "", "Write destinations directly to an SMB share (smb://server/share/path) using smbclient, without mounting it")
 	flag.BoolVar(&config.ScriptMode, "script", false, "Output shell commands instead of executing")
-	flag.StringVar(&config.ScriptShell, "shell", "cmd", "Shell format for script output: cmd, powershell, or bash")
+	flag.StringVar(&config.ScriptShell, "shell", "cmd", "Shell format for script output: cmd, powershell, bash, or python")
 	flag.StringVar(&config.ScriptOutput, "script-output", "", "Output file for script (default: rename.<ext> based on shell)")
+	flag.IntVar(&config.ChunkSize, "chunk-size", 0, "Split scripts into numbered chunks of N operations with a master script (0 = single script)")
+	flag.StringVar(&config.Manifest, "manifest", "", "Write a NUL-delimited manifest of operations to this file instead of executing (with --script, the script becomes a small runner for it)")
+	flag.StringVar(&config.HTMLReport, "html-report", "", "Write the planned operations, and the results once executed, to this HTML file for review in a browser")
+	flag.StringVar(&config.CSVReport, "csv-report", "", "Write the planned operations, and the results once executed, to this CSV file, with sizes in bytes")
+	flag.StringVar(&config.Audit, "audit", "", "Leave the files where they are and write the files whose path in Plex differs from the layout of the formats to this CSV file (to audit a library organized by hand)")
+	flag.StringVar(&config.AuditDB, "audit-db", "", "With --audit, also write a copy of the database with the paths of the layout to this new file (asks first unless --auto-approve; the database itself is never changed)")
 	modeStr := flag.String("mode", "move", "Operation mode: copy or move")
+	reflink := flag.String("reflink", "auto", "Copy-on-write clones on btrfs/XFS: auto (clone when supported), always, or never")
+	preserve := flag.String("preserve", "mode", "Attributes to keep when copying: mode, times, owner, xattr, all, or none (comma-separated)")
+	flag.IntVar(&config.Retries, "retries", 0, "Retry operations that fail with transient errors (busy files, dropped network shares) up to N times")
+	flag.DurationVar(&config.RetryWait, "retry-wait", 10*time.Second, "Wait before the first retry; doubled for each further retry")
+	onExists := flag.String("on-exists", "skip", "When a destination exists: skip, or overwrite-backup to rename it to <name>.bak-<timestamp> and write the file (e.g. to replace a worse version)")
+	flag.StringVar(&config.Sandbox, "sandbox", "", "Execute the run on zero-byte stand-ins of the sources, and sparse ones of destinations that exist, in this empty directory, leaving the media alone (to try out a run end to end)")
+	setMtime := flag.String("set-mtime", "", "Set the modification time of each destination: source for that of its source file, or airdate for the day the episode aired or the movie was released (its source's if unknown), so 'recently added' sorting survives a migration")
+	flag.BoolVar(&config.Fsync, "fsync", false, "Flush each copied or moved file and its directory to disk before the operation counts as done (and, when moving, before the source is removed), so a power loss can't lose it")
+	flag.BoolVar(&config.LowPriority, "low-priority", false, "Run with the lowest CPU and disk priority (nice/ionice, or background mode on Windows), also for operations over --remote and in scripts written with --script, so a long run doesn't slow down Plex")
+	prefer := flag.String("prefer", "", "When a destination exists, replace it only with a better version: better compares resolution, then bitrate, then size, keeping the replaced file as <name>.bak-<timestamp>")
 	flag.StringVar(&config.TVFormat, "tv-format", renamer.DefaultTVFormat, "Format for TV show filenames")
 	flag.StringVar(&config.MovieFormat, "movie-format", renamer.DefaultMovieFormat, "Format for movie filenames")
-	pathMap := flag.String("path-map", "", "Path mapping (old:new) for network shares")
+	preset := flag.String("preset", "", "Naming preset: "+strings.Join(renamer.PresetNames(), ", ")+" (explicit formats take precedence)")
+	leftovers := flag.String("leftovers", "", "After moving, list files left in source directories, with optional pattern=action rules (report, delete, trash, ignore), e.g. '*.nfo=delete,sample*=trash,report'")
+	flag.BoolVar(&config.CleanupDirs, "remove-empty-dirs", false, "After moving, remove source directories left empty (never the library root)")
+	protect := flag.String("protect", "", "Comma-separated directories that --remove-empty-dirs must never remove")
+	var pathMaps stringList
+	flag.Var(&pathMaps, "path-map", "Path mapping (old:new) for network shares (repeatable)")
+	flag.StringVar(&config.MatchDir, "match-dir", "", "Organize the files in this directory by the database's metadata, matching them to the files Plex knew by size and extension, then name (for a library whose server is gone but whose database backup is left)")
+	flag.StringVar(&config.ScanDir, "scan-dir", "", "Instead of reading a database, organize the video files in this directory by what their names say, e.g. Show.Name.S01E02.mkv or Movie.Name.1999.mkv")
+	flag.BoolVar(&config.Lookup, "lookup", false, "With --scan-dir, look the titles up in the Sonarr and Radarr of the config file, for their proper names, years, and IDs")
+	var sourceMaps stringList
+	flag.Var(&sourceMaps, "db-path-map", "Path mapping (N=old:new) for the Nth database only, when several are merged into one plan, e.g. 2=/data:/mnt/pool (repeatable; tried before --path-map)")
+	toUNC := flag.Bool("to-unc", false, `Write sources and destinations on mapped drives as \\server\share paths, for scripts run on other machines`)
+	var uncShares stringList
+	flag.Var(&uncShares, "unc-share", `With --to-unc, the share a drive letter is mapped to, e.g. Z:=\\nas\media (repeatable; default: the current mappings on Windows)`)
+	dockerMap := flag.String("docker-map", "", "Translate Docker container paths: container:NAME (read mounts with docker inspect) or PRESET:HOSTDIR ("+strings.Join(renamer.DockerPresetNames(), ", ")+")")
+	flag.StringVar(&config.ConfigPath, "config", defaultConfigPath(), "Config file with saved path mappings")
 	flag.BoolVar(&config.AutoApprove, "auto-approve", false, "Automatically approve all operations")
+	flag.StringVar(&config.SkippedFile, "skipped-file", "", "Where interactive runs save the libraries, locations, shows, and movies you declined (default: skipped.json next to the config file)")
+	flag.BoolVar(&config.OnlySkipped, "only-skipped", false, "Only process what the last interactive runs declined, as saved in the skipped file")
+	flag.BoolVar(&config.NoResume, "forget-answers", false, "Ask about every show and movie again instead of reusing the answers of an interactive session that didn't finish")
+	flag.BoolVar(&config.SkipUnavail, "skip-unavailable", false, "Skip library locations Plex marks unavailable or that aren't reachable from this machine")
+	sections := flag.String("sections", "", "Comma-separated library section IDs to process (default: all)")
+	flag.Var((*stringList)(&config.SectionNames), "section-name", "Library section name to process (repeatable, case-insensitive)")
+	flag.BoolVar(&config.NoColor, "no-color", false, "Disable colored output (also disabled by NO_COLOR, TERM=dumb, or when output is not a terminal)")
+	flag.BoolVar(&config.Stream, "stream", false, "With --auto-approve, execute each item's operations as the library is read instead of planning everything first (less memory for huge libraries)")
+	flag.BoolVar(&config.NoCache, "no-cache", false, "Don't use or update the cache of library content (it is refreshed automatically when the database changes)")
+	flag.BoolVar(&config.FolderIDs, "folder-ids", false, "Add the TVDB/TMDB/IMDb ID to show and movie folder names, e.g. 'Show (2019) [tvdbid-12345]', and rename those folders when the title changes")
+	flag.BoolVar(&config.InPlace, "in-place", false, "Only rename files within the directory they are in, using the file name part of the formats, and leave the folder layout as it is")
+	flag.BoolVar(&config.FoldersOnly, "folders-only", false, "Only move files into the show, season, or movie folders of the formats, keeping their file names")
+	flag.BoolVar(&config.Sidecars, "sidecars", false, "Also copy or move the subtitles (.srt, .ass, ...) and .nfo and image files named after each video, renamed to match it")
+	flag.StringVar(&config.SubFormat, "subtitle-format", renamer.DefaultSubtitleFormat, "Format for subtitle names with --sidecars: {base} is the video's new name, {lang} and {forced} come from the subtitle's name (implies --sidecars)")
+	sortFolders := flag.String("sort-folders", "", "Name show and movie folders for sorting: sort for Plex's sort title, e.g. 'Matrix, The (1999)', or clean for the title without its article, e.g. 'Matrix (1999)' (file names keep the title)")
+	longNames := flag.String("long-names", "truncate", "When a destination is too long for the filesystem (255 bytes per name, or the platform's path limit): truncate to shorten the episode title and then the show or movie title, or error to stop before anything runs")
+	flag.StringVar(&config.Reserved, "reserved-suffix", renamer.DefaultReservedSuffix, "Appended to folder and file names Windows reserves (CON, PRN, AUX, NUL, COM1-9, LPT1-9), e.g. CON becomes CON_ (empty to keep them)")
+	flag.StringVar(&config.SideFormat, "sidecar-format", renamer.DefaultSidecarFormat, "Format for other sidecar names with --sidecars: {suffix} is what followed the video's name, e.g. -poster (implies --sidecars)")
+	maxBytes := flag.String("max-bytes", "", "Only plan files up to this total size, e.g. 2TB or 750GiB, and report what's left for the next disk")
+	flag.IntVar(&config.Budget.MaxFiles, "max-files", 0, "Only plan up to this many files, and report what's left (0 = no limit)")
+	budgetOrder := flag.String("budget-order", "largest", "Which files --max-bytes and --max-files plan first: largest or oldest (added to Plex longest ago)")
+	minAge := flag.String("min-age", "", "Only plan files added to Plex at least this long ago, e.g. 30d, 2w, or 1y")
+	maxAge := flag.String("max-age", "", "Only plan files added to Plex at most this long ago, e.g. 7d")
+	flag.BoolVar(&config.Filter.AgeByMtime, "age-by-mtime", false, "Measure --min-age and --max-age from the files' modification time instead of when Plex added them")
+	flag.BoolVar(&config.Filter.OnlyWatched, "only-watched", false, "Only plan movies someone has watched, and shows with every episode watched")
+	flag.BoolVar(&config.Filter.OnlyUnwatched, "only-unwatched", false, "Only plan movies nobody has watched, and shows with no episode watched")
+	minRes := flag.String("min-resolution", "", "Only plan files of at least this resolution, e.g. 720, 1080, or 4k")
+	only4K := flag.Bool("only-4k", false, "Only plan 4K (2160p) files, like --min-resolution 4k")
+	flag.StringVar(&config.Output4K, "output-4k", "", "Output directory for 4K (2160p) files, so they are kept apart from HD files in the same run")
+	flag.BoolVar(&config.CheckSources, "check-sources", false, "Check that every source file exists before showing the plan, and leave out those that can't be reached or are empty")
+	flag.BoolVar(&config.CheckFormats, "check-containers", false, "Like --check-sources, and also leave out sources whose first bytes aren't the container their extension says, e.g. an .mkv that isn't Matroska")
+	flag.BoolVar(&config.WithMissing, "include-missing", false, "Also plan the items and files Plex still lists after they were deleted, which are left out by default")
+	dbOpenMode := flag.String("db-open-mode", "", "How to open the database while Plex may be writing to it: immutable (the file as it is, without locking), ro (with locking, as Plex sees it), or snapshot (a copy of the file and its write-ahead log) (default: snapshot if it has a write-ahead log, immutable otherwise)")
+	flag.BoolVar(&config.InMemory, "load-into-memory", false, "Copy the database into memory before reading it, which is much faster when it is on a network share (takes as much memory as the database is large)")
+	flag.StringVar(&config.PlexSQLite, "plex-sqlite", "", "Plex SQLite program used to read databases that need Plex's own SQLite, by copying the tables read (default: the one installed with Plex, if found)")
+	scheduleExpr := flag.String("schedule", "", "Keep running and process the libraries on this cron schedule, e.g. '0 3 * * *' or @daily (requires --auto-approve)")
+	flag.StringVar(&config.Journal, "journal", "", "With --schedule, append a JSON line per run to this file (default: journal.jsonl next to the config file)")
+	flag.BoolVar(&config.NoHistory, "no-history", false, "Don't record the run in the history (see the history subcommand)")
+	flag.StringVar(&config.PlexURL, "plex-scan", "", "After the run, ask the Plex server at this URL (e.g. http://localhost:32400) to scan the folders files were written to")
+	flag.StringVar(&config.PlexToken, "plex-token", "", "Plex token for --plex-scan (default: $PLEX_TOKEN)")
+	flag.StringVar(&config.WatchState, "export-watchstate", "", "After the run, write the watch state, ratings, and play counts of the renamed files to this JSON bundle, by their new paths (restore it with import-watchstate)")
+	flag.StringVar(&config.Language, "lang", "", "Language for output: "+strings.Join(cli.Languages(), ", ")+" (default: from LANG)")
 
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [options] <database-path>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] <database-path> [<database-path>...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [options] --scan-dir <directory>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s exec [--dry-run] [--preserve list] [--reflink mode] <manifest>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s format-test [--tv-format f] [--movie-format f] [--interactive] <database-path>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s bench [--runs n] [--sections ids] <database-path>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s import-watchstate --plex url [--path-map old:new] <bundle>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s history [--limit n] | show-run <id|last>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s retry-failures [--dry-run] <id|last>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s service install|uninstall [service options] [options] <database-path>\n\n", os.Args[0])
 		fmt.Fprintln(os.Stderr, "A CLI tool to rename/move media files based on Plex metadata.")
 		fmt.Fprintln(os.Stderr)
 		fmt.Fprintln(os.Stderr, "Options:")
@@ -67,7 +302,12 @@ func parseFlags() *Config {
 		fmt.Fprintln(os.Stderr, "\nExamples:")
 		fmt.Fprintln(os.Stderr, "  plexrenamer --dry-run --output ./renamed ./plex.db")
 		fmt.Fprintln(os.Stderr, "  plexrenamer --mode copy --output /media/organized ./plex.db")
-		fmt.Fprintln(os.Stderr, "  plexrenamer --path-map 'F:\\Media:H:\\Media' --output ./out ./plex.db")
+		fmt.Fprintln(os.Stderr, "  plexrenamer --mode copy --preserve mode,times,owner --output /backup/media ./plex.db")
+		fmt.Fprintln(os.Stderr, "  plexrenamer --path-map 'F:\\Media:/mnt/media' --output ./out ./plex.db")
+		fmt.Fprintln(os.Stderr, "  plexrenamer --auto-approve --section-name Movies --output /media/movies ./plex.db")
+		fmt.Fprintln(os.Stderr, "  plexrenamer --preset jellyfin --mode copy --output /media/jellyfin ./plex.db")
+		fmt.Fprintln(os.Stderr, "  plexrenamer --auto-approve --manifest rename.manifest ./plex.db && plexrenamer exec rename.manifest")
+		fmt.Fprintln(os.Stderr, "  plexrenamer --auto-approve --schedule '0 3 * * *' --output /media/organized /config/plex.db")
 		fmt.Fprintln(os.Stderr, "  plexrenamer --script --shell powershell --output ./out ./plex.db > rename.ps1")
 	}
 
@@ -75,593 +315,1527 @@ func parseFlags() *Config {
 
 	if flag.NArg() > 0 {
 		config.DatabasePath = flag.Arg(0)
+		for _, path := range flag.Args()[1:] {
+			config.Merged = append(config.Merged, mergedSource{Path: path})
+		}
 	}
 
-	// Parse mode
-	switch strings.ToLower(*modeStr) {
-	case "copy":
-		config.Mode = renamer.ModeCopy
-	case "move":
-		config.Mode = renamer.ModeMove
-	default:
-		fmt.Fprintf(os.Stderr, "Invalid mode: %s (use 'copy' or 'move')\n", *modeStr)
+	if config.Validate && !config.DryRun {
+		fmt.Fprintln(os.Stderr, "--validate can only be used with --dry-run")
 		os.Exit(1)
 	}
 
-	// Parse path mapping
-	if *pathMap != "" {
-		parts := strings.SplitN(*pathMap, ":", 2)
-		if len(parts) == 2 {
-			config.PathMapSrc = parts[0]
-			config.PathMapDst = parts[1]
-		} else {
-			fmt.Fprintln(os.Stderr, "Invalid path-map format. Use: old:new")
-			os.Exit(1)
-		}
+	if config.Remote != "" && (config.Validate || config.ScriptMode || config.Manifest != "" || config.CleanupDirs || *leftovers != "") {
+		fmt.Fprintln(os.Stderr, "--remote can't be combined with --validate, --script, --manifest, --leftovers, or --remove-empty-dirs")
+		os.Exit(1)
 	}
 
-	return config
-}
-
-func run(config *Config) error {
-	// In script mode, don't print banner to stdout (it would pollute the script)
-	if !config.ScriptMode {
-		cli.PrintBanner()
+	if config.Stream && (!config.AutoApprove || config.ScriptMode || config.Manifest != "" || config.Validate) {
+		fmt.Fprintln(os.Stderr, "--stream requires --auto-approve and can't be combined with --script, --manifest, or --validate")
+		os.Exit(1)
+	}
 
-		if config.DryRun {
-			pterm.Warning.Println("DRY RUN MODE - No files will be modified")
-			fmt.Println()
-		}
+	if len(config.Merged) > 0 && (config.Stream || config.OnlySkipped) {
+		fmt.Fprintln(os.Stderr, "Several databases can't be combined with --stream or --only-skipped")
+		os.Exit(1)
 	}
 
-	// Open database
-	if !config.ScriptMode {
-		pterm.Info.Printf("Opening database: %s\n", config.DatabasePath)
+	if config.ScanDir != "" && config.DatabasePath != "" {
+		fmt.Fprintln(os.Stderr, "--scan-dir doesn't read a database, leave out the database path")
+		os.Exit(1)
 	}
-	db, err := database.Open(config.DatabasePath)
-	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+	if config.ScanDir != "" && (config.Stream || config.MatchDir != "" || config.OnlySkipped || config.PlexURL != "" || config.WatchState != "") {
+		fmt.Fprintln(os.Stderr, "--scan-dir can't be combined with --stream, --match-dir, --only-skipped, --plex-scan, or --export-watchstate")
+		os.Exit(1)
 	}
-	defer db.Close()
-
-	// Get library sections
-	sections, err := db.GetLibrarySections()
-	if err != nil {
-		return fmt.Errorf("failed to get library sections: %w", err)
+	kodi := database.IsKodi(config.DatabasePath)
+	for _, source := range config.Merged {
+		kodi = kodi || database.IsKodi(source.Path)
+	}
+	if kodi && (len(config.Merged) > 0 || config.Stream || config.OnlySkipped || config.PlexURL != "" || config.WatchState != "") {
+		fmt.Fprintln(os.Stderr, "A Kodi database can't be merged with others or combined with --stream, --only-skipped, --plex-scan, or --export-watchstate")
+		os.Exit(1)
 	}
 
-	if len(sections) == 0 {
-		if !config.ScriptMode {
-			pterm.Warning.Println("No library sections found in database.")
-		}
-		return nil
+	if config.AuditDB != "" && config.Audit == "" {
+		fmt.Fprintln(os.Stderr, "--audit-db requires --audit")
+		os.Exit(1)
+	}
+	if config.Audit != "" && (config.ScanDir != "" || kodi || len(config.Merged) > 0 || config.MatchDir != "" || config.Stream || config.ScriptMode || config.Manifest != "" || *toUNC) {
+		fmt.Fprintln(os.Stderr, "--audit compares the paths of one Plex database and can't be combined with --scan-dir, a Kodi database, several databases, --match-dir, --stream, --script, --manifest, or --to-unc")
+		os.Exit(1)
 	}
 
-	if !config.ScriptMode {
-		pterm.Success.Printf("Found %d library section(s)\n", len(sections))
+	if config.MatchDir != "" && (config.Remote != "" || config.Stream) {
+		fmt.Fprintln(os.Stderr, "--match-dir can't be combined with --remote or --stream")
+		os.Exit(1)
 	}
 
-	// Initialize formatter and prompter
-	formatter := renamer.NewFormatter(config.TVFormat, config.MovieFormat)
-	prompter := cli.NewPrompter()
+	if config.CheckFormats {
+		config.CheckSources = true
+	}
+	if config.CheckSources && (config.Remote != "" || config.Stream) {
+		fmt.Fprintln(os.Stderr, "--check-sources and --check-containers can't be combined with --remote or --stream")
+		os.Exit(1)
+	}
 
-	var allOperations []renamer.Operation
+	if config.SkippedFile == "" {
+		config.SkippedFile = defaultSkippedPath(config.ConfigPath)
+	}
 
-	// Process each library
-	for _, section := range sections {
-		content, err := db.GetLibraryContent(section)
-		if err != nil {
-			if !config.ScriptMode {
-				pterm.Warning.Printf("Failed to get content for library %s: %v\n", section.Name, err)
-			}
-			continue
+	if config.PlexURL != "" {
+		if config.PlexToken == "" {
+			config.PlexToken = os.Getenv("PLEX_TOKEN")
 		}
-
-		var selectedLocations []database.SectionLocation
-		var locationOutputs []cli.LocationWithOutput
-
-		// Skip prompts in script mode, or if auto-approve is set
-		if !config.AutoApprove && !config.ScriptMode {
-			proceed, locations, err := prompter.PromptLibrary(section, content.Locations)
-			if err != nil {
-				return err
-			}
-			if !proceed {
-				continue
-			}
-			selectedLocations = locations
-
-			// If locations were selected, prompt for output paths
-			if selectedLocations != nil && len(selectedLocations) > 0 {
-				locationOutputs, err = prompter.PromptLocationOutputs(selectedLocations, config.OutputDir)
-				if err != nil {
-					return err
-				}
-			}
-		} else if !config.ScriptMode {
-			fmt.Println()
-			cli.PrintHeader(section.Name)
+		if config.PlexToken == "" {
+			fmt.Fprintln(os.Stderr, "--plex-scan needs a Plex token: use --plex-token or set PLEX_TOKEN")
+			os.Exit(1)
 		}
-
-		// Generate operations for this library
-		ops, err := generateOperations(config, formatter, prompter, content, selectedLocations, locationOutputs)
-		if err != nil {
-			return err
+		if _, err := plexapi.NewClient(config.PlexURL, config.PlexToken); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
-		allOperations = append(allOperations, ops...)
 	}
 
-	if len(allOperations) == 0 {
-		if !config.ScriptMode {
-			fmt.Println()
-			pterm.Info.Println("No operations to perform.")
+	if *scheduleExpr != "" {
+		if !config.AutoApprove || config.ScriptMode || config.Manifest != "" {
+			fmt.Fprintln(os.Stderr, "--schedule requires --auto-approve and can't be combined with --script or --manifest")
+			os.Exit(1)
+		}
+		s, err := schedule.Parse(*scheduleExpr)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		config.Schedule = s
+		if config.Journal == "" {
+			config.Journal = defaultJournalPath(config.ConfigPath)
 		}
-		return nil
 	}
 
-	// Script mode: output commands to file and exit
-	if config.ScriptMode {
-		return outputScript(allOperations, config)
+	// Parse mode
+	switch strings.ToLower(*modeStr) {
+	case "copy":
+		config.Mode = renamer.ModeCopy
+	case "move":
+		config.Mode = renamer.ModeMove
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid mode: %s (use 'copy' or 'move')\n", *modeStr)
+		os.Exit(1)
 	}
 
-	// Show preview
-	cli.ShowOperationPreview(allOperations, 10)
+	cli.ConfigureColor(config.NoColor)
 
-	// Confirm and execute
-	proceed, err := prompter.ConfirmProceed(len(allOperations), config.Mode, config.DryRun)
-	if err != nil {
-		return err
-	}
-	if !proceed {
-		pterm.Info.Println("Operation cancelled.")
-		return nil
+	var err error
+	if err = cli.SetLanguage(config.Language); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 
-	// Execute operations with progress bar
-	fmt.Println()
-	progressBar, _ := cli.CreateProgressBar(len(allOperations), "Processing files")
-
-	results := make([]renamer.Result, len(allOperations))
-	for i, op := range allOperations {
-		results[i] = op.Execute(config.DryRun)
-		if progressBar != nil {
-			progressBar.Increment()
+	if config.LowPriority {
+		if err := renamer.LowerPriority(); err != nil {
+			pterm.Warning.Println(err)
 		}
 	}
 
-	if progressBar != nil {
-		progressBar.Stop()
+	if config.Preserve, err = renamer.ParsePreserve(*preserve); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid preserve list: %v\n", err)
+		os.Exit(1)
 	}
 
-	// Show results
-	cli.ShowResults(results)
-
-	return nil
-}
+	if config.Reflink, err = renamer.ParseReflinkMode(*reflink); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-// outputScript writes shell commands to a file
-func outputScript(operations []renamer.Operation, config *Config) error {
-	// Determine output filename
-	outputFile := config.ScriptOutput
-	if outputFile == "" {
-		// In dry-run mode, output as .txt preview file
-		if config.DryRun {
-			outputFile = "rename_preview.txt"
-		} else {
-			switch strings.ToLower(config.ScriptShell) {
-			case "powershell", "ps", "ps1":
-				outputFile = "rename.ps1"
-			case "bash", "sh":
-				outputFile = "rename.sh"
-			default:
-				outputFile = "rename.bat"
-			}
+	if config.OnExists, err = renamer.ParseExistsPolicy(*onExists); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	switch *prefer {
+	case "":
+	case "better":
+		if config.OnExists != renamer.ExistsSkip {
+			fmt.Fprintln(os.Stderr, "--prefer can't be combined with --on-exists")
+			os.Exit(1)
 		}
+		config.OnExists = renamer.ExistsBetter
+	default:
+		fmt.Fprintf(os.Stderr, "invalid --prefer value: %s (use better)\n", *prefer)
+		os.Exit(1)
 	}
-
-	// Create the file
-	file, err := os.Create(outputFile)
-	if err != nil {
-		return fmt.Errorf("failed to create script file: %w", err)
+	if config.OnExists != renamer.ExistsSkip && (config.Remote != "" || *smbURL != "" || config.ScriptMode) {
+		fmt.Fprintln(os.Stderr, "--on-exists overwrite-backup and --prefer can't be combined with --remote, --smb, or --script")
+		os.Exit(1)
+	}
+	if config.Fsync && (*smbURL != "" || config.ScriptMode) {
+		fmt.Fprintln(os.Stderr, "--fsync can't be combined with --smb or --script")
+		os.Exit(1)
+	}
+	if config.PreviewLimit < 0 {
+		fmt.Fprintln(os.Stderr, "--preview-limit can't be negative")
+		os.Exit(1)
+	}
+	if config.Sandbox != "" && (config.DryRun || config.Remote != "" || *smbURL != "" || config.ScriptMode || config.Manifest != "" || config.Stream || config.Idempotent) {
+		fmt.Fprintln(os.Stderr, "--sandbox can't be combined with --dry-run, --remote, --smb, --script, --manifest, --stream, or --assert-idempotent")
+		os.Exit(1)
+	}
+	if config.SetMtime, err = renamer.ParseMtimeMode(*setMtime); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if config.SetMtime != renamer.MtimeKeep && (*smbURL != "" || config.ScriptMode || config.Manifest != "") {
+		fmt.Fprintln(os.Stderr, "--set-mtime can't be combined with --smb, --script, or --manifest")
+		os.Exit(1)
 	}
-	defer file.Close()
 
-	// Write script content
-	if config.DryRun {
-		// Write preview/text format for dry-run
-		writeScriptPreview(file, operations, config)
-	} else {
-		switch strings.ToLower(config.ScriptShell) {
-		case "powershell", "ps", "ps1":
-			writeScriptPowerShell(file, operations, config)
-		case "bash", "sh":
-			writeScriptBash(file, operations, config)
-		default:
-			writeScriptCmd(file, operations, config)
+	if *maxBytes != "" {
+		if config.Budget.MaxBytes, err = renamer.ParseByteSize(*maxBytes); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
 	}
-
-	// Print success message
-	absPath, _ := filepath.Abs(outputFile)
-	if config.DryRun {
-		pterm.Warning.Println("DRY RUN - Preview file generated (not executable)")
-		pterm.Success.Printf("Preview written to: %s\n", absPath)
-	} else {
-		pterm.Success.Printf("Script written to: %s\n", absPath)
+	if config.Budget.Order, err = renamer.ParseBudgetOrder(*budgetOrder); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if config.Budget.Limited() && config.Stream {
+		fmt.Fprintln(os.Stderr, "--max-bytes and --max-files can't be combined with --stream")
+		os.Exit(1)
 	}
-	pterm.Info.Printf("Total operations: %d\n", len(operations))
-	pterm.Info.Printf("Mode: %s\n", config.Mode)
-
-	return nil
-}
-
-func writeScriptPreview(file *os.File, operations []renamer.Operation, config *Config) {
-	fmt.Fprintln(file, "============================================")
-	fmt.Fprintln(file, "Plex File Renamer - DRY RUN PREVIEW")
-	fmt.Fprintln(file, "============================================")
-	fmt.Fprintln(file)
-	fmt.Fprintf(file, "Mode: %s\n", config.Mode)
-	fmt.Fprintf(file, "Output directory: %s\n", config.OutputDir)
-	fmt.Fprintf(file, "Total operations: %d\n", len(operations))
-	if config.PathMapSrc != "" {
-		fmt.Fprintf(file, "Path mapping: %s -> %s\n", config.PathMapSrc, config.PathMapDst)
-	}
-	fmt.Fprintln(file)
-	fmt.Fprintln(file, "This is a PREVIEW - no files will be modified.")
-	fmt.Fprintln(file, "Remove --dry-run flag to generate an executable script.")
-	fmt.Fprintln(file)
-	fmt.Fprintln(file, "============================================")
-	fmt.Fprintln(file, "PLANNED OPERATIONS")
-	fmt.Fprintln(file, "============================================")
-	fmt.Fprintln(file)
-
-	for i, op := range operations {
-		fmt.Fprintf(file, "[%d] %s\n", i+1, op.Mode)
-		fmt.Fprintf(file, "    From: %s\n", op.Source)
-		fmt.Fprintf(file, "    To:   %s\n", op.Destination)
-		fmt.Fprintln(file)
-	}
-
-	fmt.Fprintln(file, "============================================")
-	fmt.Fprintf(file, "Total: %d operations\n", len(operations))
-	fmt.Fprintln(file, "============================================")
-}
 
-func writeScriptCmd(file *os.File, operations []renamer.Operation, config *Config) {
-	fmt.Fprintln(file, "@echo off")
-	fmt.Fprintln(file, "REM ============================================")
-	fmt.Fprintln(file, "REM Generated by Plex File Renamer")
-	fmt.Fprintln(file, "REM ============================================")
-	fmt.Fprintln(file, "REM")
-	fmt.Fprintf(file, "REM Mode: %s\n", config.Mode)
-	fmt.Fprintf(file, "REM Output directory: %s\n", config.OutputDir)
-	fmt.Fprintf(file, "REM Total operations: %d\n", len(operations))
-	if config.PathMapSrc != "" {
-		fmt.Fprintf(file, "REM Path mapping: %s -> %s\n", config.PathMapSrc, config.PathMapDst)
-	}
-	fmt.Fprintln(file, "REM")
-	fmt.Fprintln(file, "REM This script will skip files that already exist at destination.")
-	fmt.Fprintln(file, "REM ============================================")
-	fmt.Fprintln(file)
-
-	total := len(operations)
-	for i, op := range operations {
-		src := escapeCmdPath(op.Source)
-		dst := escapeCmdPath(op.Destination)
-		destDir := escapeCmdPath(filepath.Dir(op.Destination))
-
-		// Print progress
-		fmt.Fprintf(file, "echo [%d/%d] %s\n", i+1, total, config.Mode)
-		fmt.Fprintf(file, "echo   From: %s\n", escapeCmdPath(op.Source))
-		fmt.Fprintf(file, "echo   To:   %s\n", escapeCmdPath(op.Destination))
-
-		fmt.Fprintf(file, "if not exist \"%s\" mkdir \"%s\"\n", destDir, destDir)
-
-		if config.Mode == renamer.ModeCopy {
-			fmt.Fprintf(file, "if not exist \"%s\" copy \"%s\" \"%s\"\n", dst, src, dst)
-		} else {
-			fmt.Fprintf(file, "if not exist \"%s\" move \"%s\" \"%s\"\n", dst, src, dst)
+	if *minAge != "" {
+		if config.Filter.MinAge, err = parseAge(*minAge); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
 	}
+	if *maxAge != "" {
+		if config.Filter.MaxAge, err = parseAge(*maxAge); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if *minRes != "" {
+		if config.Filter.MinRes, err = parseResolution(*minRes); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if *only4K {
+		config.Filter.MinRes = 2160
+	}
+	if config.Filter.OnlyWatched && config.Filter.OnlyUnwatched {
+		fmt.Fprintln(os.Stderr, "--only-watched and --only-unwatched can't be combined")
+		os.Exit(1)
+	}
+	if (config.Filter.OnlyWatched || config.Filter.OnlyUnwatched) && (config.ScanDir != "" || kodi) {
+		fmt.Fprintln(os.Stderr, "--only-watched and --only-unwatched need Plex's watch state and can't be combined with --scan-dir or a Kodi database")
+		os.Exit(1)
+	}
+	if config.Filter.AgeByMtime && config.Remote != "" {
+		fmt.Fprintln(os.Stderr, "--age-by-mtime can't be combined with --remote")
+		os.Exit(1)
+	}
 
-	fmt.Fprintln(file)
-	fmt.Fprintln(file, "echo.")
-	fmt.Fprintf(file, "echo Completed %d operations.\n", total)
-	fmt.Fprintln(file, "pause")
-}
-
-// escapeCmdPath escapes special characters for Windows batch scripts
-func escapeCmdPath(path string) string {
-	// In batch scripts within double quotes, we need to escape:
-	// % -> %% (percent signs are used for variables)
-	// ^ -> ^^ (caret is the escape character)
-	// & -> ^& (ampersand separates commands)
-	// < -> ^< (redirection)
-	// > -> ^> (redirection)
-	// | -> ^| (pipe)
-	// ! -> ^^! (exclamation mark in delayed expansion)
-
-	result := path
-	// Escape percent signs first (double them)
-	result = strings.ReplaceAll(result, "%", "%%")
-	// Escape caret (must be done before other escapes that use caret)
-	result = strings.ReplaceAll(result, "^", "^^")
-	// Escape other special characters with caret
-	result = strings.ReplaceAll(result, "&", "^&")
-	result = strings.ReplaceAll(result, "<", "^<")
-	result = strings.ReplaceAll(result, ">", "^>")
-	result = strings.ReplaceAll(result, "|", "^|")
-	// Escape exclamation marks (for delayed expansion mode)
-	result = strings.ReplaceAll(result, "!", "^!")
-
-	return result
-}
-
-func writeScriptPowerShell(file *os.File, operations []renamer.Operation, config *Config) {
-	fmt.Fprintln(file, "# ============================================")
-	fmt.Fprintln(file, "# Generated by Plex File Renamer")
-	fmt.Fprintln(file, "# ============================================")
-	fmt.Fprintln(file, "#")
-	fmt.Fprintf(file, "# Mode: %s\n", config.Mode)
-	fmt.Fprintf(file, "# Output directory: %s\n", config.OutputDir)
-	fmt.Fprintf(file, "# Total operations: %d\n", len(operations))
-	if config.PathMapSrc != "" {
-		fmt.Fprintf(file, "# Path mapping: %s -> %s\n", config.PathMapSrc, config.PathMapDst)
-	}
-	fmt.Fprintln(file, "#")
-	fmt.Fprintln(file, "# This script will skip files that already exist at destination.")
-	fmt.Fprintln(file, "# ============================================")
-	fmt.Fprintln(file)
-
-	total := len(operations)
-	for i, op := range operations {
-		src := strings.ReplaceAll(op.Source, "'", "''")
-		dst := strings.ReplaceAll(op.Destination, "'", "''")
-		destDir := strings.ReplaceAll(filepath.Dir(op.Destination), "'", "''")
-
-		// Print progress
-		fmt.Fprintf(file, "Write-Host '[%d/%d] %s'\n", i+1, total, config.Mode)
-		fmt.Fprintf(file, "Write-Host '  From: %s'\n", src)
-		fmt.Fprintf(file, "Write-Host '  To:   %s'\n", dst)
-
-		fmt.Fprintf(file, "if (-not (Test-Path '%s')) { New-Item -ItemType Directory -Path '%s' -Force | Out-Null }\n", destDir, destDir)
-
-		if config.Mode == renamer.ModeCopy {
-			fmt.Fprintf(file, "if (-not (Test-Path '%s')) { Copy-Item -Path '%s' -Destination '%s' }\n", dst, src, dst)
-		} else {
-			fmt.Fprintf(file, "if (-not (Test-Path '%s')) { Move-Item -Path '%s' -Destination '%s' }\n", dst, src, dst)
+	// Apply naming preset, unless formats were given explicitly
+	if *preset != "" {
+		p, ok := renamer.LookupPreset(*preset)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Unknown preset: %s (use one of: %s)\n", *preset, strings.Join(renamer.PresetNames(), ", "))
+			os.Exit(1)
+		}
+		explicit := map[string]bool{}
+		flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+		if !explicit["tv-format"] {
+			config.TVFormat = p.TVFormat
+		}
+		if !explicit["movie-format"] {
+			config.MovieFormat = p.MovieFormat
 		}
 	}
 
-	fmt.Fprintln(file)
-	fmt.Fprintf(file, "Write-Host 'Completed %d operations.'\n", total)
-}
+	// Giving a sidecar format asks for sidecars, which are found on disk
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "subtitle-format" || f.Name == "sidecar-format" {
+			config.Sidecars = true
+		}
+	})
+	if config.Sidecars && config.Remote != "" {
+		fmt.Fprintln(os.Stderr, "--sidecars can't be combined with --remote")
+		os.Exit(1)
+	}
+	if err := renamer.ValidateReservedSuffix(config.Reserved); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if config.DBOpenMode, err = database.ParseOpenMode(*dbOpenMode); err != nil {
+		exitWithError(err)
+	}
+	if config.LongNames, err = renamer.ParseLengthMode(*longNames); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if config.SortFolders, err = renamer.ParseSortMode(*sortFolders); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-func writeScriptBash(file *os.File, operations []renamer.Operation, config *Config) {
-	fmt.Fprintln(file, "#!/bin/bash")
-	fmt.Fprintln(file, "# ============================================")
-	fmt.Fprintln(file, "# Generated by Plex File Renamer")
-	fmt.Fprintln(file, "# ============================================")
-	fmt.Fprintln(file, "#")
-	fmt.Fprintf(file, "# Mode: %s\n", config.Mode)
-	fmt.Fprintf(file, "# Output directory: %s\n", config.OutputDir)
-	fmt.Fprintf(file, "# Total operations: %d\n", len(operations))
-	if config.PathMapSrc != "" {
-		fmt.Fprintf(file, "# Path mapping: %s -> %s\n", config.PathMapSrc, config.PathMapDst)
-	}
-	fmt.Fprintln(file, "#")
-	fmt.Fprintln(file, "# This script will skip files that already exist at destination.")
-	fmt.Fprintln(file, "# ============================================")
-	fmt.Fprintln(file)
-
-	total := len(operations)
-	for i, op := range operations {
-		src := strings.ReplaceAll(op.Source, "'", "'\\''")
-		dst := strings.ReplaceAll(op.Destination, "'", "'\\''")
-		destDir := strings.ReplaceAll(filepath.Dir(op.Destination), "'", "'\\''")
-
-		// Print progress
-		fmt.Fprintf(file, "echo '[%d/%d] %s'\n", i+1, total, config.Mode)
-		fmt.Fprintf(file, "echo '  From: %s'\n", src)
-		fmt.Fprintf(file, "echo '  To:   %s'\n", dst)
-
-		fmt.Fprintf(file, "mkdir -p '%s'\n", destDir)
-
-		if config.Mode == renamer.ModeCopy {
-			fmt.Fprintf(file, "[ ! -f '%s' ] && cp '%s' '%s'\n", dst, src, dst)
-		} else {
-			fmt.Fprintf(file, "[ ! -f '%s' ] && mv '%s' '%s'\n", dst, src, dst)
+	if *leftovers != "" {
+		if config.Leftovers, err = renamer.ParseLeftoverRules(*leftovers); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid leftovers rules: %v\n", err)
+			os.Exit(1)
 		}
 	}
 
-	fmt.Fprintln(file)
-	fmt.Fprintf(file, "echo 'Completed %d operations.'\n", total)
-}
+	if config.Sections, err = parseSectionIDs(*sections); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-func generateOperations(config *Config, formatter *renamer.Formatter, prompter *cli.Prompter, content *database.LibraryContent, selectedLocations []database.SectionLocation, locationOutputs []cli.LocationWithOutput) ([]renamer.Operation, error) {
-	var operations []renamer.Operation
+	for _, p := range strings.Split(*protect, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			config.Protect = append(config.Protect, p)
+		}
+	}
 
-	// Helper to get output path for a file based on its location
-	getOutputPath := func(filePath string) string {
-		// First check if there's a custom output for this specific location
-		if len(locationOutputs) > 0 {
-			for _, lo := range locationOutputs {
-				if pathInLocations(filePath, []database.SectionLocation{lo.Location}) {
-					return lo.OutputPath
-				}
-			}
+	// Parse path mappings, followed by Docker mappings and those saved in the config file
+	for _, s := range pathMaps {
+		m, err := renamer.ParsePathMap(s)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
-		// If --output was specified, use it
-		if config.OutputDir != "" {
-			return config.OutputDir
+		config.PathMaps = append(config.PathMaps, m)
+	}
+	for _, s := range sourceMaps {
+		n, m, err := parseSourcePathMap(s)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if n == 1 {
+			fmt.Fprintf(os.Stderr, "--db-path-map %s: use --path-map for the first database\n", s)
+			os.Exit(1)
+		}
+		if n > len(config.Merged)+1 {
+			fmt.Fprintf(os.Stderr, "--db-path-map %s: there is no database %d\n", s, n)
+			os.Exit(1)
 		}
-		// Otherwise, use the file's source location root as the output
-		// This keeps files organized in their original library location
-		if locPath := getLocationForPath(filePath, content.Locations); locPath != "" {
-			return locPath
+		config.Merged[n-2].PathMaps = append(config.Merged[n-2].PathMaps, m)
+	}
+	if *dockerMap != "" {
+		maps, err := parseDockerMap(*dockerMap)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
-		// Fallback to current directory (shouldn't happen normally)
-		return "."
+		config.PathMaps = append(config.PathMaps, maps...)
+	}
+	fc, err := loadConfigFile(config.ConfigPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	config.PathMaps = append(config.PathMaps, fc.PathMaps...)
+	config.ShowFormats = fc.showFormats()
+	config.Hooks = fc.Hooks
+	if err := fc.registerTokenProviders(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	config.Sonarr, config.Radarr = fc.Sonarr, fc.Radarr
+	if config.Lookup && (config.ScanDir == "" || (config.Sonarr == nil && config.Radarr == nil)) {
+		fmt.Fprintln(os.Stderr, "--lookup requires --scan-dir and Sonarr or Radarr in the config file")
+		os.Exit(1)
 	}
 
-	switch content.Section.SectionType {
-	case database.SectionTypeMovie:
-		for _, movie := range content.Movies {
-			// Filter by selected locations if specified
-			if selectedLocations != nil && !fileInLocations(movie.Files, selectedLocations) {
-				continue
+	// UNC shares from --unc-share, then the config file, then the drives
+	// currently mapped
+	if *toUNC {
+		config.UNCShares = map[string]string{}
+		for _, s := range uncShares {
+			drive, share, err := renamer.ParseUNCShare(s)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
 			}
-
-			// Generate path previews for this movie
-			var previews []cli.PathPreview
-			for _, file := range movie.Files {
-				if selectedLocations != nil && !pathInLocations(file.File, selectedLocations) {
-					continue
-				}
-				srcPath := file.File
-				if config.PathMapSrc != "" {
-					srcPath = renamer.ApplyPathMapping(srcPath, config.PathMapSrc, config.PathMapDst)
-				}
-				ext := renamer.GetExtension(srcPath)
-				destName := formatter.FormatMovie(&movie, ext)
-				outputDir := getOutputPath(file.File)
-				destPath := filepath.Join(outputDir, destName)
-				previews = append(previews, cli.PathPreview{Source: srcPath, Destination: destPath})
-			}
-
-			if !config.AutoApprove && !config.ScriptMode {
-				proceed, _, err := prompter.PromptMovie(&movie, previews)
-				if err != nil {
-					return nil, err
-				}
-				if !proceed {
-					continue
+			config.UNCShares[drive] = share
+		}
+		for _, shares := range []map[string]string{fc.UNCShares, renamer.MappedDrives()} {
+			for drive, share := range shares {
+				drive = strings.ToUpper(strings.TrimSuffix(drive, ":"))
+				if _, ok := config.UNCShares[drive]; !ok {
+					config.UNCShares[drive] = share
 				}
 			}
+		}
+		if len(config.UNCShares) == 0 {
+			fmt.Fprintln(os.Stderr, "--to-unc found no mapped drives; name their shares with --unc-share or unc_shares in the config file")
+			os.Exit(1)
+		}
+	}
+
+	// Check the formats before anything is planned, so a typo doesn't end up
+	// in every filename
+	if err := validateFormats(config); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, "Use the format-test subcommand to try formats on a few items.")
+		os.Exit(1)
+	}
+
+	// SMB destination, with credentials from the URL, environment, or config file
+	if *smbURL != "" {
+		if config.Remote != "" || config.OutputDir != "" || config.Validate || config.ScriptMode || config.Manifest != "" {
+			fmt.Fprintln(os.Stderr, "--smb can't be combined with --remote, --output, --validate, --script, or --manifest")
+			os.Exit(1)
+		}
+		if config.SMB, err = renamer.ParseSMBURL(*smbURL); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		creds := smbCredentials{}
+		if fc.SMB != nil {
+			creds = *fc.SMB
+		}
+		if config.SMB.User == "" {
+			config.SMB.User = firstNonEmpty(os.Getenv("PLEXRENAMER_SMB_USER"), creds.User)
+		}
+		config.SMB.Password = firstNonEmpty(os.Getenv("PLEXRENAMER_SMB_PASSWORD"), creds.Password)
+		config.SMB.Domain = firstNonEmpty(os.Getenv("PLEXRENAMER_SMB_DOMAIN"), creds.Domain)
+		config.OutputDir = config.SMB.Dir
+	}
+
+	if config.InPlace && (config.OutputDir != "" || config.Output4K != "" || config.SMB != nil || config.FolderIDs) {
+		fmt.Fprintln(os.Stderr, "--in-place can't be combined with --output, --output-4k, --smb, or --folder-ids")
+		os.Exit(1)
+	}
+	if config.InPlace && config.FoldersOnly {
+		fmt.Fprintln(os.Stderr, "--in-place and --folders-only can't be combined")
+		os.Exit(1)
+	}
+
+	// Several output volumes are separated like the entries of PATH
+	if volumes := filepath.SplitList(config.OutputDir); len(volumes) > 1 {
+		if config.Remote != "" || config.Stream {
+			fmt.Fprintln(os.Stderr, "Several --output directories can't be combined with --remote or --stream")
+			os.Exit(1)
+		}
+		config.OutputDir = volumes[0]
+		config.Volumes = volumes
+	}
+	if config.Idempotent && (len(config.Merged) > 0 || config.Stream || config.ScriptMode || config.Manifest != "" || config.Audit != "" || config.Remote != "" || config.SMB != nil || config.Volumes != nil || config.UNCShares != nil) {
+		fmt.Fprintln(os.Stderr, "--assert-idempotent plans one database again after the run and can't be combined with several databases, --stream, --script, --manifest, --audit, --remote, --smb, several --output directories, or --to-unc")
+		os.Exit(1)
+	}
+	if config.UNCShares != nil {
+		config.OutputDir = renamer.ToUNC(config.OutputDir, config.UNCShares)
+		config.Output4K = renamer.ToUNC(config.Output4K, config.UNCShares)
+		for i, v := range config.Volumes {
+			config.Volumes[i] = renamer.ToUNC(v, config.UNCShares)
+		}
+	}
+
+	return config
+}
+
+// run processes the libraries in the database once and returns the results
+// of the executed operations
+func run(ctx context.Context, config *Config) ([]renamer.Result, error) {
+	// In script mode, don't print banner to stdout (it would pollute the script)
+	if !config.ScriptMode {
+		cli.PrintBanner()
+
+		if config.DryRun {
+			pterm.Warning.Println("DRY RUN MODE - No files will be modified")
+			fmt.Println()
+		}
+	}
+
+	// Programs supplying placeholders are asked afresh on each run
+	defer renamer.CloseTokenProviders()
+
+	var db *database.PlexDB
+	var libraries []library
+	var skipped *cli.Skipped
+	var err error
+	switch {
+	case config.ScanDir != "":
+		libraries, err = scanLibraries(ctx, config)
+	case database.IsKodi(config.DatabasePath):
+		libraries, err = kodiLibraries(ctx, config)
+	default:
+		db, libraries, skipped, err = openDatabase(ctx, config)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if db != nil {
+		defer db.Close()
+	}
+	if len(libraries) == 0 {
+		return nil, nil
+	}
+
+	// Libraries of the databases merged into the plan follow those of the
+	// first, each read with its own path mappings
+	if len(config.Merged) > 0 {
+		merged, closeMerged, err := openMerged(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		defer closeMerged()
+		libraries = append(libraries, merged...)
+	}
 
-			// Add operations from previews
-			for _, pv := range previews {
-				operations = append(operations, renamer.Operation{
-					Source:      pv.Source,
-					Destination: pv.Destination,
-					Mode:        config.Mode,
-				})
+	// Initialize formatter and prompter
+	formatter := renamer.NewFormatter(config.TVFormat, config.MovieFormat)
+	formatter.ShowFormats = config.ShowFormats
+	formatter.FolderIDs = config.FolderIDs
+	formatter.SubFormat = config.SubFormat
+	formatter.SideFormat = config.SideFormat
+	formatter.Reserved = config.Reserved
+	formatter.SortFolders = config.SortFolders
+	prompter := cli.NewPrompter(ctx)
+	if config.Schedule != nil {
+		prompter.Unattended()
+	}
+
+	// Answers are saved as they are given, so an interactive session that is
+	// quit halfway can pick up where it left off
+	answersPath := defaultAnswersPath(config.ConfigPath)
+	interactive := !config.AutoApprove && !config.ScriptMode
+	if interactive {
+		state, err := loadAnswers(answersPath, planSource(config))
+		if err != nil {
+			return nil, err
+		}
+		if state == nil || config.NoResume {
+			state = cli.NewApprovalState()
+			if state.Database, err = filepath.Abs(planSource(config)); err != nil {
+				state.Database = planSource(config)
 			}
+		} else if n := state.Answers(); n > 0 {
+			pterm.Info.Printf("Reusing %d answer(s) from an interrupted session (--forget-answers to start over)\n", n)
 		}
+		warned := false
+		prompter.ResumeFrom(state, func(state *cli.ApprovalState) {
+			if err := saveAnswers(answersPath, state); err != nil && !warned {
+				pterm.Warning.Println(err)
+				warned = true
+			}
+		})
+	}
+	// The session is finished once its operations have run
+	finished := func() {
+		if interactive {
+			if err := forgetAnswers(answersPath); err != nil {
+				pterm.Warning.Println(err)
+			}
+		}
+	}
 
-	case database.SectionTypeShow:
-		for _, show := range content.Shows {
-			// Filter by selected locations if specified
-			if selectedLocations != nil && !showInLocations(&show, selectedLocations) {
-				continue
+	var allOperations []renamer.Operation
+	var libraryRoots []string
+	known := qualityIndex{}
+	var promptedSections []int64
+	var planned []plannedLibrary // With --assert-idempotent
+
+	// With --audit, the paths Plex has for the files planned
+	var plexPaths map[string]string
+	if config.Audit != "" {
+		plexPaths = map[string]string{}
+	}
+
+	// With --leftovers, the files Plex knows, which are never leftovers
+	var media map[string]string
+	if config.Leftovers != nil {
+		media = map[string]string{}
+	}
+
+	// In streaming mode, operations are executed as they are generated
+	var streamOpts renamer.ExecOptions
+	var streamResults []renamer.Result
+	var streamTime time.Duration
+	if config.Stream {
+		opts, closeExec, err := execOptions(config)
+		if err != nil {
+			return nil, err
+		}
+		defer closeExec()
+		streamOpts = opts
+		if err := runPreRunHook(ctx, config); err != nil {
+			return nil, err
+		}
+	}
+
+	// With --match-dir, the files are those found there instead of where
+	// Plex saw them
+	var matchIndex *renamer.MatchIndex
+	if config.MatchDir != "" {
+		if matchIndex, err = renamer.NewMatchIndex(config.MatchDir); err != nil {
+			return nil, err
+		}
+	}
+
+	source := -1
+	var basePathMaps []renamer.PathMap // While a merged database is read
+	restorePathMaps := func() {
+		if source >= 0 {
+			config.PathMaps = basePathMaps
+		}
+	}
+	defer restorePathMaps()
+
+	// Process each library
+	for _, lib := range libraries {
+		section := lib.section
+		if lib.source != source {
+			if source < 0 {
+				basePathMaps = config.PathMaps
 			}
+			source = lib.source
+			if err := useSource(ctx, config, lib, basePathMaps); err != nil {
+				return nil, err
+			}
+			prompter.UseSource(config.Merged[lib.source].Path)
+		}
 
-			// Generate path previews for this show
-			var previews []cli.PathPreview
-			for _, season := range show.Seasons {
-				for _, episode := range season.Episodes {
-					for _, file := range episode.Files {
-						if selectedLocations != nil && !pathInLocations(file.File, selectedLocations) {
-							continue
-						}
-						srcPath := file.File
-						if config.PathMapSrc != "" {
-							srcPath = renamer.ApplyPathMapping(srcPath, config.PathMapSrc, config.PathMapDst)
-						}
-						ext := renamer.GetExtension(srcPath)
-						destName := formatter.FormatEpisode(&show.Metadata, &season.Metadata, &episode, ext)
-						outputDir := getOutputPath(file.File)
-						destPath := filepath.Join(outputDir, destName)
-						previews = append(previews, cli.PathPreview{Source: srcPath, Destination: destPath})
-					}
+		// Streaming mode reads the items later, one at a time
+		var content *database.LibraryContent
+		if config.Stream {
+			content = &database.LibraryContent{Section: section}
+			content.Locations, err = db.GetSectionLocations(ctx, section.ID)
+		} else {
+			content = lib.content
+			if content == nil {
+				content, err = loadLibraryContent(ctx, lib.db, lib.cache, config, section)
+			}
+			if err == nil && config.OnExists == renamer.ExistsBetter {
+				known.add(config, content)
+			}
+			if err == nil && skipped != nil {
+				content = skippedContent(content, skipped)
+			}
+			if err == nil && matchIndex != nil {
+				matched, unmatched := matchContent(config, content, matchIndex)
+				if !config.ScriptMode {
+					pterm.Info.Printf("Library %s: %d file(s) found in %s, %d not found or ambiguous\n", section.Name, matched, config.MatchDir, unmatched)
 				}
 			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if !config.ScriptMode {
+				pterm.Warning.Printf("Failed to get content for library %s: %v\n", section.Name, err)
+			}
+			continue
+		}
+
+		// Offer to map locations that don't exist here, then check that the
+		// locations are available, optionally dropping those that aren't
+		if !config.AutoApprove && !config.ScriptMode && config.Remote == "" {
+			if err := runPathMapWizard(config, prompter, content); err != nil {
+				return nil, err
+			}
+		}
+		usable := checkLocations(config, content.Locations)
+		if len(usable) == 0 {
+			if !config.ScriptMode {
+				pterm.Warning.Printf("Skipping library %s: none of its locations are available\n", section.Name)
+			}
+			continue
+		}
+		restricted := len(usable) < len(content.Locations)
+		content.Locations = usable
+
+		for _, loc := range content.Locations {
+			libraryRoots = append(libraryRoots, renamer.MapPath(loc.RootPath, config.PathMaps))
+		}
+		if media != nil {
+			addPlexPaths(media, content, config.PathMaps)
+		}
 
-			if len(previews) == 0 {
+		var selectedLocations []database.SectionLocation
+		var locationOutputs []cli.LocationWithOutput
+
+		// Skip prompts in script mode, or if auto-approve is set
+		if !config.AutoApprove && !config.ScriptMode {
+			if lib.source < 0 && lib.db != nil {
+				promptedSections = append(promptedSections, section.ID)
+			}
+			proceed, locations, err := prompter.PromptLibrary(section, content)
+			if err != nil {
+				return nil, err
+			}
+			if !proceed {
 				continue
 			}
+			selectedLocations = locations
 
-			if !config.AutoApprove && !config.ScriptMode {
-				proceed, _, err := prompter.PromptShow(&show, len(previews), previews)
+			// If locations were selected, prompt for output paths
+			if len(selectedLocations) > 0 && !config.InPlace {
+				locationOutputs, err = prompter.PromptLocationOutputs(selectedLocations, config.OutputDir)
 				if err != nil {
 					return nil, err
 				}
-				if !proceed {
-					continue
-				}
 			}
+		} else if !config.ScriptMode {
+			fmt.Println()
+			cli.PrintHeader(section.Name)
+		}
+
+		// Restrict to the usable locations if some were skipped
+		if restricted && selectedLocations == nil {
+			selectedLocations = usable
+		}
+
+		if config.Stream {
+			start := time.Now()
+			results, err := streamSection(ctx, db, config, formatter, section, content.Locations, selectedLocations, streamOpts)
+			streamResults = append(streamResults, results...)
+			streamTime += time.Since(start)
+			if ctx.Err() != nil {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Generate operations for this library
+		ops, err := generateOperations(config, formatter, prompter, content, selectedLocations, locationOutputs)
+		if err != nil {
+			return nil, err
+		}
+		allOperations = append(allOperations, ops...)
+		if config.Idempotent {
+			planned = append(planned, plannedLibrary{content, locationOutputs})
+		}
+		if plexPaths != nil {
+			addPlexPaths(plexPaths, content, config.PathMaps)
+		}
+	}
+	restorePathMaps()
+	if err := renamer.TokenProviderErr(); err != nil {
+		return nil, fmt.Errorf("failed to get placeholder values, nothing was done: %w", err)
+	}
+	if n := sharedDestinations(allOperations); len(config.Merged) > 0 && n > 0 && !config.ScriptMode {
+		pterm.Info.Printf("%d file(s) have the same destination as a file of another database; the first is written and the others skipped, unless --prefer better finds them better\n", n)
+	}
+
+	// Save what was declined, so it can be revisited with --only-skipped
+	if len(promptedSections) > 0 {
+		declined := prompter.Skipped()
+		if declined.Database, err = filepath.Abs(config.DatabasePath); err != nil {
+			declined.Database = config.DatabasePath
+		}
+		if err := saveSkipped(config.SkippedFile, declined, promptedSections); err != nil {
+			pterm.Warning.Println(err)
+		} else if !declined.Empty() {
+			fmt.Println()
+			pterm.Info.Printf("Saved %d declined item(s) to %s; revisit them with --only-skipped\n", declined.Count(), config.SkippedFile)
+		}
+	}
+
+	// Audit mode: report how Plex's paths differ from the layout, leaving
+	// the files alone
+	if config.Audit != "" {
+		return nil, auditPaths(ctx, db, config, prompter, plexPaths, allOperations)
+	}
+
+	if config.Stream {
+		finishRun(ctx, config, nil, streamResults, config.Filter.leftOut(), libraryRoots, nil, nil, streamTime)
+		updateFolderJournal(config, streamResults)
+		plexScan(ctx, db, config, streamResults)
+		notifyArr(ctx, config, streamResults)
+		exportWatchState(ctx, db, config, streamResults)
+		return streamResults, ctx.Err()
+	}
+
+	// Leave out operations whose source is gone instead of failing on them,
+	// and those whose source looks broken instead of bringing it along
+	var unreachable, suspect []renamer.Result
+	if !database.IsKodi(config.DatabasePath) {
+		// Kodi doesn't record sizes, so its sources are only checked with
+		// --check-sources
+		allOperations, suspect = renamer.EmptySources(allOperations, config.Remote == "")
+	}
+	if config.CheckSources && len(allOperations) > 0 {
+		var broken []renamer.Result
+		if !config.ScriptMode {
+			fmt.Println()
+			pterm.Info.Printf("Checking %d source files...\n", len(allOperations))
+		}
+		allOperations, unreachable, broken = renamer.CheckSources(ctx, allOperations, config.CheckFormats)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		suspect = append(suspect, broken...)
+	}
+
+	// Plan only what fits in the budget, e.g. the free space of a new disk
+	var overBudget []renamer.Operation
+	if (config.Budget.Limited() || config.Volumes != nil) && config.Remote == "" {
+		statSizes(allOperations)
+	}
+	if config.Budget.Limited() {
+		allOperations, overBudget = config.Budget.Apply(allOperations)
+	}
+	leftOut := leftOutResults(unreachable, suspect, config.Filter.leftOut(), overBudget, prompter.Skipped())
+
+	// Spread the files over the output volumes, keeping shows together
+	var volumes []*renamer.Volume
+	if config.Volumes != nil {
+		for _, path := range config.Volumes {
+			free, err := renamer.FreeSpace(path)
+			if err != nil {
+				return nil, err
+			}
+			volumes = append(volumes, &renamer.Volume{Path: path, Free: free})
+		}
+		renamer.DistributeVolumes(allOperations, volumes)
+	}
+
+	// With --prefer better, destinations that exist are compared with what
+	// Plex knows of them
+	if config.OnExists == renamer.ExistsBetter {
+		known.fill(allOperations)
+	}
 
-			// Add operations from previews
-			for _, pv := range previews {
-				operations = append(operations, renamer.Operation{
-					Source:      pv.Source,
-					Destination: pv.Destination,
-					Mode:        config.Mode,
-				})
+	// Rename the folders of shows and movies whose title changed since they
+	// were written, before their files are planned into the new folder
+	if config.FolderIDs {
+		journal, err := loadFolders(defaultFoldersPath(config.ConfigPath))
+		if err != nil {
+			return nil, err
+		}
+		if renames := folderRenames(journal, allOperations); len(renames) > 0 {
+			allOperations = append(renames, allOperations...)
+			if !config.ScriptMode {
+				fmt.Println()
+				pterm.Info.Printf("Renaming %d folder(s) whose title changed since they were written\n", len(renames))
 			}
 		}
 	}
 
+	if err := checkLengths(config, allOperations); err != nil {
+		return nil, err
+	}
+
+	// Moves onto the names of other sources, as when renaming in place, go
+	// through a temporary name so nothing is skipped or overwritten
+	if staged := renamer.StageOverlaps(allOperations); staged > 0 {
+		fmt.Println()
+		if config.ScriptMode {
+			pterm.Warning.Printf("%d move(s) overlap with other operations, which scripts can't stage; run them without --script\n", staged)
+		} else {
+			pterm.Info.Printf("%d move(s) overlap with other operations and go through a temporary name\n", staged)
+		}
+	}
+
+	if len(allOperations) == 0 {
+		if !config.ScriptMode {
+			cli.ShowUnreachable(unreachable, 10)
+			cli.ShowSuspects(suspect, 10)
+			showOverBudget(overBudget)
+			fmt.Println()
+			pterm.Info.Println("No operations to perform.")
+		}
+		finished()
+		return nil, nil
+	}
+
+	// Write the plan for review in a browser; it is replaced by the results
+	// once the operations have run
+	if config.HTMLReport != "" || config.CSVReport != "" {
+		if err := writeReports(config, allOperations, nil, nil); err != nil {
+			return nil, err
+		}
+		if !config.ScriptMode {
+			pterm.Info.Printf("Wrote the plan to %s\n", reportPaths(config))
+		}
+	}
+
+	// Script mode: output commands to file and exit
+	if config.ScriptMode {
+		return nil, outputScript(allOperations, config)
+	}
+
+	// Manifest mode: write operations to a manifest for `exec` and exit
+	if config.Manifest != "" {
+		if err := outputManifest(allOperations, config); err != nil {
+			return nil, err
+		}
+		finished()
+		return nil, nil
+	}
+
+	// Show preview
+	cut, err := prompter.ShowOperationPreview(allOperations, config.PreviewLimit)
+	if err != nil {
+		return nil, err
+	}
+	var showAll func() error
+	if cut {
+		showAll = func() error {
+			_, err := prompter.ShowOperationPreview(allOperations, 0)
+			return err
+		}
+	}
+	cli.ShowUnreachable(unreachable, 10)
+	cli.ShowSuspects(suspect, 10)
+	showOverBudget(overBudget)
+	showVolumes(volumes)
+
+	// Confirm and execute; scheduled runs have nobody to ask
+	if config.Schedule == nil {
+		proceed, err := prompter.ConfirmProceed(len(allOperations), config.Mode, config.DryRun, showAll)
+		if err != nil {
+			return nil, err
+		}
+		if !proceed {
+			pterm.Info.Println("Operation cancelled.")
+			return nil, nil
+		}
+	}
+
+	opts, closeExec, err := execOptions(config)
+	if err != nil {
+		return nil, err
+	}
+	defer closeExec()
+	if err := runPreRunHook(ctx, config); err != nil {
+		return nil, err
+	}
+
+	// With --sandbox, everything from here on happens to stand-ins
+	discarded := prompter.Discarded()
+	mediaFiles := slices.Collect(maps.Keys(media))
+	if config.Sandbox != "" {
+		allOperations, libraryRoots, discarded, mediaFiles, err = enterSandbox(config, allOperations, libraryRoots, discarded, mediaFiles)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Execute operations with progress bar, or run the pre-flight checks
+	fmt.Println()
+	var results []renamer.Result
+	start := time.Now()
+	if config.Validate {
+		pterm.Info.Println("Validating operations...")
+		results = renamer.ValidateBatch(allOperations)
+	} else {
+		results = executeOperations(ctx, allOperations, opts, postOperationHook(ctx, config))
+	}
+
+	finishRun(ctx, config, allOperations, results, leftOut, libraryRoots, discarded, mediaFiles, time.Since(start))
+	updateFolderJournal(config, results)
+	plexScan(ctx, db, config, results)
+	notifyArr(ctx, config, results)
+	exportWatchState(ctx, db, config, results)
+	if config.Idempotent && ctx.Err() == nil {
+		if err := assertIdempotent(config, formatter, planned, results); err != nil {
+			return results, err
+		}
+		fmt.Println()
+		pterm.Success.Println("Planning again would rename nothing: the run is idempotent")
+	}
+	if ctx.Err() == nil && !config.DryRun && config.Sandbox == "" {
+		finished()
+	}
+	return results, ctx.Err()
+}
+
+// statSizes fills in the size of sources Plex doesn't know the size of
+func statSizes(operations []renamer.Operation) {
+	for i := range operations {
+		if operations[i].Size > 0 {
+			continue
+		}
+		if info, err := os.Stat(operations[i].Source); err == nil {
+			operations[i].Size = info.Size()
+		}
+	}
+}
+
+// showOverBudget reports the operations left out by --max-bytes or
+// --max-files, for the next run
+func showOverBudget(left []renamer.Operation) {
+	if len(left) == 0 {
+		return
+	}
+	var total int64
+	for _, op := range left {
+		total += op.Size
+	}
+	fmt.Println()
+	pterm.Warning.Printf("Budget reached: %d file(s) (%s) are left for the next run\n", len(left), cli.FormatBytes(total))
+}
+
+// leftOutResults describes what was left out of the plan as skipped
+// results: files whose source can't be reached or looks broken, those left
+// out by the filters or the budget, and what was declined at the prompts,
+// which has SkipDeclined and is counted apart from the skipped files
+func leftOutResults(unreachable, suspect, filtered []renamer.Result, overBudget []renamer.Operation, declined *cli.Skipped) []renamer.Result {
+	var results []renamer.Result
+	skip := func(op renamer.Operation, reason renamer.SkipReason, message string) {
+		results = append(results, renamer.Result{Operation: op, Success: true, Skipped: true, Reason: reason, Message: message})
+	}
+	for _, r := range unreachable {
+		skip(r.Operation, renamer.SkipMissing, "source can't be reached: "+r.Error.Error())
+	}
+	for _, r := range suspect {
+		skip(r.Operation, renamer.SkipSuspect, "source looks broken: "+r.Error.Error())
+	}
+	results = append(results, filtered...)
+	for _, op := range overBudget {
+		skip(op, renamer.SkipFiltered, "over the budget, left for the next run")
+	}
+	for _, lib := range declined.Libraries {
+		skip(renamer.Operation{Title: lib.Name}, renamer.SkipDeclined, "library declined")
+	}
+	for _, loc := range declined.Locations {
+		skip(renamer.Operation{Source: loc.Path}, renamer.SkipDeclined, "location declined")
+	}
+	for _, show := range declined.Shows {
+		message := "show declined"
+		if len(show.Seasons) > 0 {
+			message = fmt.Sprintf("seasons %v declined", show.Seasons)
+		}
+		skip(renamer.Operation{Title: show.Title}, renamer.SkipDeclined, message)
+	}
+	for _, movie := range declined.Movies {
+		skip(renamer.Operation{Title: movie.Title}, renamer.SkipDeclined, "movie declined")
+	}
+	return results
+}
+
+// showVolumes reports how much goes to each output volume, warning about
+// volumes that will run out of space
+func showVolumes(volumes []*renamer.Volume) {
+	if len(volumes) == 0 {
+		return
+	}
+	fmt.Println()
+	for _, v := range volumes {
+		if v.Remaining() < 0 {
+			pterm.Warning.Printf("%s: %d file(s), %s, which is %s more than is free\n", v.Path, v.Files, cli.FormatBytes(v.Planned), cli.FormatBytes(-v.Remaining()))
+			continue
+		}
+		pterm.Info.Printf("%s: %d file(s), %s, leaving %s free\n", v.Path, v.Files, cli.FormatBytes(v.Planned), cli.FormatBytes(v.Remaining()))
+	}
+}
+
+// execOptions returns the options for executing operations, connecting to
+// the remote host or SMB share if one is used. The returned function closes
+// the connection.
+func execOptions(config *Config) (renamer.ExecOptions, func(), error) {
+	opts := renamer.ExecOptions{
+		DryRun:    config.DryRun,
+		Preserve:  config.Preserve,
+		Reflink:   config.Reflink,
+		Retries:   config.Retries,
+		RetryWait: config.RetryWait,
+		OnExists:  config.OnExists,
+		Fsync:     config.Fsync,
+		SetMtime:  config.SetMtime,
+	}
+	if config.Remote != "" {
+		remote, err := connectRemote(config.Remote)
+		if err != nil {
+			return opts, nil, err
+		}
+		remote.LowPriority = config.LowPriority
+		opts.Executor = remote
+		return opts, func() { remote.Close() }, nil
+	}
+	if config.SMB != nil && !config.DryRun {
+		pterm.Info.Printf("Connecting to %s...\n", config.SMB)
+		if err := config.SMB.Check(); err != nil {
+			return opts, nil, err
+		}
+		opts.Executor = config.SMB
+	}
+	return opts, func() {}, nil
+}
+
+// finishRun shows the results, with what was left out of the plan, and how
+// long they took and updates the HTML report, then trashes discarded
+// versions, handles leftovers and empty source directories and runs the
+// post hooks unless the run was cancelled
+func finishRun(ctx context.Context, config *Config, operations []renamer.Operation, results, leftOut []renamer.Result, libraryRoots []string, discarded []renamer.Leftover, media []string, elapsed time.Duration) {
+	// Show results
+	cli.ShowResults(results, leftOut, elapsed)
+	recordHistory(config, results, leftOut, elapsed, ctx.Err() != nil)
+
+	if config.HTMLReport != "" || config.CSVReport != "" {
+		if results == nil {
+			results = []renamer.Result{}
+		}
+		if err := writeReports(config, operations, results, leftOut); err != nil {
+			pterm.Warning.Println(err)
+		} else {
+			pterm.Info.Printf("Wrote the results to %s\n", reportPaths(config))
+		}
+	}
+
+	if ctx.Err() != nil {
+		pterm.Warning.Printf("Cancelled after %d operations; the remaining ones were not run\n", len(results))
+		return
+	}
+
+	// Trash the versions the user chose not to keep, before looking for
+	// leftovers among them. They stay if anything failed, as the versions
+	// kept may not have made it.
+	if len(discarded) > 0 {
+		failed := slices.ContainsFunc(results, func(r renamer.Result) bool { return !r.Success && !r.Skipped })
+		switch {
+		case config.DryRun:
+			var size int64
+			for _, d := range discarded {
+				size += d.Size
+			}
+			pterm.Info.Println(cli.T("versions.trash_dry", len(discarded), cli.FormatBytes(size)))
+		case failed:
+			pterm.Warning.Printf("Not sending %d file(s) of versions you didn't keep to the trash, as some operations failed\n", len(discarded))
+		default:
+			renamer.HandleLeftovers(discarded, libraryRoots)
+			cli.ShowDiscarded(discarded)
+		}
+	}
+
+	// Report and handle files left behind, before removing emptied directories
+	if config.Leftovers != nil && config.Mode == renamer.ModeMove && !config.DryRun {
+		leftovers := renamer.FindLeftovers(results, libraryRoots, config.Leftovers, media)
+		renamer.HandleLeftovers(leftovers, libraryRoots)
+		cli.ShowLeftovers(leftovers)
+	}
+
+	// Clean up source directories emptied by the moves
+	if config.CleanupDirs && config.Mode == renamer.ModeMove && !config.DryRun {
+		removed := renamer.RemoveEmptyDirs(results, libraryRoots, config.Protect)
+		if len(removed) > 0 {
+			pterm.Info.Printf("Removed %d empty source directories\n", len(removed))
+		}
+	}
+
+	runPostRunHook(ctx, config, results)
+}
+
+// generateOperations plans the files of a library and, unless approved
+// automatically, asks about each movie or show before including it
+func generateOperations(config *Config, formatter *renamer.Formatter, prompter *cli.Prompter, content *database.LibraryContent, selectedLocations []database.SectionLocation, locationOutputs []cli.LocationWithOutput) ([]renamer.Operation, error) {
+	plan := plannerOptions(config, formatter).PlanLibrary(content, selectedLocations, locationOutputs)
+	if config.AutoApprove || config.ScriptMode {
+		return plan.Operations(config.Mode), nil
+	}
+
+	var operations []renamer.Operation
+	titles := make([]string, len(plan.Items))
+	for i, item := range plan.Items {
+		titles[i] = item.Title
+	}
+	queue := newPromptQueue(titles)
+	for i, ok := queue.next(); ok; i, ok = queue.next() {
+		item := plan.Items[i]
+		var proceed bool
+		var seasons []int64
+		var err error
+		if item.Show != nil {
+			proceed, seasons, err = prompter.PromptShow(item.Show, len(item.Files), item.Files)
+		} else {
+			proceed, _, err = prompter.PromptMovie(item.Movie, item.Files)
+		}
+		var search *cli.SearchRequest
+		if errors.As(err, &search) {
+			queue.search(search.Query)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		queue.answer()
+		if proceed {
+			// Keep only the files of the selected seasons and versions
+			files, err := chooseVersions(prompter, item, item.InSeasons(seasons))
+			if err != nil {
+				return nil, err
+			}
+			operations = append(operations, item.Operations(files, config.Mode)...)
+		}
+	}
 	return operations, nil
 }
 
-// pathInLocations checks if a file path is under any of the selected locations
-func pathInLocations(filePath string, locations []database.SectionLocation) bool {
-	normalizedPath := normalizePathForComparison(filePath)
-	for _, loc := range locations {
-		normalizedLoc := normalizePathForComparison(loc.RootPath)
-		// Remove trailing slash, then check with /
-		normalizedLoc = strings.TrimSuffix(normalizedLoc, "/")
-		if strings.HasPrefix(normalizedPath, normalizedLoc+"/") {
-			return true
+// plannerOptions returns how the config plans the files of a library
+func plannerOptions(config *Config, formatter *renamer.Formatter) *planner.Options {
+	return &planner.Options{
+		Formatter:   formatter,
+		Mode:        config.Mode,
+		OutputDir:   config.OutputDir,
+		Output4K:    config.Output4K,
+		InPlace:     config.InPlace,
+		FoldersOnly: config.FoldersOnly,
+		LongNames:   config.LongNames,
+		PathMaps:    config.PathMaps,
+		UNCShares:   config.UNCShares,
+		Sidecars:    config.Sidecars,
+		Subtitles:   config.Subtitles,
+		Keep:        config.Filter.keep,
+	}
+}
+
+// subtitleIndex holds the external subtitles Plex knows, by local path
+type subtitleIndex map[string]database.Subtitle
+
+// loadSubtitles reads the language and flags of the external subtitles
+// Plex knows, for naming them with --sidecars
+func loadSubtitles(ctx context.Context, db *database.PlexDB, config *Config) error {
+	if !config.Sidecars {
+		return nil
+	}
+	subtitles, err := db.GetSubtitles(ctx)
+	if err != nil {
+		return err
+	}
+	config.Subtitles = make(subtitleIndex, len(subtitles))
+	for path, sub := range subtitles {
+		config.Subtitles[filepath.Clean(renamer.MapPath(path, config.PathMaps))] = sub
+	}
+	return nil
+}
+
+// checkLengths reports the destinations still too long for the filesystem
+// once planned, so the run stops before anything is done instead of
+// failing halfway
+func checkLengths(config *Config, operations []renamer.Operation) error {
+	var long []string
+	for _, op := range operations {
+		if err := renamer.CheckPathLength(op.Destination); err != nil {
+			long = append(long, fmt.Sprintf("  %s: %v", op.Destination, err))
 		}
 	}
-	return false
+	if len(long) == 0 {
+		return nil
+	}
+	count := len(long)
+	if count > 5 {
+		long = append(long[:5], fmt.Sprintf("  ... and %d more", count-5))
+	}
+	hint := "shorten the formats or the output path"
+	if config.LongNames == renamer.LengthError {
+		hint += ", or use --long-names truncate"
+	}
+	return fmt.Errorf("%d destination(s) are too long for the filesystem; %s:\n%s", count, hint, strings.Join(long, "\n"))
 }
 
-// normalizePathForComparison normalizes a path for comparison by converting to lowercase,
-// using forward slashes, and removing Windows long path prefixes
-func normalizePathForComparison(path string) string {
-	normalized := strings.ToLower(filepath.ToSlash(path))
-	// Remove Windows long path prefix //?/ or \\?\
-	normalized = strings.TrimPrefix(normalized, "//?/")
-	normalized = strings.TrimPrefix(normalized, "//./")
-	return normalized
+// runPathMapWizard asks for the local equivalent of each library location
+// that doesn't exist on this machine, verifying the answer against a sample
+// file, and offers to save the new mappings to the config file
+func runPathMapWizard(config *Config, prompter *cli.Prompter, content *database.LibraryContent) error {
+	var added []renamer.PathMap
+	for _, loc := range content.Locations {
+		if info, err := os.Stat(renamer.MapPath(loc.RootPath, config.PathMaps)); err == nil && info.IsDir() {
+			continue
+		}
+
+		m, ok, err := prompter.PromptPathMap(loc.RootPath, sampleFile(content, loc))
+		if err != nil {
+			return err
+		}
+		if ok {
+			// Earlier mappings win for equal prefixes, so new ones go first
+			config.PathMaps = append([]renamer.PathMap{m}, config.PathMaps...)
+			added = append(added, m)
+		}
+	}
+
+	if len(added) == 0 {
+		return nil
+	}
+	save, err := prompter.ConfirmSavePathMaps(config.ConfigPath)
+	if err != nil || !save {
+		return err
+	}
+	if err := savePathMaps(config.ConfigPath, added); err != nil {
+		return err
+	}
+	pterm.Success.Printf("Saved %d path mapping(s) to %s\n", len(added), config.ConfigPath)
+	return nil
+}
+
+// sampleFile returns the path of a media file under loc, or "" if there is none
+func sampleFile(content *database.LibraryContent, loc database.SectionLocation) string {
+	locs := []database.SectionLocation{loc}
+	for _, movie := range content.Movies {
+		for _, file := range movie.Files {
+			if planner.PathInLocations(file.File, locs) {
+				return file.File
+			}
+		}
+	}
+	for _, show := range content.Shows {
+		for _, season := range show.Seasons {
+			for _, episode := range season.Episodes {
+				for _, file := range episode.Files {
+					if planner.PathInLocations(file.File, locs) {
+						return file.File
+					}
+				}
+			}
+		}
+	}
+	return ""
 }
 
-// getLocationForPath returns the location root path for a given file path
-func getLocationForPath(filePath string, locations []database.SectionLocation) string {
-	normalizedPath := normalizePathForComparison(filePath)
+// checkLocations warns about library locations that Plex marks unavailable,
+// or whose root path (after path mapping) can't be reached from this machine.
+// With --skip-unavailable those locations are left out of the returned list.
+func checkLocations(config *Config, locations []database.SectionLocation) []database.SectionLocation {
+	var usable []database.SectionLocation
 	for _, loc := range locations {
-		normalizedLoc := normalizePathForComparison(loc.RootPath)
-		// Remove trailing slash for comparison
-		normalizedLoc = strings.TrimSuffix(normalizedLoc, "/")
-		// Check if the file path starts with the location path followed by /
-		if strings.HasPrefix(normalizedPath, normalizedLoc+"/") {
-			return loc.RootPath
+		root := renamer.MapPath(loc.RootPath, config.PathMaps)
+
+		// With --remote, the paths are on the remote host and can't be checked here
+		var problem string
+		if info, err := os.Stat(root); config.Remote == "" && (err != nil || !info.IsDir()) {
+			problem = fmt.Sprintf("Location %s isn't reachable from this machine", root)
+		} else if loc.Available == 0 {
+			problem = fmt.Sprintf("Plex marks location %s as unavailable", loc.RootPath)
+		}
+
+		if problem == "" {
+			usable = append(usable, loc)
+			continue
+		}
+
+		if !config.ScriptMode {
+			if config.SkipUnavail {
+				pterm.Warning.Println(problem + ", skipping it")
+			} else {
+				pterm.Warning.Println(problem)
+			}
+			if presets := renamer.DockerPresetsFor(loc.RootPath); root == loc.RootPath && len(presets) > 0 {
+				pterm.Info.Printf("This looks like a path inside a Docker container. Map it with --docker-map container:<name>, or --docker-map %s:/host/media\n", presets[0])
+			} else if root == loc.RootPath {
+				pterm.Info.Printf("If Plex runs on another machine or sees this library through a different mount, map the path: --path-map '%s:/path/on/this/machine'\n", loc.RootPath)
+			}
+		}
+		if !config.SkipUnavail {
+			usable = append(usable, loc)
+		}
+	}
+	return usable
+}
+
+// firstNonEmpty returns the first non-empty string in values
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
 		}
 	}
 	return ""
 }
 
-// fileInLocations checks if any file in the list is under any of the selected locations
-func fileInLocations(files []database.MediaPart, locations []database.SectionLocation) bool {
-	for _, file := range files {
-		if pathInLocations(file.File, locations) {
-			return true
+// validateFormats checks the TV, movie, and per-show formats for unknown
+// placeholders
+func validateFormats(config *Config) error {
+	if err := renamer.ValidateTVFormat(config.TVFormat); err != nil {
+		return err
+	}
+	if err := renamer.ValidateMovieFormat(config.MovieFormat); err != nil {
+		return err
+	}
+	for show, format := range config.ShowFormats {
+		if err := renamer.ValidateTVFormat(format); err != nil {
+			return fmt.Errorf("show_formats entry for %q in %s: %w", show, config.ConfigPath, err)
 		}
 	}
-	return false
+	if !config.Sidecars {
+		return nil
+	}
+	if err := renamer.ValidateSubtitleFormat(config.SubFormat); err != nil {
+		return err
+	}
+	return renamer.ValidateSidecarFormat(config.SideFormat)
 }
 
-// showInLocations checks if any episode in the show is under any of the selected locations
-func showInLocations(show *database.ShowInfo, locations []database.SectionLocation) bool {
-	for _, season := range show.Seasons {
-		for _, episode := range season.Episodes {
-			if fileInLocations(episode.Files, locations) {
-				return true
+// parseSectionIDs parses a comma-separated list of library section IDs
+func parseSectionIDs(list string) ([]int64, error) {
+	var ids []int64
+	for _, s := range strings.Split(list, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid section ID: %s", s)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// filterSections returns the sections whose ID is in ids or whose name is in
+// names (case-insensitive)
+func filterSections(sections []database.LibrarySection, ids []int64, names []string) []database.LibrarySection {
+	var selected []database.LibrarySection
+	for _, s := range sections {
+		match := slices.Contains(ids, s.ID)
+		for _, name := range names {
+			if strings.EqualFold(strings.TrimSpace(name), s.Name) {
+				match = true
 			}
 		}
+		if match {
+			selected = append(selected, s)
+		}
+	}
+	return selected
+}
+
+// stringList is a flag.Value collecting the values of a repeatable flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// selectSections narrows sections down to those selected with --sections
+// and --section-name, which must match at least one
+func selectSections(config *Config, sections []database.LibrarySection) ([]database.LibrarySection, error) {
+	if len(config.Sections) == 0 && len(config.SectionNames) == 0 {
+		return sections, nil
+	}
+	selected := filterSections(sections, config.Sections, config.SectionNames)
+	if len(selected) == 0 {
+		var available []string
+		for _, s := range sections {
+			available = append(available, fmt.Sprintf("%d (%s)", s.ID, s.Name))
+		}
+		return nil, fmt.Errorf("no library section matches the selection (available: %s)", strings.Join(available, ", "))
+	}
+	return selected, nil
+}
+
+// planSource is what the plan is made from: the database, or the directory
+// scanned with --scan-dir
+func planSource(config *Config) string {
+	if config.ScanDir != "" {
+		return config.ScanDir
+	}
+	return config.DatabasePath
+}
+
+// openDatabase opens the database and returns its libraries, narrowed down
+// to those selected and, with --only-skipped, to what was declined, which
+// is returned as well. The caller closes the database unless there's an error.
+func openDatabase(ctx context.Context, config *Config) (db *database.PlexDB, libraries []library, skipped *cli.Skipped, err error) {
+	if !config.ScriptMode {
+		pterm.Info.Printf("Opening database: %s\n", config.DatabasePath)
+	}
+	opened, err := openPlex(ctx, config.DatabasePath, config.DBOpenMode, config.PlexSQLite, config.ScriptMode)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			opened.Close()
+		}
+	}()
+	db = opened
+	if err := loadIntoMemory(ctx, config, db); err != nil {
+		return nil, nil, nil, err
+	}
+	db.IncludeMissing = config.WithMissing
+	if err := config.Filter.loadWatched(ctx, db); err != nil {
+		return nil, nil, nil, err
+	}
+	if err := loadSubtitles(ctx, db, config); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Get library sections
+	sections, err := db.GetLibrarySections(ctx)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to get library sections: %w", err)
+	}
+
+	if len(sections) == 0 {
+		if !config.ScriptMode {
+			pterm.Warning.Println("No library sections found in database.")
+		}
+		return db, nil, nil, nil
+	}
+
+	if sections, err = selectSections(config, sections); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Narrow down to what earlier interactive runs declined
+	if config.OnlySkipped {
+		if skipped, err = loadSkipped(config.SkippedFile); err != nil {
+			return nil, nil, nil, err
+		}
+		if !skipped.Empty() && !sameDatabase(skipped.Database, config.DatabasePath) {
+			return nil, nil, nil, fmt.Errorf("%s lists items of %s, not of %s", config.SkippedFile, skipped.Database, config.DatabasePath)
+		}
+		if sections = skippedSections(sections, skipped); len(sections) == 0 {
+			if !config.ScriptMode {
+				pterm.Info.Printf("Nothing was skipped (%s is empty or missing).\n", config.SkippedFile)
+			}
+			return db, nil, nil, nil
+		}
+	}
+
+	if !config.ScriptMode {
+		pterm.Success.Printf("Found %d library section(s)\n", len(sections))
+	}
+
+	// Library content is cached between runs unless the database changed.
+	// The cache only holds content without deleted items.
+	var cache *database.Cache
+	if !config.NoCache && !config.Stream && !config.WithMissing {
+		if cache, err = database.NewCache(defaultCacheDir(), config.DatabasePath, config.DBOpenMode); err != nil && !config.ScriptMode {
+			pterm.Warning.Printf("Not using the library cache: %v\n", err)
+		}
+	}
+
+	libraries = make([]library, 0, len(sections))
+	for _, section := range sections {
+		libraries = append(libraries, library{section: section, db: db, cache: cache, source: -1})
+	}
+	return db, libraries, skipped, nil
+}
+
+// loadLibraryContent returns the content of a section from the cache when
+// the database is unchanged, and otherwise queries and caches it
+func loadLibraryContent(ctx context.Context, db *database.PlexDB, cache *database.Cache, config *Config, section database.LibrarySection) (*database.LibraryContent, error) {
+	if cache != nil {
+		if content, ok := cache.Load(section); ok {
+			return content, nil
+		}
+	}
+
+	content, err := db.GetLibraryContent(ctx, section)
+	if err != nil {
+		return nil, err
+	}
+	if cache != nil {
+		if err := cache.Save(content); err != nil && !config.ScriptMode {
+			pterm.Warning.Printf("Failed to cache library %s: %v\n", section.Name, err)
+		}
 	}
-	return false
+	return content, nil
 }
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"plexrenamer/internal/database"
+	"plexrenamer/internal/renamer"
+)
+
+// matchContent points the files of content at the files under
+// config.MatchDir that match them, leaves out the files that have no match,
+// and makes that directory the library's only location. It returns how many
+// files matched and how many didn't.
+func matchContent(config *Config, content *database.LibraryContent, index *renamer.MatchIndex) (matched, unmatched int) {
+	match := func(files []database.MediaPart) []database.MediaPart {
+		var kept []database.MediaPart
+		for _, file := range files {
+			path, ok := index.Match(file.File, file.Size)
+			if !ok {
+				unmatched++
+				continue
+			}
+			file.File = path
+			kept = append(kept, file)
+			matched++
+		}
+		return kept
+	}
+
+	var movies []database.MovieInfo
+	for _, movie := range content.Movies {
+		if movie.Files = match(movie.Files); len(movie.Files) > 0 {
+			movies = append(movies, movie)
+		}
+	}
+	content.Movies = movies
+
+	var shows []database.ShowInfo
+	for _, show := range content.Shows {
+		var seasons []database.SeasonInfo
+		for _, season := range show.Seasons {
+			var episodes []database.EpisodeInfo
+			for _, episode := range season.Episodes {
+				if episode.Files = match(episode.Files); len(episode.Files) > 0 {
+					episodes = append(episodes, episode)
+				}
+			}
+			if season.Episodes = episodes; len(episodes) > 0 {
+				seasons = append(seasons, season)
+			}
+		}
+		if show.Seasons = seasons; len(seasons) > 0 {
+			shows = append(shows, show)
+		}
+	}
+	content.Shows = shows
+
+	content.Locations = []database.SectionLocation{{
+		LibrarySectionID: content.Section.ID,
+		RootPath:         config.MatchDir,
+		Available:        1,
+	}}
+	return matched, unmatched
+}
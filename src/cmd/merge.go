@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"plexrenamer/internal/database"
+	"plexrenamer/internal/renamer"
+)
+
+// mergedSource is a further database whose libraries are merged into the
+// plan, as when two Plex servers are consolidated onto one storage pool
+type mergedSource struct {
+	Path     string
+	PathMaps []renamer.PathMap // Tried before the path mappings of all databases
+}
+
+// parseSourcePathMap parses an "N=old:new" mapping for the Nth database
+// given on the command line
+func parseSourcePathMap(s string) (int, renamer.PathMap, error) {
+	n, mapping, ok := strings.Cut(s, "=")
+	index, err := strconv.Atoi(n)
+	if !ok || err != nil || index < 1 {
+		return 0, renamer.PathMap{}, fmt.Errorf("invalid database path mapping %q, use N=old:new with N the position of the database", s)
+	}
+	m, err := renamer.ParsePathMap(mapping)
+	return index, m, err
+}
+
+// library is a library section to plan and the database it is read from
+type library struct {
+	section database.LibrarySection
+	db      *database.PlexDB
+	cache   *database.Cache
+	source  int                      // Index in Config.Merged, or -1 for the first database
+	content *database.LibraryContent // Known up front with --scan-dir, which has no database
+}
+
+// openMerged opens the databases merged into the plan and returns their
+// libraries, narrowed down like those of the first database. Their names
+// say which database they are from. The returned function closes them.
+func openMerged(ctx context.Context, config *Config) ([]library, func(), error) {
+	var dbs []*database.PlexDB
+	closeAll := func() {
+		for _, db := range dbs {
+			db.Close()
+		}
+	}
+
+	var libraries []library
+	for i, source := range config.Merged {
+		if !config.ScriptMode {
+			pterm.Info.Printf("Opening database: %s\n", source.Path)
+		}
+		db, err := openPlex(ctx, source.Path, config.DBOpenMode, config.PlexSQLite, config.ScriptMode)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("failed to open database %s: %w", source.Path, err)
+		}
+		dbs = append(dbs, db)
+		if err := loadIntoMemory(ctx, config, db); err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		db.IncludeMissing = config.WithMissing
+
+		sections, err := db.GetLibrarySections(ctx)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("failed to get library sections of %s: %w", source.Path, err)
+		}
+		if len(config.Sections) > 0 || len(config.SectionNames) > 0 {
+			sections = filterSections(sections, config.Sections, config.SectionNames)
+		}
+		if !config.ScriptMode {
+			pterm.Success.Printf("Found %d library section(s) in %s\n", len(sections), source.Path)
+		}
+
+		var cache *database.Cache
+		if !config.NoCache && !config.WithMissing {
+			if cache, err = database.NewCache(defaultCacheDir(), source.Path, config.DBOpenMode); err != nil && !config.ScriptMode {
+				pterm.Warning.Printf("Not using the library cache for %s: %v\n", source.Path, err)
+			}
+		}
+
+		for _, section := range sections {
+			section.Name = fmt.Sprintf("%s (%s)", section.Name, filepath.Base(source.Path))
+			libraries = append(libraries, library{section: section, db: db, cache: cache, source: i})
+		}
+	}
+	return libraries, closeAll, nil
+}
+
+// useSource switches to planning the libraries of the merged database lib
+// is from: its own path mappings are tried before base, the mappings of all
+// databases, and the watch state and subtitles are read from it
+func useSource(ctx context.Context, config *Config, lib library, base []renamer.PathMap) error {
+	config.PathMaps = append(append([]renamer.PathMap{}, config.Merged[lib.source].PathMaps...), base...)
+	if err := config.Filter.loadWatched(ctx, lib.db); err != nil {
+		return err
+	}
+	return loadSubtitles(ctx, lib.db, config)
+}
+
+// sharedDestinations counts the operations planned onto the destination of
+// an earlier operation, as when both merged servers have the same movie
+func sharedDestinations(operations []renamer.Operation) int {
+	seen := make(map[string]bool, len(operations))
+	shared := 0
+	for _, op := range operations {
+		key := filepath.Clean(op.Destination)
+		if seen[key] {
+			shared++
+		}
+		seen[key] = true
+	}
+	return shared
+}
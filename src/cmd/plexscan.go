@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"plexrenamer/internal/database"
+	"plexrenamer/internal/plexapi"
+	"plexrenamer/internal/renamer"
+	"plexrenamer/pkg/planner"
+)
+
+// scanFolder is a folder that Plex is asked to scan, as Plex sees it
+type scanFolder struct {
+	section int64
+	path    string
+}
+
+// plexScan asks the Plex server to scan the folders that files were renamed
+// or copied into, so they show up without waiting for the next library scan.
+// Folders outside every library location of the database are left out.
+func plexScan(ctx context.Context, db *database.PlexDB, config *Config, results []renamer.Result) {
+	if config.PlexURL == "" || config.DryRun || config.Sandbox != "" || ctx.Err() != nil {
+		return
+	}
+
+	var dirs []string
+	for _, r := range results {
+		if r.Success && !r.Skipped {
+			dirs = append(dirs, filepath.Dir(r.Operation.Destination))
+		}
+	}
+	if len(dirs) == 0 {
+		return
+	}
+
+	var locations []database.SectionLocation
+	sections, err := db.GetLibrarySections(ctx)
+	if err != nil {
+		pterm.Warning.Printf("Not scanning in Plex: %v\n", err)
+		return
+	}
+	for _, section := range sections {
+		locs, err := db.GetSectionLocations(ctx, section.ID)
+		if err != nil {
+			pterm.Warning.Printf("Not scanning in Plex: %v\n", err)
+			return
+		}
+		locations = append(locations, locs...)
+	}
+
+	folders, outside := scanFolders(dirs, locations, config.PathMaps)
+	if outside > 0 {
+		pterm.Warning.Printf("%d destination folder(s) are not in a Plex library location and were not scanned\n", outside)
+	}
+	if len(folders) == 0 {
+		return
+	}
+
+	client, err := plexapi.NewClient(config.PlexURL, config.PlexToken)
+	if err != nil {
+		pterm.Warning.Printf("Not scanning in Plex: %v\n", err)
+		return
+	}
+	scanned := 0
+	for _, f := range folders {
+		if err := client.ScanPath(ctx, f.section, f.path); err != nil {
+			pterm.Warning.Printf("Failed to scan %s in Plex: %v\n", f.path, err)
+			continue
+		}
+		scanned++
+	}
+	if scanned > 0 {
+		pterm.Info.Printf("Asked Plex to scan %d folder(s)\n", scanned)
+	}
+}
+
+// scanFolders translates destination folders to Plex paths in the library
+// location holding them, leaving out folders inside other ones. It also
+// returns how many folders are outside every location.
+func scanFolders(dirs []string, locations []database.SectionLocation, maps []renamer.PathMap) ([]scanFolder, int) {
+	normalize := func(path string) string {
+		return strings.TrimSuffix(strings.ReplaceAll(planner.NormalizePath(path), `\`, "/"), "/")
+	}
+	inside := func(path, root string) bool {
+		return path == root || strings.HasPrefix(path, root+"/")
+	}
+
+	var folders []scanFolder
+	var keys []string
+	seen := map[string]bool{}
+	outside := 0
+	for _, dir := range dirs {
+		plexDir := renamer.UnmapPath(dir, maps)
+		key := normalize(plexDir)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		// The location with the longest root holding the folder
+		best := -1
+		for i, loc := range locations {
+			root := normalize(loc.RootPath)
+			if inside(key, root) && (best < 0 || len(root) > len(normalize(locations[best].RootPath))) {
+				best = i
+			}
+		}
+		if best < 0 {
+			outside++
+			continue
+		}
+
+		// Plex expects the separators of its own paths
+		if strings.Contains(locations[best].RootPath, `\`) {
+			plexDir = strings.ReplaceAll(plexDir, "/", `\`)
+		} else {
+			plexDir = strings.ReplaceAll(plexDir, `\`, "/")
+		}
+		folders = append(folders, scanFolder{section: locations[best].LibrarySectionID, path: plexDir})
+		keys = append(keys, key)
+	}
+
+	// A scan covers subfolders, so folders inside another one are left out
+	var kept []scanFolder
+	for i, f := range folders {
+		covered := false
+		for j := range folders {
+			if i != j && folders[j].section == f.section && keys[i] != keys[j] && inside(keys[i], keys[j]) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			kept = append(kept, f)
+		}
+	}
+	return kept, outside
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/pterm/pterm"
+	"plexrenamer/internal/database"
+)
+
+// openPlex opens a Plex database in the given mode. One that needs Plex's
+// own SQLite is read from a copy of its tables made with the Plex SQLite
+// program: plexSQLite, or the one installed with Plex.
+func openPlex(ctx context.Context, path string, mode database.OpenMode, plexSQLite string, quiet bool) (*database.PlexDB, error) {
+	db, err := database.OpenWith(path, mode)
+	var formatErr *database.FormatError
+	if !errors.As(err, &formatErr) {
+		return db, err
+	}
+	if formatErr.Extracted != "" {
+		// The database extracted from a backup is read in its place
+		defer os.Remove(formatErr.Extracted)
+		path, mode = formatErr.Extracted, database.OpenImmutable
+	}
+	if plexSQLite == "" {
+		plexSQLite = database.FindPlexSQLite()
+	}
+	if plexSQLite == "" {
+		return nil, fmt.Errorf("%w. Run this on the Plex server, or point --plex-sqlite at the Plex SQLite program that comes with Plex Media Server", err)
+	}
+	if !quiet {
+		pterm.Warning.Println(err)
+		pterm.Info.Printf("Copying the library tables with %s...\n", plexSQLite)
+	}
+	return database.OpenWithPlexSQLite(ctx, plexSQLite, path, mode)
+}
+
+// loadIntoMemory copies an opened database into memory with
+// --load-into-memory
+func loadIntoMemory(ctx context.Context, config *Config, db *database.PlexDB) error {
+	if !config.InMemory {
+		return nil
+	}
+	if !config.ScriptMode {
+		pterm.Info.Println("Loading the database into memory...")
+	}
+	return db.LoadIntoMemory(ctx)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"path/filepath"
+
+	"plexrenamer/internal/database"
+	"plexrenamer/internal/renamer"
+)
+
+// qualityIndex holds the quality of the files Plex knows, by local path, for
+// comparing a destination that exists with the file planned onto it
+type qualityIndex map[string]renamer.Quality
+
+// add records the files of a library's content
+func (idx qualityIndex) add(config *Config, content *database.LibraryContent) {
+	addFiles := func(files []database.MediaPart) {
+		for _, file := range files {
+			path := renamer.ToUNC(renamer.MapPath(file.File, config.PathMaps), config.UNCShares)
+			idx[filepath.Clean(path)] = renamer.FileQuality(file)
+		}
+	}
+	for _, movie := range content.Movies {
+		addFiles(movie.Files)
+	}
+	for _, show := range content.Shows {
+		for _, season := range show.Seasons {
+			for _, episode := range season.Episodes {
+				addFiles(episode.Files)
+			}
+		}
+	}
+}
+
+// fill sets the quality of the existing destination of each operation that
+// Plex knows. The others are compared by size alone when executed.
+func (idx qualityIndex) fill(operations []renamer.Operation) {
+	for i := range operations {
+		if q, ok := idx[filepath.Clean(operations[i].Destination)]; ok {
+			operations[i].Existing = q
+		}
+	}
+}
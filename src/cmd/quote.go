@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// bashQuote returns s as a single bash word. Plain single quotes are used when
+// possible, since nothing ($, `, !, \) is special inside them. Strings with
+// control characters such as newlines use ANSI-C $'...' quoting instead.
+func bashQuote(s string) string {
+	hasControl := strings.IndexFunc(s, func(r rune) bool { return r < 0x20 || r == 0x7f }) >= 0
+	if !hasControl {
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	}
+
+	var b strings.Builder
+	b.WriteString("$'")
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' || c == '\'':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteString("'")
+	return b.String()
+}
+
+// psQuote returns s as a PowerShell single-quoted string literal. PowerShell
+// also treats the typographic quotes ‘ ’ ‚ ‛ as single quotes, so those are
+// doubled as well.
+func psQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, r := range s {
+		switch r {
+		case '\'', '‘', '’', '‚', '‛':
+			b.WriteRune(r)
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// cmdQuote returns s as a double-quoted batch argument. Inside double quotes
+// only % needs escaping, as generated scripts disable delayed expansion.
+func cmdQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, "%", "%%") + `"`
+}
+
+// escapeCmdPath escapes special characters for unquoted use in Windows batch
+// scripts (e.g. echo lines). Use cmdQuote for command arguments.
+func escapeCmdPath(path string) string {
+	// Outside double quotes, we need to escape:
+	// % -> %% (percent signs are used for variables)
+	// ^ -> ^^ (caret is the escape character)
+	// & -> ^& (ampersand separates commands)
+	// < -> ^< (redirection)
+	// > -> ^> (redirection)
+	// | -> ^| (pipe)
+	// ( ) -> ^( ^) (grouping, when inside a parenthesized block)
+	// ! -> ^! (exclamation mark in delayed expansion)
+
+	result := path
+	// Escape percent signs first (double them)
+	result = strings.ReplaceAll(result, "%", "%%")
+	// Escape caret (must be done before other escapes that use caret)
+	result = strings.ReplaceAll(result, "^", "^^")
+	// Escape other special characters with caret
+	result = strings.ReplaceAll(result, "&", "^&")
+	result = strings.ReplaceAll(result, "<", "^<")
+	result = strings.ReplaceAll(result, ">", "^>")
+	result = strings.ReplaceAll(result, "|", "^|")
+	result = strings.ReplaceAll(result, "(", "^(")
+	result = strings.ReplaceAll(result, ")", "^)")
+	// Escape exclamation marks (for delayed expansion mode)
+	result = strings.ReplaceAll(result, "!", "^!")
+
+	return result
+}
+
+// pyQuote returns a Python expression for the string s. Go's quoted-string
+// escapes (\n, \t, \xNN, \uNNNN, \UNNNNNNNN) are all valid in Python 3
+// strings. A path that isn't UTF-8 is given as bytes and decoded with
+// os.fsdecode, which keeps its bytes as surrogate escapes, as Python does
+// for the names it reads from the filesystem.
+func pyQuote(s string) string {
+	if utf8.ValidString(s) {
+		return strconv.Quote(s)
+	}
+	var b strings.Builder
+	b.WriteString(`os.fsdecode(b"`)
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c >= 0x20 && c < 0x7f && c != '"' && c != '\\' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, `\x%02x`, c)
+		}
+	}
+	b.WriteString(`")`)
+	return b.String()
+}
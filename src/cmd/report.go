@@ -0,0 +1,475 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"plexrenamer/internal/cli"
+	"plexrenamer/internal/renamer"
+)
+
+// reportRow is one operation in the HTML report
+type reportRow struct {
+	Index       int
+	Mode        string
+	SourceDir   string
+	SourceName  string
+	DestDir     string
+	DestName    string
+	Library     string
+	Title       string
+	Source      string
+	Destination string
+	Size        string // Of the source, empty if unknown
+	Bytes       int64
+	Cumulative  int64  // Space needed by the plan up to this operation
+	Status      string // Empty until the operation has run
+	Message     string
+}
+
+// reportData is what the HTML report template renders
+type reportData struct {
+	Generated  string
+	Database   string
+	Executed   bool
+	DryRun     bool
+	Rows       []reportRow
+	Succeeded  int
+	Skipped    int
+	Reasons    string // What the skips were for, e.g. "3990 destination exists, 10 filtered out"
+	Declined   int    // Libraries, shows, and movies declined at the prompts
+	Failed     int
+	Operations int
+	TotalSize  string    // Of the planned operations
+	Sizes      []sizeRow // What the planned operations bring to each library
+}
+
+// sizeRow is the size of what is planned for a show or movie, or for all of
+// a library, in the report
+type sizeRow struct {
+	Library    string
+	Title      string // Empty for the row of the library
+	Files      int
+	Size       string
+	Cumulative string // Space needed by the plan up to the end of this row
+	Free       string // For libraries, free space where their files go, if known
+}
+
+// skipReasonText describes the reasons for skipping in the report
+var skipReasonText = map[renamer.SkipReason]string{
+	renamer.SkipExists:    "destination exists",
+	renamer.SkipNotBetter: "not better than the existing file",
+	renamer.SkipMissing:   "source missing",
+	renamer.SkipSuspect:   "source looks broken",
+	renamer.SkipFiltered:  "filtered out",
+}
+
+// writeReports writes the HTML and CSV reports that were asked for, listing
+// the operations with their outcome once results are available (results may
+// be nil for a plan), followed by what was left out of the plan
+func writeReports(config *Config, operations []renamer.Operation, results, leftOut []renamer.Result) error {
+	data := newReport(config, operations, results, leftOut)
+	if config.HTMLReport != "" {
+		if err := writeHTMLReport(config.HTMLReport, data); err != nil {
+			return err
+		}
+	}
+	if config.CSVReport != "" {
+		if err := writeCSVReport(config.CSVReport, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reportPaths returns the files writeReports writes, for messages
+func reportPaths(config *Config) string {
+	var paths []string
+	for _, path := range []string{config.HTMLReport, config.CSVReport} {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return strings.Join(paths, " and ")
+}
+
+// newReport gathers what the reports show
+func newReport(config *Config, operations []renamer.Operation, results, leftOut []renamer.Result) reportData {
+	local := config.Remote == "" && config.SMB == nil
+	data := reportData{
+		Generated:  time.Now().Format("2006-01-02 15:04:05"),
+		Database:   planSource(config),
+		Executed:   results != nil,
+		DryRun:     config.DryRun,
+		Operations: len(operations),
+	}
+
+	row := func(op renamer.Operation) reportRow {
+		rr := reportRow{
+			Index:       len(data.Rows) + 1,
+			Mode:        string(op.Mode),
+			Library:     op.Library,
+			Title:       op.Title,
+			Source:      op.Source,
+			Destination: op.Destination,
+			SourceDir:   filepath.Dir(op.Source),
+			SourceName:  filepath.Base(op.Source),
+			DestDir:     filepath.Dir(op.Destination),
+			DestName:    filepath.Base(op.Destination),
+			Bytes:       op.Size,
+		}
+		if op.Size > 0 {
+			rr.Size = cli.FormatBytes(op.Size)
+		}
+		if op.Source == "" {
+			// A declined show or movie, which has no files planned
+			rr.SourceDir, rr.SourceName = "", op.Title
+		}
+		if op.Destination == "" {
+			rr.DestDir, rr.DestName = "", ""
+		}
+		return rr
+	}
+
+	if results != nil {
+		// Operations that never ran (e.g. after Ctrl+C) have no result
+		data.Operations = max(len(operations), len(results))
+		for _, r := range results {
+			rr := row(r.Operation)
+			switch {
+			case r.Error != nil:
+				rr.Status = "failed"
+				rr.Message = r.Error.Error()
+				data.Failed++
+			case r.Skipped:
+				rr.Status = "skipped"
+				rr.Message = r.Message
+				data.Skipped++
+			default:
+				rr.Status = "succeeded"
+				rr.Message = r.Message
+				data.Succeeded++
+			}
+			data.Rows = append(data.Rows, rr)
+		}
+		for _, op := range operations[min(len(results), len(operations)):] {
+			rr := row(op)
+			rr.Status = "not run"
+			data.Rows = append(data.Rows, rr)
+		}
+		for _, r := range leftOut {
+			rr := row(r.Operation)
+			rr.Status = "skipped"
+			rr.Message = r.Message
+			if r.Reason == renamer.SkipDeclined {
+				rr.Status = "declined"
+				data.Declined++
+			} else {
+				data.Skipped++
+			}
+			data.Rows = append(data.Rows, rr)
+		}
+
+		counts := renamer.CountSkips(append(slices.Clip(results), leftOut...))
+		var reasons []string
+		for _, reason := range renamer.SkipReasons {
+			if n := counts[reason]; n > 0 {
+				reasons = append(reasons, fmt.Sprintf("%d %s", n, skipReasonText[reason]))
+			}
+		}
+		data.Reasons = strings.Join(reasons, ", ")
+	} else {
+		for _, op := range operations {
+			data.Rows = append(data.Rows, row(op))
+		}
+	}
+
+	// The running total of each row is the space needed up to it, in the
+	// order the operations run
+	needed := map[string]int64{}
+	var total, cumulative int64
+	for _, op := range operations {
+		if op.Source == "" {
+			continue
+		}
+		total += op.Size
+		if takesSpace(op, local) {
+			cumulative += op.Size
+		}
+		needed[op.Source] = cumulative
+	}
+	for i := range data.Rows {
+		data.Rows[i].Cumulative = needed[data.Rows[i].Source]
+	}
+	data.TotalSize = cli.FormatBytes(total)
+	data.Sizes = reportSizes(operations, local)
+	return data
+}
+
+// takesSpace reports whether op needs new space for its file: anything but a
+// move that is a rename on one filesystem. local is false when the files are
+// written on another host, where that can't be told.
+func takesSpace(op renamer.Operation, local bool) bool {
+	return op.Mode != renamer.ModeMove || !local || !renamer.SameFilesystem(op.Source, op.Destination)
+}
+
+// writeHTMLReport writes a standalone HTML page of the report
+func writeHTMLReport(path string, data reportData) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report: %w", err)
+	}
+	if err := reportTemplate.Execute(f, data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// writeCSVReport writes the operations of the report to a CSV file, with
+// sizes in bytes for a spreadsheet
+func writeCSVReport(path string, data reportData) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report: %w", err)
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"mode", "library", "title", "source", "destination", "size", "cumulative", "status", "message"})
+	for _, r := range data.Rows {
+		w.Write([]string{r.Mode, r.Library, r.Title, r.Source, r.Destination, strconv.FormatInt(r.Bytes, 10), strconv.FormatInt(r.Cumulative, 10), r.Status, r.Message})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// reportSizes returns the size of the files planned for each show or movie,
+// grouped by library in the order of the plan, with the running total of
+// the new space the plan needs as it is executed. With local destinations, libraries show the
+// free space of the filesystem their first file goes to.
+func reportSizes(operations []renamer.Operation, local bool) []sizeRow {
+	type title struct {
+		name   string
+		files  int
+		size   int64
+		needed int64 // Leaving out renames, which take no new space
+	}
+	type library struct {
+		name   string
+		dest   string // Destination of its first file
+		titles []*title
+		byName map[string]*title
+	}
+	var libraries []*library
+	byName := map[string]*library{}
+	for _, op := range operations {
+		if op.Source == "" {
+			// A declined show or movie, which has no files planned
+			continue
+		}
+		lib := byName[op.Library]
+		if lib == nil {
+			lib = &library{name: op.Library, dest: op.Destination, byName: map[string]*title{}}
+			byName[op.Library] = lib
+			libraries = append(libraries, lib)
+		}
+		name := op.Title
+		if name == "" {
+			name = "Other"
+		}
+		t := lib.byName[name]
+		if t == nil {
+			t = &title{name: name}
+			lib.byName[name] = t
+			lib.titles = append(lib.titles, t)
+		}
+		t.files++
+		t.size += op.Size
+		if takesSpace(op, local) {
+			t.needed += op.Size
+		}
+	}
+
+	var rows []sizeRow
+	var cumulative int64
+	for _, lib := range libraries {
+		name := lib.name
+		if name == "" {
+			name = "Other"
+		}
+		libRow := len(rows)
+		rows = append(rows, sizeRow{Library: name})
+		var files int
+		var size int64
+		for _, t := range lib.titles {
+			cumulative += t.needed
+			files += t.files
+			size += t.size
+			rows = append(rows, sizeRow{
+				Library:    name,
+				Title:      t.name,
+				Files:      t.files,
+				Size:       cli.FormatBytes(t.size),
+				Cumulative: cli.FormatBytes(cumulative),
+			})
+		}
+		rows[libRow].Files = files
+		rows[libRow].Size = cli.FormatBytes(size)
+		rows[libRow].Cumulative = cli.FormatBytes(cumulative)
+		if local && lib.dest != "" {
+			rows[libRow].Free = freeSpaceAt(lib.dest)
+		}
+	}
+	return rows
+}
+
+// freeSpaceAt returns the free space of the filesystem path is to be
+// written to, found from the closest of its directories that exists, or ""
+// if it can't be told
+func freeSpaceAt(path string) string {
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if free, err := renamer.FreeSpace(dir); err == nil {
+			return cli.FormatBytes(free)
+		}
+		if filepath.Dir(dir) == dir {
+			return ""
+		}
+	}
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Plex File Renamer report</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 1.5em; color: #222; }
+h1 { font-size: 1.4em; margin: 0 0 .2em; }
+.meta { color: #666; margin-bottom: 1em; }
+.summary span { margin-right: 1.5em; }
+.controls { position: sticky; top: 0; background: #fff; padding: .6em 0; display: flex; gap: .8em; align-items: center; }
+.controls input { flex: 1; max-width: 40em; padding: .35em; }
+table { border-collapse: collapse; width: 100%; font-size: .9em; }
+th, td { border-bottom: 1px solid #e4e4e4; padding: .3em .5em; text-align: left; vertical-align: top; }
+th { background: #f4f4f4; cursor: pointer; user-select: none; white-space: nowrap; position: sticky; top: 2.8em; }
+th.asc::after { content: " \25B2"; } th.desc::after { content: " \25BC"; }
+td.dir { color: #777; word-break: break-all; } td.name { word-break: break-all; }
+tr.failed td { background: #fdecea; } tr.skipped td, tr.declined td { color: #999; }
+.succeeded { color: #2e7d32; } .failed { color: #c62828; }
+td.size { white-space: nowrap; text-align: right; }
+h2 { font-size: 1.1em; margin: 1.5em 0 .5em; }
+#sizes { width: auto; } #sizes th { position: static; cursor: default; } tr.library td { font-weight: bold; background: #fafafa; }
+</style>
+</head>
+<body>
+<h1>Plex File Renamer {{if .Executed}}results{{else}}plan{{end}}{{if .DryRun}} (dry run){{end}}</h1>
+<div class="meta">{{.Database}} &middot; generated {{.Generated}}</div>
+<div class="summary">
+<span><b>{{.Operations}}</b> operations</span>
+<span><b>{{.TotalSize}}</b> planned</span>
+{{- if .Executed}}
+<span class="succeeded"><b>{{.Succeeded}}</b> succeeded</span>
+<span><b>{{.Skipped}}</b> skipped{{if .Reasons}} ({{.Reasons}}){{end}}</span>
+{{- if .Declined}}
+<span><b>{{.Declined}}</b> declined at the prompts</span>
+{{- end}}
+<span class="failed"><b>{{.Failed}}</b> failed</span>
+{{- end}}
+</div>
+<div class="controls">
+<input id="filter" type="search" placeholder="Filter (space-separated words, all must match)" autofocus>
+{{- if .Executed}}
+<select id="status"><option value="">All statuses</option><option>succeeded</option><option>skipped</option><option>declined</option><option>failed</option><option>not run</option></select>
+{{- end}}
+<span id="count"></span>
+</div>
+<table id="ops">
+<thead><tr>
+<th data-type="num">#</th><th>Mode</th><th>Before: folder</th><th>Before: file</th><th>After: folder</th><th>After: file</th><th data-type="num">Size</th>
+{{- if .Executed}}<th>Status</th><th>Message</th>{{end}}
+</tr></thead>
+<tbody>
+{{- range $r := .Rows}}
+<tr{{if $r.Status}} class="{{$r.Status}}"{{end}}><td>{{$r.Index}}</td><td>{{$r.Mode}}</td><td class="dir">{{$r.SourceDir}}</td><td class="name">{{$r.SourceName}}</td><td class="dir">{{$r.DestDir}}</td><td class="name">{{$r.DestName}}</td><td class="size" data-bytes="{{$r.Bytes}}">{{$r.Size}}</td>
+{{- if $.Executed}}<td class="{{$r.Status}}">{{$r.Status}}</td><td>{{$r.Message}}</td>{{end}}</tr>
+{{- end}}
+</tbody>
+</table>
+{{- if .Sizes}}
+<h2>Sizes</h2>
+<table id="sizes">
+<thead><tr><th>Library</th><th>Show or movie</th><th>Files</th><th>Size</th><th>Cumulative</th><th>Free at destination</th></tr></thead>
+<tbody>
+{{- range $s := .Sizes}}
+<tr{{if not $s.Title}} class="library"{{end}}><td>{{if not $s.Title}}{{$s.Library}}{{end}}</td><td>{{$s.Title}}</td><td class="size">{{$s.Files}}</td><td class="size">{{$s.Size}}</td><td class="size">{{$s.Cumulative}}</td><td class="size">{{$s.Free}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+{{- end}}
+<script>
+(function () {
+  var tbody = document.querySelector("#ops tbody");
+  var rows = Array.prototype.slice.call(tbody.rows);
+  var text = rows.map(function (r) { return r.textContent.toLowerCase(); });
+  var filter = document.getElementById("filter");
+  var status = document.getElementById("status");
+  var count = document.getElementById("count");
+  var timer;
+
+  function apply() {
+    var words = filter.value.toLowerCase().split(/\s+/).filter(Boolean);
+    var want = status ? status.value : "";
+    var shown = 0;
+    rows.forEach(function (r, i) {
+      var ok = words.every(function (w) { return text[i].indexOf(w) >= 0; }) &&
+        (!want || r.className === want);
+      r.style.display = ok ? "" : "none";
+      if (ok) shown++;
+    });
+    count.textContent = shown + " of " + rows.length + " shown";
+  }
+  filter.addEventListener("input", function () { clearTimeout(timer); timer = setTimeout(apply, 150); });
+  if (status) status.addEventListener("change", apply);
+
+  document.querySelectorAll("#ops th").forEach(function (th, col) {
+    th.addEventListener("click", function () {
+      var desc = th.classList.contains("asc");
+      document.querySelectorAll("#ops th").forEach(function (h) { h.classList.remove("asc", "desc"); });
+      th.classList.add(desc ? "desc" : "asc");
+      var num = th.dataset.type === "num";
+      var key = rows.map(function (r) {
+        var cell = r.cells[col];
+        return num ? +(cell.dataset.bytes || cell.textContent) : cell.textContent;
+      });
+      var order = rows.map(function (r, i) { return i; });
+      order.sort(function (a, b) {
+        var c = num ? key[a] - key[b] : key[a].localeCompare(key[b], undefined, { numeric: true, sensitivity: "base" });
+        return desc ? -c : c;
+      });
+      var frag = document.createDocumentFragment();
+      order.forEach(function (i) { frag.appendChild(rows[i]); });
+      tbody.appendChild(frag);
+    });
+  });
+  apply();
+})();
+</script>
+</body>
+</html>
+`))
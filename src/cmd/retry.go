@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pterm/pterm"
+	"plexrenamer/internal/cli"
+	"plexrenamer/internal/renamer"
+)
+
+// runRetryFailures implements the `retry-failures` subcommand, which tries
+// the operations that failed in a past run again, e.g. after fixing
+// permissions or freeing disk space, without planning the library again
+func runRetryFailures(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("retry-failures", flag.ExitOnError)
+	ef := addExecFlags(fs)
+	configPath := fs.String("config", defaultConfigPath(), "Config file the history is kept next to")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s retry-failures [options] <id|last|run.json>\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Try the operations that failed in a past run again. Runs are listed by the history subcommand.")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Options:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	opts, closeExec, err := ef.options()
+	if err != nil {
+		return err
+	}
+	defer closeExec()
+
+	dir := defaultHistoryDir(*configPath)
+	entry, err := findHistoryRun(dir, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if len(entry.Failures) == 0 {
+		pterm.Info.Printf("Run %s had no failed operations.\n", entry.ID)
+		return nil
+	}
+
+	mode := renamer.OperationMode(entry.Mode)
+	operations := make([]renamer.Operation, len(entry.Failures))
+	for i, f := range entry.Failures {
+		operations[i] = renamer.Operation{Source: f.Source, Destination: f.Destination, Mode: mode}
+	}
+
+	pterm.Info.Printf("Retrying %d failed operation(s) of run %s\n", len(operations), entry.ID)
+	if opts.DryRun {
+		pterm.Warning.Println("DRY RUN MODE - No files will be modified")
+	}
+	fmt.Println()
+
+	start := time.Now()
+	results := executeOperations(ctx, operations, opts, nil)
+	elapsed := time.Since(start)
+	cli.ShowResults(results, nil, elapsed)
+
+	// The retry is a run of its own, so what still fails can be retried again
+	config := &Config{DatabasePath: entry.Database, Mode: mode, ConfigPath: *configPath, DryRun: opts.DryRun}
+	recordHistory(config, results, nil, elapsed, ctx.Err() != nil)
+
+	if ctx.Err() != nil {
+		pterm.Warning.Printf("Cancelled after %d of %d operations\n", len(results), len(operations))
+		return ctx.Err()
+	}
+	for _, r := range results {
+		if r.Error != nil {
+			return fmt.Errorf("some operations failed again")
+		}
+	}
+	return nil
+}
+
+// findHistoryRun returns the run given by arg: a history file, the ID of a
+// run in the history in dir, or "last" for the latest one
+func findHistoryRun(dir, arg string) (historyRun, error) {
+	if info, err := os.Stat(arg); err == nil && !info.IsDir() {
+		var entry historyRun
+		data, err := os.ReadFile(arg)
+		if err != nil {
+			return entry, fmt.Errorf("failed to read run: %w", err)
+		}
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return entry, fmt.Errorf("failed to parse run %s: %w", arg, err)
+		}
+		return entry, nil
+	}
+
+	id := arg
+	if id == "last" {
+		ids, err := historyIDs(dir)
+		if err != nil {
+			return historyRun{}, err
+		}
+		if len(ids) == 0 {
+			return historyRun{}, fmt.Errorf("no runs recorded in %s yet", dir)
+		}
+		id = ids[len(ids)-1]
+	}
+	return loadHistoryRun(dir, id)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"plexrenamer/internal/renamer"
+)
+
+// sandbox is a directory shadowing the paths a run touches with empty
+// stand-ins of their files, for --sandbox. Operations executed on the
+// shadow paths create folders, meet existing files, and rename the way
+// they would on the real ones, without reading or changing any media.
+type sandbox struct {
+	root string
+}
+
+// newSandbox returns the sandbox at root, which must be empty or not exist,
+// so nothing but the stand-ins is in the way of the run
+func newSandbox(root string) (*sandbox, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve sandbox path: %w", err)
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read sandbox: %w", err)
+	}
+	if len(entries) > 0 {
+		return nil, fmt.Errorf("sandbox %s is not empty", root)
+	}
+	return &sandbox{root: root}, nil
+}
+
+// path returns where path is in the sandbox: under its root, by its
+// absolute path, with the drive letter or server and share of Windows
+// paths as folders
+func (s *sandbox) path(path string) string {
+	if path == "" {
+		return ""
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	volume := filepath.VolumeName(path)
+	folder := strings.Trim(strings.TrimSuffix(volume, ":"), `\/`)
+	return filepath.Join(s.root, folder, path[len(volume):])
+}
+
+// paths returns where each of paths is in the sandbox
+func (s *sandbox) paths(paths []string) []string {
+	shadowed := make([]string, len(paths))
+	for i, p := range paths {
+		shadowed[i] = s.path(p)
+	}
+	return shadowed
+}
+
+// populate creates stand-ins for the sources of the operations, with the
+// other files and folders next to them, which leftovers and emptied
+// folders are made of, and for destinations that already exist, which the
+// operations run into. Folder sources, and the folders of sources inside a
+// library root, are shadowed with all they hold; a root itself only with
+// the files in it, as leftovers and emptied folders are never looked for
+// in it. extra are other files the run handles, such as discarded versions.
+// Stand-ins of existing destinations have the size of the real file, as
+// sparse files, since whether they are replaced can depend on it.
+func (s *sandbox) populate(operations []renamer.Operation, roots, extra []string) (int, error) {
+	created := 0
+	// add creates the stand-in of path, of size bytes if it is a file
+	add := func(path string, dir bool, size int64) error {
+		shadow := s.path(path)
+		if _, err := os.Lstat(shadow); err == nil {
+			return nil
+		}
+		if dir {
+			if err := os.MkdirAll(shadow, 0755); err != nil {
+				return fmt.Errorf("failed to create sandbox folder: %w", err)
+			}
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(shadow), 0755); err != nil {
+			return fmt.Errorf("failed to create sandbox folder: %w", err)
+		}
+		f, err := os.Create(shadow)
+		if err != nil {
+			return fmt.Errorf("failed to create stand-in: %w", err)
+		}
+		created++
+		if size > 0 {
+			if err := f.Truncate(size); err != nil {
+				f.Close()
+				return fmt.Errorf("failed to size stand-in: %w", err)
+			}
+		}
+		return f.Close()
+	}
+	// addTree adds path, and everything in it if it is a folder, with the
+	// real sizes of files if sized
+	addTree := func(path string, sized bool) error {
+		return filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			var size int64
+			if info, err := d.Info(); err == nil && sized && info.Mode().IsRegular() {
+				size = info.Size()
+			}
+			return add(p, d.IsDir(), size)
+		})
+	}
+
+	dirs := map[string]bool{}
+	for _, op := range operations {
+		if op.Source != "" {
+			if err := addTree(op.Source, false); err != nil {
+				return created, err
+			}
+		}
+		if op.Destination != "" {
+			if err := addTree(op.Destination, true); err != nil {
+				return created, err
+			}
+		}
+		if dir := filepath.Dir(op.Source); op.Source != "" && !dirs[dir] {
+			dirs[dir] = true
+			if insideRoot(dir, roots) {
+				if err := addTree(dir, false); err != nil {
+					return created, err
+				}
+				continue
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				continue
+			}
+			for _, e := range entries {
+				if err := add(filepath.Join(dir, e.Name()), e.IsDir(), 0); err != nil {
+					return created, err
+				}
+			}
+		}
+	}
+	for _, path := range extra {
+		if err := addTree(path, false); err != nil {
+			return created, err
+		}
+	}
+	return created, nil
+}
+
+// insideRoot reports whether dir is inside one of roots, and not a root
+func insideRoot(dir string, roots []string) bool {
+	for _, root := range roots {
+		if rel, err := filepath.Rel(root, dir); err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// operations returns copies of the operations on the shadow paths
+func (s *sandbox) operations(operations []renamer.Operation) []renamer.Operation {
+	shadowed := make([]renamer.Operation, len(operations))
+	for i, op := range operations {
+		op.Source = s.path(op.Source)
+		op.Destination = s.path(op.Destination)
+		op.Staging = s.path(op.Staging)
+		shadowed[i] = op
+	}
+	return shadowed
+}
+
+// leftovers returns copies of the leftovers at their shadow paths
+func (s *sandbox) leftovers(leftovers []renamer.Leftover) []renamer.Leftover {
+	shadowed := make([]renamer.Leftover, len(leftovers))
+	for i, l := range leftovers {
+		l.Path = s.path(l.Path)
+		shadowed[i] = l
+	}
+	return shadowed
+}
+
+// enterSandbox populates the --sandbox directory for the operations and
+// returns the operations, library roots, discarded versions, and media
+// files on its shadow paths. Folders protected from cleanup are shadowed in
+// config.
+func enterSandbox(config *Config, operations []renamer.Operation, roots []string, discarded []renamer.Leftover, media []string) ([]renamer.Operation, []string, []renamer.Leftover, []string, error) {
+	s, err := newSandbox(config.Sandbox)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	extra := make([]string, len(discarded))
+	for i, d := range discarded {
+		extra[i] = d.Path
+	}
+	created, err := s.populate(operations, roots, extra)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	pterm.Info.Printf("Sandbox: created %d stand-in file(s) in %s; the media is left alone\n", created, s.root)
+	config.Protect = s.paths(config.Protect)
+	return s.operations(operations), s.paths(roots), s.leftovers(discarded), s.paths(media), nil
+}
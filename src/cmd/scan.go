@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"plexrenamer/internal/arr"
+	"plexrenamer/internal/database"
+	"plexrenamer/internal/renamer"
+)
+
+// scanLibraries builds a movie and a TV library from the names of the
+// video files under config.ScanDir, for organizing files no Plex server has
+// seen. With config.Lookup, the titles are looked up in Sonarr and Radarr.
+func scanLibraries(ctx context.Context, config *Config) ([]library, error) {
+	if !config.ScriptMode {
+		pterm.Info.Printf("Scanning directory: %s\n", config.ScanDir)
+	}
+	videos, err := renamer.ScanVideos(config.ScanDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", config.ScanDir, err)
+	}
+
+	movies := &database.LibraryContent{Section: database.LibrarySection{ID: 1, Name: "Movies", SectionType: database.SectionTypeMovie}}
+	shows := &database.LibraryContent{Section: database.LibrarySection{ID: 2, Name: "TV Shows", SectionType: database.SectionTypeShow}}
+
+	// IDs are hashes of what identifies the item or file, so they stay the
+	// same when files are added or removed, as --resume needs
+	newItem := func(key string, section int64, kind int, parent *int64, title string, year, index int) database.MetadataItem {
+		item := database.MetadataItem{ID: scanID(key), LibrarySectionID: section, MetadataType: kind, ParentID: parent, Title: title}
+		if year > 0 {
+			item.Year = &year
+		}
+		if kind == database.MediaTypeSeason || kind == database.MediaTypeEpisode {
+			item.Index = &index
+		}
+		return item
+	}
+	newFile := func(v renamer.ScannedVideo) database.MediaPart {
+		rel, err := filepath.Rel(config.ScanDir, v.Path)
+		if err != nil {
+			rel = v.Path
+		}
+		id := scanID("file|" + filepath.ToSlash(rel))
+		return database.MediaPart{ID: id, MediaItemID: id, File: v.Path, Size: v.Size, Height: v.Name.Height}
+	}
+
+	// Files with the same title and year are versions of one movie or
+	// episodes of one show
+	found := map[string]int{}
+	episodes := 0
+	for _, v := range videos {
+		name := v.Name
+		key := fmt.Sprintf("%s|%d", strings.ToLower(name.Title), name.Year)
+		if !name.IsEpisode() {
+			i, ok := found["movie|"+key]
+			if !ok {
+				i = len(movies.Movies)
+				found["movie|"+key] = i
+				movie := database.MovieInfo{Metadata: newItem("movie|"+key, 1, database.MediaTypeMovie, nil, name.Title, name.Year, 0)}
+				movie.Metadata.AddedAt = v.ModTime
+				movies.Movies = append(movies.Movies, movie)
+			}
+			movies.Movies[i].Files = append(movies.Movies[i].Files, newFile(v))
+			continue
+		}
+
+		i, ok := found["show|"+key]
+		if !ok {
+			i = len(shows.Shows)
+			found["show|"+key] = i
+			shows.Shows = append(shows.Shows, database.ShowInfo{Metadata: newItem("show|"+key, 2, database.MediaTypeShow, nil, name.Title, name.Year, 0)})
+		}
+		show := &shows.Shows[i]
+		showID := show.Metadata.ID
+		seasonKey := fmt.Sprintf("season|%s|%d", key, name.Season)
+		j, ok := found[seasonKey]
+		if !ok {
+			j = len(show.Seasons)
+			found[seasonKey] = j
+			season := newItem(seasonKey, 2, database.MediaTypeSeason, &showID, fmt.Sprintf("Season %d", name.Season), 0, name.Season)
+			show.Seasons = append(show.Seasons, database.SeasonInfo{Metadata: season})
+		}
+		season := &show.Seasons[j]
+		seasonID := season.Metadata.ID
+		episodeKey := fmt.Sprintf("%s|%d", seasonKey, name.Episode)
+		k, ok := found[episodeKey]
+		if !ok {
+			k = len(season.Episodes)
+			found[episodeKey] = k
+			// Plex names episodes it knows nothing about the same way
+			episode := newItem(episodeKey, 2, database.MediaTypeEpisode, &seasonID, fmt.Sprintf("Episode %d", name.Episode), 0, name.Episode)
+			episode.AddedAt = v.ModTime
+			season.Episodes = append(season.Episodes, database.EpisodeInfo{Metadata: episode})
+			episodes++
+		}
+		season.Episodes[k].Files = append(season.Episodes[k].Files, newFile(v))
+	}
+
+	if config.Lookup {
+		var showItems, movieItems []*database.MetadataItem
+		for i := range shows.Shows {
+			showItems = append(showItems, &shows.Shows[i].Metadata)
+		}
+		for i := range movies.Movies {
+			movieItems = append(movieItems, &movies.Movies[i].Metadata)
+		}
+		lookupTitles(ctx, config, arr.Sonarr, config.Sonarr, showItems)
+		lookupTitles(ctx, config, arr.Radarr, config.Radarr, movieItems)
+	}
+
+	if !config.ScriptMode {
+		pterm.Success.Printf("Found %d movie(s) and %d episode(s) of %d show(s)\n", len(movies.Movies), episodes, len(shows.Shows))
+	}
+
+	var libraries []library
+	for _, content := range []*database.LibraryContent{movies, shows} {
+		if len(content.Movies) == 0 && len(content.Shows) == 0 {
+			continue
+		}
+		content.Locations = []database.SectionLocation{{ID: content.Section.ID, LibrarySectionID: content.Section.ID, RootPath: config.ScanDir, Available: 1}}
+		libraries = append(libraries, library{section: content.Section, content: content, source: -1})
+	}
+	return libraries, nil
+}
+
+// scanID returns the ID of the scanned item or file identified by key: a
+// positive number from its FNV-1a hash
+func scanID(key string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return int64(h.Sum64() >> 1)
+}
+
+// lookupTitles replaces the parsed titles and years of the items with
+// those of their best match in Sonarr or Radarr, if it is configured, and
+// records the match's ID. A match must have the year the file name has, if
+// it has one.
+func lookupTitles(ctx context.Context, config *Config, kind arr.Kind, conf *arrConfig, items []*database.MetadataItem) {
+	if conf == nil || len(items) == 0 {
+		return
+	}
+	warn := func(format string, a ...any) {
+		if !config.ScriptMode {
+			pterm.Warning.Printf(format, a...)
+		}
+	}
+	client, err := arr.NewClient(kind, conf.URL, conf.APIKey)
+	if err != nil {
+		warn("%v\n", err)
+		return
+	}
+
+	for _, item := range items {
+		term := item.Title
+		if item.Year != nil {
+			term = fmt.Sprintf("%s %d", term, *item.Year)
+		}
+		matches, err := client.Lookup(ctx, term)
+		if err != nil {
+			warn("Not looking up titles in %s: %v\n", kind, err)
+			return
+		}
+		found := false
+		for _, m := range matches {
+			if item.Year != nil && m.Year != *item.Year {
+				continue
+			}
+			item.Title = m.Title
+			if m.Year > 0 {
+				year := m.Year
+				item.Year = &year
+			}
+			if m.GUID != "" {
+				item.GUID = m.GUID
+				item.ExternalIDs = []string{m.GUID}
+			}
+			found = true
+			break
+		}
+		if !found {
+			warn("%s found nothing for %q, keeping the name of the file\n", kind, term)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+// staleLockAge is how old a lock file must be before a new run takes it over,
+// in case a previous run was killed without removing it
+const staleLockAge = 12 * time.Hour
+
+// journalEntry is one line in the run journal
+type journalEntry struct {
+	Start      time.Time `json:"start"`
+	Duration   string    `json:"duration"`
+	Operations int       `json:"operations"`
+	Succeeded  int       `json:"succeeded"`
+	Skipped    int       `json:"skipped"`
+	Failed     int       `json:"failed"`
+	Failures   []string  `json:"failures,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// defaultJournalPath returns the run journal location next to the config file
+func defaultJournalPath(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), "journal.jsonl")
+}
+
+// runScheduled runs the configured job every time the schedule fires until
+// ctx is cancelled. A run that is still going when the next one is due makes
+// that one be skipped, and every run is appended to the journal.
+func runScheduled(ctx context.Context, config *Config) error {
+	lockPath := config.Journal + ".lock"
+	pterm.Info.Printf("Scheduled with %q, journaling runs to %s\n", config.Schedule, config.Journal)
+
+	for {
+		next := config.Schedule.Next(time.Now())
+		if next.IsZero() {
+			return fmt.Errorf("schedule %q never fires", config.Schedule)
+		}
+		pterm.Info.Printf("Next run at %s\n", next.Format("2006-01-02 15:04"))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			pterm.Info.Println("Stopping scheduler.")
+			return nil
+		case <-timer.C:
+		}
+
+		unlock, err := acquireLock(lockPath)
+		if err != nil {
+			pterm.Warning.Printf("Skipping run: %v\n", err)
+			continue
+		}
+		entry := scheduledRun(ctx, config)
+		unlock()
+
+		if err := appendJournal(config.Journal, entry); err != nil {
+			pterm.Warning.Println(err)
+		}
+		if ctx.Err() != nil {
+			pterm.Info.Println("Stopping scheduler.")
+			return nil
+		}
+	}
+}
+
+// scheduledRun performs a single run and records its outcome
+func scheduledRun(ctx context.Context, config *Config) journalEntry {
+	entry := journalEntry{Start: time.Now()}
+	pterm.Info.Printf("Starting scheduled run at %s\n", entry.Start.Format("2006-01-02 15:04:05"))
+
+	results, err := run(ctx, config)
+	entry.Duration = time.Since(entry.Start).Round(time.Second).String()
+	entry.Operations = len(results)
+	for _, r := range results {
+		switch {
+		case r.Error != nil:
+			entry.Failed++
+			entry.Failures = append(entry.Failures, fmt.Sprintf("%s: %v", r.Operation.Source, r.Error))
+		case r.Skipped:
+			entry.Skipped++
+		case r.Success:
+			entry.Succeeded++
+		}
+	}
+	if err != nil {
+		entry.Error = err.Error()
+		pterm.Error.Printf("Scheduled run failed: %v\n", err)
+	}
+	return entry
+}
+
+// acquireLock creates the lock file at path, failing if another run holds
+// it. Locks older than staleLockAge are taken over. The returned function
+// releases the lock.
+func acquireLock(path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			fmt.Fprintln(f, os.Getpid())
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to create lock file: %w", err)
+		}
+
+		info, statErr := os.Stat(path)
+		if statErr != nil || time.Since(info.ModTime()) < staleLockAge {
+			return nil, fmt.Errorf("a previous run is still in progress (lock file %s)", path)
+		}
+		pterm.Warning.Printf("Removing stale lock file %s\n", path)
+		os.Remove(path)
+	}
+	return nil, fmt.Errorf("failed to acquire lock file %s", path)
+}
+
+// appendJournal adds entry as a JSON line to the journal at path
+func appendJournal(path string, entry journalEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode journal entry: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,890 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"plexrenamer/internal/renamer"
+)
+
+// scriptPart describes which slice of the full plan a script file contains
+type scriptPart struct {
+	Offset int    // Index of the first operation in the full plan
+	Total  int    // Total operations in the full plan
+	Chunk  int    // 1-based chunk number (0 = single, unchunked script)
+	Chunks int    // Total number of chunks
+	Log    string // Operation log file name, relative to the script's directory
+}
+
+// scriptShell describes how to write scripts for a given shell
+type scriptShell struct {
+	Ext         string
+	Write       func(w io.Writer, operations []renamer.Operation, config *Config, part scriptPart)
+	WriteMaster func(w io.Writer, chunkFiles []string, config *Config, total int)
+}
+
+// lookupShell returns the script writer for a --shell value (defaults to cmd)
+func lookupShell(name string) scriptShell {
+	switch strings.ToLower(name) {
+	case "powershell", "pwsh", "ps", "ps1":
+		return scriptShell{Ext: ".ps1", Write: writeScriptPowerShell, WriteMaster: writeMasterPowerShell}
+	case "python", "python3", "py":
+		return scriptShell{Ext: ".py", Write: writeScriptPython, WriteMaster: writeMasterPython}
+	case "bash", "sh":
+		return scriptShell{Ext: ".sh", Write: writeScriptBash, WriteMaster: writeMasterBash}
+	default:
+		return scriptShell{Ext: ".bat", Write: writeScriptCmd, WriteMaster: writeMasterCmd}
+	}
+}
+
+// outputScript writes shell commands to a file
+func outputScript(operations []renamer.Operation, config *Config) error {
+	shell := lookupShell(config.ScriptShell)
+
+	// Determine output filename
+	outputFile := config.ScriptOutput
+	if outputFile == "" {
+		// In dry-run mode, output as .txt preview file
+		if config.DryRun {
+			outputFile = "rename_preview.txt"
+		} else {
+			outputFile = "rename" + shell.Ext
+		}
+	}
+
+	// With a manifest, the script is only a small runner that streams it
+	if !config.DryRun && config.Manifest != "" {
+		return outputManifestRunner(operations, config, outputFile)
+	}
+
+	// Large plans are split into numbered chunk scripts plus a master script
+	if !config.DryRun && config.ChunkSize > 0 && len(operations) > config.ChunkSize {
+		return outputChunkedScript(operations, config, shell, outputFile)
+	}
+
+	// Create the file
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return fmt.Errorf("failed to create script file: %w", err)
+	}
+	defer file.Close()
+
+	// Write script content
+	if config.DryRun {
+		// Write preview/text format for dry-run
+		writeScriptPreview(file, operations, config)
+	} else {
+		shell.Write(file, operations, config, scriptPart{
+			Total: len(operations),
+			Log:   operationLogName(outputFile),
+		})
+	}
+
+	// Print success message
+	absPath, _ := filepath.Abs(outputFile)
+	if config.DryRun {
+		pterm.Warning.Println("DRY RUN - Preview file generated (not executable)")
+		pterm.Success.Printf("Preview written to: %s\n", absPath)
+	} else {
+		pterm.Success.Printf("Script written to: %s\n", absPath)
+	}
+	pterm.Info.Printf("Total operations: %d\n", len(operations))
+	pterm.Info.Printf("Mode: %s\n", config.Mode)
+
+	return nil
+}
+
+// outputChunkedScript writes operations to numbered chunk scripts
+// (rename_001.sh, rename_002.sh, ...) and a master script that runs them in order
+func outputChunkedScript(operations []renamer.Operation, config *Config, shell scriptShell, masterFile string) error {
+	ext := filepath.Ext(masterFile)
+	base := strings.TrimSuffix(masterFile, ext)
+	chunks := (len(operations) + config.ChunkSize - 1) / config.ChunkSize
+
+	var chunkFiles []string
+	for i := 0; i < chunks; i++ {
+		start := i * config.ChunkSize
+		end := start + config.ChunkSize
+		if end > len(operations) {
+			end = len(operations)
+		}
+
+		chunkFile := fmt.Sprintf("%s_%03d%s", base, i+1, ext)
+		file, err := os.Create(chunkFile)
+		if err != nil {
+			return fmt.Errorf("failed to create script file: %w", err)
+		}
+		shell.Write(file, operations[start:end], config, scriptPart{
+			Offset: start,
+			Total:  len(operations),
+			Chunk:  i + 1,
+			Chunks: chunks,
+			Log:    operationLogName(masterFile),
+		})
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("failed to write script file: %w", err)
+		}
+		chunkFiles = append(chunkFiles, filepath.Base(chunkFile))
+	}
+
+	file, err := os.Create(masterFile)
+	if err != nil {
+		return fmt.Errorf("failed to create script file: %w", err)
+	}
+	defer file.Close()
+	shell.WriteMaster(file, chunkFiles, config, len(operations))
+
+	absPath, _ := filepath.Abs(masterFile)
+	pterm.Success.Printf("Master script written to: %s\n", absPath)
+	pterm.Info.Printf("Chunks: %d (%d operations each)\n", chunks, config.ChunkSize)
+	pterm.Info.Printf("Total operations: %d\n", len(operations))
+	pterm.Info.Printf("Mode: %s\n", config.Mode)
+
+	return nil
+}
+
+// outputManifest writes operations to the --manifest file
+func outputManifest(operations []renamer.Operation, config *Config) error {
+	file, err := os.Create(config.Manifest)
+	if err != nil {
+		return fmt.Errorf("failed to create manifest: %w", err)
+	}
+	defer file.Close()
+
+	if err := renamer.WriteManifest(file, operations); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	absPath, _ := filepath.Abs(config.Manifest)
+	pterm.Success.Printf("Manifest written to: %s\n", absPath)
+	pterm.Info.Printf("Total operations: %d\n", len(operations))
+	pterm.Info.Printf("Mode: %s\n", config.Mode)
+	pterm.Info.Printf("Run it with: %s exec %s\n", filepath.Base(os.Args[0]), config.Manifest)
+
+	return nil
+}
+
+// outputManifestRunner writes the --manifest file plus a runner script that
+// streams it. Only bash can read NUL-delimited input natively; other shells
+// should use the exec subcommand instead.
+func outputManifestRunner(operations []renamer.Operation, config *Config, scriptFile string) error {
+	if lookupShell(config.ScriptShell).Ext != ".sh" {
+		return fmt.Errorf("manifest runner scripts are only available for bash; run the manifest with '%s exec %s' instead",
+			filepath.Base(os.Args[0]), config.Manifest)
+	}
+
+	if err := outputManifest(operations, config); err != nil {
+		return err
+	}
+
+	// Reference the manifest relative to the script, so both can be moved together
+	manifestPath, _ := filepath.Abs(config.Manifest)
+	scriptDir, _ := filepath.Abs(filepath.Dir(scriptFile))
+	if rel, err := filepath.Rel(scriptDir, manifestPath); err == nil {
+		manifestPath = rel
+	}
+
+	file, err := os.Create(scriptFile)
+	if err != nil {
+		return fmt.Errorf("failed to create script file: %w", err)
+	}
+	defer file.Close()
+	writeManifestRunnerBash(file, filepath.ToSlash(manifestPath), operations, config, operationLogName(scriptFile))
+
+	absPath, _ := filepath.Abs(scriptFile)
+	pterm.Success.Printf("Runner script written to: %s\n", absPath)
+
+	return nil
+}
+
+// chunkLogName returns the log file name for a chunk script
+func chunkLogName(chunkFile string) string {
+	return strings.TrimSuffix(chunkFile, filepath.Ext(chunkFile)) + ".log"
+}
+
+// operationLogName returns the name of the per-operation log shared by a
+// script and all of its chunks (rename.sh -> rename.log)
+func operationLogName(scriptFile string) string {
+	return chunkLogName(filepath.Base(scriptFile))
+}
+
+// operationKey returns a stable identifier for an operation, used by generated
+// scripts to recognize operations already recorded as done in the log
+func operationKey(op renamer.Operation) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s\x00%s\x00%s", op.Mode, op.Source, op.Destination)
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+func writeScriptPreview(w io.Writer, operations []renamer.Operation, config *Config) {
+	fmt.Fprintln(w, "============================================")
+	fmt.Fprintln(w, "Plex File Renamer - DRY RUN PREVIEW")
+	fmt.Fprintln(w, "============================================")
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Mode: %s\n", config.Mode)
+	fmt.Fprintf(w, "Output directory: %s\n", outputDirs(config))
+	fmt.Fprintf(w, "Total operations: %d\n", len(operations))
+	for _, m := range config.PathMaps {
+		fmt.Fprintf(w, "Path mapping: %s -> %s\n", m.From, m.To)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "This is a PREVIEW - no files will be modified.")
+	fmt.Fprintln(w, "Remove --dry-run flag to generate an executable script.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "============================================")
+	fmt.Fprintln(w, "PLANNED OPERATIONS")
+	fmt.Fprintln(w, "============================================")
+	fmt.Fprintln(w)
+
+	for i, op := range operations {
+		fmt.Fprintf(w, "[%d] %s\n", i+1, op.Mode)
+		fmt.Fprintf(w, "    From: %s\n", op.Source)
+		fmt.Fprintf(w, "    To:   %s\n", op.Destination)
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintln(w, "============================================")
+	fmt.Fprintf(w, "Total: %d operations\n", len(operations))
+	fmt.Fprintln(w, "============================================")
+}
+
+// writeScriptHeader writes the common comment header using the given comment prefix
+func writeScriptHeader(w io.Writer, comment string, operations []renamer.Operation, config *Config, part scriptPart) {
+	fmt.Fprintf(w, "%s ============================================\n", comment)
+	fmt.Fprintf(w, "%s Generated by Plex File Renamer\n", comment)
+	fmt.Fprintf(w, "%s ============================================\n", comment)
+	fmt.Fprintln(w, comment)
+	fmt.Fprintf(w, "%s Mode: %s\n", comment, config.Mode)
+	fmt.Fprintf(w, "%s Output directory: %s\n", comment, outputDirs(config))
+	if part.Chunk > 0 {
+		fmt.Fprintf(w, "%s Chunk: %d of %d (operations %d-%d of %d)\n", comment,
+			part.Chunk, part.Chunks, part.Offset+1, part.Offset+len(operations), part.Total)
+	} else {
+		fmt.Fprintf(w, "%s Total operations: %d\n", comment, len(operations))
+	}
+	for _, m := range config.PathMaps {
+		fmt.Fprintf(w, "%s Path mapping: %s -> %s\n", comment, m.From, m.To)
+	}
+	fmt.Fprintln(w, comment)
+	fmt.Fprintf(w, "%s This script will skip files that already exist at destination.\n", comment)
+	fmt.Fprintf(w, "%s Each operation is logged to %s (status, source, destination);\n", comment, part.Log)
+	fmt.Fprintf(w, "%s operations logged as OK or SKIPPED are skipped when the script is re-run.\n", comment)
+	fmt.Fprintf(w, "%s ============================================\n", comment)
+	fmt.Fprintln(w)
+}
+
+func writeScriptCmd(w io.Writer, operations []renamer.Operation, config *Config, part scriptPart) {
+	fmt.Fprintln(w, "@echo off")
+	writeScriptHeader(w, "REM", operations, config, part)
+	writeCmdLowPriority(w, config)
+
+	// Read the rest of the script as UTF-8, and keep ! literal in paths
+	fmt.Fprintln(w, "chcp 65001 >nul")
+	fmt.Fprintln(w, "setlocal DisableDelayedExpansion")
+	fmt.Fprintf(w, "set \"LOG=%%~dp0%s\"\n", strings.ReplaceAll(part.Log, "%", "%%"))
+	fmt.Fprintln(w, "set /a OK_COUNT=0, SKIPPED_COUNT=0, FAILED_COUNT=0")
+	fmt.Fprintln(w)
+
+	for i, op := range operations {
+		src := cmdQuote(op.Source)
+		dst := cmdQuote(op.Destination)
+		destDir := cmdQuote(filepath.Dir(op.Destination))
+		key := operationKey(op)
+		label := fmt.Sprintf("done_%d", part.Offset+i+1)
+
+		// Print progress
+		fmt.Fprintf(w, "echo [%d/%d] %s\n", part.Offset+i+1, part.Total, config.Mode)
+		fmt.Fprintf(w, "echo   From: %s\n", escapeCmdPath(op.Source))
+		fmt.Fprintf(w, "echo   To:   %s\n", escapeCmdPath(op.Destination))
+
+		// Skip operations already recorded as done by a previous run
+		fmt.Fprintf(w, "findstr /l /b /c:\"OK %s \" /c:\"SKIPPED %s \" \"%%LOG%%\" >nul 2>&1 && (echo   Already done, skipping) && (set /a SKIPPED_COUNT+=1) && goto %s\n", key, key, label)
+
+		fmt.Fprintf(w, "if not exist %s mkdir %s\n", destDir, destDir)
+
+		command := "move"
+		if config.Mode == renamer.ModeCopy {
+			command = "copy"
+		}
+		fmt.Fprintln(w, "set \"STATUS=OK\"")
+		fmt.Fprintf(w, "if exist %s (set \"STATUS=SKIPPED\") else (%s %s %s || set \"STATUS=FAILED\")\n", dst, command, src, dst)
+		fmt.Fprintln(w, "set /a %STATUS%_COUNT+=1")
+		fmt.Fprintln(w, "if \"%STATUS%\"==\"FAILED\" echo   Error: operation failed")
+		fmt.Fprintf(w, ">>\"%%LOG%%\" echo %%STATUS%% %s %s -^> %s\n", key, escapeCmdPath(op.Source), escapeCmdPath(op.Destination))
+		fmt.Fprintf(w, ":%s\n", label)
+	}
+
+	// Chunks are run by the master script, which pauses once at the end
+	pause := part.Chunk == 0
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "echo.")
+	fmt.Fprintf(w, "echo Completed %d operations: %%OK_COUNT%% done, %%SKIPPED_COUNT%% skipped, %%FAILED_COUNT%% failed.\n", len(operations))
+	fmt.Fprintf(w, "if %%FAILED_COUNT%% gtr 0 (\n")
+	fmt.Fprintf(w, "  echo Some operations failed, see %s\n", escapeCmdPath(part.Log))
+	if pause {
+		fmt.Fprintln(w, "  pause")
+	}
+	fmt.Fprintln(w, "  exit /b 1")
+	fmt.Fprintln(w, ")")
+	if pause {
+		fmt.Fprintln(w, "pause")
+	}
+}
+
+// writeCmdLowPriority writes the cmd snippet that runs the script again with
+// below normal priority, for --low-priority. Chunks called by the master
+// script find it already lowered.
+func writeCmdLowPriority(w io.Writer, config *Config) {
+	if !config.LowPriority {
+		return
+	}
+	fmt.Fprintln(w, "if not defined PLEXRENAMER_LOW (")
+	fmt.Fprintln(w, "  set \"PLEXRENAMER_LOW=1\"")
+	fmt.Fprintln(w, "  start \"\" /belownormal /b /wait cmd /c \"\"%~f0\"\"")
+	fmt.Fprintln(w, "  exit /b")
+	fmt.Fprintln(w, ")")
+	fmt.Fprintln(w)
+}
+
+func writeMasterCmd(w io.Writer, chunkFiles []string, config *Config, total int) {
+	fmt.Fprintln(w, "@echo off")
+	fmt.Fprintln(w, "REM ============================================")
+	fmt.Fprintln(w, "REM Generated by Plex File Renamer")
+	fmt.Fprintln(w, "REM ============================================")
+	fmt.Fprintln(w, "REM")
+	fmt.Fprintf(w, "REM Mode: %s\n", config.Mode)
+	fmt.Fprintf(w, "REM Total operations: %d in %d chunks\n", total, len(chunkFiles))
+	fmt.Fprintln(w, "REM Each chunk writes its output to a matching .log file.")
+	fmt.Fprintln(w, "REM It stops at the first chunk with failures; run it with /keep-going")
+	fmt.Fprintln(w, "REM to run the remaining chunks anyway.")
+	fmt.Fprintln(w, "REM ============================================")
+	fmt.Fprintln(w)
+	writeCmdLowPriority(w, config)
+	fmt.Fprintln(w, "chcp 65001 >nul")
+	fmt.Fprintln(w, "setlocal DisableDelayedExpansion")
+	fmt.Fprintln(w, "cd /d \"%~dp0\"")
+	fmt.Fprintln(w, "set KEEP_GOING=")
+	fmt.Fprintln(w, "if /i \"%~1\"==\"/keep-going\" set KEEP_GOING=1")
+	fmt.Fprintln(w, "set /a FAILED_CHUNKS=0")
+
+	for i, chunk := range chunkFiles {
+		name := escapeCmdPath(chunk)
+		log := escapeCmdPath(chunkLogName(chunk))
+		fmt.Fprintf(w, "echo [chunk %d/%d] %s\n", i+1, len(chunkFiles), name)
+		fmt.Fprintf(w, "call %s > %s 2>&1\n", cmdQuote(chunk), cmdQuote(chunkLogName(chunk)))
+		fmt.Fprintf(w, "if errorlevel 1 (\n  echo   Chunk %s had failures, see %s\n  set /a FAILED_CHUNKS+=1\n  if not defined KEEP_GOING goto stop\n)\n", name, log)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "goto done")
+	fmt.Fprintln(w, ":stop")
+	fmt.Fprintln(w, "echo Stopped at the first chunk with failures; run with /keep-going to run the remaining chunks anyway.")
+	fmt.Fprintln(w, ":done")
+	fmt.Fprintln(w, "echo.")
+	fmt.Fprintf(w, "echo Completed %d chunks, %%FAILED_CHUNKS%% with failures.\n", len(chunkFiles))
+	fmt.Fprintln(w, "pause")
+	fmt.Fprintf(w, "if %%FAILED_CHUNKS%% gtr 0 exit /b 1\n")
+}
+
+func writeScriptPowerShell(w io.Writer, operations []renamer.Operation, config *Config, part scriptPart) {
+	writeScriptHeader(w, "#", operations, config, part)
+	writePowerShellLowPriority(w, config)
+
+	command := "Move-Item"
+	if config.Mode == renamer.ModeCopy {
+		command = "Copy-Item"
+	}
+
+	// Load operations recorded as done by a previous run
+	fmt.Fprintf(w, "$Log = Join-Path $PSScriptRoot %s\n", psQuote(part.Log))
+	fmt.Fprintln(w, "$Done = @{}")
+	fmt.Fprintln(w, "$Counts = @{ OK = 0; SKIPPED = 0; FAILED = 0 }")
+	fmt.Fprintln(w, "if (Test-Path -LiteralPath $Log) {")
+	fmt.Fprintln(w, "    Get-Content -LiteralPath $Log | ForEach-Object {")
+	fmt.Fprintln(w, "        $f = $_.Split(' ', 3)")
+	fmt.Fprintln(w, "        if ($f[0] -eq 'OK' -or $f[0] -eq 'SKIPPED') { $Done[$f[1]] = $true }")
+	fmt.Fprintln(w, "    }")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "function Invoke-Operation($Key, $Src, $Dst) {")
+	fmt.Fprintln(w, "    if ($Done.ContainsKey($Key)) { Write-Host '  Already done, skipping'; $Counts.SKIPPED++; return }")
+	fmt.Fprintln(w, "    $status = 'OK'")
+	fmt.Fprintln(w, "    try {")
+	fmt.Fprintln(w, "        $dir = Split-Path -Parent $Dst")
+	fmt.Fprintln(w, "        if (-not (Test-Path -LiteralPath $dir)) { New-Item -ItemType Directory -Path $dir -Force | Out-Null }")
+	fmt.Fprintln(w, "        if (Test-Path -LiteralPath $Dst) { $status = 'SKIPPED' }")
+	fmt.Fprintf(w, "        else { %s -LiteralPath $Src -Destination $Dst -ErrorAction Stop }\n", command)
+	fmt.Fprintln(w, "    } catch {")
+	fmt.Fprintln(w, "        $status = 'FAILED'")
+	fmt.Fprintln(w, "        Write-Host \"  Error: $_\"")
+	fmt.Fprintln(w, "    }")
+	fmt.Fprintln(w, "    $Counts[$status]++")
+	fmt.Fprintln(w, "    Add-Content -LiteralPath $Log -Value \"$status $Key $Src -> $Dst\"")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w)
+
+	for i, op := range operations {
+		src := psQuote(op.Source)
+		dst := psQuote(op.Destination)
+
+		// Print progress
+		fmt.Fprintf(w, "Write-Host '[%d/%d] %s'\n", part.Offset+i+1, part.Total, config.Mode)
+		fmt.Fprintf(w, "Write-Host ('  From: ' + %s)\n", src)
+		fmt.Fprintf(w, "Write-Host ('  To:   ' + %s)\n", dst)
+
+		fmt.Fprintf(w, "Invoke-Operation '%s' %s %s\n", operationKey(op), src, dst)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Write-Host \"Completed %d operations: $($Counts.OK) done, $($Counts.SKIPPED) skipped, $($Counts.FAILED) failed.\"\n", len(operations))
+	fmt.Fprintf(w, "if ($Counts.FAILED -gt 0) { Write-Host ('Some operations failed, see ' + %s); exit 1 }\n", psQuote(part.Log))
+}
+
+// writePowerShellLowPriority writes the PowerShell snippet that lowers the
+// priority of the script, for --low-priority
+func writePowerShellLowPriority(w io.Writer, config *Config) {
+	if config.LowPriority {
+		fmt.Fprintln(w, "(Get-Process -Id $PID).PriorityClass = 'BelowNormal'")
+		fmt.Fprintln(w)
+	}
+}
+
+func writeMasterPowerShell(w io.Writer, chunkFiles []string, config *Config, total int) {
+	fmt.Fprintln(w, "# ============================================")
+	fmt.Fprintln(w, "# Generated by Plex File Renamer")
+	fmt.Fprintln(w, "# ============================================")
+	fmt.Fprintln(w, "#")
+	fmt.Fprintf(w, "# Mode: %s\n", config.Mode)
+	fmt.Fprintf(w, "# Total operations: %d in %d chunks\n", total, len(chunkFiles))
+	fmt.Fprintln(w, "# Each chunk writes its output to a matching .log file.")
+	fmt.Fprintln(w, "# It stops at the first chunk with failures; run it with -KeepGoing")
+	fmt.Fprintln(w, "# to run the remaining chunks anyway.")
+	fmt.Fprintln(w, "# ============================================")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "param([switch]$KeepGoing)")
+	fmt.Fprintln(w)
+
+	writePowerShellLowPriority(w, config)
+	// Each chunk runs in a PowerShell process of its own, the same program
+	// as this one, so its exit code is that of the chunk
+	fmt.Fprintln(w, "$PowerShell = (Get-Process -Id $PID).Path")
+	fmt.Fprintln(w, "$FailedChunks = 0")
+	fmt.Fprintln(w, "function Invoke-Chunk($Name, $Log) {")
+	fmt.Fprintln(w, "    & $PowerShell -NoProfile -ExecutionPolicy Bypass -File (Join-Path $PSScriptRoot $Name) *> (Join-Path $PSScriptRoot $Log)")
+	fmt.Fprintln(w, "    $code = $LASTEXITCODE")
+	fmt.Fprintln(w, "    if ($code -eq 0) { return }")
+	fmt.Fprintln(w, "    Write-Host ('  Chunk ' + $Name + ' had failures, see ' + $Log)")
+	fmt.Fprintln(w, "    $script:FailedChunks++")
+	fmt.Fprintln(w, "    if (-not $KeepGoing) {")
+	fmt.Fprintln(w, "        Write-Host 'Stopped at the first chunk with failures; run with -KeepGoing to run the remaining chunks anyway.'")
+	fmt.Fprintln(w, "        exit $code")
+	fmt.Fprintln(w, "    }")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w)
+	for i, chunk := range chunkFiles {
+		name := psQuote(chunk)
+		fmt.Fprintf(w, "Write-Host ('[chunk %d/%d] ' + %s)\n", i+1, len(chunkFiles), name)
+		fmt.Fprintf(w, "Invoke-Chunk %s %s\n", name, psQuote(chunkLogName(chunk)))
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Write-Host \"Completed %d chunks, $FailedChunks with failures.\"\n", len(chunkFiles))
+	fmt.Fprintln(w, "if ($FailedChunks -gt 0) { exit 1 }")
+}
+
+func writeScriptBash(w io.Writer, operations []renamer.Operation, config *Config, part scriptPart) {
+	fmt.Fprintln(w, "#!/bin/bash")
+	writeScriptHeader(w, "#", operations, config, part)
+
+	command := "mv"
+	if config.Mode == renamer.ModeCopy {
+		command = "cp"
+	}
+
+	fmt.Fprintln(w, "set -u")
+	writeBashLowPriority(w, config)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "MODE=%s\n", bashQuote(string(config.Mode)))
+	fmt.Fprintf(w, "OFFSET=%d\n", part.Offset)
+	fmt.Fprintf(w, "TOTAL=%d\n", part.Total)
+	fmt.Fprintf(w, "LOG=\"$(dirname \"$0\")/\"%s\n", bashQuote(part.Log))
+	fmt.Fprintln(w, "touch \"$LOG\"")
+	fmt.Fprintln(w, "ok=0 skipped=0 failed=0")
+	fmt.Fprintln(w)
+
+	// Paths are only ever stored as array elements and expanded in double
+	// quotes, so no filename character can be interpreted by the shell
+	fmt.Fprintln(w, "# key, source, destination")
+	fmt.Fprintln(w, "OPS=(")
+	for _, op := range operations {
+		fmt.Fprintf(w, "    %s %s %s\n", bashQuote(operationKey(op)), bashQuote(op.Source), bashQuote(op.Destination))
+	}
+	fmt.Fprintln(w, ")")
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "run_op() {")
+	fmt.Fprintln(w, "    local key=\"$1\" src=\"$2\" dst=\"$3\" status=OK rc")
+	fmt.Fprintln(w, "    if grep -qE \"^(OK|SKIPPED) $key \" \"$LOG\"; then")
+	fmt.Fprintln(w, "        echo '  Already done, skipping'")
+	fmt.Fprintln(w, "        skipped=$((skipped + 1))")
+	fmt.Fprintln(w, "        return 0")
+	fmt.Fprintln(w, "    fi")
+	fmt.Fprintln(w, "    mkdir -p -- \"$(dirname -- \"$dst\")\"")
+	fmt.Fprintln(w, "    if [ -e \"$dst\" ]; then")
+	fmt.Fprintln(w, "        status=SKIPPED")
+	fmt.Fprintln(w, "    else")
+	fmt.Fprintf(w, "        %s -- \"$src\" \"$dst\"\n", command)
+	fmt.Fprintln(w, "        rc=$?")
+	fmt.Fprintln(w, "        [ $rc -ne 0 ] && status=\"FAILED:$rc\"")
+	fmt.Fprintln(w, "    fi")
+	writeBashCount(w)
+	io.WriteString(w, "    printf '%s %s %s -> %s\\n' \"$status\" \"$key\" \"$src\" \"$dst\" >> \"$LOG\"\n")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "for ((i = 0; i < ${#OPS[@]}; i += 3)); do")
+	io.WriteString(w, "    printf '[%d/%d] %s\\n' $((OFFSET + i / 3 + 1)) \"$TOTAL\" \"$MODE\"\n")
+	io.WriteString(w, "    printf '  From: %s\\n  To:   %s\\n' \"${OPS[i+1]}\" \"${OPS[i+2]}\"\n")
+	fmt.Fprintln(w, "    run_op \"${OPS[i]}\" \"${OPS[i+1]}\" \"${OPS[i+2]}\"")
+	fmt.Fprintln(w, "done")
+	fmt.Fprintln(w)
+	writeBashSummary(w, fmt.Sprint(len(operations)), part.Log)
+}
+
+// writeBashLowPriority writes the bash snippet that gives the script the
+// lowest CPU and disk priority, for --low-priority. ionice only exists on
+// Linux, so failures are ignored.
+func writeBashLowPriority(w io.Writer, config *Config) {
+	if config.LowPriority {
+		fmt.Fprintln(w, "renice -n 19 -p $$ >/dev/null 2>&1")
+		fmt.Fprintln(w, "ionice -c 2 -n 7 -p $$ >/dev/null 2>&1")
+	}
+}
+
+// writeBashCount writes the bash snippet that counts an operation by $status
+func writeBashCount(w io.Writer) {
+	fmt.Fprintln(w, "    case \"$status\" in")
+	fmt.Fprintln(w, "        OK) ok=$((ok + 1)) ;;")
+	fmt.Fprintln(w, "        SKIPPED) skipped=$((skipped + 1)) ;;")
+	fmt.Fprintln(w, "        *) failed=$((failed + 1)); echo \"  Error: $status\" >&2 ;;")
+	fmt.Fprintln(w, "    esac")
+}
+
+// writeBashSummary writes the final bash summary, exiting non-zero on failures
+func writeBashSummary(w io.Writer, total, logName string) {
+	io.WriteString(w, "printf 'Completed %s operations: %d done, %d skipped, %d failed.\\n' "+total+" \"$ok\" \"$skipped\" \"$failed\"\n")
+	fmt.Fprintf(w, "if [ \"$failed\" -gt 0 ]; then\n    echo 'Some operations failed, see' %s\n    exit 1\nfi\n", bashQuote(logName))
+}
+
+func writeMasterBash(w io.Writer, chunkFiles []string, config *Config, total int) {
+	fmt.Fprintln(w, "#!/bin/bash")
+	fmt.Fprintln(w, "# ============================================")
+	fmt.Fprintln(w, "# Generated by Plex File Renamer")
+	fmt.Fprintln(w, "# ============================================")
+	fmt.Fprintln(w, "#")
+	fmt.Fprintf(w, "# Mode: %s\n", config.Mode)
+	fmt.Fprintf(w, "# Total operations: %d in %d chunks\n", total, len(chunkFiles))
+	fmt.Fprintln(w, "# Each chunk writes its output to a matching .log file.")
+	fmt.Fprintln(w, "# It stops at the first chunk with failures; run it with --keep-going")
+	fmt.Fprintln(w, "# to run the remaining chunks anyway.")
+	fmt.Fprintln(w, "# ============================================")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "set -u")
+	fmt.Fprintln(w, "cd \"$(dirname \"$0\")\" || exit 1")
+	fmt.Fprintln(w, "keep_going=0")
+	fmt.Fprintln(w, "[ \"${1:-}\" = --keep-going ] && keep_going=1")
+	fmt.Fprintln(w, "failed_chunks=0")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "run_chunk() {")
+	fmt.Fprintln(w, "    bash \"$1\" > \"$2\" 2>&1")
+	fmt.Fprintln(w, "    local rc=$?")
+	fmt.Fprintln(w, "    [ \"$rc\" -eq 0 ] && return")
+	fmt.Fprintln(w, "    echo \"  Chunk had failures, see $2\"")
+	fmt.Fprintln(w, "    failed_chunks=$((failed_chunks + 1))")
+	fmt.Fprintln(w, "    if [ \"$keep_going\" -eq 0 ]; then")
+	fmt.Fprintln(w, "        echo 'Stopped at the first chunk with failures; run with --keep-going to run the remaining chunks anyway.'")
+	fmt.Fprintln(w, "        exit \"$rc\"")
+	fmt.Fprintln(w, "    fi")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w)
+
+	for i, chunk := range chunkFiles {
+		name := bashQuote("./" + chunk)
+		fmt.Fprintf(w, "echo '[chunk %d/%d]' %s\n", i+1, len(chunkFiles), name)
+		fmt.Fprintf(w, "run_chunk %s %s\n", name, bashQuote("./"+chunkLogName(chunk)))
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "echo \"Completed %d chunks, $failed_chunks with failures.\"\n", len(chunkFiles))
+	fmt.Fprintln(w, "[ \"$failed_chunks\" -eq 0 ] || exit 1")
+}
+
+func writeScriptPython(w io.Writer, operations []renamer.Operation, config *Config, part scriptPart) {
+	fmt.Fprintln(w, "#!/usr/bin/env python3")
+	writeScriptHeader(w, "#", operations, config, part)
+	fmt.Fprintln(w, "# Run with --dry-run to show what would be done without changing anything.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "import argparse")
+	fmt.Fprintln(w, "import os")
+	fmt.Fprintln(w, "import shutil")
+	fmt.Fprintln(w, "import subprocess")
+	fmt.Fprintln(w, "import sys")
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "MODE = %s\n", pyQuote(string(config.Mode)))
+	if config.LowPriority {
+		fmt.Fprintln(w, "LOW_PRIORITY = True")
+	} else {
+		fmt.Fprintln(w, "LOW_PRIORITY = False")
+	}
+	fmt.Fprintf(w, "LOG_NAME = %s\n", pyQuote(part.Log))
+	fmt.Fprintf(w, "OFFSET = %d\n", part.Offset)
+	fmt.Fprintf(w, "TOTAL = %d\n", part.Total)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "# (key, source, destination)")
+	fmt.Fprintln(w, "OPERATIONS = [")
+	for _, op := range operations {
+		fmt.Fprintf(w, "    (%s, %s, %s),\n", pyQuote(operationKey(op)), pyQuote(op.Source), pyQuote(op.Destination))
+	}
+	fmt.Fprintln(w, "]")
+	io.WriteString(w, pythonRunner)
+}
+
+// pythonRunner is the static body of generated Python scripts
+const pythonRunner = `
+
+def load_done(log_path):
+    """Return the keys of operations logged as OK or SKIPPED by a previous run."""
+    done = set()
+    if not os.path.exists(log_path):
+        return done
+    with open(log_path, encoding="utf-8", errors="replace") as log:
+        for line in log:
+            fields = line.split(" ", 2)
+            if len(fields) >= 2 and fields[0] in ("OK", "SKIPPED"):
+                done.add(fields[1])
+    return done
+
+
+def lower_priority():
+    """Run with the lowest CPU and disk priority, so Plex stays responsive."""
+    if sys.platform == "win32":
+        import ctypes
+        kernel32 = ctypes.windll.kernel32
+        kernel32.SetPriorityClass(kernel32.GetCurrentProcess(), 0x4000)  # BELOW_NORMAL_PRIORITY_CLASS
+        return
+    try:
+        os.nice(19)
+    except OSError:
+        pass
+    try:
+        subprocess.call(["ionice", "-c", "2", "-n", "7", "-p", str(os.getpid())],
+                        stdout=subprocess.DEVNULL, stderr=subprocess.DEVNULL)
+    except OSError:
+        pass  # ionice only exists on Linux
+
+
+def run_operation(src, dst):
+    os.makedirs(os.path.dirname(dst) or ".", exist_ok=True)
+    if MODE == "copy":
+        shutil.copy2(src, dst)
+    else:
+        shutil.move(src, dst)
+
+
+def main():
+    parser = argparse.ArgumentParser(description="Generated by Plex File Renamer")
+    parser.add_argument("--dry-run", action="store_true",
+                        help="show what would be done without changing anything")
+    args = parser.parse_args()
+    if LOW_PRIORITY:
+        lower_priority()
+
+    # Never crash on filenames the console encoding can't represent
+    if hasattr(sys.stdout, "reconfigure"):
+        sys.stdout.reconfigure(errors="replace")
+        sys.stderr.reconfigure(errors="replace")
+
+    log_path = os.path.join(os.path.dirname(os.path.abspath(__file__)), LOG_NAME)
+    done = load_done(log_path)
+    # Names that aren't UTF-8 are logged with their own bytes
+    log = None if args.dry_run else open(log_path, "a", encoding="utf-8", errors="surrogateescape")
+
+    completed = skipped = failed = 0
+    for i, (key, src, dst) in enumerate(OPERATIONS, OFFSET + 1):
+        print("[%d/%d] %s" % (i, TOTAL, MODE))
+        print("  From: " + src)
+        print("  To:   " + dst)
+
+        if key in done:
+            print("  Already done, skipping")
+            skipped += 1
+            continue
+
+        if os.path.exists(dst):
+            status = "SKIPPED"
+            skipped += 1
+        elif args.dry_run:
+            if not os.path.exists(src):
+                print("  Warning: source does not exist", file=sys.stderr)
+            completed += 1
+            continue
+        else:
+            try:
+                run_operation(src, dst)
+                status = "OK"
+                completed += 1
+            except OSError as e:
+                status = "FAILED"
+                failed += 1
+                print("  Error: %s" % e, file=sys.stderr)
+
+        if log:
+            log.write("%s %s %s -> %s\n" % (status, key, src, dst))
+            log.flush()
+
+    if log:
+        log.close()
+
+    print()
+    print("Completed %d operations: %d done, %d skipped, %d failed%s." % (
+        len(OPERATIONS), completed, skipped, failed, " [dry run]" if args.dry_run else ""))
+    if failed:
+        print("Some operations failed, see " + LOG_NAME)
+        return 1
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
+`
+
+func writeMasterPython(w io.Writer, chunkFiles []string, config *Config, total int) {
+	fmt.Fprintln(w, "#!/usr/bin/env python3")
+	fmt.Fprintln(w, "# ============================================")
+	fmt.Fprintln(w, "# Generated by Plex File Renamer")
+	fmt.Fprintln(w, "# ============================================")
+	fmt.Fprintln(w, "#")
+	fmt.Fprintf(w, "# Mode: %s\n", config.Mode)
+	fmt.Fprintf(w, "# Total operations: %d in %d chunks\n", total, len(chunkFiles))
+	fmt.Fprintln(w, "# Each chunk writes its output to a matching .log file.")
+	fmt.Fprintln(w, "# Run with --dry-run to show what would be done without changing anything.")
+	fmt.Fprintln(w, "# It stops at the first chunk with failures; run it with --keep-going")
+	fmt.Fprintln(w, "# to run the remaining chunks anyway.")
+	fmt.Fprintln(w, "# ============================================")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "import os")
+	fmt.Fprintln(w, "import subprocess")
+	fmt.Fprintln(w, "import sys")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "# (chunk script, chunk log)")
+	fmt.Fprintln(w, "CHUNKS = [")
+	for _, chunk := range chunkFiles {
+		fmt.Fprintf(w, "    (%s, %s),\n", pyQuote(chunk), pyQuote(chunkLogName(chunk)))
+	}
+	fmt.Fprintln(w, "]")
+	io.WriteString(w, pythonMasterRunner)
+}
+
+// pythonMasterRunner is the static body of generated Python master scripts
+const pythonMasterRunner = `
+
+
+def main():
+    here = os.path.dirname(os.path.abspath(__file__))
+    keep_going = "--keep-going" in sys.argv[1:]
+    args = [a for a in sys.argv[1:] if a != "--keep-going"]
+    failed_chunks = 0
+    for i, (script, log_name) in enumerate(CHUNKS, 1):
+        print("[chunk %d/%d] %s" % (i, len(CHUNKS), script))
+        with open(os.path.join(here, log_name), "w", encoding="utf-8") as log:
+            rc = subprocess.call([sys.executable, os.path.join(here, script)] + args,
+                                 stdout=log, stderr=subprocess.STDOUT)
+        if rc != 0:
+            print("  Chunk %s had failures, see %s" % (script, log_name))
+            failed_chunks += 1
+            if not keep_going:
+                print("Stopped at the first chunk with failures; run with --keep-going to run the remaining chunks anyway.")
+                return rc
+    print("Completed %d chunks, %d with failures." % (len(CHUNKS), failed_chunks))
+    return 1 if failed_chunks else 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
+`
+
+func writeManifestRunnerBash(w io.Writer, manifest string, operations []renamer.Operation, config *Config, logName string) {
+	total := len(operations)
+	fmt.Fprintln(w, "#!/bin/bash")
+	fmt.Fprintln(w, "# ============================================")
+	fmt.Fprintln(w, "# Generated by Plex File Renamer")
+	fmt.Fprintln(w, "# ============================================")
+	fmt.Fprintln(w, "#")
+	fmt.Fprintf(w, "# Mode: %s\n", config.Mode)
+	fmt.Fprintf(w, "# Manifest: %s (%d operations)\n", manifest, total)
+	fmt.Fprintln(w, "#")
+	fmt.Fprintln(w, "# Operations are read from the NUL-delimited manifest (mode, source,")
+	fmt.Fprintln(w, "# destination); view it with: tr '\\0' '\\n' < manifest")
+	fmt.Fprintln(w, "# This script will skip files that already exist at destination.")
+	fmt.Fprintf(w, "# Each operation is logged to %s; operations logged as done are\n", logName)
+	fmt.Fprintln(w, "# skipped when the script is run again.")
+	fmt.Fprintln(w, "# ============================================")
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "MANIFEST=\"$(dirname \"$0\")/\"%s\n", bashQuote(manifest))
+	fmt.Fprintln(w, "set -u")
+	writeBashLowPriority(w, config)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "LOG=\"$(dirname \"$0\")/\"%s\n", bashQuote(logName))
+	fmt.Fprintln(w, "touch \"$LOG\"")
+	fmt.Fprintln(w, "n=0 ok=0 skipped=0 failed=0")
+	fmt.Fprintln(w)
+
+	// The keys of the operations, in the order of the manifest, that the log
+	// records them by
+	fmt.Fprintln(w, "KEYS=(")
+	for _, op := range operations {
+		fmt.Fprintf(w, "    %s\n", operationKey(op))
+	}
+	fmt.Fprintln(w, ")")
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "while IFS= read -r -d '' mode && IFS= read -r -d '' src && IFS= read -r -d '' dst; do")
+	fmt.Fprintln(w, "    key=\"${KEYS[n]}\"")
+	fmt.Fprintln(w, "    n=$((n + 1))")
+	io.WriteString(w, "    printf '[%d/%d] %s\\n  From: %s\\n  To:   %s\\n' \"$n\" "+fmt.Sprint(total)+" \"$mode\" \"$src\" \"$dst\"\n")
+	fmt.Fprintln(w, "    if grep -qE \"^(OK|SKIPPED) $key \" \"$LOG\"; then")
+	fmt.Fprintln(w, "        echo '  Already done, skipping'")
+	fmt.Fprintln(w, "        skipped=$((skipped + 1))")
+	fmt.Fprintln(w, "        continue")
+	fmt.Fprintln(w, "    fi")
+	fmt.Fprintln(w, "    status=OK")
+	fmt.Fprintln(w, "    mkdir -p -- \"$(dirname -- \"$dst\")\"")
+	fmt.Fprintln(w, "    if [ -e \"$dst\" ]; then")
+	fmt.Fprintln(w, "        status=SKIPPED")
+	fmt.Fprintln(w, "    else")
+	fmt.Fprintln(w, "        case \"$mode\" in")
+	fmt.Fprintln(w, "            copy) cp -- \"$src\" \"$dst\" ;;")
+	fmt.Fprintln(w, "            move) mv -- \"$src\" \"$dst\" ;;")
+	fmt.Fprintln(w, "            *) echo \"  Unknown mode: $mode\" >&2; false ;;")
+	fmt.Fprintln(w, "        esac || status=\"FAILED:$?\"")
+	fmt.Fprintln(w, "    fi")
+	writeBashCount(w)
+	io.WriteString(w, "    printf '%s %s %s -> %s\\n' \"$status\" \"$key\" \"$src\" \"$dst\" >> \"$LOG\"\n")
+	fmt.Fprintln(w, "done < \"$MANIFEST\"")
+	fmt.Fprintln(w)
+	writeBashSummary(w, "\"$n\"", logName)
+}
+
+// outputDirs describes the output directory, or the volumes files are spread over
+func outputDirs(config *Config) string {
+	if config.Volumes != nil {
+		return strings.Join(config.Volumes, ", ")
+	}
+	return config.OutputDir
+}
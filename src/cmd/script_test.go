@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"plexrenamer/internal/renamer"
+)
+
+// adversarialNames are file names that break scripts that don't quote them
+// properly: shell quotes, expansions, command substitutions, batch
+// metacharacters, control characters and bytes that aren't UTF-8
+var adversarialNames = []string{
+	"plain.mkv",
+	"it's.mkv",
+	`say "hi".mkv`,
+	"$HOME.mkv",
+	"${PATH}.mkv",
+	"`touch pwned`.mkv",
+	"$(touch pwned).mkv",
+	"100% & done!.mkv",
+	"^caret (x) <y> |z;.mkv",
+	"back\\slash.mkv",
+	"new\nline.mkv",
+	"tab\there.mkv",
+	"‘typographic’ ‚quotes‛.mkv",
+	"Amélie.mkv",
+	"-dash.mkv",
+	"latin1 \xe9t\xe9.mkv",
+	"\xff\xfe.mkv",
+}
+
+// scriptFixture creates a file for each name in a source folder, and
+// returns the operations moving them to a folder of the same name
+func scriptFixture(t *testing.T, names []string) (string, []renamer.Operation) {
+	t.Helper()
+	dir := t.TempDir()
+	var operations []renamer.Operation
+	for _, name := range names {
+		src := filepath.Join(dir, "src", name)
+		dst := filepath.Join(dir, "dst", name, name)
+		if err := os.MkdirAll(filepath.Dir(src), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(src, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		operations = append(operations, renamer.Operation{Source: src, Destination: dst, Mode: renamer.ModeMove})
+	}
+	return dir, operations
+}
+
+// runScript generates the script of shell for operations in dir and runs
+// it with interpreter, then checks that every file was moved and nothing
+// else happened
+func runScript(t *testing.T, shell, interpreter string, names []string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("file names with these characters can't be created on Windows")
+	}
+	path, err := exec.LookPath(interpreter)
+	if err != nil {
+		t.Skipf("%s not found", interpreter)
+	}
+	dir, operations := scriptFixture(t, names)
+	config := &Config{Mode: renamer.ModeMove, OutputDir: filepath.Join(dir, "dst")}
+	s := lookupShell(shell)
+
+	var script bytes.Buffer
+	s.Write(&script, operations, config, scriptPart{Total: len(operations), Log: "rename.log"})
+	scriptFile := filepath.Join(dir, "rename"+s.Ext)
+	if err := os.WriteFile(scriptFile, script.Bytes(), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(path, scriptFile)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("script failed: %v\n%s", err, out)
+	}
+	for _, op := range operations {
+		if data, err := os.ReadFile(op.Destination); err != nil {
+			t.Errorf("%q was not moved: %v", op.Source, err)
+		} else if want := filepath.Base(op.Source); string(data) != want {
+			t.Errorf("%q holds %q, want %q", op.Destination, data, want)
+		}
+		if _, err := os.Lstat(op.Source); err == nil {
+			t.Errorf("%q is still there", op.Source)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "pwned")); err == nil {
+		t.Error("a file name was run as a command")
+	}
+}
+
+func TestBashScriptQuoting(t *testing.T) {
+	runScript(t, "bash", "bash", adversarialNames)
+}
+
+func TestPythonScriptQuoting(t *testing.T) {
+	runScript(t, "python", "python3", adversarialNames)
+}
+
+func TestManifestRunnerResumes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file names with these characters can't be created on Windows")
+	}
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not found")
+	}
+	dir, operations := scriptFixture(t, adversarialNames)
+	var manifest bytes.Buffer
+	if err := renamer.WriteManifest(&manifest, operations); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "rename.manifest"), manifest.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var script bytes.Buffer
+	writeManifestRunnerBash(&script, "rename.manifest", operations, &Config{Mode: renamer.ModeMove}, "rename.log")
+	scriptFile := filepath.Join(dir, "rename.sh")
+	if err := os.WriteFile(scriptFile, script.Bytes(), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for run := 1; run <= 2; run++ {
+		out, err := exec.Command(bash, scriptFile).CombinedOutput()
+		if err != nil {
+			t.Fatalf("run %d failed: %v\n%s", run, err, out)
+		}
+		// The second run finds every operation in the log
+		want := 0
+		if run == 2 {
+			want = len(operations)
+		}
+		if got := bytes.Count(out, []byte("Already done")); got != want {
+			t.Errorf("run %d skipped %d operations as done, want %d\n%s", run, got, want, out)
+		}
+	}
+	for _, op := range operations {
+		if _, err := os.Stat(op.Destination); err != nil {
+			t.Errorf("%q was not moved: %v", op.Source, err)
+		}
+	}
+}
+
+func TestBashQuote(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", `'plain'`},
+		{"it's", `'it'\''s'`},
+		{"$HOME `x` !", "'$HOME `x` !'"},
+		{"a\nb", `$'a\nb'`},
+		{"a\tb's\\", `$'a\tb\'s\\'`},
+		{"\x01", `$'\x01'`},
+	}
+	for _, tt := range tests {
+		if got := bashQuote(tt.in); got != tt.want {
+			t.Errorf("bashQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPyQuote(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", `"plain"`},
+		{`say "hi" \`, `"say \"hi\" \\"`},
+		{"a\nb", `"a\nb"`},
+		{"Amélie", `"Amélie"`},
+		{"été \xe9\"\\", `os.fsdecode(b"\xc3\xa9t\xc3\xa9 \xe9\x22\x5c")`},
+	}
+	for _, tt := range tests {
+		if got := pyQuote(tt.in); got != tt.want {
+			t.Errorf("pyQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPSQuote(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", `'plain'`},
+		{"it's", `'it''s'`},
+		{"$env:PATH `n $(x)", "'$env:PATH `n $(x)'"},
+		{"‘a’ ‚b‛", "'‘‘a’’ ‚‚b‛‛'"},
+	}
+	for _, tt := range tests {
+		if got := psQuote(tt.in); got != tt.want {
+			t.Errorf("psQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCmdQuote(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`C:\Movies\plain.mkv`, `"C:\Movies\plain.mkv"`},
+		{"100% & done!", `"100%% & done!"`},
+		{"%PATH% ^ < > | ( )", `"%%PATH%% ^ < > | ( )"`},
+	}
+	for _, tt := range tests {
+		if got := cmdQuote(tt.in); got != tt.want {
+			t.Errorf("cmdQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeCmdPath(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`C:\Movies\plain.mkv`, `C:\Movies\plain.mkv`},
+		{"100% & done!", "100%% ^& done^!"},
+		{"^ < > | ( )", "^^ ^< ^> ^| ^( ^)"},
+	}
+	for _, tt := range tests {
+		if got := escapeCmdPath(tt.in); got != tt.want {
+			t.Errorf("escapeCmdPath(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
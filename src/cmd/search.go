@@ -0,0 +1,57 @@
+package main
+
+import (
+	"slices"
+	"strings"
+
+	"plexrenamer/internal/cli"
+)
+
+// promptQueue holds the shows or movies of a library that are still to be
+// prompted for, in order. A search at the prompts narrows it down to the
+// unanswered items whose title matches.
+type promptQueue struct {
+	titles   []string
+	pending  []int // Indexes into titles, in prompt order
+	answered []bool
+}
+
+func newPromptQueue(titles []string) *promptQueue {
+	q := &promptQueue{titles: titles, answered: make([]bool, len(titles))}
+	for i := range titles {
+		q.pending = append(q.pending, i)
+	}
+	return q
+}
+
+// next returns the index of the item to prompt for, or false when done
+func (q *promptQueue) next() (int, bool) {
+	if len(q.pending) == 0 {
+		return 0, false
+	}
+	return q.pending[0], true
+}
+
+// answer moves on from the current item
+func (q *promptQueue) answer() {
+	q.answered[q.pending[0]] = true
+	q.pending = q.pending[1:]
+}
+
+// search continues with the unanswered items whose title contains every
+// word of query (case-insensitive), or all of them if query is empty. Nothing
+// changes if no item matches.
+func (q *promptQueue) search(query string) {
+	words := strings.Fields(strings.ToLower(query))
+	var matches []int
+	for i, title := range q.titles {
+		title = strings.ToLower(title)
+		if !q.answered[i] && !slices.ContainsFunc(words, func(w string) bool { return !strings.Contains(title, w) }) {
+			matches = append(matches, i)
+		}
+	}
+	cli.ShowSearchResult(query, len(matches))
+	if len(matches) > 0 {
+		q.pending = matches
+	}
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/pterm/pterm"
+)
+
+// runService implements the `service` subcommand, which installs or removes
+// a system service running plexrenamer in schedule mode
+func runService(args []string) error {
+	if len(args) == 0 || (args[0] != "install" && args[0] != "uninstall") {
+		serviceUsage()
+		os.Exit(1)
+	}
+	action := args[0]
+
+	fs := flag.NewFlagSet("service "+action, flag.ExitOnError)
+	name := fs.String("name", "plexrenamer", "Service name")
+	userUnit := fs.Bool("user", false, "Install a systemd user service instead of a system service (Linux)")
+	runAs := fs.String("run-as", os.Getenv("SUDO_USER"), "User the system service runs as (Linux; default: the user running sudo)")
+	printOnly := fs.Bool("print", false, "Print the service definition instead of installing it")
+	fs.Usage = serviceUsage
+
+	// Service options come first; everything after them is passed on to the
+	// service, optionally separated by --
+	n := serviceOptionCount(fs, args[1:])
+	fs.Parse(args[1 : 1+n])
+	serviceArgs := args[1+n:]
+	if len(serviceArgs) > 0 && serviceArgs[0] == "--" {
+		serviceArgs = serviceArgs[1:]
+	}
+
+	if action == "uninstall" {
+		if runtime.GOOS == "windows" {
+			return runCommand("schtasks", "/Delete", "/TN", *name, "/F")
+		}
+		return uninstallSystemdUnit(*name, *userUnit)
+	}
+
+	if !hasFlag(serviceArgs, "schedule") || !hasFlag(serviceArgs, "auto-approve") {
+		return fmt.Errorf("the service needs --auto-approve and --schedule, e.g. service install --auto-approve --schedule '0 3 * * *' --output /media /path/to/plex.db")
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the plexrenamer executable: %w", err)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return fmt.Errorf("failed to find the plexrenamer executable: %w", err)
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	if runtime.GOOS == "windows" {
+		// The task runs as SYSTEM, which has a config directory of its own
+		// and resolves relative paths differently
+		if serviceArgs, err = absConfigArg(serviceArgs); err != nil {
+			return err
+		}
+		return installScheduledTask(*name, exe, dir, serviceArgs, *printOnly)
+	}
+	return installSystemdUnit(*name, exe, dir, serviceArgs, *userUnit, *runAs, *printOnly)
+}
+
+func serviceUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s service install [service options] <plexrenamer options> <database-path>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s service uninstall [--name name] [--user]\n\n", os.Args[0])
+	fmt.Fprintln(os.Stderr, "Install plexrenamer as a service that runs with --schedule. On Linux this")
+	fmt.Fprintln(os.Stderr, "writes a systemd unit; on Windows it registers a task that starts at boot.")
+	fmt.Fprintln(os.Stderr, "Relative paths are resolved from the current directory. On Windows the task")
+	fmt.Fprintln(os.Stderr, "is given an absolute --config, your default config if none is passed.")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Service options:")
+	fmt.Fprintln(os.Stderr, "  --name string    Service name (default \"plexrenamer\")")
+	fmt.Fprintln(os.Stderr, "  --user           Install a systemd user service instead of a system service (Linux)")
+	fmt.Fprintln(os.Stderr, "  --run-as string  User the system service runs as (Linux; default: the user running sudo)")
+	fmt.Fprintln(os.Stderr, "  --print          Print the service definition instead of installing it")
+	fmt.Fprintln(os.Stderr, "\nExample:")
+	fmt.Fprintln(os.Stderr, "  sudo plexrenamer service install --auto-approve --schedule '0 3 * * *' --output /media/organized /var/lib/plexmediaserver/plex.db")
+}
+
+// serviceOptionCount returns how many leading args are options defined in fs
+func serviceOptionCount(fs *flag.FlagSet, args []string) int {
+	i := 0
+	for i < len(args) {
+		name, _, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		f := fs.Lookup(name)
+		if !strings.HasPrefix(args[i], "-") || f == nil {
+			break
+		}
+		i++
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !hasValue && !(ok && b.IsBoolFlag()) {
+			i++
+		}
+	}
+	return min(i, len(args))
+}
+
+// hasFlag reports whether args contain the flag name, in any of the forms
+// the flag package accepts
+func hasFlag(args []string, name string) bool {
+	for _, a := range args {
+		if a == "--" {
+			break
+		}
+		a = strings.TrimLeft(a, "-")
+		if a == name || strings.HasPrefix(a, name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// absConfigArg returns args with the --config path made absolute. Without
+// --config, the default config of the user installing the service is added.
+func absConfigArg(args []string) ([]string, error) {
+	args = append([]string(nil), args...)
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--" {
+			break
+		}
+		flagName, value, hasValue := strings.Cut(args[i], "=")
+		if strings.TrimLeft(flagName, "-") != "config" || !strings.HasPrefix(flagName, "-") {
+			continue
+		}
+		if !hasValue {
+			if i+1 == len(args) {
+				return nil, fmt.Errorf("flag needs an argument: %s", flagName)
+			}
+			i++
+			value = args[i]
+		}
+		abs, err := filepath.Abs(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve config path %s: %w", value, err)
+		}
+		if hasValue {
+			args[i] = flagName + "=" + abs
+		} else {
+			args[i] = abs
+		}
+		return args, nil
+	}
+
+	abs, err := filepath.Abs(defaultConfigPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve config path: %w", err)
+	}
+	return append([]string{"--config", abs}, args...), nil
+}
+
+// systemdUnitPath returns where the unit file for name is installed
+func systemdUnitPath(name string, userUnit bool) (string, error) {
+	if !userUnit {
+		return filepath.Join("/etc/systemd/system", name+".service"), nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the user config directory: %w", err)
+	}
+	return filepath.Join(dir, "systemd", "user", name+".service"), nil
+}
+
+// systemdUnit returns a unit file running exe with args from dir
+func systemdUnit(exe, dir string, args []string, userUnit bool, runAs string) string {
+	words := []string{systemdQuote(exe)}
+	for _, a := range args {
+		words = append(words, systemdQuote(a))
+	}
+
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	b.WriteString("Description=Plex File Renamer (scheduled)\n")
+	b.WriteString("After=network-online.target\n")
+	b.WriteString("Wants=network-online.target\n\n")
+	b.WriteString("[Service]\n")
+	b.WriteString("Type=simple\n")
+	if !userUnit && runAs != "" {
+		fmt.Fprintf(&b, "User=%s\n", runAs)
+	}
+	fmt.Fprintf(&b, "WorkingDirectory=%s\n", strings.ReplaceAll(dir, "%", "%%"))
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.Join(words, " "))
+	b.WriteString("Restart=on-failure\n")
+	b.WriteString("RestartSec=60\n\n")
+	b.WriteString("[Install]\n")
+	if userUnit {
+		b.WriteString("WantedBy=default.target\n")
+	} else {
+		b.WriteString("WantedBy=multi-user.target\n")
+	}
+	return b.String()
+}
+
+// systemdQuote returns s as a double-quoted systemd word. % and $ are
+// doubled so systemd doesn't expand specifiers or variables.
+func systemdQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "%", "%%", "$", "$$")
+	return `"` + r.Replace(s) + `"`
+}
+
+// installSystemdUnit writes the unit for name, then enables and starts it
+func installSystemdUnit(name, exe, dir string, args []string, userUnit bool, runAs string, printOnly bool) error {
+	unit := systemdUnit(exe, dir, args, userUnit, runAs)
+	if printOnly {
+		fmt.Print(unit)
+		return nil
+	}
+
+	path, err := systemdUnitPath(name, userUnit)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(unit), 0644); err != nil {
+		return fmt.Errorf("failed to write unit file (run with sudo, or use --user): %w", err)
+	}
+	pterm.Success.Printf("Wrote %s\n", path)
+
+	systemctl := []string{}
+	if userUnit {
+		systemctl = append(systemctl, "--user")
+	}
+	if err := runCommand("systemctl", append(systemctl, "daemon-reload")...); err != nil {
+		return err
+	}
+	if err := runCommand("systemctl", append(systemctl, "enable", "--now", name+".service")...); err != nil {
+		return err
+	}
+
+	pterm.Success.Printf("Service %s is enabled and running\n", name)
+	if userUnit {
+		pterm.Info.Printf("Follow its output with: journalctl --user -u %s -f\n", name)
+	} else {
+		pterm.Info.Printf("Follow its output with: journalctl -u %s -f\n", name)
+	}
+	return nil
+}
+
+// uninstallSystemdUnit stops and disables the unit for name, then removes it
+func uninstallSystemdUnit(name string, userUnit bool) error {
+	path, err := systemdUnitPath(name, userUnit)
+	if err != nil {
+		return err
+	}
+
+	systemctl := []string{}
+	if userUnit {
+		systemctl = append(systemctl, "--user")
+	}
+	if err := runCommand("systemctl", append(systemctl, "disable", "--now", name+".service")...); err != nil {
+		pterm.Warning.Println(err)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove unit file: %w", err)
+	}
+	if err := runCommand("systemctl", append(systemctl, "daemon-reload")...); err != nil {
+		return err
+	}
+	pterm.Success.Printf("Removed service %s\n", name)
+	return nil
+}
+
+// installScheduledTask registers a Windows task that starts plexrenamer at
+// boot. A task is used rather than an SCM service, since plexrenamer doesn't
+// implement the service control protocol and would be killed by it.
+func installScheduledTask(name, exe, dir string, args []string, printOnly bool) error {
+	words := []string{cmdQuote(exe)}
+	for _, a := range args {
+		words = append(words, cmdQuote(a))
+	}
+	command := fmt.Sprintf("cmd /c cd /d %s && %s", cmdQuote(dir), strings.Join(words, " "))
+
+	taskArgs := []string{"/Create", "/TN", name, "/TR", command, "/SC", "ONSTART", "/RU", "SYSTEM", "/RL", "HIGHEST", "/F"}
+	if printOnly {
+		fmt.Printf("schtasks %s\n", strings.Join(taskArgs, " "))
+		return nil
+	}
+	if err := runCommand("schtasks", taskArgs...); err != nil {
+		return fmt.Errorf("%w (run from an elevated prompt)", err)
+	}
+	if err := runCommand("schtasks", "/Run", "/TN", name); err != nil {
+		return err
+	}
+	pterm.Success.Printf("Task %s is installed and running; it starts again at boot\n", name)
+	return nil
+}
+
+// runCommand runs name with args, passing its output through
+func runCommand(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s %s failed: %w", name, strings.Join(args, " "), err)
+	}
+	return nil
+}
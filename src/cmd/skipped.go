@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"plexrenamer/internal/cli"
+	"plexrenamer/internal/database"
+	"plexrenamer/pkg/planner"
+)
+
+// defaultSkippedPath returns the skipped list location next to the config file
+func defaultSkippedPath(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), "skipped.json")
+}
+
+// loadSkipped reads the skipped list at path. A missing file is an empty list.
+func loadSkipped(path string) (*cli.Skipped, error) {
+	skipped := &cli.Skipped{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return skipped, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read skipped list: %w", err)
+	}
+	if err := json.Unmarshal(data, skipped); err != nil {
+		return nil, fmt.Errorf("failed to parse skipped list %s: %w", path, err)
+	}
+	return skipped, nil
+}
+
+// saveSkipped replaces the skipped list at path with what was declined in
+// the given sections, keeping the entries of other sections of the same
+// database. An empty list removes the file.
+func saveSkipped(path string, skipped *cli.Skipped, sections []int64) error {
+	previous, err := loadSkipped(path)
+	if err != nil {
+		return err
+	}
+
+	merged := *skipped
+	if sameDatabase(previous.Database, skipped.Database) {
+		keep := func(section int64) bool { return !slices.Contains(sections, section) }
+		for _, l := range previous.Libraries {
+			if keep(l.ID) {
+				merged.Libraries = append(merged.Libraries, l)
+			}
+		}
+		for _, l := range previous.Locations {
+			if keep(l.Section) {
+				merged.Locations = append(merged.Locations, l)
+			}
+		}
+		for _, s := range previous.Shows {
+			if keep(s.Section) {
+				merged.Shows = append(merged.Shows, s)
+			}
+		}
+		for _, m := range previous.Movies {
+			if keep(m.Section) {
+				merged.Movies = append(merged.Movies, m)
+			}
+		}
+	}
+
+	if merged.Empty() {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove skipped list: %w", err)
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(&merged, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode skipped list: %w", err)
+	}
+	if err := writeFileAtomic(path, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write skipped list: %w", err)
+	}
+	return nil
+}
+
+// sameDatabase reports whether two database paths refer to the same file
+func sameDatabase(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// skippedSections returns the sections that have entries in the skipped list
+func skippedSections(sections []database.LibrarySection, skipped *cli.Skipped) []database.LibrarySection {
+	var ids []int64
+	for _, l := range skipped.Libraries {
+		ids = append(ids, l.ID)
+	}
+	for _, l := range skipped.Locations {
+		ids = append(ids, l.Section)
+	}
+	for _, s := range skipped.Shows {
+		ids = append(ids, s.Section)
+	}
+	for _, m := range skipped.Movies {
+		ids = append(ids, m.Section)
+	}
+	return filterSections(sections, ids, nil)
+}
+
+// skippedContent narrows content down to what the skipped list holds for its
+// library: everything if the library was declined, otherwise the listed shows
+// and movies and those with files in the listed locations
+func skippedContent(content *database.LibraryContent, skipped *cli.Skipped) *database.LibraryContent {
+	for _, l := range skipped.Libraries {
+		if l.ID == content.Section.ID {
+			return content
+		}
+	}
+
+	var locations []database.SectionLocation
+	for _, l := range skipped.Locations {
+		if l.Section == content.Section.ID {
+			locations = append(locations, database.SectionLocation{RootPath: l.Path})
+		}
+	}
+	listed := func(items []cli.SkippedItem, id int64) bool {
+		return slices.ContainsFunc(items, func(item cli.SkippedItem) bool { return item.ID == id })
+	}
+
+	narrowed := &database.LibraryContent{Section: content.Section, Locations: content.Locations}
+	for _, movie := range content.Movies {
+		if listed(skipped.Movies, movie.Metadata.ID) || planner.FilesInLocations(movie.Files, locations) {
+			narrowed.Movies = append(narrowed.Movies, movie)
+		}
+	}
+	for _, show := range content.Shows {
+		if listed(skipped.Shows, show.Metadata.ID) || planner.ShowInLocations(&show, locations) {
+			narrowed.Shows = append(narrowed.Shows, show)
+		}
+	}
+	return narrowed
+}
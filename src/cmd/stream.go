@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"plexrenamer/internal/cli"
+	"plexrenamer/internal/database"
+	"plexrenamer/internal/renamer"
+	"plexrenamer/pkg/planner"
+)
+
+// streamSection reads the items of a section one at a time and executes
+// their operations right away, so the library never has to fit in memory.
+// Items are handled in library order; there is no preview or confirmation.
+func streamSection(ctx context.Context, db *database.PlexDB, config *Config, formatter *renamer.Formatter, section database.LibrarySection, locations, selectedLocations []database.SectionLocation, opts renamer.ExecOptions) ([]renamer.Result, error) {
+	plan := plannerOptions(config, formatter).ForLibrary(section)
+	outputPath := func(filePath string) string {
+		return plan.OutputPath(filePath, locations, nil)
+	}
+
+	spinner, _ := cli.CreateSpinner(cli.T("progress.title"))
+	hook := postOperationHook(ctx, config)
+	var results []renamer.Result
+	execute := func(title string, files []planner.File) error {
+		// The names are wrong without the values of the placeholders
+		if err := renamer.TokenProviderErr(); err != nil {
+			return fmt.Errorf("failed to get placeholder values: %w", err)
+		}
+		for _, op := range planner.Operations(title, files, config.Mode) {
+			result := op.Execute(ctx, opts)
+			results = append(results, result)
+			// Files in use are retried at the end, and passed to the hook then
+			if hook != nil && (result.Error == nil || !renamer.IsLocked(result.Error)) {
+				hook(result)
+			}
+		}
+		if spinner != nil {
+			spinner.UpdateText(fmt.Sprintf("%s (%d)", cli.T("progress.title"), len(results)))
+		}
+		return nil
+	}
+
+	var err error
+	switch section.SectionType {
+	case database.SectionTypeMovie:
+		err = db.ForEachMovie(ctx, section.ID, func(movie database.MovieInfo) error {
+			return execute(movie.Metadata.Title, plan.MovieFiles(&movie, selectedLocations, outputPath))
+		})
+	case database.SectionTypeShow:
+		err = db.ForEachEpisode(ctx, section.ID, func(show, season *database.MetadataItem, episode database.EpisodeInfo) error {
+			return execute(show.Title, plan.EpisodeFiles(show, season, &episode, selectedLocations, outputPath))
+		})
+	}
+
+	if spinner != nil {
+		spinner.Stop()
+	}
+	retryLockedFiles(ctx, results, opts, hook)
+	return results, err
+}
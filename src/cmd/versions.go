@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+
+	"plexrenamer/internal/cli"
+	"plexrenamer/pkg/planner"
+)
+
+// chooseVersions asks which versions to keep of each movie or episode of
+// item that has more than one among files, returning the files kept
+func chooseVersions(prompter *cli.Prompter, item planner.Item, files []planner.File) ([]planner.File, error) {
+	byItem := map[int64][]planner.File{}
+	var order []int64
+	for _, f := range files {
+		if _, ok := byItem[f.Item]; !ok {
+			order = append(order, f.Item)
+		}
+		byItem[f.Item] = append(byItem[f.Item], f)
+	}
+
+	var kept []planner.File
+	for _, id := range order {
+		chosen, err := prompter.PromptVersions(versionTitle(item, id), byItem[id])
+		if err != nil {
+			return nil, err
+		}
+		kept = append(kept, chosen...)
+	}
+	return kept, nil
+}
+
+// versionTitle names the movie of item, or its episode with the given
+// metadata ID, e.g. "Breaking Bad S01E02"
+func versionTitle(item planner.Item, id int64) string {
+	if item.Show == nil {
+		return item.Title
+	}
+	for _, season := range item.Show.Seasons {
+		for _, episode := range season.Episodes {
+			if episode.Metadata.ID == id && season.Metadata.Index != nil && episode.Metadata.Index != nil {
+				return fmt.Sprintf("%s S%02dE%02d", item.Title, *season.Metadata.Index, *episode.Metadata.Index)
+			}
+		}
+	}
+	return item.Title
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pterm/pterm"
+	"plexrenamer/internal/cli"
+	"plexrenamer/internal/database"
+	"plexrenamer/internal/plexapi"
+	"plexrenamer/internal/renamer"
+	"plexrenamer/pkg/planner"
+)
+
+// watchBundle is the watch state of renamed files, written with
+// --export-watchstate and restored with the import-watchstate subcommand
+type watchBundle struct {
+	Version  int         `json:"version"`
+	Exported time.Time   `json:"exported"`
+	Database string      `json:"database"` // Plex database the watch state was read from
+	Items    []watchItem `json:"items"`
+}
+
+// watchItem is the watch state of one file, by its new path
+type watchItem struct {
+	Path  string                `json:"path"`
+	GUID  string                `json:"guid"`
+	Title string                `json:"title,omitempty"`
+	Watch []database.WatchState `json:"watch"`
+}
+
+// watchBundleVersion is the current version of the bundle format
+const watchBundleVersion = 1
+
+// exportWatchState writes the watch state of the files that were renamed to
+// config.WatchState, keyed by their new paths
+func exportWatchState(ctx context.Context, db *database.PlexDB, config *Config, results []renamer.Result) {
+	if config.WatchState == "" || config.DryRun || config.Sandbox != "" || ctx.Err() != nil {
+		return
+	}
+
+	var guids []string
+	for _, r := range results {
+		if r.Success && !r.Skipped && r.Operation.GUID != "" {
+			guids = append(guids, r.Operation.GUID)
+		}
+	}
+	states, err := db.GetWatchState(ctx, guids)
+	if err != nil {
+		pterm.Warning.Printf("Not exporting the watch state: %v\n", err)
+		return
+	}
+
+	bundle := watchBundle{Version: watchBundleVersion, Exported: time.Now().UTC(), Items: []watchItem{}}
+	if bundle.Database, err = filepath.Abs(config.DatabasePath); err != nil {
+		bundle.Database = config.DatabasePath
+	}
+	for _, r := range results {
+		if watch := states[r.Operation.GUID]; r.Success && !r.Skipped && len(watch) > 0 {
+			bundle.Items = append(bundle.Items, watchItem{
+				Path:  r.Operation.Destination,
+				GUID:  r.Operation.GUID,
+				Title: r.Operation.Title,
+				Watch: watch,
+			})
+		}
+	}
+
+	data, err := json.MarshalIndent(&bundle, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(config.WatchState, append(data, '\n'))
+	}
+	if err != nil {
+		pterm.Warning.Printf("Failed to export the watch state: %v\n", err)
+		return
+	}
+	pterm.Info.Printf("Exported the watch state of %d file(s) to %s\n", len(bundle.Items), config.WatchState)
+}
+
+// runImportWatchState implements the `import-watchstate` subcommand, which
+// restores the watch state in a bundle on a Plex server that has the files
+// at their new paths, through its HTTP API
+func runImportWatchState(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("import-watchstate", flag.ExitOnError)
+	plexURL := fs.String("plex", "", "URL of the Plex server to restore the watch state on (e.g. http://localhost:32400)")
+	token := fs.String("plex-token", "", "Plex token of the account to restore (default: $PLEX_TOKEN)")
+	account := fs.Int64("account", 1, "Account ID in the bundle whose watch state is restored (1 is the server owner)")
+	var pathMaps stringList
+	fs.Var(&pathMaps, "path-map", "Path mapping (old:new) from the paths in the bundle to the paths the server sees (repeatable)")
+	dryRun := fs.Bool("dry-run", false, "Show what would be restored without changing anything")
+	noColor := fs.Bool("no-color", false, "Disable colored output (also disabled by NO_COLOR, TERM=dumb, or when output is not a terminal)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s import-watchstate [options] <bundle>\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Restore the watch state exported with --export-watchstate on a Plex server.")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Options:")
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr, "\nExample:")
+		fmt.Fprintln(os.Stderr, "  plexrenamer import-watchstate --plex http://newserver:32400 --path-map /media:/data watchstate.json")
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || *plexURL == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+	cli.ConfigureColor(*noColor)
+
+	if *token == "" {
+		*token = os.Getenv("PLEX_TOKEN")
+	}
+	if *token == "" {
+		return fmt.Errorf("a Plex token is needed: use --plex-token or set PLEX_TOKEN")
+	}
+	client, err := plexapi.NewClient(*plexURL, *token)
+	if err != nil {
+		return err
+	}
+	var maps []renamer.PathMap
+	for _, pm := range pathMaps {
+		m, err := renamer.ParsePathMap(pm)
+		if err != nil {
+			return err
+		}
+		maps = append(maps, m)
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to read bundle: %w", err)
+	}
+	var bundle watchBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return fmt.Errorf("failed to parse bundle %s: %w", fs.Arg(0), err)
+	}
+	if bundle.Version > watchBundleVersion {
+		return fmt.Errorf("%s was written by a newer version (bundle version %d)", fs.Arg(0), bundle.Version)
+	}
+
+	// Find the server's item for every file
+	sections, err := client.Sections(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get library sections: %w", err)
+	}
+	files := map[string]plexapi.Item{}
+	for _, section := range sections {
+		if section.Type != "movie" && section.Type != "show" {
+			continue
+		}
+		sectionFiles, err := client.SectionFiles(ctx, section)
+		if err != nil {
+			return fmt.Errorf("failed to get the files of %s: %w", section.Title, err)
+		}
+		for file, item := range sectionFiles {
+			files[planner.NormalizePath(file)] = item
+		}
+	}
+
+	restored, missing, failed := 0, 0, 0
+	done := map[string]bool{}
+	for _, item := range bundle.Items {
+		var state *database.WatchState
+		for i := range item.Watch {
+			if item.Watch[i].AccountID == *account {
+				state = &item.Watch[i]
+			}
+		}
+		if state == nil {
+			continue
+		}
+
+		path := renamer.MapPath(item.Path, maps)
+		target, ok := files[planner.NormalizePath(path)]
+		if !ok {
+			pterm.Warning.Printf("Not on the server: %s\n", path)
+			missing++
+			continue
+		}
+		// Files of the same movie or episode share its watch state
+		if done[target.RatingKey] {
+			continue
+		}
+		done[target.RatingKey] = true
+
+		if *dryRun {
+			pterm.Info.Printf("Would restore %s: %s\n", item.Title, describeWatchState(state))
+			restored++
+			continue
+		}
+		if err := restoreWatchState(ctx, client, target, state); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			pterm.Warning.Printf("Failed to restore %s: %v\n", path, err)
+			failed++
+			continue
+		}
+		restored++
+	}
+
+	fmt.Println()
+	if *dryRun {
+		pterm.Info.Printf("DRY RUN: Would restore the watch state of %d item(s) (%d not on the server)\n", restored, missing)
+		return nil
+	}
+	pterm.Success.Printf("Restored the watch state of %d item(s) (%d not on the server, %d failed)\n", restored, missing, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d item(s) could not be restored", failed)
+	}
+	return nil
+}
+
+// restoreWatchState sets the watch state of item. The views it doesn't have
+// yet are scrobbled, so the play count matches, also when it is restored
+// again after a partial failure.
+func restoreWatchState(ctx context.Context, client *plexapi.Client, item plexapi.Item, state *database.WatchState) error {
+	ratingKey := item.RatingKey
+	for i := item.ViewCount; i < state.ViewCount; i++ {
+		if err := client.Scrobble(ctx, ratingKey); err != nil {
+			return err
+		}
+	}
+	if state.ViewOffset > 0 {
+		if err := client.SetProgress(ctx, ratingKey, time.Duration(state.ViewOffset)*time.Millisecond); err != nil {
+			return err
+		}
+	}
+	if state.Rating != nil {
+		if err := client.Rate(ctx, ratingKey, *state.Rating); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// describeWatchState summarizes a watch state for --dry-run
+func describeWatchState(state *database.WatchState) string {
+	s := fmt.Sprintf("watched %d time(s)", state.ViewCount)
+	if state.ViewOffset > 0 {
+		s += fmt.Sprintf(", stopped at %s", (time.Duration(state.ViewOffset) * time.Millisecond).Round(time.Second))
+	}
+	if state.Rating != nil {
+		s += fmt.Sprintf(", rated %g", *state.Rating)
+	}
+	return s
+}
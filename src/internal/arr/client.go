@@ -0,0 +1,182 @@
+package arr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Kind is the *arr app a client talks to
+type Kind string
+
+const (
+	Sonarr Kind = "Sonarr"
+	Radarr Kind = "Radarr"
+)
+
+// Item is a series in Sonarr or a movie in Radarr
+type Item struct {
+	ID    int64
+	Title string
+	Path  string // Folder of the item, as the app sees it
+
+	raw map[string]any // The full object, sent back when the path changes
+}
+
+// Client talks to the v3 API of Sonarr or Radarr
+type Client struct {
+	Kind    Kind
+	BaseURL string // e.g. http://localhost:8989
+	APIKey  string
+	HTTP    *http.Client
+}
+
+// NewClient creates a client for the app at baseURL
+func NewClient(kind Kind, baseURL, apiKey string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid %s URL %q, use e.g. http://localhost:%s", kind, baseURL, defaultPort(kind))
+	}
+	if apiKey == "" {
+		return nil, fmt.Errorf("%s needs an API key (Settings > General in %s)", kind, kind)
+	}
+	return &Client{
+		Kind:    kind,
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		APIKey:  apiKey,
+		HTTP:    &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+func defaultPort(kind Kind) string {
+	if kind == Radarr {
+		return "7878"
+	}
+	return "8989"
+}
+
+// resource is the API path of the app's items
+func (c *Client) resource() string {
+	if c.Kind == Radarr {
+		return "/api/v3/movie"
+	}
+	return "/api/v3/series"
+}
+
+// Items returns all series or movies
+func (c *Client) Items(ctx context.Context) ([]Item, error) {
+	var raw []map[string]any
+	if err := c.do(ctx, http.MethodGet, c.resource(), nil, &raw); err != nil {
+		return nil, err
+	}
+	items := make([]Item, 0, len(raw))
+	for _, r := range raw {
+		id, _ := r["id"].(float64)
+		title, _ := r["title"].(string)
+		path, _ := r["path"].(string)
+		items = append(items, Item{ID: int64(id), Title: title, Path: path, raw: r})
+	}
+	return items, nil
+}
+
+// SetPath changes the folder of an item without moving its files, which is
+// what the app expects after they were moved by someone else
+func (c *Client) SetPath(ctx context.Context, item Item, path string) error {
+	body := make(map[string]any, len(item.raw))
+	for k, v := range item.raw {
+		body[k] = v
+	}
+	body["path"] = path
+	return c.do(ctx, http.MethodPut, fmt.Sprintf("%s/%d?moveFiles=false", c.resource(), item.ID), body, nil)
+}
+
+// Rescan makes the app look at the files in the item's folder again
+func (c *Client) Rescan(ctx context.Context, item Item) error {
+	command := map[string]any{"name": "RescanSeries", "seriesId": item.ID}
+	if c.Kind == Radarr {
+		command = map[string]any{"name": "RescanMovie", "movieId": item.ID}
+	}
+	return c.do(ctx, http.MethodPost, "/api/v3/command", command, nil)
+}
+
+// Match is a series or movie found by Lookup
+type Match struct {
+	Title string
+	Year  int
+	GUID  string // As Plex names it, e.g. tvdb://81189 or tmdb://603
+}
+
+// Lookup searches the app's metadata source (TheTVDB for Sonarr, TMDB for
+// Radarr) for series or movies named term, best match first
+func (c *Client) Lookup(ctx context.Context, term string) ([]Match, error) {
+	var raw []struct {
+		Title  string `json:"title"`
+		Year   int    `json:"year"`
+		TvdbID int64  `json:"tvdbId"`
+		TmdbID int64  `json:"tmdbId"`
+	}
+	if err := c.do(ctx, http.MethodGet, c.resource()+"/lookup?term="+url.QueryEscape(term), nil, &raw); err != nil {
+		return nil, err
+	}
+	matches := make([]Match, 0, len(raw))
+	for _, r := range raw {
+		m := Match{Title: r.Title, Year: r.Year}
+		switch {
+		case c.Kind == Sonarr && r.TvdbID > 0:
+			m.GUID = fmt.Sprintf("tvdb://%d", r.TvdbID)
+		case c.Kind == Radarr && r.TmdbID > 0:
+			m.GUID = fmt.Sprintf("tmdb://%d", r.TmdbID)
+		}
+		matches = append(matches, m)
+	}
+	return matches, nil
+}
+
+// do sends a request with body encoded as JSON, decoding the response into
+// out unless it is nil
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-Api-Key", c.APIKey)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach %s: %w", c.Kind, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return fmt.Errorf("%s rejected the API key (401 Unauthorized)", c.Kind)
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("%s returned %s", c.Kind, resp.Status)
+	}
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to read %s response: %w", c.Kind, err)
+	}
+	return nil
+}
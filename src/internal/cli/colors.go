@@ -2,6 +2,8 @@ package cli
 
 import (
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/pterm/pterm"
 )
@@ -19,6 +21,24 @@ var (
 	AccentStyle    = pterm.NewStyle(pterm.FgMagenta, pterm.Bold)
 )
 
+// ConfigureColor disables colors when requested with noColor, when the NO_COLOR
+// environment variable is set (https://no-color.org), for dumb terminals, and
+// when stdout is not a terminal, so piped output and logs stay free of ANSI codes
+func ConfigureColor(noColor bool) {
+	if noColor || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" || !isTerminal(os.Stdout) {
+		pterm.DisableColor()
+	}
+}
+
+// isTerminal reports whether f is a character device such as a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
 // PrintHeader prints a prominent header
 func PrintHeader(text string) {
 	pterm.DefaultHeader.WithBackgroundStyle(pterm.NewStyle(pterm.BgCyan)).
@@ -83,26 +103,38 @@ func CreateProgressBar(total int, title string) (*pterm.ProgressbarPrinter, erro
 		WithTitle(title).
 		WithShowCount(true).
 		WithShowPercentage(true).
-		WithShowElapsedTime(false).
+		WithShowElapsedTime(true).
 		Start()
 }
 
+// CreateSpinner creates and starts a spinner for work of unknown length
+func CreateSpinner(text string) (*pterm.SpinnerPrinter, error) {
+	return pterm.DefaultSpinner.WithRemoveWhenDone(true).Start(text)
+}
+
 // PrintOperationTable prints operations in a table format
 func PrintOperationTable(data [][]string) {
-	table := pterm.TableData{{"#", "Source", "Destination"}}
+	table := pterm.TableData{{"#", T("table.source"), T("table.destination")}}
 	table = append(table, data...)
 	pterm.DefaultTable.WithHasHeader().WithData(table).Render()
 }
 
-// PrintResultsBox prints results in a styled box
-func PrintResultsBox(succeeded, skipped, failed int) {
+// PrintResultsBox prints results in a styled box. Declined libraries, shows,
+// and movies are only shown if there are any.
+func PrintResultsBox(succeeded, skipped, declined, failed int, elapsed time.Duration) {
 	content := fmt.Sprintf(
-		"%s %d   %s %d   %s %d",
-		pterm.FgGreen.Sprint("Succeeded:"), succeeded,
-		pterm.FgYellow.Sprint("Skipped:"), skipped,
-		pterm.FgRed.Sprint("Failed:"), failed,
+		"%s %d   %s %d",
+		pterm.FgGreen.Sprint(T("results.succeeded")), succeeded,
+		pterm.FgYellow.Sprint(T("results.skipped")), skipped,
 	)
-	pterm.DefaultBox.WithTitle("Results").Println(content)
+	if declined > 0 {
+		content += fmt.Sprintf("   %s %d", T("results.declined"), declined)
+	}
+	content += fmt.Sprintf("   %s %d", pterm.FgRed.Sprint(T("results.failed")), failed)
+	if elapsed > 0 {
+		content += fmt.Sprintf("   %s %s", T("results.time"), elapsed.Round(time.Second))
+	}
+	pterm.DefaultBox.WithTitle(T("results.title")).Println(content)
 }
 
 // PrintBanner prints the application banner
@@ -112,7 +144,7 @@ func PrintBanner() {
 		pterm.NewLettersFromStringWithStyle("File", pterm.NewStyle(pterm.FgLightMagenta)),
 		pterm.NewLettersFromStringWithStyle("Renamer", pterm.NewStyle(pterm.FgCyan)),
 	).Render()
-	DimStyle.Println("v1.0 - Rename media files using Plex metadata")
+	DimStyle.Println(T("banner.tagline"))
 	fmt.Println()
 }
 
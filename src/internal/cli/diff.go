@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/pterm/pterm"
+)
+
+// Styles of the parts of a path a rename changes, see printFromTo
+var (
+	RemovedStyle = pterm.NewStyle(pterm.FgLightRed)
+	ChangedStyle = pterm.NewStyle(pterm.FgLightGreen, pterm.Bold)
+)
+
+// wordRuns splits s into runs of letters and digits and the runs of other
+// characters between them
+func wordRuns(s string) []string {
+	var runs []string
+	start, word := 0, false
+	for i, r := range s {
+		w := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if i > start && w != word {
+			runs = append(runs, s[start:i])
+			start = i
+		}
+		word = w
+	}
+	if start < len(s) {
+		runs = append(runs, s[start:])
+	}
+	return runs
+}
+
+// isWord reports whether a run of wordRuns is made of letters and digits
+func isWord(run string) bool {
+	for _, r := range run {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+	return false
+}
+
+// highlightWords styles the words of path that other doesn't have with
+// changed, ignoring case, and the rest of path with same. A word that
+// appears twice in path must appear twice in other to be the same.
+func highlightWords(path, other string, same, changed *pterm.Style) string {
+	have := map[string]int{}
+	for _, run := range wordRuns(other) {
+		if isWord(run) {
+			have[strings.ToLower(run)]++
+		}
+	}
+	// Runs of the same style are styled together
+	var b strings.Builder
+	var pending strings.Builder
+	style := same
+	for _, run := range wordRuns(path) {
+		runStyle := same
+		if key := strings.ToLower(run); isWord(run) {
+			if have[key] > 0 {
+				have[key]--
+			} else {
+				runStyle = changed
+			}
+		}
+		if runStyle != style {
+			b.WriteString(style.Sprint(pending.String()))
+			pending.Reset()
+			style = runStyle
+		}
+		pending.WriteString(run)
+	}
+	b.WriteString(style.Sprint(pending.String()))
+	return b.String()
+}
+
+// splitDir splits a path after its last / or \
+func splitDir(path string) (dir, file string) {
+	i := strings.LastIndexAny(path, `/\`)
+	return path[:i+1], path[i+1:]
+}
+
+// diffPaths returns source and destination styled to show what a rename
+// changes: the words of source that are gone, and those of destination that
+// are new. A destination in the same directory as its source has the
+// directory dimmed, so the new name stands out.
+func diffPaths(source, destination string) (string, string) {
+	from := highlightWords(source, destination, DimStyle, RemovedStyle)
+	dir, file := splitDir(destination)
+	if srcDir, _ := splitDir(source); dir == srcDir {
+		return from, Dim(dir) + highlightWords(file, source, PathStyle, ChangedStyle)
+	}
+	return from, highlightWords(destination, source, PathStyle, ChangedStyle)
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// currentLanguage is the language used by T
+var currentLanguage = "en"
+
+// Languages returns the supported language codes, sorted
+func Languages() []string {
+	langs := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// SetLanguage selects the language for all output. Locale names such as
+// "de_DE.UTF-8" are accepted; an empty string selects DetectLanguage().
+func SetLanguage(lang string) error {
+	if lang == "" {
+		currentLanguage = DetectLanguage()
+		return nil
+	}
+	code := normalizeLanguage(lang)
+	if _, ok := catalogs[code]; !ok {
+		return fmt.Errorf("unsupported language: %s (use one of: %s)", lang, strings.Join(Languages(), ", "))
+	}
+	currentLanguage = code
+	return nil
+}
+
+// DetectLanguage returns the supported language from the LC_ALL, LC_MESSAGES
+// or LANG environment variables, falling back to English
+func DetectLanguage() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			if code := normalizeLanguage(value); catalogs[code] != nil {
+				return code
+			}
+			return "en"
+		}
+	}
+	return "en"
+}
+
+// normalizeLanguage reduces a locale such as "fr_CA.UTF-8" to "fr"
+func normalizeLanguage(lang string) string {
+	lang = strings.ToLower(lang)
+	if i := strings.IndexAny(lang, "_-.@"); i >= 0 {
+		lang = lang[:i]
+	}
+	return lang
+}
+
+// T returns the message for key in the current language, formatted with args.
+// Messages missing from a translation fall back to English.
+func T(key string, args ...any) string {
+	msg, ok := catalogs[currentLanguage][key]
+	if !ok {
+		if msg, ok = catalogs["en"][key]; !ok {
+			msg = key
+		}
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// isAnswer reports whether input is one of the comma-separated answers for
+// key. English answers are always accepted.
+func isAnswer(input, key string) bool {
+	for _, lang := range []string{currentLanguage, "en"} {
+		for _, answer := range strings.Split(catalogs[lang][key], ",") {
+			if input == answer {
+				return true
+			}
+		}
+	}
+	return false
+}
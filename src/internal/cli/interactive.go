@@ -2,83 +2,280 @@ package cli
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/pterm/pterm"
 	"plexrenamer/internal/database"
 	"plexrenamer/internal/renamer"
+	"plexrenamer/pkg/planner"
 )
 
-// ApprovalState tracks user approval choices
+// ApprovalState tracks user approval choices. It is saved as JSON, so an
+// interrupted session can be resumed.
 type ApprovalState struct {
-	ApproveAll     bool
-	ApprovedShows  map[int64]bool // Show ID -> approved
-	SkippedShows   map[int64]bool // Show ID -> skipped
-	ApprovedMovies map[int64]bool // Movie ID -> approved
+	Database   string `json:"database"`
+	ApproveAll bool   `json:"approve_all,omitempty"`
+	Choices           // For the items of Database
+	// Merged holds the answers for the items of each database merged into
+	// the run, by its path, as metadata IDs are only unique per database
+	Merged map[string]*Choices `json:"merged,omitempty"`
+}
+
+// Choices are the approval choices for the items of one database, by
+// metadata ID
+type Choices struct {
+	ApprovedShows   map[int64]bool    `json:"approved_shows"`             // Show ID -> approved
+	ApprovedSeasons map[int64][]int64 `json:"approved_seasons,omitempty"` // Show ID -> season IDs, if not all were chosen
+	SkippedShows    map[int64]bool    `json:"skipped_shows"`              // Show ID -> skipped
+	ApprovedMovies  map[int64]bool    `json:"approved_movies"`            // Movie ID -> approved
+	SkippedMovies   map[int64]bool    `json:"skipped_movies"`             // Movie ID -> skipped
 }
 
 // NewApprovalState creates a new approval state
 func NewApprovalState() *ApprovalState {
-	return &ApprovalState{
-		ApprovedShows:  make(map[int64]bool),
-		SkippedShows:   make(map[int64]bool),
-		ApprovedMovies: make(map[int64]bool),
+	s := &ApprovalState{}
+	s.init()
+	return s
+}
+
+// init creates the maps that are missing, e.g. after decoding
+func (s *ApprovalState) init() {
+	s.Choices.init()
+	if s.Merged == nil {
+		s.Merged = make(map[string]*Choices)
+	}
+	for _, a := range s.Merged {
+		a.init()
+	}
+}
+
+// init creates the maps that are missing
+func (a *Choices) init() {
+	if a.ApprovedShows == nil {
+		a.ApprovedShows = make(map[int64]bool)
+	}
+	if a.ApprovedSeasons == nil {
+		a.ApprovedSeasons = make(map[int64][]int64)
+	}
+	if a.SkippedShows == nil {
+		a.SkippedShows = make(map[int64]bool)
+	}
+	if a.ApprovedMovies == nil {
+		a.ApprovedMovies = make(map[int64]bool)
+	}
+	if a.SkippedMovies == nil {
+		a.SkippedMovies = make(map[int64]bool)
 	}
 }
 
+// count returns the number of shows and movies answered for
+func (a *Choices) count() int {
+	return len(a.ApprovedShows) + len(a.SkippedShows) + len(a.ApprovedMovies) + len(a.SkippedMovies)
+}
+
+// Answers returns the number of shows and movies answered for
+func (s *ApprovalState) Answers() int {
+	n := s.Choices.count()
+	for _, a := range s.Merged {
+		n += a.count()
+	}
+	return n
+}
+
 // Prompter handles user interaction
 type Prompter struct {
-	reader *bufio.Reader
-	state  *ApprovalState
+	ctx     context.Context
+	reader  *bufio.Reader
+	state   *ApprovalState
+	source  string               // Path of the merged database being planned, "" for the first
+	save    func(*ApprovalState) // Called after each new answer (nil = not saved)
+	skipped Skipped
+	hinted  bool // The /search hint was shown
+	noPager bool // Nobody is there to page through listings
+
+	discarded []renamer.Leftover // Files of versions not kept, for the trash
+}
+
+// SearchRequest is returned by PromptShow and PromptMovie when "/search" is
+// entered instead of an answer. An empty Query ends the search.
+type SearchRequest struct {
+	Query string
+}
+
+func (s *SearchRequest) Error() string {
+	return "search requested: " + s.Query
+}
+
+// parseSearch returns the query of a "/search" command
+func parseSearch(input string) (string, bool) {
+	command, query, _ := strings.Cut(input, " ")
+	if command != "/search" {
+		return "", false
+	}
+	return strings.TrimSpace(query), true
+}
+
+// searchHint shows how to search, before the first show or movie prompt
+func (p *Prompter) searchHint() {
+	if !p.hinted {
+		PrintDim(T("search.hint"))
+		p.hinted = true
+	}
+}
+
+// ShowSearchResult reports how many items a search at the prompts found
+func ShowSearchResult(query string, matches int) {
+	fmt.Println()
+	switch {
+	case query == "":
+		PrintDim(T("search.cleared", matches))
+	case matches == 0:
+		pterm.Warning.Println(T("search.none", query))
+	default:
+		PrintDim(T("search.found", matches, query))
+	}
 }
 
-// NewPrompter creates a new prompter
-func NewPrompter() *Prompter {
+// NewPrompter creates a new prompter. Prompts return ctx's error once it is
+// cancelled, instead of waiting for input.
+func NewPrompter(ctx context.Context) *Prompter {
 	return &Prompter{
+		ctx:    ctx,
 		reader: bufio.NewReader(os.Stdin),
 		state:  NewApprovalState(),
 	}
 }
 
-// PromptLibrary asks user if they want to process a library
+// Unattended makes the prompter never pause listings when the screen is
+// full, for runs nobody is watching
+func (p *Prompter) Unattended() {
+	p.noPager = true
+}
+
+// ResumeFrom makes the prompter reuse the answers in state instead of asking
+// again, and calls save with the state after every new answer
+func (p *Prompter) ResumeFrom(state *ApprovalState, save func(*ApprovalState)) {
+	state.init()
+	p.state = state
+	p.save = save
+}
+
+// UseSource makes the answers that follow be for the items of the merged
+// database at path, or of the first database if path is ""
+func (p *Prompter) UseSource(path string) {
+	p.source = path
+}
+
+// choices returns the answers for the items of the database being planned
+func (p *Prompter) choices() *Choices {
+	if p.source == "" {
+		return &p.state.Choices
+	}
+	c, ok := p.state.Merged[p.source]
+	if !ok {
+		c = &Choices{}
+		c.init()
+		p.state.Merged[p.source] = c
+	}
+	return c
+}
+
+// answered saves the approval state after a new answer
+func (p *Prompter) answered() {
+	if p.save != nil {
+		p.save(p.state)
+	}
+}
+
+// Skipped returns what was declined at the prompts so far
+func (p *Prompter) Skipped() *Skipped {
+	return &p.skipped
+}
+
+// readLine reads a line of input, or returns early if the context is cancelled
+func (p *Prompter) readLine() (string, error) {
+	type line struct {
+		text string
+		err  error
+	}
+	ch := make(chan line, 1)
+	go func() {
+		text, err := p.reader.ReadString('\n')
+		ch <- line{text, err}
+	}()
+
+	select {
+	case l := <-ch:
+		return l.text, l.err
+	case <-p.ctx.Done():
+		fmt.Println()
+		return "", p.ctx.Err()
+	}
+}
+
+// PromptLibrary asks user if they want to process a library, showing what
+// each of its locations holds
 // Returns: proceed, selectedLocations (nil means all), error
-func (p *Prompter) PromptLibrary(section database.LibrarySection, locations []database.SectionLocation) (bool, []database.SectionLocation, error) {
+func (p *Prompter) PromptLibrary(section database.LibrarySection, content *database.LibraryContent) (bool, []database.SectionLocation, error) {
+	locations := content.Locations
+	proceed, selected, err := p.promptLibrary(section, content)
+	if err != nil {
+		return false, nil, err
+	}
+	if !proceed {
+		p.skipped.Libraries = append(p.skipped.Libraries, SkippedLibrary{ID: section.ID, Name: section.Name})
+	} else if selected != nil {
+		p.skipped.addLocations(section, locations, selected)
+	}
+	return proceed, selected, nil
+}
+
+func (p *Prompter) promptLibrary(section database.LibrarySection, content *database.LibraryContent) (bool, []database.SectionLocation, error) {
+	locations := content.Locations
 	fmt.Println()
 	PrintHeader(section.Name)
 
-	sectionType := "Unknown"
+	sectionType := T("library.unknown")
 	switch section.SectionType {
 	case database.SectionTypeMovie:
-		sectionType = "Movies"
+		sectionType = T("library.movies")
 	case database.SectionTypeShow:
-		sectionType = "TV Shows"
+		sectionType = T("library.shows")
+	}
+	PrintLabel(T("library.type"), sectionType)
+	PrintLabel(T("library.locations"), fmt.Sprintf("%d", len(locations)))
+	if len(locations) > 1 {
+		PrintLabel(T("library.contents"), countsText(planner.CountIn(content, locations)))
 	}
-	PrintLabel("Type", sectionType)
-	PrintLabel("Locations", fmt.Sprintf("%d", len(locations)))
 
 	fmt.Println()
 	for i, loc := range locations {
-		PrintNumberedItem(i+1, Path(loc.RootPath))
+		counts := planner.CountIn(content, []database.SectionLocation{loc})
+		PrintNumberedItem(i+1, Path(loc.RootPath)+"  "+Dim(countsText(counts)))
 	}
 	fmt.Println()
 
-	fmt.Print(pterm.FgWhite.Sprint("Process this library? ") + Dim("[y/n/l(oop)/1-N]: "))
-	input, err := p.reader.ReadString('\n')
+	fmt.Print(pterm.FgWhite.Sprint(T("library.prompt")) + Dim(T("library.hint")))
+	input, err := p.readLine()
 	if err != nil {
 		return false, nil, err
 	}
 
 	input = strings.TrimSpace(strings.ToLower(input))
 
-	switch input {
-	case "y", "yes":
+	switch {
+	case isAnswer(input, "answer.yes"):
 		return true, nil, nil // nil means all locations
-	case "l", "loop":
+	case isAnswer(input, "answer.loop"):
 		// Loop through each location with y/n/a prompts
 		return p.promptLocationsLoop(locations)
-	case "n", "no":
+	case isAnswer(input, "answer.no"):
 		return false, nil, nil
 	default:
 		// Try to parse as location number(s) - comma separated
@@ -100,12 +297,23 @@ func (p *Prompter) PromptLibrary(section database.LibrarySection, locations []da
 	}
 }
 
-// LocationWithOutput pairs a location with its custom output path
-type LocationWithOutput struct {
-	Location   database.SectionLocation
-	OutputPath string // Custom output path for this location (empty = use default)
+// countsText describes what a library holds under some of its locations
+func countsText(c planner.Counts) string {
+	var text string
+	if c.Shows > 0 {
+		text = T("library.count_shows", c.Shows, c.Seasons, c.Episodes)
+	} else {
+		text = T("library.count_movies", c.Movies)
+	}
+	if c.Size > 0 {
+		return T("library.count_size", text, c.Files, FormatBytes(c.Size))
+	}
+	return T("library.count_files", text, c.Files)
 }
 
+// LocationWithOutput pairs a location with its custom output path
+type LocationWithOutput = planner.LocationOutput
+
 // promptLocationsLoop asks about each location one by one
 func (p *Prompter) promptLocationsLoop(locations []database.SectionLocation) (bool, []database.SectionLocation, error) {
 	var selected []database.SectionLocation
@@ -119,7 +327,7 @@ func (p *Prompter) promptLocationsLoop(locations []database.SectionLocation) (bo
 
 		fmt.Println()
 		fmt.Printf("  %s %s\n", Dim(fmt.Sprintf("[%d/%d]", i+1, len(locations))), Path(loc.RootPath))
-		yes, all, err := p.askYesNoAll("  Process this location?")
+		yes, all, err := p.askYesNoAll(T("location.prompt"))
 		if err != nil {
 			return false, nil, err
 		}
@@ -144,16 +352,16 @@ func (p *Prompter) PromptLocationOutputs(locations []database.SectionLocation, d
 	var results []LocationWithOutput
 
 	fmt.Println()
-	PrintSubHeader("Set output paths for each location")
-	PrintDim(fmt.Sprintf("  Default output: %s", defaultOutput))
-	PrintDim("  Press Enter to use default, or type a custom path")
+	PrintSubHeader(T("outputs.header"))
+	PrintDim(T("outputs.default", defaultOutput))
+	PrintDim(T("outputs.hint"))
 	fmt.Println()
 
 	for i, loc := range locations {
 		fmt.Printf("  %s %s\n", Dim(fmt.Sprintf("[%d/%d]", i+1, len(locations))), Path(loc.RootPath))
-		fmt.Print(pterm.FgWhite.Sprint("  Output path: "))
+		fmt.Print(pterm.FgWhite.Sprint(T("outputs.prompt")))
 
-		input, err := p.reader.ReadString('\n')
+		input, err := p.readLine()
 		if err != nil {
 			return nil, err
 		}
@@ -174,168 +382,647 @@ func (p *Prompter) PromptLocationOutputs(locations []database.SectionLocation, d
 	return results, nil
 }
 
-// PromptShow asks user if they want to process a show
-func (p *Prompter) PromptShow(show *database.ShowInfo, episodeCount int, previews []PathPreview) (bool, bool, error) {
+// PromptPathMap asks for the local equivalent of a library root that doesn't
+// exist on this machine. If sample (a file under root) is given, the answer is
+// verified by checking that the sample exists under the new mapping.
+// Returns false if the user skips the root.
+func (p *Prompter) PromptPathMap(root, sample string) (renamer.PathMap, bool, error) {
+	fmt.Println()
+	pterm.Warning.Println(T("pathmap.header", root))
+	PrintDim(T("pathmap.hint"))
+
+	for {
+		fmt.Print(pterm.FgWhite.Sprint(T("pathmap.prompt")))
+		input, err := p.readLine()
+		if err != nil {
+			return renamer.PathMap{}, false, err
+		}
+		input = strings.TrimSpace(input)
+		if input == "" {
+			return renamer.PathMap{}, false, nil
+		}
+
+		m := renamer.PathMap{From: root, To: input}
+		if sample == "" {
+			return m, true, nil
+		}
+		mapped := renamer.MapPath(sample, []renamer.PathMap{m})
+		if _, err := os.Stat(mapped); err == nil {
+			fmt.Printf("    %s %s\n", pterm.FgGreen.Sprint("→"), T("pathmap.found", Path(mapped)))
+			return m, true, nil
+		}
+
+		fmt.Printf("    %s %s\n", pterm.FgRed.Sprint("✗"), T("pathmap.missing", Path(mapped)))
+		useAnyway, err := p.askYesNo(T("pathmap.use_anyway"))
+		if err != nil || useAnyway {
+			return m, useAnyway, err
+		}
+	}
+}
+
+// ConfirmSavePathMaps asks whether to save new path mappings to the config file
+func (p *Prompter) ConfirmSavePathMaps(configPath string) (bool, error) {
+	fmt.Println()
+	return p.askYesNo(T("pathmap.save", configPath))
+}
+
+// ConfirmWriteDB asks whether to write corrected paths to a copy of the
+// database
+func (p *Prompter) ConfirmWriteDB(count int, copyPath string) (bool, error) {
+	fmt.Println()
+	return p.askYesNo(T("audit.write_db", count, copyPath))
+}
+
+// PromptFormat asks for a new naming format of the given kind ("format.tv"
+// or "format.movie"), returning current if nothing is entered
+func (p *Prompter) PromptFormat(kind, current string) (string, error) {
+	fmt.Print(pterm.FgWhite.Sprint(T("format.prompt", T(kind))))
+	input, err := p.readLine()
+	if err != nil {
+		return "", err
+	}
+	if input = strings.TrimSpace(input); input == "" {
+		return current, nil
+	}
+	return input, nil
+}
+
+// PromptShow asks user if they want to process a show. If the user selects a
+// subset of seasons, their metadata IDs are returned (nil means all seasons).
+// Entering "/search" returns a *SearchRequest.
+func (p *Prompter) PromptShow(show *database.ShowInfo, episodeCount int, previews []PathPreview) (bool, []int64, error) {
 	if p.state.ApproveAll {
-		return true, false, nil
+		return true, nil, nil
+	}
+
+	// Answered in an earlier session
+	choices := p.choices()
+	id := show.Metadata.ID
+	if choices.SkippedShows[id] {
+		p.skipShow(show, false, nil)
+		return false, nil, nil
+	}
+	if choices.ApprovedShows[id] {
+		seasons := choices.ApprovedSeasons[id]
+		p.skipShow(show, true, seasons)
+		return true, seasons, nil
 	}
 
 	fmt.Println()
-	PrintSubHeader(fmt.Sprintf("TV Show: %s", show.Metadata.Title))
+	PrintSubHeader(T("show.header", show.Metadata.Title))
 	if show.Metadata.Year != nil {
-		PrintLabel("Year", fmt.Sprintf("%d", *show.Metadata.Year))
+		PrintLabel(T("label.year"), fmt.Sprintf("%d", *show.Metadata.Year))
 	}
 	fmt.Printf("  %s %d  %s %d\n",
-		Dim("Seasons:"), len(show.Seasons),
-		Dim("Episodes:"), episodeCount)
+		Dim(T("label.seasons")), len(show.Seasons),
+		Dim(T("label.episode")), episodeCount)
 
-	// Show sample path previews (limit to 3 examples)
+	// Show the path previews a screen at a time, or 3 examples when the
+	// output can't be paged
 	if len(previews) > 0 {
 		fmt.Println()
-		showCount := len(previews)
-		if showCount > 3 {
-			showCount = 3
-		}
-		for i := 0; i < showCount; i++ {
-			pv := previews[i]
-			fmt.Printf("  %s %s\n", pterm.FgRed.Sprint("From:"), Dim(pv.Source))
-			fmt.Printf("  %s %s\n", pterm.FgGreen.Sprint("To:  "), Path(pv.Destination))
+		pager := p.pager(4)
+		for i, pv := range previews {
+			ok, err := pager.room(pager.fromToRows(pv.Source, pv.Destination) + 1)
+			if err != nil {
+				return false, nil, err
+			}
+			if !ok || (!pager.paged() && i == 3) {
+				PrintDim(T("preview.more_files", len(previews)-i))
+				break
+			}
+			printFromTo(pv.Source, pv.Destination)
 			fmt.Println()
 		}
-		if len(previews) > 3 {
-			PrintDim(fmt.Sprintf("  ... and %d more files", len(previews)-3))
+	}
+
+	p.searchHint()
+	fmt.Print(pterm.FgWhite.Sprint(T("show.prompt")) + Dim(T("hint.yes_no_all_select")))
+	input, err := p.readLine()
+	if err != nil {
+		return false, nil, err
+	}
+
+	input = strings.TrimSpace(strings.ToLower(input))
+	if query, ok := parseSearch(input); ok {
+		return false, nil, &SearchRequest{Query: query}
+	}
+	proceed, seasons := false, []int64(nil)
+	if !isAnswer(input, "answer.yes") && isAnswer(input, "answer.select") {
+		if proceed, seasons, err = p.promptSeasons(show); err != nil {
+			return false, nil, err
 		}
+	} else {
+		proceed, _ = p.parseYesNoAll(input)
 	}
 
-	return p.askYesNoAll("Rename files for this show?")
+	if proceed {
+		choices.ApprovedShows[id] = true
+		if seasons != nil {
+			choices.ApprovedSeasons[id] = seasons
+		}
+	} else {
+		choices.SkippedShows[id] = true
+	}
+	p.answered()
+	p.skipShow(show, proceed, seasons)
+	return proceed, seasons, nil
 }
 
-// PathPreview holds source and destination path for preview
-type PathPreview struct {
-	Source      string
-	Destination string
+// skipShow adds a declined show to the skipped list, or the seasons left out
+// of a show that is processed
+func (p *Prompter) skipShow(show *database.ShowInfo, proceed bool, seasons []int64) {
+	if proceed && seasons == nil {
+		return
+	}
+	item := skippedItem(&show.Metadata)
+	if proceed {
+		for _, season := range show.Seasons {
+			if !slices.Contains(seasons, season.Metadata.ID) && season.Metadata.Index != nil {
+				item.Seasons = append(item.Seasons, *season.Metadata.Index)
+			}
+		}
+	}
+	p.skipped.Shows = append(p.skipped.Shows, item)
 }
 
-// PromptMovie asks user if they want to process a movie
+// promptSeasons lists the seasons of a show and asks which to process.
+// Returns the metadata IDs of the chosen seasons.
+func (p *Prompter) promptSeasons(show *database.ShowInfo) (bool, []int64, error) {
+	numbers := make(map[int]int64, len(show.Seasons))
+	fmt.Println()
+	for i, season := range show.Seasons {
+		num := i + 1
+		if season.Metadata.Index != nil {
+			num = *season.Metadata.Index
+		}
+		numbers[num] = season.Metadata.ID
+
+		name := T("season.name", num)
+		if num == 0 {
+			name = T("season.specials")
+		}
+		PrintNumberedItem(num, fmt.Sprintf("%s %s", name, Dim(T("season.episodes", len(season.Episodes)))))
+	}
+	fmt.Println()
+
+	fmt.Print(pterm.FgWhite.Sprint(T("season.prompt")))
+	input, err := p.readLine()
+	if err != nil {
+		return false, nil, err
+	}
+
+	var selected []int64
+	for _, num := range parseNumberList(input) {
+		if id, ok := numbers[num]; ok {
+			selected = append(selected, id)
+		}
+	}
+	if len(selected) == 0 {
+		return false, nil, nil
+	}
+	return true, selected, nil
+}
+
+// parseNumberList parses comma-separated numbers and ranges such as "1,3-5"
+func parseNumberList(input string) []int {
+	var numbers []int
+	for _, part := range strings.Split(input, ",") {
+		part = strings.TrimSpace(part)
+		var from, to int
+		if n, _ := fmt.Sscanf(part, "%d-%d", &from, &to); n == 2 {
+			for i := from; i <= to; i++ {
+				numbers = append(numbers, i)
+			}
+		} else if n == 1 {
+			numbers = append(numbers, from)
+		}
+	}
+	return numbers
+}
+
+// PathPreview holds source and destination path for preview
+type PathPreview = planner.File
+
+// PromptMovie asks user if they want to process a movie. Entering "/search"
+// returns a *SearchRequest.
 func (p *Prompter) PromptMovie(movie *database.MovieInfo, previews []PathPreview) (bool, bool, error) {
 	if p.state.ApproveAll {
 		return true, false, nil
 	}
 
+	// Answered in an earlier session
+	choices := p.choices()
+	id := movie.Metadata.ID
+	if choices.SkippedMovies[id] {
+		p.skipped.Movies = append(p.skipped.Movies, skippedItem(&movie.Metadata))
+		return false, false, nil
+	}
+	if choices.ApprovedMovies[id] {
+		return true, false, nil
+	}
+
 	fmt.Println()
-	PrintSubHeader(fmt.Sprintf("Movie: %s", movie.Metadata.Title))
+	PrintSubHeader(T("movie.header", movie.Metadata.Title))
 	if movie.Metadata.Year != nil {
-		PrintLabel("Year", fmt.Sprintf("%d", *movie.Metadata.Year))
+		PrintLabel(T("label.year"), fmt.Sprintf("%d", *movie.Metadata.Year))
 	}
-	fmt.Printf("  %s %d\n", Dim("Files:"), len(movie.Files))
+	fmt.Printf("  %s %d\n", Dim(T("label.files")), len(movie.Files))
 
-	// Show path previews
+	// Show path previews, a screen at a time
 	if len(previews) > 0 {
 		fmt.Println()
-		for _, pv := range previews {
-			fmt.Printf("  %s %s\n", pterm.FgRed.Sprint("From:"), Dim(pv.Source))
-			fmt.Printf("  %s %s\n", pterm.FgGreen.Sprint("To:  "), Path(pv.Destination))
+		pager := p.pager(4)
+		for i, pv := range previews {
+			ok, err := pager.room(pager.fromToRows(pv.Source, pv.Destination) + 1)
+			if err != nil {
+				return false, false, err
+			}
+			if !ok {
+				PrintDim(T("preview.more_files", len(previews)-i))
+				break
+			}
+			printFromTo(pv.Source, pv.Destination)
 			if len(previews) > 1 {
 				fmt.Println()
 			}
 		}
 	}
 
-	return p.askYesNoAll("Rename files for this movie?")
+	p.searchHint()
+	fmt.Print(pterm.FgWhite.Sprint(T("movie.prompt")) + Dim(T("hint.yes_no_all")))
+	input, err := p.readLine()
+	if err != nil {
+		return false, false, err
+	}
+
+	input = strings.TrimSpace(strings.ToLower(input))
+	if query, ok := parseSearch(input); ok {
+		return false, false, &SearchRequest{Query: query}
+	}
+	yes, all := p.parseYesNoAll(input)
+	if yes {
+		choices.ApprovedMovies[id] = true
+	} else {
+		choices.SkippedMovies[id] = true
+		p.skipped.Movies = append(p.skipped.Movies, skippedItem(&movie.Metadata))
+	}
+	p.answered()
+	return yes, all, nil
 }
 
-// ShowOperationPreview displays what operations will be performed
-func ShowOperationPreview(operations []renamer.Operation, limit int) {
+// ShowOperationPreview displays what operations will be performed, grouped
+// by show or movie with how many each has, up to limit of them (0 = all),
+// a screen at a time. It returns whether some were left out.
+func (p *Prompter) ShowOperationPreview(operations []renamer.Operation, limit int) (bool, error) {
 	fmt.Println()
-	pterm.DefaultSection.Println("Planned Operations")
+	pterm.DefaultSection.Println(T("preview.title"))
 
-	count := len(operations)
-	if limit > 0 && count > limit {
-		count = limit
+	pager := p.pager(3)
+	// next reports whether to show another operation, taking lines
+	next := func(shown, lines int) (bool, error) {
+		if limit > 0 && shown == limit {
+			return false, nil
+		}
+		return pager.room(lines)
 	}
 
-	for i := 0; i < count; i++ {
-		op := operations[i]
-		fmt.Printf("  %s %s\n", pterm.FgRed.Sprint("From:"), Dim(op.Source))
-		fmt.Printf("  %s %s\n", pterm.FgGreen.Sprint("To:  "), Path(op.Destination))
+	groups := groupByTitle(operations)
+	shown, cut := 0, false
+	for i, group := range groups {
+		// The title, its first operation, and the line after the group
+		ok, err := next(shown, pager.fromToRows(group[0].Source, group[0].Destination)+2)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			hidden := 0
+			for _, g := range groups[i:] {
+				hidden += len(g)
+			}
+			PrintDim(T("preview.more_titles", hidden, len(groups)-i))
+			return true, nil
+		}
+
+		title := group[0].Title
+		if title == "" {
+			title = T("preview.untitled")
+		}
+		fmt.Printf("%s %s\n", Accent(title), Dim(T("preview.count", len(group))))
+		for j, op := range group {
+			if j > 0 {
+				if ok, err = next(shown, pager.fromToRows(op.Source, op.Destination)); err != nil {
+					return false, err
+				}
+				if !ok {
+					PrintDim(T("preview.more_ops", len(group)-j))
+					cut = true
+					break
+				}
+			}
+			printFromTo(op.Source, op.Destination)
+			shown++
+		}
 		fmt.Println()
 	}
+	return cut, nil
+}
+
+// groupByTitle groups operations by their show or movie, in the order they
+// first appear. Shows or movies of the same title, such as remakes, are told
+// apart by their library and metadata ID.
+func groupByTitle(operations []renamer.Operation) [][]renamer.Operation {
+	type key struct {
+		library, title string
+		item           int64
+	}
+	var groups [][]renamer.Operation
+	index := map[key]int{}
+	for _, op := range operations {
+		k := key{op.Library, op.Title, op.Item}
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], op)
+	}
+	return groups
+}
+
+// ShowUnreachable lists up to limit operations that were left out of the
+// plan because their source can't be reached
+func ShowUnreachable(results []renamer.Result, limit int) {
+	if len(results) == 0 {
+		return
+	}
+
+	fmt.Println()
+	pterm.Warning.Println(T("preview.missing", len(results)))
+	for i, r := range results {
+		if limit > 0 && i == limit {
+			PrintDim(T("preview.more_ops", len(results)-limit))
+			break
+		}
+		fmt.Printf("  %s\n", r.Operation.Source)
+		fmt.Printf("    %s %s\n", pterm.FgRed.Sprint(T("label.error")), r.Error)
+	}
+}
+
+// ShowSuspects lists up to limit operations that were left out of the plan
+// because their source looks broken, with why
+func ShowSuspects(results []renamer.Result, limit int) {
+	if len(results) == 0 {
+		return
+	}
+
+	fmt.Println()
+	pterm.Warning.Println(T("preview.suspect", len(results)))
+	for i, r := range results {
+		if limit > 0 && i == limit {
+			PrintDim(T("preview.more_ops", len(results)-limit))
+			break
+		}
+		fmt.Printf("  %s\n", r.Operation.Source)
+		fmt.Printf("    %s %s\n", pterm.FgYellow.Sprint(T("label.error")), r.Error)
+	}
+}
 
-	if limit > 0 && len(operations) > limit {
-		PrintDim(fmt.Sprintf("  ... and %d more operations", len(operations)-limit))
+// ShowFormatSamples displays how sample files are named with format, along
+// with the problem found when validating it, if any
+func ShowFormatSamples(format string, samples []PathPreview, problem error) {
+	PrintLabel(T("format.label"), Accent(format))
+	if problem != nil {
+		pterm.Warning.Println(problem)
+	}
+	if len(samples) == 0 {
+		PrintDim("  " + T("format.none"))
+	}
+	for _, pv := range samples {
+		fmt.Println()
+		printFromTo(pv.Source, pv.Destination)
 	}
 }
 
-// ShowResults displays the results of operations
-func ShowResults(results []renamer.Result) {
-	var succeeded, skipped, failed int
-	var failures []renamer.Result
+// printFromTo prints a source and destination pair with aligned labels,
+// highlighting what changes between them
+func printFromTo(source, destination string) {
+	from, to := T("label.from"), T("label.to")
+	width := max(utf8.RuneCountInString(from), utf8.RuneCountInString(to))
+	pad := func(s string) string { return s + strings.Repeat(" ", width-utf8.RuneCountInString(s)) }
+	source, destination = diffPaths(source, destination)
+	fmt.Printf("  %s %s\n", pterm.FgRed.Sprint(pad(from)), source)
+	fmt.Printf("  %s %s\n", pterm.FgGreen.Sprint(pad(to)), destination)
+}
+
+// ShowResults displays the results of operations, with what was left out of
+// the plan counted as skipped, or as declined if it was declined at the
+// prompts, and how long they took
+func ShowResults(results, leftOut []renamer.Result, elapsed time.Duration) {
+	var succeeded, skipped, declined, failed, retried int
+	var failures, locked, warned []renamer.Result
 
 	for _, r := range results {
-		if r.Error != nil {
+		if r.Error != nil && renamer.IsLocked(r.Error) {
+			failed++
+			locked = append(locked, r)
+		} else if r.Error != nil {
 			failed++
 			failures = append(failures, r)
 		} else if r.Skipped {
 			skipped++
 		} else if r.Success {
 			succeeded++
+			if r.Attempts > 1 {
+				retried++
+			}
+			if r.Warning != "" {
+				warned = append(warned, r)
+			}
+		}
+	}
+
+	for _, r := range leftOut {
+		if r.Reason == renamer.SkipDeclined {
+			declined++
+		} else {
+			skipped++
 		}
 	}
 
 	fmt.Println()
-	PrintResultsBox(succeeded, skipped, failed)
+	PrintResultsBox(succeeded, skipped, declined, failed, elapsed)
+	showSkipReasons(append(slices.Clip(results), leftOut...))
+	if retried > 0 {
+		pterm.Info.Println(T("results.retried", retried))
+	}
 
-	// Show failures in detail
-	if failed > 0 {
+	// Show failures in detail, with files that stayed locked listed separately
+	if len(failures) > 0 {
 		fmt.Println()
-		pterm.Error.Println("Failed operations:")
+		pterm.Error.Println(T("results.failures"))
 		for _, r := range failures {
 			fmt.Printf("  %s\n", r.Operation.Source)
-			fmt.Printf("    %s %s\n", pterm.FgRed.Sprint("Error:"), r.Error)
+			if r.Attempts > 1 {
+				fmt.Printf("    %s %s (%s)\n", pterm.FgRed.Sprint(T("label.error")), r.Error, T("results.attempts", r.Attempts))
+			} else {
+				fmt.Printf("    %s %s\n", pterm.FgRed.Sprint(T("label.error")), r.Error)
+			}
+		}
+	}
+
+	if len(warned) > 0 {
+		fmt.Println()
+		pterm.Warning.Println(T("results.warnings"))
+		for _, r := range warned {
+			fmt.Printf("  %s\n    %s\n", r.Operation.Destination, Dim(r.Warning))
+		}
+	}
+
+	if len(locked) > 0 {
+		fmt.Println()
+		pterm.Warning.Println(T("results.locked"))
+		for _, r := range locked {
+			fmt.Printf("  %s\n", r.Operation.Source)
+			if len(r.LockedBy) > 0 {
+				fmt.Printf("    %s %s\n", Dim(T("results.locked_by")), strings.Join(r.LockedBy, ", "))
+			}
 		}
 	}
 }
 
-// ConfirmProceed asks user to confirm before executing
-func (p *Prompter) ConfirmProceed(operationCount int, mode renamer.OperationMode, dryRun bool) (bool, error) {
+// showSkipReasons breaks the skipped results down by why they were skipped
+func showSkipReasons(results []renamer.Result) {
+	counts := renamer.CountSkips(results)
+	var parts []string
+	for _, reason := range renamer.SkipReasons {
+		if n := counts[reason]; n > 0 {
+			parts = append(parts, T("skip."+string(reason), n))
+		}
+	}
+	if len(parts) > 0 {
+		fmt.Printf("  %s %s\n", Dim(T("results.skipped")), strings.Join(parts, ", "))
+	}
+}
+
+// ShowLeftovers lists files remaining in source directories after moves,
+// with the action taken on each
+func ShowLeftovers(leftovers []renamer.Leftover) {
+	if len(leftovers) == 0 {
+		return
+	}
+	fmt.Println()
+	pterm.Warning.Println(T("leftovers.header", len(leftovers), FormatBytes(leftoverSize(leftovers))))
+	showLeftoverActions(leftovers)
+}
+
+// leftoverSize returns the total size of leftovers
+func leftoverSize(leftovers []renamer.Leftover) int64 {
+	var total int64
+	for _, l := range leftovers {
+		total += l.Size
+	}
+	return total
+}
+
+// showLeftoverActions lists leftovers with what was done with them
+func showLeftoverActions(leftovers []renamer.Leftover) {
+	for _, l := range leftovers {
+		label := Dim(T("leftovers.kept"))
+		switch l.Action {
+		case renamer.LeftoverDelete:
+			label = pterm.FgRed.Sprint(T("leftovers.deleted"))
+		case renamer.LeftoverTrash:
+			label = pterm.FgYellow.Sprint(T("leftovers.trashed"))
+		}
+		if l.Error != nil {
+			label = pterm.FgRed.Sprint(T("leftovers.error"))
+		}
+		fmt.Printf("  %s %s %s\n", label, l.Path, Dim("("+FormatBytes(l.Size)+")"))
+		if l.Error != nil {
+			fmt.Printf("    %s %s\n", pterm.FgRed.Sprint(T("label.error")), l.Error)
+		}
+	}
+}
+
+// FormatBytes formats a byte count with a binary unit, e.g. "1.5 GiB"
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// ConfirmProceed asks user to confirm before executing. If showAll is set,
+// the preview left operations out, and the user can ask to see them all.
+func (p *Prompter) ConfirmProceed(operationCount int, mode renamer.OperationMode, dryRun bool, showAll func() error) (bool, error) {
 	fmt.Println()
 
+	modeName := T("mode." + string(mode))
 	if dryRun {
-		pterm.Info.Printf("DRY RUN: Would %s %d files\n", mode, operationCount)
+		pterm.Info.Println(T("confirm.dry_run", modeName, operationCount))
 		return true, nil
 	}
 
-	pterm.Warning.Printf("About to %s %d files. This cannot be undone.\n", mode, operationCount)
-	return p.askYesNo("Proceed?")
+	pterm.Warning.Println(T("confirm.warning", modeName, operationCount))
+	if showAll == nil {
+		return p.askYesNo(T("confirm.prompt"))
+	}
+
+	fmt.Print(pterm.FgWhite.Sprint(T("confirm.prompt")) + Dim(T("hint.yes_no_view")))
+	input, err := p.readLine()
+	if err != nil {
+		return false, err
+	}
+	input = strings.TrimSpace(strings.ToLower(input))
+	if !isAnswer(input, "answer.view") {
+		return isAnswer(input, "answer.yes"), nil
+	}
+	if err := showAll(); err != nil {
+		return false, err
+	}
+	return p.askYesNo(T("confirm.prompt"))
 }
 
 func (p *Prompter) askYesNo(prompt string) (bool, error) {
-	fmt.Print(pterm.FgWhite.Sprint(prompt) + Dim(" [y/n]: "))
-	input, err := p.reader.ReadString('\n')
+	fmt.Print(pterm.FgWhite.Sprint(prompt) + Dim(T("hint.yes_no")))
+	input, err := p.readLine()
 	if err != nil {
 		return false, err
 	}
 
 	input = strings.TrimSpace(strings.ToLower(input))
-	return input == "y" || input == "yes", nil
+	return isAnswer(input, "answer.yes"), nil
 }
 
 func (p *Prompter) askYesNoAll(prompt string) (yes bool, approveAll bool, err error) {
-	fmt.Print(pterm.FgWhite.Sprint(prompt) + Dim(" [y/n/a(ll)]: "))
-	input, err := p.reader.ReadString('\n')
+	fmt.Print(pterm.FgWhite.Sprint(prompt) + Dim(T("hint.yes_no_all")))
+	input, err := p.readLine()
 	if err != nil {
 		return false, false, err
 	}
 
-	input = strings.TrimSpace(strings.ToLower(input))
-	switch input {
-	case "y", "yes":
-		return true, false, nil
-	case "a", "all":
+	yes, approveAll = p.parseYesNoAll(strings.TrimSpace(strings.ToLower(input)))
+	return yes, approveAll, nil
+}
+
+// parseYesNoAll interprets a y/n/a answer, remembering "all" for later prompts
+func (p *Prompter) parseYesNoAll(input string) (yes bool, approveAll bool) {
+	switch {
+	case isAnswer(input, "answer.yes"):
+		return true, false
+	case isAnswer(input, "answer.all"):
 		p.state.ApproveAll = true
-		return true, true, nil
+		return true, true
 	default:
-		return false, false, nil
+		return false, false
 	}
 }
 
@@ -343,7 +1030,7 @@ func (p *Prompter) askYesNoAll(prompt string) (yes bool, approveAll bool, err er
 func PrintProgress(current, total int, op renamer.Operation) {
 	// This is the old callback-style progress, replaced by progress bar
 	fmt.Printf("\r%s [%d/%d] %s",
-		Dim("Processing:"),
+		Dim(T("progress.processing")),
 		current, total,
 		truncatePath(op.Source, 50))
 }
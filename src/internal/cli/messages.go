@@ -0,0 +1,498 @@
+package cli
+
+// catalogs holds the user-facing messages per language. Keys are grouped by
+// where they are used; answer.* values are comma-separated accepted inputs.
+var catalogs = map[string]map[string]string{
+	"en": {
+		"banner.tagline": "v1.0 - Rename media files using Plex metadata",
+
+		"library.type":         "Type",
+		"library.locations":    "Locations",
+		"library.contents":     "Contents",
+		"library.count_movies": "%d movie(s)",
+		"library.count_shows":  "%d show(s), %d season(s), %d episode(s)",
+		"library.count_files":  "%s in %d file(s)",
+		"library.count_size":   "%s in %d file(s), %s",
+		"library.movies":       "Movies",
+		"library.shows":        "TV Shows",
+		"library.unknown":      "Unknown",
+		"library.prompt":       "Process this library? ",
+		"library.hint":         "[y/n/l(oop)/1-N]: ",
+		"location.prompt":      "  Process this location?",
+
+		"outputs.header":  "Set output paths for each location",
+		"outputs.default": "  Default output: %s",
+		"outputs.hint":    "  Press Enter to use default, or type a custom path",
+		"outputs.prompt":  "  Output path: ",
+
+		"pathmap.header":     "Library location not found on this machine: %s",
+		"pathmap.hint":       "  Enter where this folder is mounted here, e.g. a network share or Docker volume.",
+		"pathmap.prompt":     "  Local path (Enter to skip): ",
+		"pathmap.found":      "Found sample file %s",
+		"pathmap.missing":    "Sample file not found at %s",
+		"pathmap.use_anyway": "  Use this mapping anyway?",
+		"pathmap.save":       "Save path mappings to %s?",
+		"audit.write_db":     "Write the %d corrected path(s) to a copy of the database at %s? The database itself is not changed.",
+		"format.hint":        "Type a new format to try it, or press Enter to keep the current one (Enter at every prompt to finish).",
+		"format.prompt":      "%s format: ",
+		"format.tv":          "TV",
+		"format.movie":       "Movie",
+		"format.label":       "Format",
+		"format.none":        "No items with files in this library",
+		"search.hint":        "Type /search <title> at a prompt to jump to matching items, or /search alone to go back to all of them.",
+		"search.found":       "%d unanswered item(s) match %q",
+		"search.none":        "No unanswered items match %q",
+		"search.cleared":     "Back to all %d unanswered item(s)",
+
+		"show.header":   "TV Show: %s",
+		"show.prompt":   "Rename files for this show?",
+		"movie.header":  "Movie: %s",
+		"movie.prompt":  "Rename files for this movie?",
+		"label.year":    "Year",
+		"label.seasons": "Seasons:",
+		"label.episode": "Episodes:",
+		"label.files":   "Files:",
+		"label.from":    "From:",
+		"label.to":      "To:",
+		"label.error":   "Error:",
+
+		"preview.title":       "Planned Operations",
+		"preview.more_files":  "  ... and %d more files",
+		"preview.more_ops":    "  ... and %d more operations",
+		"preview.count":       "%d operation(s)",
+		"preview.untitled":    "Other files",
+		"preview.more_titles": "  ... and %d more operation(s) for %d more title(s)",
+		"hint.yes_no_view":    " [y/n/v(iew all)]: ",
+		"answer.view":         "v,view",
+		"pager.more":          "-- More: Enter to go on, q to skip the rest -- ",
+		"answer.quit":         "q,quit",
+		"preview.missing":     "%d operation(s) left out because their source can't be reached:",
+		"preview.suspect":     "%d operation(s) left out because their source looks broken:",
+		"table.source":        "Source",
+		"table.destination":   "Destination",
+
+		"results.title":     "Results",
+		"results.succeeded": "Succeeded:",
+		"results.skipped":   "Skipped:",
+		"results.declined":  "Declined:",
+		"results.failed":    "Failed:",
+		"results.time":      "Time:",
+		"results.failures":  "Failed operations:",
+		"results.locked":    "Still in use by another program:",
+		"results.warnings":  "Done, with warnings:",
+		"results.locked_by": "held by:",
+		"results.attempts":  "after %d attempts",
+		"results.retried":   "%d operation(s) succeeded after retrying",
+		"skip.exists":       "%d destination exists",
+		"skip.not-better":   "%d not better than the existing file",
+		"skip.missing":      "%d source missing",
+		"skip.suspect":      "%d source looks broken",
+		"skip.filtered":     "%d filtered out",
+
+		"leftovers.header":  "%d leftover files (%s) in source directories:",
+		"leftovers.kept":    "[kept]",
+		"leftovers.deleted": "[deleted]",
+		"leftovers.trashed": "[trashed]",
+		"leftovers.error":   "[error]",
+
+		"versions.header":       "%s has %d versions:",
+		"versions.sidecars":     "and %d file(s) named after it",
+		"versions.prompt":       "Versions to keep",
+		"hint.versions":         " [Enter = all, e.g. 2 to keep only that one, 2d to also send the others to the trash]: ",
+		"answer.trash":          "d",
+		"versions.trash_header": "%d file(s) (%s) of versions you didn't keep:",
+		"versions.trash_dry":    "DRY RUN: Would send %d file(s) (%s) of versions you didn't keep to the trash",
+
+		"mode.copy":       "copy",
+		"mode.move":       "move",
+		"confirm.dry_run": "DRY RUN: Would %[1]s %[2]d files",
+		"confirm.warning": "About to %[1]s %[2]d files. This cannot be undone.",
+		"confirm.prompt":  "Proceed?",
+
+		"hint.yes_no":            " [y/n]: ",
+		"hint.yes_no_all":        " [y/n/a(ll)]: ",
+		"answer.yes":             "y,yes",
+		"answer.no":              "n,no",
+		"answer.all":             "a,all",
+		"answer.loop":            "l,loop",
+		"hint.yes_no_all_select": " [y/n/a(ll)/s(elect)]: ",
+		"answer.select":          "s,select",
+		"season.name":            "Season %d",
+		"season.specials":        "Specials",
+		"season.episodes":        "(%d episodes)",
+		"season.prompt":          "Seasons to process (e.g. 1,3-5): ",
+
+		"progress.title":      "Processing files",
+		"progress.eta":        "%s left",
+		"progress.processing": "Processing:",
+	},
+	"de": {
+		"banner.tagline": "v1.0 - Mediendateien anhand von Plex-Metadaten umbenennen",
+
+		"library.type":         "Typ",
+		"library.locations":    "Speicherorte",
+		"library.contents":     "Inhalt",
+		"library.count_movies": "%d Film(e)",
+		"library.count_shows":  "%d Serie(n), %d Staffel(n), %d Folge(n)",
+		"library.count_files":  "%s in %d Datei(en)",
+		"library.count_size":   "%s in %d Datei(en), %s",
+		"library.movies":       "Filme",
+		"library.shows":        "Serien",
+		"library.unknown":      "Unbekannt",
+		"library.prompt":       "Diese Mediathek verarbeiten? ",
+		"library.hint":         "[j/n/l(iste)/1-N]: ",
+		"location.prompt":      "  Diesen Speicherort verarbeiten?",
+
+		"outputs.header":  "Ausgabepfade für jeden Speicherort festlegen",
+		"outputs.default": "  Standardausgabe: %s",
+		"outputs.hint":    "  Eingabetaste für den Standard, oder einen eigenen Pfad eingeben",
+		"outputs.prompt":  "  Ausgabepfad: ",
+
+		"pathmap.header":     "Speicherort der Mediathek auf diesem Rechner nicht gefunden: %s",
+		"pathmap.hint":       "  Geben Sie an, wo dieser Ordner hier eingebunden ist, z. B. eine Netzwerkfreigabe oder ein Docker-Volume.",
+		"pathmap.prompt":     "  Lokaler Pfad (Eingabetaste zum Überspringen): ",
+		"pathmap.found":      "Beispieldatei gefunden: %s",
+		"pathmap.missing":    "Beispieldatei nicht gefunden: %s",
+		"pathmap.use_anyway": "  Diese Zuordnung trotzdem verwenden?",
+		"pathmap.save":       "Pfadzuordnungen in %s speichern?",
+		"audit.write_db":     "Die %d korrigierten Pfade in eine Kopie der Datenbank unter %s schreiben? Die Datenbank selbst wird nicht geändert.",
+		"format.hint":        "Geben Sie ein neues Format zum Ausprobieren ein oder drücken Sie die Eingabetaste, um das aktuelle zu behalten (Eingabetaste bei jeder Frage zum Beenden).",
+		"format.prompt":      "%s-Format: ",
+		"format.tv":          "Serien",
+		"format.movie":       "Film",
+		"format.label":       "Format",
+		"format.none":        "Keine Einträge mit Dateien in dieser Mediathek",
+		"search.hint":        "Geben Sie bei einer Frage /search <Titel> ein, um zu passenden Einträgen zu springen, oder nur /search, um wieder alle zu sehen.",
+		"search.found":       "%d unbeantwortete Einträge passen zu %q",
+		"search.none":        "Keine unbeantworteten Einträge passen zu %q",
+		"search.cleared":     "Zurück zu allen %d unbeantworteten Einträgen",
+
+		"show.header":   "Serie: %s",
+		"show.prompt":   "Dateien dieser Serie umbenennen?",
+		"movie.header":  "Film: %s",
+		"movie.prompt":  "Dateien dieses Films umbenennen?",
+		"label.year":    "Jahr",
+		"label.seasons": "Staffeln:",
+		"label.episode": "Folgen:",
+		"label.files":   "Dateien:",
+		"label.from":    "Von:",
+		"label.to":      "Nach:",
+		"label.error":   "Fehler:",
+
+		"preview.title":       "Geplante Vorgänge",
+		"preview.more_files":  "  ... und %d weitere Dateien",
+		"preview.more_ops":    "  ... und %d weitere Vorgänge",
+		"preview.count":       "%d Vorgang/Vorgänge",
+		"preview.untitled":    "Weitere Dateien",
+		"preview.more_titles": "  ... und %d weitere Vorgänge für %d weitere Titel",
+		"hint.yes_no_view":    " [j/n/z(eige alle)]: ",
+		"answer.view":         "z,zeigen",
+		"pager.more":          "-- Weiter: Enter zum Fortfahren, q überspringt den Rest -- ",
+		"answer.quit":         "q,beenden",
+		"preview.missing":     "%d Vorgang/Vorgänge ausgelassen, weil die Quelle nicht erreichbar ist:",
+		"preview.suspect":     "%d Vorgang/Vorgänge ausgelassen, weil die Quelle beschädigt scheint:",
+		"table.source":        "Quelle",
+		"table.destination":   "Ziel",
+
+		"results.title":     "Ergebnis",
+		"results.succeeded": "Erfolgreich:",
+		"results.skipped":   "Übersprungen:",
+		"results.declined":  "Abgelehnt:",
+		"results.failed":    "Fehlgeschlagen:",
+		"results.time":      "Dauer:",
+		"results.failures":  "Fehlgeschlagene Vorgänge:",
+		"results.locked":    "Weiterhin von einem anderen Programm verwendet:",
+		"results.warnings":  "Erledigt, mit Warnungen:",
+		"results.locked_by": "geöffnet von:",
+		"results.attempts":  "nach %d Versuchen",
+		"results.retried":   "%d Vorgang/Vorgänge nach erneutem Versuch erfolgreich",
+		"skip.exists":       "%d Ziel vorhanden",
+		"skip.not-better":   "%d nicht besser als die vorhandene Datei",
+		"skip.missing":      "%d Quelle fehlt",
+		"skip.suspect":      "%d Quelle scheint beschädigt",
+		"skip.filtered":     "%d herausgefiltert",
+
+		"leftovers.header":  "%d übrige Dateien (%s) in Quellordnern:",
+		"leftovers.kept":    "[behalten]",
+		"leftovers.deleted": "[gelöscht]",
+		"leftovers.trashed": "[Papierkorb]",
+		"leftovers.error":   "[Fehler]",
+
+		"versions.header":       "%s hat %d Versionen:",
+		"versions.sidecars":     "und %d danach benannte Datei(en)",
+		"versions.prompt":       "Zu behaltende Versionen",
+		"hint.versions":         " [Eingabe = alle, z. B. 2, um nur diese zu behalten, 2l, um die anderen außerdem in den Papierkorb zu legen]: ",
+		"answer.trash":          "l",
+		"versions.trash_header": "%d Datei(en) (%s) nicht behaltener Versionen:",
+		"versions.trash_dry":    "TESTLAUF: %d Datei(en) (%s) nicht behaltener Versionen würden in den Papierkorb gelegt",
+
+		"mode.copy":       "kopiert",
+		"mode.move":       "verschoben",
+		"confirm.dry_run": "TESTLAUF: %[2]d Dateien würden %[1]s",
+		"confirm.warning": "Gleich werden %[2]d Dateien %[1]s. Dies kann nicht rückgängig gemacht werden.",
+		"confirm.prompt":  "Fortfahren?",
+
+		"hint.yes_no":            " [j/n]: ",
+		"hint.yes_no_all":        " [j/n/a(lle)]: ",
+		"answer.yes":             "j,ja",
+		"answer.no":              "n,nein",
+		"answer.all":             "a,alle",
+		"answer.loop":            "l,liste",
+		"hint.yes_no_all_select": " [j/n/a(lle)/w(ählen)]: ",
+		"answer.select":          "w,wählen",
+		"season.name":            "Staffel %d",
+		"season.specials":        "Specials",
+		"season.episodes":        "(%d Folgen)",
+		"season.prompt":          "Zu verarbeitende Staffeln (z. B. 1,3-5): ",
+
+		"progress.title":      "Dateien werden verarbeitet",
+		"progress.eta":        "noch %s",
+		"progress.processing": "Verarbeite:",
+	},
+	"fr": {
+		"banner.tagline": "v1.0 - Renommer les fichiers multimédias d'après les métadonnées Plex",
+
+		"library.type":         "Type",
+		"library.locations":    "Emplacements",
+		"library.contents":     "Contenu",
+		"library.count_movies": "%d film(s)",
+		"library.count_shows":  "%d série(s), %d saison(s), %d épisode(s)",
+		"library.count_files":  "%s dans %d fichier(s)",
+		"library.count_size":   "%s dans %d fichier(s), %s",
+		"library.movies":       "Films",
+		"library.shows":        "Séries",
+		"library.unknown":      "Inconnu",
+		"library.prompt":       "Traiter cette bibliothèque ? ",
+		"library.hint":         "[o/n/b(oucle)/1-N] : ",
+		"location.prompt":      "  Traiter cet emplacement ?",
+
+		"outputs.header":  "Choisir le dossier de sortie de chaque emplacement",
+		"outputs.default": "  Sortie par défaut : %s",
+		"outputs.hint":    "  Appuyez sur Entrée pour la valeur par défaut, ou saisissez un chemin",
+		"outputs.prompt":  "  Dossier de sortie : ",
+
+		"pathmap.header":     "Emplacement de bibliothèque introuvable sur cette machine : %s",
+		"pathmap.hint":       "  Indiquez où ce dossier est monté ici, par ex. un partage réseau ou un volume Docker.",
+		"pathmap.prompt":     "  Chemin local (Entrée pour ignorer) : ",
+		"pathmap.found":      "Fichier d'exemple trouvé : %s",
+		"pathmap.missing":    "Fichier d'exemple introuvable : %s",
+		"pathmap.use_anyway": "  Utiliser cette correspondance quand même ?",
+		"pathmap.save":       "Enregistrer les correspondances de chemins dans %s ?",
+		"audit.write_db":     "Écrire les %d chemin(s) corrigé(s) dans une copie de la base de données à %s ? La base elle-même n'est pas modifiée.",
+		"format.hint":        "Saisissez un nouveau format pour l'essayer, ou appuyez sur Entrée pour garder l'actuel (Entrée à chaque question pour terminer).",
+		"format.prompt":      "Format %s : ",
+		"format.tv":          "séries",
+		"format.movie":       "films",
+		"format.label":       "Format",
+		"format.none":        "Aucun élément avec des fichiers dans cette bibliothèque",
+		"search.hint":        "Tapez /search <titre> à une question pour aller aux éléments correspondants, ou /search seul pour revenir à tous.",
+		"search.found":       "%d élément(s) sans réponse correspondent à %q",
+		"search.none":        "Aucun élément sans réponse ne correspond à %q",
+		"search.cleared":     "Retour aux %d élément(s) sans réponse",
+
+		"show.header":   "Série : %s",
+		"show.prompt":   "Renommer les fichiers de cette série ?",
+		"movie.header":  "Film : %s",
+		"movie.prompt":  "Renommer les fichiers de ce film ?",
+		"label.year":    "Année",
+		"label.seasons": "Saisons :",
+		"label.episode": "Épisodes :",
+		"label.files":   "Fichiers :",
+		"label.from":    "De :",
+		"label.to":      "Vers :",
+		"label.error":   "Erreur :",
+
+		"preview.title":       "Opérations prévues",
+		"preview.more_files":  "  ... et %d autres fichiers",
+		"preview.more_ops":    "  ... et %d autres opérations",
+		"preview.count":       "%d opération(s)",
+		"preview.untitled":    "Autres fichiers",
+		"preview.more_titles": "  ... et %d autres opérations pour %d autres titres",
+		"hint.yes_no_view":    " [o/n/v(oir tout)] : ",
+		"answer.view":         "v,voir",
+		"pager.more":          "-- Suite : Entrée pour continuer, q pour passer le reste -- ",
+		"answer.quit":         "q,quitter",
+		"preview.missing":     "%d opération(s) écartée(s) car leur source est inaccessible :",
+		"preview.suspect":     "%d opération(s) écartée(s) car leur source semble endommagée :",
+		"table.source":        "Source",
+		"table.destination":   "Destination",
+
+		"results.title":     "Résultats",
+		"results.succeeded": "Réussis :",
+		"results.skipped":   "Ignorés :",
+		"results.declined":  "Refusés :",
+		"results.failed":    "Échecs :",
+		"results.time":      "Durée :",
+		"results.failures":  "Opérations échouées :",
+		"results.locked":    "Toujours utilisés par un autre programme :",
+		"results.warnings":  "Terminés, avec des avertissements :",
+		"results.locked_by": "ouvert par :",
+		"results.attempts":  "après %d tentatives",
+		"results.retried":   "%d opération(s) réussie(s) après une nouvelle tentative",
+		"skip.exists":       "%d destination(s) existante(s)",
+		"skip.not-better":   "%d pas meilleur(s) que le fichier existant",
+		"skip.missing":      "%d source(s) manquante(s)",
+		"skip.suspect":      "%d source(s) endommagée(s)",
+		"skip.filtered":     "%d filtré(s)",
+
+		"leftovers.header":  "%d fichiers restants (%s) dans les dossiers source :",
+		"leftovers.kept":    "[conservé]",
+		"leftovers.deleted": "[supprimé]",
+		"leftovers.trashed": "[corbeille]",
+		"leftovers.error":   "[erreur]",
+
+		"versions.header":       "%s a %d versions :",
+		"versions.sidecars":     "et %d fichier(s) nommé(s) d'après elle",
+		"versions.prompt":       "Versions à garder",
+		"hint.versions":         " [Entrée = toutes, p. ex. 2 pour ne garder que celle-ci, 2s pour mettre aussi les autres à la corbeille] : ",
+		"answer.trash":          "s",
+		"versions.trash_header": "%d fichier(s) (%s) des versions non gardées :",
+		"versions.trash_dry":    "SIMULATION : %d fichier(s) (%s) des versions non gardées seraient mis à la corbeille",
+
+		"mode.copy":       "copier",
+		"mode.move":       "déplacer",
+		"confirm.dry_run": "SIMULATION : %[2]d fichiers à %[1]s",
+		"confirm.warning": "Sur le point de %[1]s %[2]d fichiers. Cette action est irréversible.",
+		"confirm.prompt":  "Continuer ?",
+
+		"hint.yes_no":            " [o/n] : ",
+		"hint.yes_no_all":        " [o/n/t(ous)] : ",
+		"answer.yes":             "o,oui",
+		"answer.no":              "n,non",
+		"answer.all":             "t,tous",
+		"answer.loop":            "b,boucle",
+		"hint.yes_no_all_select": " [o/n/t(ous)/c(hoisir)] : ",
+		"answer.select":          "c,choisir",
+		"season.name":            "Saison %d",
+		"season.specials":        "Épisodes spéciaux",
+		"season.episodes":        "(%d épisodes)",
+		"season.prompt":          "Saisons à traiter (ex. 1,3-5) : ",
+
+		"progress.title":      "Traitement des fichiers",
+		"progress.eta":        "encore %s",
+		"progress.processing": "Traitement :",
+	},
+	"es": {
+		"banner.tagline": "v1.0 - Renombrar archivos multimedia con los metadatos de Plex",
+
+		"library.type":         "Tipo",
+		"library.locations":    "Ubicaciones",
+		"library.contents":     "Contenido",
+		"library.count_movies": "%d película(s)",
+		"library.count_shows":  "%d serie(s), %d temporada(s), %d episodio(s)",
+		"library.count_files":  "%s en %d archivo(s)",
+		"library.count_size":   "%s en %d archivo(s), %s",
+		"library.movies":       "Películas",
+		"library.shows":        "Series",
+		"library.unknown":      "Desconocido",
+		"library.prompt":       "¿Procesar esta biblioteca? ",
+		"library.hint":         "[s/n/b(ucle)/1-N]: ",
+		"location.prompt":      "  ¿Procesar esta ubicación?",
+
+		"outputs.header":  "Indicar la ruta de salida de cada ubicación",
+		"outputs.default": "  Salida predeterminada: %s",
+		"outputs.hint":    "  Pulse Intro para usar la predeterminada, o escriba una ruta",
+		"outputs.prompt":  "  Ruta de salida: ",
+
+		"pathmap.header":     "Ubicación de la biblioteca no encontrada en este equipo: %s",
+		"pathmap.hint":       "  Indique dónde está montada esta carpeta aquí, p. ej. un recurso de red o un volumen de Docker.",
+		"pathmap.prompt":     "  Ruta local (Intro para omitir): ",
+		"pathmap.found":      "Archivo de ejemplo encontrado: %s",
+		"pathmap.missing":    "Archivo de ejemplo no encontrado: %s",
+		"pathmap.use_anyway": "  ¿Usar esta correspondencia de todos modos?",
+		"pathmap.save":       "¿Guardar las correspondencias de rutas en %s?",
+		"audit.write_db":     "¿Escribir las %d ruta(s) corregida(s) en una copia de la base de datos en %s? La base de datos en sí no se modifica.",
+		"format.hint":        "Escriba un nuevo formato para probarlo, o pulse Intro para mantener el actual (Intro en cada pregunta para terminar).",
+		"format.prompt":      "Formato de %s: ",
+		"format.tv":          "series",
+		"format.movie":       "películas",
+		"format.label":       "Formato",
+		"format.none":        "No hay elementos con archivos en esta biblioteca",
+		"search.hint":        "Escriba /search <título> en una pregunta para saltar a los elementos que coincidan, o solo /search para volver a todos.",
+		"search.found":       "%d elemento(s) sin responder coinciden con %q",
+		"search.none":        "Ningún elemento sin responder coincide con %q",
+		"search.cleared":     "De vuelta a los %d elemento(s) sin responder",
+
+		"show.header":   "Serie: %s",
+		"show.prompt":   "¿Renombrar los archivos de esta serie?",
+		"movie.header":  "Película: %s",
+		"movie.prompt":  "¿Renombrar los archivos de esta película?",
+		"label.year":    "Año",
+		"label.seasons": "Temporadas:",
+		"label.episode": "Episodios:",
+		"label.files":   "Archivos:",
+		"label.from":    "De:",
+		"label.to":      "A:",
+		"label.error":   "Error:",
+
+		"preview.title":       "Operaciones previstas",
+		"preview.more_files":  "  ... y %d archivos más",
+		"preview.more_ops":    "  ... y %d operaciones más",
+		"preview.count":       "%d operación(es)",
+		"preview.untitled":    "Otros archivos",
+		"preview.more_titles": "  ... y %d operaciones más de %d títulos más",
+		"hint.yes_no_view":    " [s/n/v(er todo)]: ",
+		"answer.view":         "v,ver",
+		"pager.more":          "-- Más: Enter para seguir, q para saltar el resto -- ",
+		"answer.quit":         "q,salir",
+		"preview.missing":     "%d operación(es) omitida(s) porque no se puede acceder a su origen:",
+		"preview.suspect":     "%d operación(es) omitida(s) porque su origen parece dañado:",
+		"table.source":        "Origen",
+		"table.destination":   "Destino",
+
+		"results.title":     "Resultados",
+		"results.succeeded": "Correctas:",
+		"results.skipped":   "Omitidas:",
+		"results.declined":  "Rechazadas:",
+		"results.failed":    "Fallidas:",
+		"results.time":      "Tiempo:",
+		"results.failures":  "Operaciones fallidas:",
+		"results.locked":    "Todavía en uso por otro programa:",
+		"results.warnings":  "Hechas, con advertencias:",
+		"results.locked_by": "abierto por:",
+		"results.attempts":  "tras %d intentos",
+		"results.retried":   "%d operación(es) correcta(s) tras reintentar",
+		"skip.exists":       "%d destino(s) existente(s)",
+		"skip.not-better":   "%d no mejor(es) que el archivo existente",
+		"skip.missing":      "%d origen(es) ausente(s)",
+		"skip.suspect":      "%d origen(es) dañado(s)",
+		"skip.filtered":     "%d filtrado(s)",
+
+		"leftovers.header":  "%d archivos restantes (%s) en las carpetas de origen:",
+		"leftovers.kept":    "[conservado]",
+		"leftovers.deleted": "[eliminado]",
+		"leftovers.trashed": "[papelera]",
+		"leftovers.error":   "[error]",
+
+		"versions.header":       "%s tiene %d versiones:",
+		"versions.sidecars":     "y %d archivo(s) con su nombre",
+		"versions.prompt":       "Versiones a conservar",
+		"hint.versions":         " [Intro = todas, p. ej. 2 para conservar solo esa, 2e para enviar además las otras a la papelera]: ",
+		"answer.trash":          "e",
+		"versions.trash_header": "%d archivo(s) (%s) de versiones no conservadas:",
+		"versions.trash_dry":    "SIMULACIÓN: Se enviarían a la papelera %d archivo(s) (%s) de versiones no conservadas",
+
+		"mode.copy":       "copiar",
+		"mode.move":       "mover",
+		"confirm.dry_run": "SIMULACIÓN: Se van a %[1]s %[2]d archivos",
+		"confirm.warning": "Se van a %[1]s %[2]d archivos. Esta acción no se puede deshacer.",
+		"confirm.prompt":  "¿Continuar?",
+
+		"hint.yes_no":            " [s/n]: ",
+		"hint.yes_no_all":        " [s/n/t(odos)]: ",
+		"answer.yes":             "s,si,sí",
+		"answer.no":              "n,no",
+		"answer.all":             "t,todos",
+		"answer.loop":            "b,bucle",
+		"hint.yes_no_all_select": " [s/n/t(odos)/e(legir)]: ",
+		"answer.select":          "e,elegir",
+		"season.name":            "Temporada %d",
+		"season.specials":        "Especiales",
+		"season.episodes":        "(%d episodios)",
+		"season.prompt":          "Temporadas a procesar (p. ej. 1,3-5): ",
+
+		"progress.title":      "Procesando archivos",
+		"progress.eta":        "quedan %s",
+		"progress.processing": "Procesando:",
+	},
+}
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pterm/pterm"
+	"golang.org/x/text/width"
+)
+
+// pager shows a long listing a screen at a time when the prompts are read
+// from a terminal, asking to go on whenever the screen is full
+type pager struct {
+	p      *Prompter
+	height int  // Lines that fit on the screen (0 = the listing isn't paged)
+	width  int  // Columns of the screen, where long lines wrap
+	lines  int  // Lines on the screen since the last pause
+	quit   bool // The user skipped the rest of the listing
+}
+
+// pager returns a pager for a listing that starts below used lines of
+// output belonging with it
+func (p *Prompter) pager(used int) *pager {
+	pg := &pager{p: p, lines: used}
+	if !p.noPager && isTerminal(os.Stdin) && isTerminal(os.Stdout) {
+		// Leave room for the pause line, or the prompt after the listing
+		pg.height = max(pterm.GetTerminalHeight()-2, 1)
+		pg.width = pterm.GetTerminalWidth()
+	}
+	return pg
+}
+
+// paged reports whether the listing is shown a screen at a time, rather
+// than cut short by its caller
+func (pg *pager) paged() bool {
+	return pg.height > 0
+}
+
+// room makes room for n more lines, pausing first if they don't fit on the
+// screen. It returns false once the user skipped the rest of the listing.
+func (pg *pager) room(n int) (bool, error) {
+	if pg.quit {
+		return false, nil
+	}
+	if pg.paged() && pg.lines > 0 && pg.lines+n > pg.height {
+		fmt.Print(Dim(T("pager.more")))
+		input, err := pg.p.readLine()
+		if err != nil {
+			return false, err
+		}
+		if isAnswer(strings.TrimSpace(strings.ToLower(input)), "answer.quit") {
+			pg.quit = true
+			return false, nil
+		}
+		pg.lines = 0
+	}
+	pg.lines += n
+	return true, nil
+}
+
+// rows returns how many lines of the screen a line of text columns wide
+// takes once it wraps
+func (pg *pager) rows(columns int) int {
+	if pg.width <= 0 {
+		return 1
+	}
+	return max((columns+pg.width-1)/pg.width, 1)
+}
+
+// fromToRows returns how many lines of the screen printFromTo takes for
+// source and destination, as long paths wrap
+func (pg *pager) fromToRows(source, destination string) int {
+	label := max(utf8.RuneCountInString(T("label.from")), utf8.RuneCountInString(T("label.to")))
+	return pg.rows(label+3+columns(source)) + pg.rows(label+3+columns(destination))
+}
+
+// columns returns how many columns of a terminal s takes: two for wide
+// East Asian characters, one for the others
+func columns(s string) int {
+	n := 0
+	for _, r := range s {
+		switch width.LookupRune(r).Kind() {
+		case width.EastAsianWide, width.EastAsianFullwidth:
+			n += 2
+		default:
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/pterm/pterm"
+	"plexrenamer/internal/renamer"
+)
+
+// progressSmoothing is the weight of the latest operation in the moving
+// average of the throughput
+const progressSmoothing = 0.2
+
+// Progress is the progress bar of a run. Besides the count and the elapsed
+// time it shows the file being processed and the time left, estimated from a
+// moving average of the throughput so far.
+type Progress struct {
+	bar     *pterm.ProgressbarPrinter
+	bySize  bool      // Estimate from bytes, or from operations if sizes are unknown
+	left    float64   // Bytes (or operations) not processed yet
+	rate    float64   // Moving average of bytes (or operations) per second
+	started time.Time // When the current operation started
+}
+
+// StartProgress starts a progress bar for operations
+func StartProgress(operations []renamer.Operation) *Progress {
+	p := &Progress{}
+	for _, op := range operations {
+		p.left += float64(op.Size)
+	}
+	if p.bySize = p.left > 0; !p.bySize {
+		p.left = float64(len(operations))
+	}
+	p.bar, _ = CreateProgressBar(len(operations), T("progress.title"))
+	return p
+}
+
+// Begin shows op as the operation being processed
+func (p *Progress) Begin(op renamer.Operation) {
+	p.started = time.Now()
+	if p.bar == nil {
+		return
+	}
+	title := T("progress.title")
+	if p.rate > 0 {
+		eta := time.Duration(p.left / p.rate * float64(time.Second)).Round(time.Second)
+		title += " · " + T("progress.eta", eta)
+	}
+	name := truncateName(filepath.Base(op.Source), 30)
+	if op.Size > 0 {
+		name = fmt.Sprintf("%s (%s)", name, FormatBytes(op.Size))
+	}
+	p.bar.UpdateTitle(title + " · " + name)
+}
+
+// Done counts the operation shown by Begin as processed. Only operations that
+// copied data are sampled for the throughput, as skips and renames take no
+// time and would make the estimate far too short.
+func (p *Progress) Done(op renamer.Operation, result renamer.Result) {
+	work := 1.0
+	if p.bySize {
+		work = float64(op.Size)
+	}
+	p.left -= work
+	if secs := time.Since(p.started).Seconds(); secs > 0 && work > 0 && result.Copied {
+		if rate := work / secs; p.rate == 0 {
+			p.rate = rate
+		} else {
+			p.rate = progressSmoothing*rate + (1-progressSmoothing)*p.rate
+		}
+	}
+	if p.bar != nil {
+		if p.bar.Current+1 >= p.bar.Total {
+			// The bar stops by itself when it is full
+			p.bar.Title = T("progress.title")
+		}
+		p.bar.Increment()
+	}
+}
+
+// Stop stops the bar
+func (p *Progress) Stop() {
+	if p.bar != nil {
+		p.bar.Stop()
+	}
+}
+
+// truncateName shortens a file name to maxLen characters, keeping its end
+// (with the extension and often the episode) visible
+func truncateName(name string, maxLen int) string {
+	runes := []rune(name)
+	if len(runes) <= maxLen {
+		return name
+	}
+	return "…" + string(runes[len(runes)-maxLen+1:])
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"slices"
+
+	"plexrenamer/internal/database"
+)
+
+// Skipped lists what was declined at the prompts of a run, so it can be
+// revisited later or reviewed as deliberately left untouched
+type Skipped struct {
+	Database  string            `json:"database"`
+	Libraries []SkippedLibrary  `json:"libraries,omitempty"`
+	Locations []SkippedLocation `json:"locations,omitempty"`
+	Shows     []SkippedItem     `json:"shows,omitempty"`
+	Movies    []SkippedItem     `json:"movies,omitempty"`
+}
+
+// SkippedLibrary is a library that was declined as a whole
+type SkippedLibrary struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// SkippedLocation is a location left out of a library that was processed
+type SkippedLocation struct {
+	Section int64  `json:"section"`
+	Path    string `json:"path"`
+}
+
+// SkippedItem is a declined show or movie
+type SkippedItem struct {
+	ID      int64  `json:"id"`
+	Section int64  `json:"section"`
+	Title   string `json:"title"`
+	Year    *int   `json:"year,omitempty"`
+	GUID    string `json:"guid,omitempty"`
+	Seasons []int  `json:"seasons,omitempty"` // Declined seasons of a show whose other seasons were processed
+}
+
+// Empty reports whether nothing was declined
+func (s *Skipped) Empty() bool {
+	return len(s.Libraries) == 0 && len(s.Locations) == 0 && len(s.Shows) == 0 && len(s.Movies) == 0
+}
+
+// Count returns the number of declined libraries, locations, shows, and movies
+func (s *Skipped) Count() int {
+	return len(s.Libraries) + len(s.Locations) + len(s.Shows) + len(s.Movies)
+}
+
+// addLocations records the locations that were not selected
+func (s *Skipped) addLocations(section database.LibrarySection, locations, selected []database.SectionLocation) {
+	for _, loc := range locations {
+		if !slices.ContainsFunc(selected, func(l database.SectionLocation) bool { return l.ID == loc.ID }) {
+			s.Locations = append(s.Locations, SkippedLocation{Section: section.ID, Path: loc.RootPath})
+		}
+	}
+}
+
+// skippedItem describes a show or movie for the skipped list
+func skippedItem(m *database.MetadataItem) SkippedItem {
+	return SkippedItem{ID: m.ID, Section: m.LibrarySectionID, Title: m.Title, Year: m.Year, GUID: m.GUID}
+}
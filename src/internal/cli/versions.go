@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"plexrenamer/internal/renamer"
+)
+
+// PromptVersions asks which versions to keep of a movie or episode that
+// has more than one, given its planned files. The files of the versions
+// kept are returned; if the user asked to, those of the others are sent to
+// the trash after the run (see Discarded).
+func (p *Prompter) PromptVersions(title string, files []PathPreview) ([]PathPreview, error) {
+	var versions [][]PathPreview
+	index := map[int64]int{}
+	for _, f := range files {
+		i, ok := index[f.Version]
+		if !ok {
+			i = len(versions)
+			index[f.Version] = i
+			versions = append(versions, nil)
+		}
+		versions[i] = append(versions[i], f)
+	}
+	if len(versions) < 2 || p.state.ApproveAll {
+		return files, nil
+	}
+
+	fmt.Println()
+	PrintSubHeader(T("versions.header", title, len(versions)))
+	for i, version := range versions {
+		var size int64
+		var quality renamer.Quality
+		sidecars := 0
+		for _, f := range version {
+			size += f.Size
+			if f.Sidecar {
+				sidecars++
+			} else if quality.Resolution == 0 {
+				quality = f.Quality
+			}
+		}
+		label := FormatBytes(size)
+		if quality.Resolution > 0 {
+			label = fmt.Sprintf("%dp  %s", quality.Resolution, label)
+		}
+		fmt.Println()
+		PrintNumberedItem(i+1, label)
+		for _, f := range version {
+			if !f.Sidecar {
+				printFromTo(f.Source, f.Destination)
+			}
+		}
+		if sidecars > 0 {
+			PrintDim("  " + T("versions.sidecars", sidecars))
+		}
+	}
+
+	fmt.Println()
+	fmt.Print(pterm.FgWhite.Sprint(T("versions.prompt")) + Dim(T("hint.versions")))
+	input, err := p.readLine()
+	if err != nil {
+		return nil, err
+	}
+	input = strings.ToLower(strings.TrimSpace(input))
+	if input == "" || isAnswer(input, "answer.all") {
+		return files, nil
+	}
+	input, trash := cutAnswerSuffix(input, "answer.trash")
+
+	var keep []int
+	for _, n := range parseNumberList(input) {
+		if n >= 1 && n <= len(versions) && !slices.Contains(keep, n-1) {
+			keep = append(keep, n-1)
+		}
+	}
+	if len(keep) == 0 {
+		// Nothing valid was entered, so nothing is dropped
+		return files, nil
+	}
+
+	var kept []PathPreview
+	for i, version := range versions {
+		if slices.Contains(keep, i) {
+			kept = append(kept, version...)
+			continue
+		}
+		if trash {
+			for _, f := range version {
+				p.discarded = append(p.discarded, renamer.Leftover{Path: f.Source, Size: f.Size, Action: renamer.LeftoverTrash})
+			}
+		}
+	}
+	return kept, nil
+}
+
+// cutAnswerSuffix returns input without a trailing answer for key, and
+// whether it had one
+func cutAnswerSuffix(input, key string) (string, bool) {
+	for _, lang := range []string{currentLanguage, "en"} {
+		for _, answer := range strings.Split(catalogs[lang][key], ",") {
+			if rest, ok := strings.CutSuffix(input, answer); ok && answer != "" {
+				return strings.TrimSpace(rest), true
+			}
+		}
+	}
+	return input, false
+}
+
+// Discarded returns the files of versions the user chose not to keep and
+// to send to the trash
+func (p *Prompter) Discarded() []renamer.Leftover {
+	return p.discarded
+}
+
+// ShowDiscarded lists the files of versions not kept, with what was done
+// with each
+func ShowDiscarded(discarded []renamer.Leftover) {
+	if len(discarded) == 0 {
+		return
+	}
+	fmt.Println()
+	pterm.Warning.Println(T("versions.trash_header", len(discarded), FormatBytes(leftoverSize(discarded))))
+	showLeftoverActions(discarded)
+}
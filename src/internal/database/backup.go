@@ -0,0 +1,157 @@
+package database
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// libraryDBPattern matches the library database in a Plex backup, or one of
+// the copies Plex rotates every few days (com.plexapp.plugins.library.db-2024-05-01)
+var libraryDBPattern = regexp.MustCompile(`^com\.plexapp\.plugins\.library\.db(-\d{4}-\d{2}-\d{2})?$`)
+
+// isArchive reports whether path is a zip or tar archive, by its extension
+func isArchive(path string) bool {
+	lower := strings.ToLower(path)
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// pickLibraryDB returns the entry of names to read the library from: the
+// database itself, or else its newest rotated copy, or else the only .db file
+func pickLibraryDB(names []string) (string, bool) {
+	var newest string
+	var dbs []string
+	for _, name := range names {
+		base := path.Base(name)
+		if base == "com.plexapp.plugins.library.db" {
+			return name, true
+		}
+		if libraryDBPattern.MatchString(base) && base > path.Base(newest) {
+			newest = name
+		}
+		if strings.HasSuffix(strings.ToLower(base), ".db") {
+			dbs = append(dbs, name)
+		}
+	}
+	if newest == "" && len(dbs) == 1 {
+		newest = dbs[0]
+	}
+	return newest, newest != ""
+}
+
+// extractBackup copies the library database out of the backup archive at
+// archivePath to a temporary file and returns its path
+func extractBackup(archivePath string) (string, error) {
+	tmp, err := os.CreateTemp("", "plexrenamer-*.db")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary database: %w", err)
+	}
+	if strings.HasSuffix(strings.ToLower(archivePath), ".zip") {
+		err = extractZip(archivePath, tmp)
+	} else {
+		err = extractTar(archivePath, tmp)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to extract database from %s: %w", archivePath, err)
+	}
+	return tmp.Name(), nil
+}
+
+// extractZip copies the library database of a zip archive to w
+func extractZip(archivePath string, w io.Writer) error {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	name, ok := pickLibraryDB(names)
+	if !ok {
+		return fmt.Errorf("no Plex library database in the archive")
+	}
+	r, err := zr.Open(name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// extractTar copies the library database of a tar archive, gzipped or not,
+// to w. A tar can only be read in order, so it is read once to pick the
+// entry and again to copy it.
+func extractTar(archivePath string, w io.Writer) error {
+	var names []string
+	if err := walkTar(archivePath, func(hdr *tar.Header, _ io.Reader) (bool, error) {
+		if hdr.Typeflag == tar.TypeReg {
+			names = append(names, hdr.Name)
+		}
+		return false, nil
+	}); err != nil {
+		return err
+	}
+	name, ok := pickLibraryDB(names)
+	if !ok {
+		return fmt.Errorf("no Plex library database in the archive")
+	}
+	return walkTar(archivePath, func(hdr *tar.Header, r io.Reader) (bool, error) {
+		if hdr.Name != name {
+			return false, nil
+		}
+		_, err := io.Copy(w, r)
+		return true, err
+	})
+}
+
+// walkTar calls fn for each entry of a tar archive until it returns true
+func walkTar(archivePath string, fn func(*tar.Header, io.Reader) (bool, error)) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if lower := strings.ToLower(archivePath); strings.HasSuffix(lower, ".gz") || strings.HasSuffix(lower, ".tgz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if done, err := fn(hdr, tr); done || err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// Cache keeps parsed library content on disk so repeated runs against an
+// unchanged database skip the queries. Entries are tied to the size and
+// modification time of the database (and its WAL file), so they are
+// refreshed automatically once Plex writes to it, and to the mode it was
+// opened in, as an immutable read leaves out what is in the WAL.
+type Cache struct {
+	dir         string
+	prefix      string // Identifies the database file
+	fingerprint string // Identifies its current state
+}
+
+// cacheEntry is the stored form of one section
+type cacheEntry struct {
+	Fingerprint string
+	Content     LibraryContent
+}
+
+// contentSignature describes the layout of LibraryContent, so entries
+// written by a build with different models are not decoded
+var contentSignature = typeSignature(reflect.TypeOf(LibraryContent{}), map[reflect.Type]bool{})
+
+// contentRevision is increased when the way content is read from the
+// database changes, so entries read by an older build are not used
+const contentRevision = 2 // 2: episodes stored directly under their show
+
+// NewCache returns a cache in dir for the database at dbPath, opened in mode
+func NewCache(dir, dbPath string, mode OpenMode) (*Cache, error) {
+	absPath, err := filepath.Abs(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat database: %w", err)
+	}
+	fingerprint := fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano())
+	if wal, err := os.Stat(absPath + "-wal"); err == nil {
+		fingerprint += fmt.Sprintf(":%d:%d", wal.Size(), wal.ModTime().UnixNano())
+	}
+	fingerprint += ":" + string(resolveOpenMode(absPath, mode))
+
+	pathHash := sha256.Sum256([]byte(absPath))
+	sigHash := sha256.Sum256(fmt.Appendf(nil, "%s:%d", contentSignature, contentRevision))
+	return &Cache{
+		dir:         dir,
+		prefix:      hex.EncodeToString(pathHash[:8]),
+		fingerprint: fingerprint + ":" + hex.EncodeToString(sigHash[:8]),
+	}, nil
+}
+
+// path returns the cache file of a section
+func (c *Cache) path(sectionID int64) string {
+	return filepath.Join(c.dir, fmt.Sprintf("%s-%d.json", c.prefix, sectionID))
+}
+
+// Load returns the cached content of a section, or false if there is none
+// for the current state of the database
+func (c *Cache) Load(section LibrarySection) (*LibraryContent, bool) {
+	f, err := os.Open(c.path(section.ID))
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	var entry cacheEntry
+	if err := json.NewDecoder(f).Decode(&entry); err != nil || entry.Fingerprint != c.fingerprint {
+		return nil, false
+	}
+	entry.Content.Section = section
+	return &entry.Content, true
+}
+
+// Save stores the content of a section, replacing older entries for it
+func (c *Cache) Save(content *LibraryContent) error {
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	path := c.path(content.Section.ID)
+	tmp, err := os.CreateTemp(c.dir, filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := json.NewEncoder(tmp).Encode(cacheEntry{Fingerprint: c.fingerprint, Content: *content}); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	return nil
+}
+
+// typeSignature describes t with its field names and types
+func typeSignature(t reflect.Type, seen map[reflect.Type]bool) string {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return "*" + typeSignature(t.Elem(), seen)
+	case reflect.Slice:
+		return "[]" + typeSignature(t.Elem(), seen)
+	case reflect.Map:
+		return "map[" + typeSignature(t.Key(), seen) + "]" + typeSignature(t.Elem(), seen)
+	case reflect.Struct:
+		if seen[t] {
+			return t.Name()
+		}
+		seen[t] = true
+		var fields []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			fields = append(fields, f.Name+" "+typeSignature(f.Type, seen))
+		}
+		return t.Name() + "{" + strings.Join(fields, ";") + "}"
+	default:
+		return t.Kind().String()
+	}
+}
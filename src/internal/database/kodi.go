@@ -0,0 +1,374 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KodiDB provides access to a Kodi video database (MyVideosNN.db of Kodi 17
+// or later), whose matched metadata is read into the same LibraryContent as
+// Plex's: a Movies and a TV Shows library
+type KodiDB struct {
+	db *sql.DB
+}
+
+// kodiName matches the file names of Kodi's video databases
+var kodiName = regexp.MustCompile(`(?i)^MyVideos\d+\.db$`)
+
+// IsKodi reports whether path is a Kodi video database, by its name
+func IsKodi(path string) bool {
+	return kodiName.MatchString(filepath.Base(path))
+}
+
+// OpenKodi opens a Kodi video database
+func OpenKodi(dbPath string) (*KodiDB, error) {
+	db, err := openSQLite(dbPath, OpenImmutable)
+	if err != nil {
+		return nil, err
+	}
+	return &KodiDB{db: db}, nil
+}
+
+// Close closes the database connection
+func (k *KodiDB) Close() error {
+	return k.db.Close()
+}
+
+// Kodi has no libraries of its own, only movies and TV shows, which are
+// given the IDs of the libraries they are read into
+var (
+	kodiMovies = LibrarySection{ID: 1, Name: "Movies", SectionType: SectionTypeMovie, Agent: "kodi"}
+	kodiShows  = LibrarySection{ID: 2, Name: "TV Shows", SectionType: SectionTypeShow, Agent: "kodi"}
+)
+
+// GetLibrarySections returns a Movies and a TV Shows library, for those
+// the database has any of
+func (k *KodiDB) GetLibrarySections(ctx context.Context) ([]LibrarySection, error) {
+	var sections []LibrarySection
+	for _, s := range []struct {
+		section LibrarySection
+		table   string
+	}{{kodiMovies, "movie"}, {kodiShows, "tvshow"}} {
+		var n int
+		if err := k.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+s.table).Scan(&n); err != nil {
+			return nil, fmt.Errorf("failed to count %s items, is this a Kodi video database? %w", s.table, err)
+		}
+		if n > 0 {
+			sections = append(sections, s.section)
+		}
+	}
+	return sections, nil
+}
+
+// GetLibraryContent returns the movies or shows of a library, with the
+// sources Kodi scans them from as its locations
+func (k *KodiDB) GetLibraryContent(ctx context.Context, section LibrarySection) (*LibraryContent, error) {
+	content := &LibraryContent{Section: section}
+	kind := "movies"
+	if section.SectionType == SectionTypeShow {
+		kind = "tvshows"
+	}
+	locations, err := k.getSources(ctx, section.ID, kind)
+	if err != nil {
+		return nil, err
+	}
+	content.Locations = locations
+
+	switch section.SectionType {
+	case SectionTypeMovie:
+		content.Movies, err = k.getMovies(ctx)
+	case SectionTypeShow:
+		content.Shows, err = k.getShows(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// getSources returns the folders whose content Kodi was told is of kind
+func (k *KodiDB) getSources(ctx context.Context, sectionID int64, kind string) ([]SectionLocation, error) {
+	rows, err := k.db.QueryContext(ctx, `SELECT idPath, strPath FROM path WHERE strContent = ? ORDER BY strPath`, kind)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sources: %w", err)
+	}
+	defer rows.Close()
+
+	var locations []SectionLocation
+	for rows.Next() {
+		var l SectionLocation
+		if err := rows.Scan(&l.ID, &l.RootPath); err != nil {
+			return nil, fmt.Errorf("failed to scan source: %w", err)
+		}
+		if len(l.RootPath) > 1 {
+			l.RootPath = strings.TrimRight(l.RootPath, `/\`)
+		}
+		l.LibrarySectionID = sectionID
+		l.Available = 1
+		locations = append(locations, l)
+	}
+	return locations, rows.Err()
+}
+
+func (k *KodiDB) getMovies(ctx context.Context) ([]MovieInfo, error) {
+	ids, err := k.getUniqueIDs(ctx, "movie")
+	if err != nil {
+		return nil, err
+	}
+	rows, err := k.db.QueryContext(ctx, `
+		SELECT m.idMovie, COALESCE(m.c00, ''), COALESCE(m.c10, ''), COALESCE(m.c16, ''), COALESCE(m.c18, ''),
+			COALESCE(m.premiered, ''), COALESCE(m.c01, ''), r.rating, COALESCE(m.c12, ''), COALESCE(CAST(m.c11 AS INTEGER), 0),
+			COALESCE(m.c14, ''), f.idFile, p.strPath, f.strFilename, COALESCE(f.dateAdded, '')
+		FROM movie m
+		JOIN files f ON f.idFile = m.idFile
+		JOIN path p ON p.idPath = f.idPath
+		LEFT JOIN rating r ON r.rating_id = m.c05
+		ORDER BY m.c00
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query movies: %w", err)
+	}
+	defer rows.Close()
+
+	var movies []MovieInfo
+	for rows.Next() {
+		var m MetadataItem
+		var fileID int64
+		var runtime int64
+		var genres, dir, name, added string
+		if err := rows.Scan(&m.ID, &m.Title, &m.TitleSort, &m.OriginalTitle, &m.Studio,
+			&m.OriginallyAvailable, &m.Summary, &m.Rating, &m.ContentRating, &runtime,
+			&genres, &fileID, &dir, &name, &added); err != nil {
+			return nil, fmt.Errorf("failed to scan movie: %w", err)
+		}
+		m.LibrarySectionID = kodiMovies.ID
+		m.MetadataType = MediaTypeMovie
+		m.Year = kodiYear(m.OriginallyAvailable)
+		m.Duration = time.Duration(runtime) * time.Second
+		m.AddedAt = kodiTime(added)
+		m.Genres = kodiList(genres)
+		m.ExternalIDs = ids[m.ID]
+		movies = append(movies, MovieInfo{Metadata: m, Files: kodiFiles(fileID, dir, name)})
+	}
+	return movies, rows.Err()
+}
+
+func (k *KodiDB) getShows(ctx context.Context) ([]ShowInfo, error) {
+	ids, err := k.getUniqueIDs(ctx, "tvshow")
+	if err != nil {
+		return nil, err
+	}
+	seasonIDs, seasonNames, err := k.getSeasons(ctx)
+	if err != nil {
+		return nil, err
+	}
+	episodes, err := k.getEpisodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := k.db.QueryContext(ctx, `
+		SELECT t.idShow, COALESCE(t.c00, ''), COALESCE(t.c15, ''), COALESCE(t.c05, ''), COALESCE(t.c08, ''), COALESCE(t.c14, ''),
+			COALESCE(t.c01, ''), r.rating, COALESCE(t.c13, '')
+		FROM tvshow t
+		LEFT JOIN rating r ON r.rating_id = t.c04
+		ORDER BY t.c00
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shows: %w", err)
+	}
+	defer rows.Close()
+
+	var shows []ShowInfo
+	for rows.Next() {
+		var show MetadataItem
+		var genres string
+		if err := rows.Scan(&show.ID, &show.Title, &show.TitleSort, &show.OriginallyAvailable, &genres, &show.Studio,
+			&show.Summary, &show.Rating, &show.ContentRating); err != nil {
+			return nil, fmt.Errorf("failed to scan show: %w", err)
+		}
+		show.LibrarySectionID = kodiShows.ID
+		show.MetadataType = MediaTypeShow
+		show.Year = kodiYear(show.OriginallyAvailable)
+		show.Genres = kodiList(genres)
+		show.ExternalIDs = ids[show.ID]
+
+		info := ShowInfo{Metadata: show}
+		for _, episode := range episodes[show.ID] {
+			number := indexOf(&episode.season)
+			if n := len(info.Seasons); n == 0 || indexOf(&info.Seasons[n-1].Metadata) != number {
+				season := episode.season
+				key := [2]int64{show.ID, int64(number)}
+				if season.ID = seasonIDs[key]; season.ID == 0 {
+					season.ID = -(show.ID*10000 + int64(number))
+				}
+				season.Title = seasonNames[key]
+				season.ParentID = &show.ID
+				info.Seasons = append(info.Seasons, SeasonInfo{Metadata: season})
+			}
+			if year := kodiYear(episode.info.Metadata.OriginallyAvailable); year != nil {
+				info.Metadata.addAired(*year)
+			}
+			s := &info.Seasons[len(info.Seasons)-1]
+			seasonID := s.Metadata.ID
+			episode.info.Metadata.ParentID = &seasonID
+			s.Episodes = append(s.Episodes, episode.info)
+		}
+		shows = append(shows, info)
+	}
+	return shows, rows.Err()
+}
+
+// kodiEpisode is an episode and the season it is in
+type kodiEpisode struct {
+	info   EpisodeInfo
+	season MetadataItem
+}
+
+// getEpisodes returns the episodes of each show, in order
+func (k *KodiDB) getEpisodes(ctx context.Context) (map[int64][]kodiEpisode, error) {
+	rows, err := k.db.QueryContext(ctx, `
+		SELECT e.idEpisode, e.idShow, COALESCE(e.c00, ''), COALESCE(e.c05, ''),
+			COALESCE(e.c01, ''), r.rating, COALESCE(CAST(e.c09 AS INTEGER), 0),
+			CAST(e.c12 AS INTEGER), CAST(e.c13 AS INTEGER), f.idFile, p.strPath, f.strFilename, COALESCE(f.dateAdded, '')
+		FROM episode e
+		JOIN files f ON f.idFile = e.idFile
+		JOIN path p ON p.idPath = f.idPath
+		LEFT JOIN rating r ON r.rating_id = e.c03
+		ORDER BY e.idShow, CAST(e.c12 AS INTEGER), CAST(e.c13 AS INTEGER)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query episodes: %w", err)
+	}
+	defer rows.Close()
+
+	episodes := map[int64][]kodiEpisode{}
+	for rows.Next() {
+		var e MetadataItem
+		var showID, fileID int64
+		var season, number int
+		var runtime int64
+		var dir, name, added string
+		if err := rows.Scan(&e.ID, &showID, &e.Title, &e.OriginallyAvailable, &e.Summary, &e.Rating, &runtime,
+			&season, &number, &fileID, &dir, &name, &added); err != nil {
+			return nil, fmt.Errorf("failed to scan episode: %w", err)
+		}
+		e.LibrarySectionID = kodiShows.ID
+		e.MetadataType = MediaTypeEpisode
+		e.Index = &number
+		e.Duration = time.Duration(runtime) * time.Second
+		e.AddedAt = kodiTime(added)
+		episodes[showID] = append(episodes[showID], kodiEpisode{
+			info:   EpisodeInfo{Metadata: e, Files: kodiFiles(fileID, dir, name)},
+			season: MetadataItem{LibrarySectionID: kodiShows.ID, MetadataType: MediaTypeSeason, Index: &season},
+		})
+	}
+	return episodes, rows.Err()
+}
+
+// getSeasons returns the IDs and names of the seasons Kodi knows, by show
+// and season number
+func (k *KodiDB) getSeasons(ctx context.Context) (map[[2]int64]int64, map[[2]int64]string, error) {
+	rows, err := k.db.QueryContext(ctx, `SELECT idSeason, idShow, season, COALESCE(name, '') FROM seasons`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query seasons: %w", err)
+	}
+	defer rows.Close()
+
+	ids := map[[2]int64]int64{}
+	names := map[[2]int64]string{}
+	for rows.Next() {
+		var id, show, season int64
+		var name string
+		if err := rows.Scan(&id, &show, &season, &name); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan season: %w", err)
+		}
+		ids[[2]int64{show, season}] = id
+		names[[2]int64{show, season}] = name
+	}
+	return ids, names, rows.Err()
+}
+
+// getUniqueIDs returns the IDs at other databases of the items of a media
+// type, in the form Plex uses, e.g. tvdb://81189
+func (k *KodiDB) getUniqueIDs(ctx context.Context, mediaType string) (map[int64][]string, error) {
+	rows, err := k.db.QueryContext(ctx, `SELECT media_id, type, value FROM uniqueid WHERE media_type = ? ORDER BY uniqueid_id`, mediaType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query unique IDs: %w", err)
+	}
+	defer rows.Close()
+
+	ids := map[int64][]string{}
+	for rows.Next() {
+		var id int64
+		var source, value string
+		if err := rows.Scan(&id, &source, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan unique ID: %w", err)
+		}
+		if value != "" {
+			ids[id] = append(ids[id], strings.ToLower(source)+"://"+value)
+		}
+	}
+	return ids, rows.Err()
+}
+
+// kodiFiles returns the files of Kodi's file fileID from its folder and
+// name, which is a whole URL for a stream, or a stack://a , b URL for a
+// movie split into parts. Files that aren't on a disk or share, such as
+// streams and files in archives, are left out.
+func kodiFiles(fileID int64, dir, name string) []MediaPart {
+	paths := []string{dir + name}
+	if stack, ok := strings.CutPrefix(name, "stack://"); ok {
+		paths = nil
+		for _, part := range strings.Split(stack, " , ") {
+			paths = append(paths, strings.ReplaceAll(part, ",,", ","))
+		}
+	} else if strings.Contains(name, "://") {
+		paths = []string{name}
+	}
+
+	var files []MediaPart
+	for _, path := range paths {
+		if scheme, _, ok := strings.Cut(path, "://"); ok && scheme != "smb" && scheme != "nfs" {
+			continue
+		}
+		files = append(files, MediaPart{ID: fileID, MediaItemID: fileID, File: path})
+	}
+	return files
+}
+
+// kodiYear returns the year of a date such as 1999-03-31, or nil
+func kodiYear(date string) *int {
+	if len(date) < 4 {
+		return nil
+	}
+	year, err := strconv.Atoi(date[:4])
+	if err != nil || year == 0 {
+		return nil
+	}
+	return &year
+}
+
+// kodiTime parses a date and time as Kodi stores them, in local time
+func kodiTime(s string) time.Time {
+	t, _ := time.ParseInLocation("2006-01-02 15:04:05", s, time.Local)
+	return t
+}
+
+// kodiList splits a list of names such as genres, which Kodi separates
+// with " / "
+func kodiList(s string) []string {
+	var list []string
+	for _, name := range strings.Split(s, " / ") {
+		if name = strings.TrimSpace(name); name != "" {
+			list = append(list, name)
+		}
+	}
+	return list
+}
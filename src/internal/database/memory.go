@@ -0,0 +1,75 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"modernc.org/sqlite"
+)
+
+// LoadIntoMemory copies the database into memory and reads it from there
+// from then on. Each of the many small queries run while loading a library
+// then costs no round trip to the file, which adds up when it is on a
+// network share. It takes as much memory as the database is large.
+func (p *PlexDB) LoadIntoMemory(ctx context.Context) error {
+	mem, err := loadIntoMemory(ctx, p.uri)
+	if err != nil {
+		return fmt.Errorf("failed to load the database into memory: %w", err)
+	}
+	p.db.Close()
+	p.db = mem
+	return nil
+}
+
+// restorer is implemented by connections of the SQLite driver
+type restorer interface {
+	NewRestore(srcURI string) (*sqlite.Backup, error)
+}
+
+// loadIntoMemory returns an in-memory database holding a copy of the one
+// at uri, made with SQLite's backup, which copies it as SQLite sees it,
+// write-ahead log included
+func loadIntoMemory(ctx context.Context, uri string) (*sql.DB, error) {
+	mem, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		return nil, err
+	}
+	// Each connection to :memory: is a database of its own, so the one
+	// holding the copy must be the only one, and kept
+	mem.SetMaxOpenConns(1)
+	mem.SetMaxIdleConns(1)
+	mem.SetConnMaxLifetime(0)
+	mem.SetConnMaxIdleTime(0)
+
+	conn, err := mem.Conn(ctx)
+	if err != nil {
+		mem.Close()
+		return nil, err
+	}
+	defer conn.Close()
+	err = conn.Raw(func(dc any) error {
+		r, ok := dc.(restorer)
+		if !ok {
+			return fmt.Errorf("the SQLite driver can't copy databases")
+		}
+		restore, err := r.NewRestore(uri)
+		if err != nil {
+			return err
+		}
+		_, err = restore.Step(-1)
+		if finishErr := restore.Finish(); err == nil {
+			err = finishErr
+		}
+		return err
+	})
+	if err == nil {
+		// Like the file, the copy is only read
+		_, err = conn.ExecContext(ctx, `PRAGMA query_only = 1`)
+	}
+	if err != nil {
+		mem.Close()
+		return nil, err
+	}
+	return mem, nil
+}
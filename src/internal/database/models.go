@@ -1,5 +1,7 @@
 package database
 
+import "time"
+
 // LibrarySection represents a Plex library (e.g., "Movies", "TV Shows")
 type LibrarySection struct {
 	ID          int64
@@ -23,6 +25,7 @@ type MetadataItem struct {
 	LibrarySectionID    int64
 	MetadataType        int // 1 = movie, 2 = show, 3 = season, 4 = episode
 	ParentID            *int64
+	GUID                string // Agent identifier, e.g. plex://show/5d9c086c46115600200aa2fe
 	Title               string
 	TitleSort           string
 	OriginalTitle       string
@@ -30,6 +33,27 @@ type MetadataItem struct {
 	Year                *int
 	Index               *int // Episode/season number
 	OriginallyAvailable string
+	Summary             string        // Description of the plot
+	Rating              *float64      // Critic rating out of 10 (nil if unrated)
+	ContentRating       string        // e.g. PG-13 or TV-MA
+	Duration            time.Duration // Running time (zero if unknown)
+	AddedAt             time.Time     // When the item was added to the library (zero if unknown)
+	UpdatedAt           time.Time     // When its metadata last changed (zero if unknown)
+	Genres              []string      // Genre tags in Plex order (movies and shows only)
+	ExternalIDs         []string      // IDs at other databases, e.g. tvdb://81189 (movies and shows only)
+	FirstYear           int           // Years the first and last episodes aired (shows only, 0 if unknown)
+	LastYear            int
+}
+
+// addAired widens the years a show aired to take in year, if it is known
+func (m *MetadataItem) addAired(year int) {
+	if year <= 0 {
+		return
+	}
+	if m.FirstYear == 0 || year < m.FirstYear {
+		m.FirstYear = year
+	}
+	m.LastYear = max(m.LastYear, year)
 }
 
 // MediaItem links metadata to physical media files
@@ -50,6 +74,12 @@ type MediaPart struct {
 	MediaItemID int64
 	File        string // Full file path
 	Size        int64
+	Width       int // Resolution of the media item, which tells versions apart
+	Height      int
+	Bitrate     int    // Overall bitrate in bit/s (0 if unknown)
+	VideoCodec  string // As Plex names them, e.g. hevc or eac3
+	AudioCodec  string
+	Channels    int // Audio channels, e.g. 6 for 5.1
 }
 
 // MediaType constants
@@ -60,6 +90,12 @@ const (
 	MediaTypeEpisode = 4
 )
 
+// TagType constants (tags.tag_type)
+const (
+	TagTypeGenre = 1
+	TagTypeGUID  = 314 // External IDs of the new Plex agents, e.g. tvdb://81189
+)
+
 // SectionType constants
 const (
 	SectionTypeMovie = 1
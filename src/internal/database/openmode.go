@@ -0,0 +1,92 @@
+package database
+
+import (
+	"fmt"
+	"os"
+)
+
+// OpenMode is how a Plex database is opened while Plex may be writing to it
+type OpenMode string
+
+const (
+	// OpenAuto reads a snapshot when the database has a write-ahead log, and
+	// opens it immutable otherwise
+	OpenAuto OpenMode = ""
+	// OpenImmutable reads the database file as it is, without locking it
+	// and without what is still in its write-ahead log
+	OpenImmutable OpenMode = "immutable"
+	// OpenReadOnly reads the database with SQLite's locking, write-ahead log
+	// included. Plex's checkpoints wait while it is open.
+	OpenReadOnly OpenMode = "ro"
+	// OpenSnapshot reads a copy of the database and its write-ahead log,
+	// made when it is opened
+	OpenSnapshot OpenMode = "snapshot"
+)
+
+// ParseOpenMode parses a --db-open-mode value
+func ParseOpenMode(s string) (OpenMode, error) {
+	switch m := OpenMode(s); m {
+	case OpenAuto, OpenImmutable, OpenReadOnly, OpenSnapshot:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid db-open-mode: %s (use immutable, ro, or snapshot)", s)
+}
+
+// resolveOpenMode returns the mode the database at dbPath is opened in:
+// mode itself, or for OpenAuto, a snapshot if it has a write-ahead log and
+// immutable otherwise
+func resolveOpenMode(dbPath string, mode OpenMode) OpenMode {
+	if mode != OpenAuto {
+		return mode
+	}
+	if hasWAL(dbPath) {
+		return OpenSnapshot
+	}
+	return OpenImmutable
+}
+
+// hasWAL reports whether the database at dbPath has a write-ahead log with
+// frames in it, which an immutable open would not see
+func hasWAL(dbPath string) bool {
+	info, err := os.Stat(dbPath + "-wal")
+	return err == nil && info.Size() > 0
+}
+
+// snapshot writes a copy of the database at dbPath, with what is in its
+// write-ahead log, to a temporary file and returns the copy's path. SQLite
+// writes it with VACUUM INTO from a read-only connection, in one read
+// transaction, so a checkpoint while it runs can't tear the copy.
+func snapshot(dbPath string) (string, error) {
+	tmp, err := os.CreateTemp("", "plexrenamer-*.db")
+	if err != nil {
+		return "", fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	tmp.Close()
+	os.Remove(tmp.Name()) // VACUUM INTO creates it
+
+	db, err := openSQLite(dbPath, OpenReadOnly)
+	if err == nil {
+		// query_only forbids VACUUM INTO; mode=ro still keeps the database
+		// from being written
+		if _, err = db.Exec("PRAGMA query_only = 0"); err == nil {
+			_, err = db.Exec("VACUUM INTO ?", tmp.Name())
+		}
+		db.Close()
+	}
+	if err != nil {
+		removeTemp(tmp.Name())
+		if isFormatError(err) {
+			return "", &FormatError{Path: dbPath, Err: err}
+		}
+		return "", fmt.Errorf("failed to snapshot the database: %w", err)
+	}
+	return tmp.Name(), nil
+}
+
+// removeTemp removes a temporary database and the files SQLite keeps next
+// to it
+func removeTemp(path string) {
+	for _, suffix := range []string{"", "-wal", "-shm", "-journal"} {
+		os.Remove(path + suffix)
+	}
+}
@@ -0,0 +1,140 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// plexFixture creates a database with the tables read from Plex in WAL
+// mode, with its last changes still in the write-ahead log, as when Plex is
+// running. The files are copied while the connection writing them is open,
+// so the copy is as another program would find them. The folder has
+// characters that need escaping in SQLite URIs.
+func plexFixture(t *testing.T) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "Plex #1 100%")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	name := "com.plexapp.plugins.library.db"
+	db, err := sql.Open("sqlite", filepath.Join(dir, "writer.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	for _, stmt := range []string{
+		"PRAGMA journal_mode = WAL",
+		"PRAGMA wal_autocheckpoint = 0",
+		"CREATE TABLE library_sections (id INTEGER PRIMARY KEY, name TEXT, section_type INTEGER)",
+		"CREATE TABLE section_locations (id INTEGER PRIMARY KEY, library_section_id INTEGER, root_path TEXT, available BOOLEAN)",
+		`CREATE TABLE metadata_items (id INTEGER PRIMARY KEY, library_section_id INTEGER, metadata_type INTEGER, parent_id INTEGER, title TEXT, title_sort TEXT, year INTEGER, "index" INTEGER)`,
+		"CREATE TABLE media_items (id INTEGER PRIMARY KEY, metadata_item_id INTEGER)",
+		"CREATE TABLE media_parts (id INTEGER PRIMARY KEY, media_item_id INTEGER, file TEXT)",
+		"CREATE TABLE tags (id INTEGER PRIMARY KEY, tag TEXT, tag_type INTEGER)",
+		"CREATE TABLE taggings (metadata_item_id INTEGER, tag_id INTEGER)",
+		"INSERT INTO library_sections VALUES (1, 'Movies', 1)",
+		"PRAGMA wal_checkpoint(TRUNCATE)",
+		"INSERT INTO library_sections VALUES (2, 'TV Shows', 2)",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		data, err := os.ReadFile(filepath.Join(dir, "writer.db"+suffix))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name+suffix), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(dir, name)
+}
+
+// readFiles returns the contents of the database and the files SQLite keeps
+// next to it, by suffix
+func readFiles(t *testing.T, path string) map[string][]byte {
+	t.Helper()
+	files := map[string][]byte{}
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		data, err := os.ReadFile(path + suffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[suffix] = data
+	}
+	return files
+}
+
+func TestOpenModesLeaveDatabaseUnchanged(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     OpenMode
+		sections int // Immutable misses the section still in the log
+	}{
+		{"auto", OpenAuto, 2},
+		{"immutable", OpenImmutable, 1},
+		{"ro", OpenReadOnly, 2},
+		{"snapshot", OpenSnapshot, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := plexFixture(t)
+			before := readFiles(t, path)
+
+			p, err := OpenWith(path, tt.mode)
+			if err != nil {
+				t.Fatalf("OpenWith: %v", err)
+			}
+			sections, err := p.GetLibrarySections(context.Background())
+			if err != nil {
+				t.Fatalf("GetLibrarySections: %v", err)
+			}
+			if err := p.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+			if len(sections) != tt.sections {
+				t.Errorf("got %d sections, want %d", len(sections), tt.sections)
+			}
+
+			after := readFiles(t, path)
+			for suffix, data := range before {
+				if !bytes.Equal(data, after[suffix]) {
+					t.Errorf("%s changed", filepath.Base(path)+suffix)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheSeparatesImmutableReads(t *testing.T) {
+	path := plexFixture(t)
+	dir := t.TempDir()
+	immutable, err := NewCache(dir, path, OpenImmutable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := &LibraryContent{Section: LibrarySection{ID: 1, Name: "Movies"}}
+	if err := immutable.Save(content); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mode := range []OpenMode{OpenAuto, OpenSnapshot, OpenReadOnly} {
+		cache, err := NewCache(dir, path, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := cache.Load(content.Section); ok {
+			t.Errorf("mode %q loaded content cached by an immutable read, which misses the write-ahead log", mode)
+		}
+	}
+	if _, ok := immutable.Load(content.Section); !ok {
+		t.Error("immutable read didn't load its own content")
+	}
+}
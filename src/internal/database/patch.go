@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+)
+
+// WriteCopy writes a copy of the database to copyPath with the paths of
+// media files changed as given by paths (path in the database to new path),
+// and returns how many files were changed. The database itself is only
+// read. Plex's folder entries are left as they are; Plex corrects them on
+// its next scan.
+func (p *PlexDB) WriteCopy(ctx context.Context, copyPath string, paths map[string]string) (int, error) {
+	if _, err := os.Stat(copyPath); err == nil {
+		return 0, fmt.Errorf("%s already exists; choose a new file for the copy", copyPath)
+	}
+	if err := p.vacuumInto(ctx, copyPath); err != nil {
+		os.Remove(copyPath)
+		return 0, fmt.Errorf("failed to copy database: %w", err)
+	}
+
+	changed, err := updatePartPaths(ctx, copyPath, paths)
+	if err != nil {
+		os.Remove(copyPath)
+		return 0, err
+	}
+	return changed, nil
+}
+
+// vacuumInto writes a copy of the database to path. query_only forbids
+// VACUUM INTO, so it is lifted for it on a connection of its own; the
+// database itself is still opened read-only.
+func (p *PlexDB) vacuumInto(ctx context.Context, path string) error {
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "PRAGMA query_only = 0"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.Background(), "PRAGMA query_only = 1")
+	_, err = conn.ExecContext(ctx, "VACUUM INTO ?", path)
+	return err
+}
+
+// updatePartPaths changes the paths of media files in the database at
+// dbPath, in one transaction. The files are found by their old paths before
+// any is changed, so swapped or chained paths (A to B and B to A) each end
+// up where they belong.
+func updatePartPaths(ctx context.Context, dbPath string, paths map[string]string) (int, error) {
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open copy: %w", err)
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update copy: %w", err)
+	}
+	defer tx.Rollback()
+
+	updates := map[int64]string{} // Part ID to new path
+	for oldPath, newPath := range paths {
+		ids, err := partIDs(ctx, tx, oldPath)
+		if err != nil {
+			return 0, fmt.Errorf("failed to find %s in copy: %w", oldPath, err)
+		}
+		for _, id := range ids {
+			updates[id] = newPath
+		}
+	}
+	for id, newPath := range updates {
+		if _, err := tx.ExecContext(ctx, "UPDATE media_parts SET file = ? WHERE id = ?", newPath, id); err != nil {
+			return 0, fmt.Errorf("failed to update %s in copy: %w", newPath, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to update copy: %w", err)
+	}
+	return len(updates), nil
+}
+
+// partIDs returns the IDs of the media files at path
+func partIDs(ctx context.Context, tx *sql.Tx, path string) ([]int64, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM media_parts WHERE file = ?", path)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -1,33 +1,108 @@
 package database
 
 import (
+	"cmp"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
 
 // PlexDB provides access to the Plex Media Server database
 type PlexDB struct {
-	db *sql.DB
+	db     *sql.DB
+	path   string
+	temp   string // Database extracted from a backup archive, removed by Close
+	uri    string // SQLite URI the database was opened with
+	schema *plexSchema
+
+	// IncludeMissing also reads the items and files Plex keeps after they
+	// were deleted (deleted_at is set), which are left out by default
+	IncludeMissing bool
 }
 
-// Open opens a Plex database file
+// Open opens a Plex database file, or the library database in a zip or tar
+// backup of the Plex data directory, which is extracted to a temporary file
 func Open(dbPath string) (*PlexDB, error) {
-	// Use file: URI with read-only mode and immutable flag for WAL databases
-	absPath, err := filepath.Abs(dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	return OpenWith(dbPath, OpenAuto)
+}
+
+// OpenWith opens a Plex database like Open, in the given mode
+func OpenWith(dbPath string, mode OpenMode) (*PlexDB, error) {
+	if isArchive(dbPath) {
+		temp, err := extractBackup(dbPath)
+		if err != nil {
+			return nil, err
+		}
+		p, err := OpenWith(temp, OpenImmutable)
+		var formatErr *FormatError
+		if errors.As(err, &formatErr) {
+			return nil, &FormatError{Path: dbPath, Err: formatErr.Err, Extracted: temp}
+		}
+		if err != nil {
+			os.Remove(temp)
+			return nil, err
+		}
+		p.path, p.temp = dbPath, temp
+		return p, nil
 	}
 
-	// Convert Windows paths for SQLite URI
-	absPath = strings.ReplaceAll(absPath, "\\", "/")
+	mode = resolveOpenMode(dbPath, mode)
+	if mode == OpenSnapshot {
+		temp, err := snapshot(dbPath)
+		if err != nil {
+			return nil, err
+		}
+		p, err := OpenWith(temp, OpenReadOnly)
+		if err != nil {
+			removeTemp(temp)
+			return nil, err
+		}
+		p.path, p.temp = dbPath, temp
+		return p, nil
+	}
 
-	// Use immutable=1 to handle WAL mode databases that might be in use
-	// This allows reading even if WAL files are present
-	uri := fmt.Sprintf("file:%s?mode=ro&immutable=1", absPath)
+	db, err := openSQLite(dbPath, mode)
+	if isFormatError(err) {
+		return nil, &FormatError{Path: dbPath, Err: err}
+	}
+	if err != nil {
+		return nil, err
+	}
+	schema, err := readSchema(context.Background(), db)
+	if err == nil {
+		// Plex's own SQLite declares collations on titles that others lack
+		err = db.QueryRow(`SELECT title_sort FROM metadata_items ORDER BY title_sort LIMIT 1`).Scan(new(any))
+		if errors.Is(err, sql.ErrNoRows) {
+			err = nil
+		}
+	}
+	if err != nil {
+		db.Close()
+		if isFormatError(err) {
+			return nil, &FormatError{Path: dbPath, Err: err}
+		}
+		return nil, err
+	}
+	uri, _ := sqliteURI(dbPath, mode)
+	return &PlexDB{db: db, path: dbPath, uri: uri, schema: schema}, nil
+}
+
+// openSQLite opens a SQLite database read-only, also while a media server
+// has it open, immutable or with locking (OpenReadOnly)
+func openSQLite(dbPath string, mode OpenMode) (*sql.DB, error) {
+	uri, err := sqliteURI(dbPath, mode)
+	if err != nil {
+		return nil, err
+	}
 
 	db, err := sql.Open("sqlite", uri)
 	if err != nil {
@@ -43,23 +118,69 @@ func Open(dbPath string) (*PlexDB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &PlexDB{db: db}, nil
+	return db, nil
+}
+
+// sqliteURI returns the URI openSQLite opens a database with
+func sqliteURI(dbPath string, mode OpenMode) (string, error) {
+	// Use file: URI with read-only mode and immutable flag for WAL databases
+	absPath, err := filepath.Abs(dbPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	absPath = uriPath(absPath)
+
+	// Use immutable=1 to handle WAL mode databases that might be in use
+	// This allows reading even if WAL files are present, but not what is in
+	// them. query_only makes sure nothing is written either way.
+	uri := fmt.Sprintf("file:%s?mode=ro&_pragma=query_only(1)", absPath)
+	if mode != OpenReadOnly {
+		uri += "&immutable=1"
+	} else if _, err := os.Stat(dbPath + "-shm"); err == nil {
+		// Readers of the log take a slot in the shared memory file; with
+		// readonly_shm, they keep it in memory instead (except on Windows,
+		// where SQLite has no such option)
+		uri += "&readonly_shm=1"
+	}
+	return uri, nil
+}
+
+// uriPath returns absPath as the path of a SQLite file: URI, with forward
+// slashes and the characters that end a path or start an escape escaped
+func uriPath(absPath string) string {
+	return strings.NewReplacer("\\", "/", "%", "%25", "?", "%3f", "#", "%23").Replace(absPath)
+}
+
+// present returns a condition that leaves out the rows of table, with alias
+// t, that Plex marks as deleted, or nothing with IncludeMissing or on
+// servers that don't mark them
+func (p *PlexDB) present(table, t string) string {
+	if p.IncludeMissing || !p.schema.columns[table]["deleted_at"] {
+		return ""
+	}
+	return " AND " + t + ".deleted_at IS NULL"
 }
 
 // Close closes the database connection
 func (p *PlexDB) Close() error {
-	return p.db.Close()
+	err := p.db.Close()
+	if p.temp != "" {
+		removeTemp(p.temp)
+	}
+	return err
 }
 
 // GetLibrarySections returns all library sections
-func (p *PlexDB) GetLibrarySections() ([]LibrarySection, error) {
+func (p *PlexDB) GetLibrarySections(ctx context.Context) ([]LibrarySection, error) {
 	query := `
-		SELECT id, name, section_type, language, agent
+		SELECT id, name, section_type, COALESCE(` + p.column("library_sections", "library_sections", "language") + `, ''),
+		       COALESCE(` + p.column("library_sections", "library_sections", "agent") + `, '')
 		FROM library_sections
 		ORDER BY name
 	`
 
-	rows, err := p.db.Query(query)
+	rows, err := p.query(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query library sections: %w", err)
 	}
@@ -78,14 +199,14 @@ func (p *PlexDB) GetLibrarySections() ([]LibrarySection, error) {
 }
 
 // GetSectionLocations returns all root paths for a library section
-func (p *PlexDB) GetSectionLocations(sectionID int64) ([]SectionLocation, error) {
+func (p *PlexDB) GetSectionLocations(ctx context.Context, sectionID int64) ([]SectionLocation, error) {
 	query := `
 		SELECT id, library_section_id, root_path, available
 		FROM section_locations
 		WHERE library_section_id = ?
 	`
 
-	rows, err := p.db.Query(query, sectionID)
+	rows, err := p.query(ctx, query, sectionID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query section locations: %w", err)
 	}
@@ -103,35 +224,106 @@ func (p *PlexDB) GetSectionLocations(sectionID int64) ([]SectionLocation, error)
 	return locations, rows.Err()
 }
 
-// GetMetadataItems returns metadata items for a section of a specific type
-func (p *PlexDB) GetMetadataItems(sectionID int64, metadataType int) ([]MetadataItem, error) {
-	query := `
-		SELECT id, library_section_id, metadata_type,
-		       parent_id,
-		       title, title_sort, COALESCE(original_title, ''),
-		       COALESCE(studio, ''), year, "index",
-		       COALESCE(originally_available_at, '')
-		FROM metadata_items
-		WHERE library_section_id = ? AND metadata_type = ?
-		ORDER BY title_sort
-	`
+// metadataColumns returns the metadata_items columns read by scanDest,
+// qualified with the given table alias
+func (p *PlexDB) metadataColumns(t string) string {
+	col := func(column string) string { return p.column(t, "metadata_items", column) }
+	return fmt.Sprintf(`
+		%[1]s.id, %[1]s.library_section_id, %[1]s.metadata_type,
+		%[1]s.parent_id, COALESCE(%[2]s, ''),
+		%[1]s.title, %[1]s.title_sort, COALESCE(%[3]s, ''),
+		COALESCE(%[4]s, ''), %[1]s.year, %[1]s."index",
+		COALESCE(%[5]s, ''), COALESCE(%[6]s, ''),
+		%[7]s, COALESCE(%[8]s, ''), COALESCE(%[9]s, 0),
+		COALESCE(%[10]s, 0), COALESCE(%[11]s, 0)`, t,
+		col("guid"), col("original_title"), col("studio"), col("originally_available_at"), col("summary"),
+		col("rating"), col("content_rating"), col("duration"), col("added_at"), col("updated_at"))
+}
 
-	rows, err := p.db.Query(query, sectionID, metadataType)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query metadata items: %w", err)
+// partColumns returns the media_parts (mp) and media_items (mi) columns of
+// a MediaPart, in its order
+func (p *PlexDB) partColumns() string {
+	mp := func(column string) string { return p.column("mp", "media_parts", column) }
+	mi := func(column string) string { return p.column("mi", "media_items", column) }
+	return fmt.Sprintf(`
+		       mp.id, mp.media_item_id, mp.file, COALESCE(%s, 0),
+		       COALESCE(%s, 0), COALESCE(%s, 0), COALESCE(%s, 0),
+		       COALESCE(%s, ''), COALESCE(%s, ''), COALESCE(%s, 0)`,
+		mp("size"), mi("width"), mi("height"), mi("bitrate"), mi("video_codec"), mi("audio_codec"), mi("audio_channels"))
+}
+
+// scanDest returns the scan destinations matching metadataColumns
+func (m *MetadataItem) scanDest() []any {
+	return []any{
+		&m.ID, &m.LibrarySectionID, &m.MetadataType,
+		&m.ParentID, &m.GUID,
+		&m.Title, &m.TitleSort, &m.OriginalTitle,
+		&m.Studio, &m.Year, &m.Index,
+		&m.OriginallyAvailable, &m.Summary,
+		&m.Rating, &m.ContentRating, plexDuration{&m.Duration},
+		plexTime{&m.AddedAt}, plexTime{&m.UpdatedAt},
 	}
+}
+
+// plexDuration scans a Plex duration, stored in milliseconds
+type plexDuration struct{ d *time.Duration }
+
+func (p plexDuration) Scan(src any) error {
+	switch v := src.(type) {
+	case int64:
+		*p.d = time.Duration(v) * time.Millisecond
+	case float64:
+		*p.d = time.Duration(v * float64(time.Millisecond))
+	case string:
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			*p.d = time.Duration(n) * time.Millisecond
+		}
+	case []byte:
+		return p.Scan(string(v))
+	}
+	return nil
+}
+
+// plexTime scans a Plex timestamp into a time.Time. Plex stores Unix times,
+// but databases that went through other tools may hold dates as text.
+type plexTime struct{ t *time.Time }
+
+func (p plexTime) Scan(src any) error {
+	switch v := src.(type) {
+	case int64:
+		if v > 0 {
+			*p.t = time.Unix(v, 0)
+		}
+	case float64:
+		if v > 0 {
+			*p.t = time.Unix(int64(v), 0)
+		}
+	case time.Time:
+		*p.t = v
+	case string:
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return p.Scan(n)
+		}
+		for _, layout := range []string{time.DateTime, time.DateOnly, time.RFC3339} {
+			if t, err := time.Parse(layout, v); err == nil {
+				*p.t = t
+				break
+			}
+		}
+	case []byte:
+		return p.Scan(string(v))
+	}
+	return nil
+}
+
+// scanMetadataItems reads all rows of a query selecting metadataColumns
+func scanMetadataItems(rows *sql.Rows) ([]MetadataItem, error) {
 	defer rows.Close()
 
 	var items []MetadataItem
 	for rows.Next() {
 		var m MetadataItem
-		if err := rows.Scan(
-			&m.ID, &m.LibrarySectionID, &m.MetadataType,
-			&m.ParentID,
-			&m.Title, &m.TitleSort, &m.OriginalTitle,
-			&m.Studio, &m.Year, &m.Index,
-			&m.OriginallyAvailable,
-		); err != nil {
+		if err := rows.Scan(m.scanDest()...); err != nil {
 			return nil, fmt.Errorf("failed to scan metadata item: %w", err)
 		}
 		items = append(items, m)
@@ -140,53 +332,71 @@ func (p *PlexDB) GetMetadataItems(sectionID int64, metadataType int) ([]Metadata
 	return items, rows.Err()
 }
 
+// GetMetadataItems returns metadata items for a section of a specific type
+func (p *PlexDB) GetMetadataItems(ctx context.Context, sectionID int64, metadataType int) ([]MetadataItem, error) {
+	query := `SELECT` + p.metadataColumns("m") + `
+		FROM metadata_items m
+		WHERE library_section_id = ? AND metadata_type = ?` + p.present("metadata_items", "m") + `
+		ORDER BY title_sort
+	`
+
+	rows, err := p.query(ctx, query, sectionID, metadataType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metadata items: %w", err)
+	}
+	return scanMetadataItems(rows)
+}
+
 // GetChildMetadata returns child metadata items (episodes for a season, seasons for a show)
-func (p *PlexDB) GetChildMetadata(parentID int64) ([]MetadataItem, error) {
-	query := `
-		SELECT id, library_section_id, metadata_type,
-		       parent_id,
-		       title, title_sort, COALESCE(original_title, ''),
-		       COALESCE(studio, ''), year, "index",
-		       COALESCE(originally_available_at, '')
-		FROM metadata_items
-		WHERE parent_id = ?
+func (p *PlexDB) GetChildMetadata(ctx context.Context, parentID int64) ([]MetadataItem, error) {
+	query := `SELECT` + p.metadataColumns("m") + `
+		FROM metadata_items m
+		WHERE parent_id = ?` + p.present("metadata_items", "m") + `
 		ORDER BY "index"
 	`
 
-	rows, err := p.db.Query(query, parentID)
+	rows, err := p.query(ctx, query, parentID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query child metadata: %w", err)
 	}
-	defer rows.Close()
+	return scanMetadataItems(rows)
+}
 
-	var items []MetadataItem
-	for rows.Next() {
-		var m MetadataItem
-		if err := rows.Scan(
-			&m.ID, &m.LibrarySectionID, &m.MetadataType,
-			&m.ParentID,
-			&m.Title, &m.TitleSort, &m.OriginalTitle,
-			&m.Studio, &m.Year, &m.Index,
-			&m.OriginallyAvailable,
-		); err != nil {
-			return nil, fmt.Errorf("failed to scan child metadata: %w", err)
-		}
-		items = append(items, m)
+// getChildrenByParent returns all items of a type in a section (seasons or
+// episodes), grouped by parent ID and ordered by index
+func (p *PlexDB) getChildrenByParent(ctx context.Context, sectionID int64, metadataType int) (map[int64][]MetadataItem, error) {
+	query := `SELECT` + p.metadataColumns("m") + `
+		FROM metadata_items m
+		WHERE library_section_id = ? AND metadata_type = ? AND parent_id IS NOT NULL` + p.present("metadata_items", "m") + `
+		ORDER BY "index", id
+	`
+
+	rows, err := p.query(ctx, query, sectionID, metadataType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query child metadata: %w", err)
+	}
+	items, err := scanMetadataItems(rows)
+	if err != nil {
+		return nil, err
 	}
 
-	return items, rows.Err()
+	children := make(map[int64][]MetadataItem)
+	for _, item := range items {
+		children[*item.ParentID] = append(children[*item.ParentID], item)
+	}
+	return children, nil
 }
 
 // GetMediaParts returns all file paths for a metadata item
-func (p *PlexDB) GetMediaParts(metadataItemID int64) ([]MediaPart, error) {
+func (p *PlexDB) GetMediaParts(ctx context.Context, metadataItemID int64) ([]MediaPart, error) {
 	query := `
-		SELECT mp.id, mp.media_item_id, mp.file, COALESCE(mp.size, 0)
+		SELECT` + p.partColumns() + `
 		FROM media_parts mp
 		JOIN media_items mi ON mp.media_item_id = mi.id
-		WHERE mi.metadata_item_id = ?
+		WHERE mi.metadata_item_id = ?` + p.present("media_items", "mi") + p.present("media_parts", "mp") + `
 	`
 
-	rows, err := p.db.Query(query, metadataItemID)
+	rows, err := p.query(ctx, query, metadataItemID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query media parts: %w", err)
 	}
@@ -195,7 +405,7 @@ func (p *PlexDB) GetMediaParts(metadataItemID int64) ([]MediaPart, error) {
 	var parts []MediaPart
 	for rows.Next() {
 		var mp MediaPart
-		if err := rows.Scan(&mp.ID, &mp.MediaItemID, &mp.File, &mp.Size); err != nil {
+		if err := rows.Scan(&mp.ID, &mp.MediaItemID, &mp.File, &mp.Size, &mp.Width, &mp.Height, &mp.Bitrate, &mp.VideoCodec, &mp.AudioCodec, &mp.Channels); err != nil {
 			return nil, fmt.Errorf("failed to scan media part: %w", err)
 		}
 		parts = append(parts, mp)
@@ -204,12 +414,111 @@ func (p *PlexDB) GetMediaParts(metadataItemID int64) ([]MediaPart, error) {
 	return parts, rows.Err()
 }
 
-// GetLibraryContent returns all content for a library section
-func (p *PlexDB) GetLibraryContent(section LibrarySection) (*LibraryContent, error) {
+// getSectionMediaParts returns the files of all items of a type in a
+// section, keyed by metadata item ID
+func (p *PlexDB) getSectionMediaParts(ctx context.Context, sectionID int64, metadataType int) (map[int64][]MediaPart, error) {
+	query := `
+		SELECT mi.metadata_item_id,` + p.partColumns() + `
+		FROM media_parts mp
+		JOIN media_items mi ON mp.media_item_id = mi.id
+		JOIN metadata_items m ON mi.metadata_item_id = m.id
+		WHERE m.library_section_id = ? AND m.metadata_type = ?` + p.present("media_items", "mi") + p.present("media_parts", "mp") + `
+		ORDER BY mi.id, mp.id
+	`
+
+	rows, err := p.query(ctx, query, sectionID, metadataType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query media parts: %w", err)
+	}
+	defer rows.Close()
+
+	parts := make(map[int64][]MediaPart)
+	for rows.Next() {
+		var itemID int64
+		var mp MediaPart
+		if err := rows.Scan(&itemID, &mp.ID, &mp.MediaItemID, &mp.File, &mp.Size, &mp.Width, &mp.Height, &mp.Bitrate, &mp.VideoCodec, &mp.AudioCodec, &mp.Channels); err != nil {
+			return nil, fmt.Errorf("failed to scan media part: %w", err)
+		}
+		parts[itemID] = append(parts[itemID], mp)
+	}
+
+	return parts, rows.Err()
+}
+
+// getSectionTags returns the tags of a type (genres, external IDs) of all
+// items of a type in a section, keyed by metadata item ID
+func (p *PlexDB) getSectionTags(ctx context.Context, sectionID int64, metadataType, tagType int) (map[int64][]string, error) {
+	query := `
+		SELECT tg.metadata_item_id, t.tag
+		FROM taggings tg
+		JOIN tags t ON tg.tag_id = t.id
+		JOIN metadata_items m ON tg.metadata_item_id = m.id
+		WHERE m.library_section_id = ? AND m.metadata_type = ? AND t.tag_type = ?
+		ORDER BY tg.metadata_item_id, ` + p.column("tg", "taggings", "index") + `
+	`
+
+	rows, err := p.query(ctx, query, sectionID, metadataType, tagType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := make(map[int64][]string)
+	for rows.Next() {
+		var itemID int64
+		var tag string
+		if err := rows.Scan(&itemID, &tag); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags[itemID] = append(tags[itemID], tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// getAiredYears returns the years the first and last episodes of each show
+// in a section aired, by show. An episode's year is its own, or else that
+// of its air date.
+func (p *PlexDB) getAiredYears(ctx context.Context, sectionID int64) (map[int64][2]int, error) {
+	query := `
+		SELECT show_id, MIN(year), MAX(year) FROM (
+			SELECT CASE WHEN s.metadata_type = ? THEN s.id ELSE s.parent_id END AS show_id,
+			       COALESCE(e.year, CAST(substr(` + p.column("e", "metadata_items", "originally_available_at") + `, 1, 4) AS INTEGER)) AS year
+			FROM metadata_items e
+			JOIN metadata_items s ON e.parent_id = s.id
+			WHERE e.library_section_id = ? AND e.metadata_type = ?` + p.present("metadata_items", "e") + `
+		)
+		WHERE year > 0
+		GROUP BY show_id
+	`
+
+	rows, err := p.query(ctx, query, MediaTypeShow, sectionID, MediaTypeEpisode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query air years: %w", err)
+	}
+	defer rows.Close()
+
+	years := make(map[int64][2]int)
+	for rows.Next() {
+		var showID int64
+		var first, last int
+		if err := rows.Scan(&showID, &first, &last); err != nil {
+			return nil, fmt.Errorf("failed to scan air years: %w", err)
+		}
+		years[showID] = [2]int{first, last}
+	}
+
+	return years, rows.Err()
+}
+
+// GetLibraryContent returns all content for a library section. Each kind of
+// row (items, genres, files) is loaded for the whole section in one query,
+// so the number of queries doesn't grow with the size of the library.
+func (p *PlexDB) GetLibraryContent(ctx context.Context, section LibrarySection) (*LibraryContent, error) {
 	content := &LibraryContent{Section: section}
 
 	// Get locations
-	locations, err := p.GetSectionLocations(section.ID)
+	locations, err := p.GetSectionLocations(ctx, section.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -217,14 +526,14 @@ func (p *PlexDB) GetLibraryContent(section LibrarySection) (*LibraryContent, err
 
 	switch section.SectionType {
 	case SectionTypeMovie:
-		movies, err := p.getMovies(section.ID)
+		movies, err := p.getMovies(ctx, section.ID)
 		if err != nil {
 			return nil, err
 		}
 		content.Movies = movies
 
 	case SectionTypeShow:
-		shows, err := p.getShows(section.ID)
+		shows, err := p.getShows(ctx, section.ID)
 		if err != nil {
 			return nil, err
 		}
@@ -234,86 +543,147 @@ func (p *PlexDB) GetLibraryContent(section LibrarySection) (*LibraryContent, err
 	return content, nil
 }
 
-func (p *PlexDB) getMovies(sectionID int64) ([]MovieInfo, error) {
-	items, err := p.GetMetadataItems(sectionID, MediaTypeMovie)
+func (p *PlexDB) getMovies(ctx context.Context, sectionID int64) ([]MovieInfo, error) {
+	items, err := p.GetMetadataItems(ctx, sectionID, MediaTypeMovie)
+	if err != nil {
+		return nil, err
+	}
+	genres, err := p.getSectionTags(ctx, sectionID, MediaTypeMovie, TagTypeGenre)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := p.getSectionTags(ctx, sectionID, MediaTypeMovie, TagTypeGUID)
+	if err != nil {
+		return nil, err
+	}
+	files, err := p.getSectionMediaParts(ctx, sectionID, MediaTypeMovie)
 	if err != nil {
 		return nil, err
 	}
 
 	var movies []MovieInfo
 	for _, item := range items {
-		files, err := p.GetMediaParts(item.ID)
-		if err != nil {
-			return nil, err
-		}
+		item.Genres = genres[item.ID]
+		item.ExternalIDs = ids[item.ID]
 		movies = append(movies, MovieInfo{
 			Metadata: item,
-			Files:    files,
+			Files:    files[item.ID],
 		})
 	}
 
 	return movies, nil
 }
 
-func (p *PlexDB) getShows(sectionID int64) ([]ShowInfo, error) {
-	shows, err := p.GetMetadataItems(sectionID, MediaTypeShow)
+func (p *PlexDB) getShows(ctx context.Context, sectionID int64) ([]ShowInfo, error) {
+	shows, err := p.GetMetadataItems(ctx, sectionID, MediaTypeShow)
+	if err != nil {
+		return nil, err
+	}
+	genres, err := p.getSectionTags(ctx, sectionID, MediaTypeShow, TagTypeGenre)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := p.getSectionTags(ctx, sectionID, MediaTypeShow, TagTypeGUID)
+	if err != nil {
+		return nil, err
+	}
+	aired, err := p.getAiredYears(ctx, sectionID)
+	if err != nil {
+		return nil, err
+	}
+	seasons, err := p.getChildrenByParent(ctx, sectionID, MediaTypeSeason)
+	if err != nil {
+		return nil, err
+	}
+	episodes, err := p.getChildrenByParent(ctx, sectionID, MediaTypeEpisode)
+	if err != nil {
+		return nil, err
+	}
+	files, err := p.getSectionMediaParts(ctx, sectionID, MediaTypeEpisode)
 	if err != nil {
 		return nil, err
 	}
 
 	var showInfos []ShowInfo
 	for _, show := range shows {
-		seasons, err := p.getSeasons(show.ID)
-		if err != nil {
-			return nil, err
+		show.Genres = genres[show.ID]
+		show.ExternalIDs = ids[show.ID]
+		show.FirstYear, show.LastYear = aired[show.ID][0], aired[show.ID][1]
+
+		var seasonInfos []SeasonInfo
+		for _, season := range seasons[show.ID] {
+			var episodeInfos []EpisodeInfo
+			for _, episode := range episodes[season.ID] {
+				episodeInfos = append(episodeInfos, EpisodeInfo{
+					Metadata: episode,
+					Files:    files[episode.ID],
+				})
+			}
+			seasonInfos = append(seasonInfos, SeasonInfo{
+				Metadata: season,
+				Episodes: episodeInfos,
+			})
 		}
+
+		// Some agents store episodes directly under the show
+		if loose := episodes[show.ID]; len(loose) > 0 {
+			for _, episode := range loose {
+				season := looseSeason(&show, &episode)
+				i := slices.IndexFunc(seasonInfos, func(s SeasonInfo) bool {
+					return s.Metadata.Index != nil && *s.Metadata.Index == *season.Index
+				})
+				if i < 0 {
+					seasonInfos = append(seasonInfos, SeasonInfo{Metadata: season})
+					i = len(seasonInfos) - 1
+				}
+				seasonInfos[i].Episodes = append(seasonInfos[i].Episodes, EpisodeInfo{
+					Metadata: episode,
+					Files:    files[episode.ID],
+				})
+			}
+			slices.SortStableFunc(seasonInfos, func(a, b SeasonInfo) int {
+				return cmp.Compare(indexOf(&a.Metadata), indexOf(&b.Metadata))
+			})
+			for i := range seasonInfos {
+				slices.SortStableFunc(seasonInfos[i].Episodes, func(a, b EpisodeInfo) int {
+					return cmp.Compare(indexOf(&a.Metadata), indexOf(&b.Metadata))
+				})
+			}
+		}
+
 		showInfos = append(showInfos, ShowInfo{
 			Metadata: show,
-			Seasons:  seasons,
+			Seasons:  seasonInfos,
 		})
 	}
 
 	return showInfos, nil
 }
 
-func (p *PlexDB) getSeasons(showID int64) ([]SeasonInfo, error) {
-	seasons, err := p.GetChildMetadata(showID)
-	if err != nil {
-		return nil, err
-	}
-
-	var seasonInfos []SeasonInfo
-	for _, season := range seasons {
-		episodes, err := p.getEpisodes(season.ID)
-		if err != nil {
-			return nil, err
+// looseSeason returns the season that an episode stored directly under a
+// show is put in: the year it aired if it has no episode number (as for
+// date-based shows), otherwise season 1. It has a negative ID, as it isn't
+// in the database.
+func looseSeason(show, episode *MetadataItem) MetadataItem {
+	index := 1
+	if episode.Index == nil && len(episode.OriginallyAvailable) >= 4 {
+		if year, err := strconv.Atoi(episode.OriginallyAvailable[:4]); err == nil {
+			index = year
 		}
-		seasonInfos = append(seasonInfos, SeasonInfo{
-			Metadata: season,
-			Episodes: episodes,
-		})
 	}
-
-	return seasonInfos, nil
-}
-
-func (p *PlexDB) getEpisodes(seasonID int64) ([]EpisodeInfo, error) {
-	episodes, err := p.GetChildMetadata(seasonID)
-	if err != nil {
-		return nil, err
+	return MetadataItem{
+		ID:               -(show.ID*10000 + int64(index)),
+		LibrarySectionID: show.LibrarySectionID,
+		MetadataType:     MediaTypeSeason,
+		ParentID:         &show.ID,
+		Index:            &index,
 	}
+}
 
-	var episodeInfos []EpisodeInfo
-	for _, episode := range episodes {
-		files, err := p.GetMediaParts(episode.ID)
-		if err != nil {
-			return nil, err
-		}
-		episodeInfos = append(episodeInfos, EpisodeInfo{
-			Metadata: episode,
-			Files:    files,
-		})
+// indexOf returns the index of an item, or 0 if it has none
+func indexOf(m *MetadataItem) int {
+	if m.Index == nil {
+		return 0
 	}
-
-	return episodeInfos, nil
+	return *m.Index
 }
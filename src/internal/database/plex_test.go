@@ -0,0 +1,144 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// libraryFixture creates a Plex database with a movie section of movies
+// movies and a TV section of shows shows, each with 5 seasons of 20
+// episodes, laid out and indexed as Plex does
+func libraryFixture(b *testing.B, movies, shows int) string {
+	b.Helper()
+	path := filepath.Join(b.TempDir(), "com.plexapp.plugins.library.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+	for _, stmt := range []string{
+		"CREATE TABLE library_sections (id INTEGER PRIMARY KEY, name TEXT, section_type INTEGER, language TEXT, agent TEXT)",
+		"CREATE TABLE section_locations (id INTEGER PRIMARY KEY, library_section_id INTEGER, root_path TEXT, available BOOLEAN)",
+		`CREATE TABLE metadata_items (id INTEGER PRIMARY KEY, library_section_id INTEGER, metadata_type INTEGER, parent_id INTEGER, guid TEXT, title TEXT, title_sort TEXT, original_title TEXT, studio TEXT, year INTEGER, "index" INTEGER, originally_available_at DATETIME, summary TEXT, rating FLOAT, content_rating TEXT, duration INTEGER, added_at DATETIME, updated_at DATETIME, deleted_at DATETIME, tags_genre TEXT)`,
+		"CREATE TABLE media_items (id INTEGER PRIMARY KEY, metadata_item_id INTEGER, width INTEGER, height INTEGER, bitrate INTEGER, container TEXT, video_codec TEXT, audio_codec TEXT, size INTEGER, duration INTEGER, deleted_at DATETIME, audio_channels INTEGER)",
+		"CREATE TABLE media_parts (id INTEGER PRIMARY KEY, media_item_id INTEGER, file TEXT, size INTEGER, deleted_at DATETIME)",
+		"CREATE TABLE media_streams (id INTEGER PRIMARY KEY, stream_type_id INTEGER, media_item_id INTEGER, media_part_id INTEGER, url TEXT, codec TEXT, language TEXT, forced INTEGER, extra_data TEXT)",
+		"CREATE TABLE tags (id INTEGER PRIMARY KEY, tag TEXT, tag_type INTEGER)",
+		`CREATE TABLE taggings (id INTEGER PRIMARY KEY, metadata_item_id INTEGER, tag_id INTEGER, "index" INTEGER)`,
+		"CREATE INDEX index_metadata_items_on_parent_id ON metadata_items (parent_id)",
+		"CREATE INDEX index_metadata_items_on_library_section_id ON metadata_items (library_section_id, metadata_type)",
+		"CREATE INDEX index_media_items_on_metadata_item_id ON media_items (metadata_item_id)",
+		"CREATE INDEX index_media_parts_on_media_item_id ON media_parts (media_item_id)",
+		"CREATE INDEX index_taggings_on_metadata_item_id ON taggings (metadata_item_id)",
+		"INSERT INTO library_sections VALUES (1, 'Movies', 1, 'en', 'tv.plex.agents.movie'), (2, 'TV Shows', 2, 'en', 'tv.plex.agents.series')",
+		"INSERT INTO section_locations VALUES (1, 1, '/media/Movies', 1), (2, 2, '/media/TV', 1)",
+		"INSERT INTO tags VALUES (1, 'Drama', 1), (2, 'Comedy', 1)",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			b.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		b.Fatal(err)
+	}
+	id := int64(0)
+	item := func(section, metadataType int, parent any, title string, index int) int64 {
+		id++
+		if _, err := tx.Exec(`INSERT INTO metadata_items (id, library_section_id, metadata_type, parent_id, title, title_sort, year, "index") VALUES (?, ?, ?, ?, ?, ?, 2000, ?)`,
+			id, section, metadataType, parent, title, title, index); err != nil {
+			b.Fatal(err)
+		}
+		return id
+	}
+	file := func(metadataID int64, path string) {
+		if _, err := tx.Exec("INSERT INTO media_items (id, metadata_item_id, width, height, size) VALUES (?, ?, 1920, 1080, 1000)", metadataID, metadataID); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := tx.Exec("INSERT INTO media_parts (id, media_item_id, file, size) VALUES (?, ?, ?, 1000)", metadataID, metadataID, path); err != nil {
+			b.Fatal(err)
+		}
+	}
+	genre := func(metadataID int64) {
+		if _, err := tx.Exec(`INSERT INTO taggings (metadata_item_id, tag_id, "index") VALUES (?, ?, 0)`, metadataID, metadataID%2+1); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	for m := range movies {
+		movie := item(1, 1, nil, fmt.Sprintf("Movie %d", m), 0)
+		genre(movie)
+		file(movie, fmt.Sprintf("/media/Movies/Movie %d.mkv", m))
+	}
+	for s := range shows {
+		show := item(2, 2, nil, fmt.Sprintf("Show %d", s), 0)
+		genre(show)
+		for season := 1; season <= 5; season++ {
+			seasonID := item(2, 3, show, "", season)
+			for episode := 1; episode <= 20; episode++ {
+				episodeID := item(2, 4, seasonID, fmt.Sprintf("Episode %d", episode), episode)
+				file(episodeID, fmt.Sprintf("/media/TV/Show %d/Season %d/S%02dE%02d.mkv", s, season, season, episode))
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		b.Fatal(err)
+	}
+	return path
+}
+
+// benchmarkLibraryContent loads the section called name of a fixture
+// database each iteration
+func benchmarkLibraryContent(b *testing.B, movies, shows int, name string) {
+	ctx := context.Background()
+	p, err := Open(libraryFixture(b, movies, shows))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer p.Close()
+	sections, err := p.GetLibrarySections(ctx)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var section LibrarySection
+	for _, s := range sections {
+		if s.Name == name {
+			section = s
+		}
+	}
+
+	content, err := p.GetLibraryContent(ctx, section)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if len(content.Movies) != movies || len(content.Shows) != shows {
+		b.Fatalf("got %d movies and %d shows, want %d and %d", len(content.Movies), len(content.Shows), movies, shows)
+	}
+
+	for b.Loop() {
+		if _, err := p.GetLibraryContent(ctx, section); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetLibraryContentMovies(b *testing.B) {
+	for _, movies := range []int{1000, 10000} {
+		b.Run(fmt.Sprint(movies), func(b *testing.B) {
+			benchmarkLibraryContent(b, movies, 0, "Movies")
+		})
+	}
+}
+
+func BenchmarkGetLibraryContentShows(b *testing.B) {
+	// 100 episodes per show
+	for _, shows := range []int{10, 100} {
+		b.Run(fmt.Sprint(shows*100), func(b *testing.B) {
+			benchmarkLibraryContent(b, 0, shows, "TV Shows")
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// FormatError is returned by Open for a database the SQLite built into this
+// tool can't read: one written by a newer Plex Media Server with Plex's own
+// build of SQLite, which adds collations and full-text tokenizers, or one
+// that isn't a plain SQLite file at all (e.g. encrypted)
+type FormatError struct {
+	Path string
+	Err  error
+	// Extracted is the database extracted from Path if it is a backup
+	// archive, left for the caller to read with Plex SQLite and remove
+	Extracted string
+}
+
+func (e *FormatError) Error() string {
+	return fmt.Sprintf("%s can't be read directly (%v): newer Plex Media Server versions write their database with their own build of SQLite, \"Plex SQLite\", which has extensions this tool doesn't", e.Path, e.Err)
+}
+
+func (e *FormatError) Unwrap() error {
+	return e.Err
+}
+
+// formatErrors are the errors SQLite gives for databases that need Plex's
+// extensions, or aren't SQLite databases
+var formatErrors = []string{
+	"no such collation sequence",
+	"no such tokenizer",
+	"unknown tokenizer",
+	"no such module",
+	"malformed database schema",
+	"file is not a database",
+	"file is encrypted",
+}
+
+// isFormatError reports whether err is one of formatErrors
+func isFormatError(err error) bool {
+	return err != nil && slices.ContainsFunc(formatErrors, func(s string) bool {
+		return strings.Contains(err.Error(), s)
+	})
+}
+
+// plexSQLiteTables are the tables copied by OpenWithPlexSQLite besides
+// plexTables, if the database has them
+var plexSQLiteTables = []string{"media_streams", "metadata_item_settings", "schema_migrations"}
+
+// FindPlexSQLite returns the path of the Plex SQLite program installed with
+// Plex Media Server in its usual place, or found on the PATH, or "" if
+// there's none
+func FindPlexSQLite() string {
+	var candidates []string
+	switch runtime.GOOS {
+	case "windows":
+		for _, env := range []string{"ProgramFiles", "ProgramFiles(x86)"} {
+			if dir := os.Getenv(env); dir != "" {
+				candidates = append(candidates, filepath.Join(dir, "Plex", "Plex Media Server", "Plex SQLite.exe"))
+			}
+		}
+	case "darwin":
+		candidates = []string{"/Applications/Plex Media Server.app/Contents/MacOS/Plex SQLite"}
+	default:
+		candidates = []string{"/usr/lib/plexmediaserver/Plex SQLite", "/usr/lib64/plexmediaserver/Plex SQLite"}
+	}
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	if path, err := exec.LookPath("Plex SQLite"); err == nil {
+		return path
+	}
+	return ""
+}
+
+// OpenWithPlexSQLite copies the tables read from the Plex database at
+// dbPath into a temporary database with plexSQLite, Plex's build of the
+// sqlite3 shell, and opens the copy, which Close removes. The copy has no
+// indexes or collations, so it can be read whichever SQLite wrote the
+// original. The database is attached read-only, and immutable unless mode
+// reads its write-ahead log; the tables are then copied in one read
+// transaction, so they are a snapshot of one moment.
+func OpenWithPlexSQLite(ctx context.Context, plexSQLite, dbPath string, mode OpenMode) (*PlexDB, error) {
+	absPath, err := filepath.Abs(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	mode = resolveOpenMode(absPath, mode)
+	uri := "file:" + uriPath(absPath) + "?mode=ro"
+	if mode == OpenImmutable {
+		uri += "&immutable=1"
+	}
+	out, err := runPlexSQLite(ctx, plexSQLite, "SELECT name FROM sqlite_master WHERE type = 'table';", "-readonly", absPath)
+	if err != nil {
+		return nil, err
+	}
+	have := strings.Fields(string(out))
+	wanted := append(slices.Sorted(maps.Keys(plexTables)), plexSQLiteTables...)
+
+	tmp, err := os.CreateTemp("", "plexrenamer-*.db")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary database: %w", err)
+	}
+	tmp.Close()
+	os.Remove(tmp.Name()) // The shell creates it
+
+	script := []string{"ATTACH DATABASE " + sqlString(uri) + " AS plex;", "BEGIN;"}
+	for _, table := range wanted {
+		if slices.Contains(have, table) {
+			script = append(script, fmt.Sprintf(`CREATE TABLE main."%[1]s" AS SELECT * FROM plex."%[1]s";`, table))
+		}
+	}
+	script = append(script, "COMMIT;")
+	if _, err := runPlexSQLite(ctx, plexSQLite, strings.Join(script, "\n"), tmp.Name()); err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	p, err := Open(tmp.Name())
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	p.temp = tmp.Name()
+	return p, nil
+}
+
+// runPlexSQLite runs Plex SQLite with args, ending with the database, and
+// the given SQL as input, and returns its output
+func runPlexSQLite(ctx context.Context, plexSQLite, sql string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, plexSQLite, args...)
+	cmd.Stdin = strings.NewReader(sql + "\n")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err == nil && stderr.Len() > 0 {
+		// The shell carries on after a failed statement
+		err = fmt.Errorf("it reported an error")
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("failed to run %s: %w", plexSQLite, err)
+	}
+	return out, nil
+}
+
+// sqlString quotes s as an SQL string literal
+func sqlString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// plexTables are the tables read from a Plex database, with the columns that
+// must be there. The other columns read are left empty on servers whose
+// schema doesn't have them (see column).
+var plexTables = map[string][]string{
+	"library_sections":  {"id", "name", "section_type"},
+	"section_locations": {"id", "library_section_id", "root_path", "available"},
+	"metadata_items":    {"id", "library_section_id", "metadata_type", "parent_id", "title", "title_sort", "year", "index"},
+	"media_items":       {"id", "metadata_item_id"},
+	"media_parts":       {"id", "media_item_id", "file"},
+	"tags":              {"id", "tag", "tag_type"},
+	"taggings":          {"metadata_item_id", "tag_id"},
+}
+
+// plexSchema is what was found of the schema of a Plex database when it was
+// opened
+type plexSchema struct {
+	version string                     // Latest schema migration, empty if unknown
+	columns map[string]map[string]bool // By table
+}
+
+// SchemaError is returned when a Plex database's schema isn't one that can
+// be read: a table or column is missing, or a query fails on it
+type SchemaError struct {
+	Version string // Latest schema migration of the database, empty if unknown
+	Missing string // The table or table.column missing, if that is the problem
+	Query   string // The query that failed, if one did
+	Err     error
+}
+
+func (e *SchemaError) Error() string {
+	version := "unsupported schema v" + e.Version
+	if e.Version == "" {
+		version = "unsupported schema (unknown version)"
+	}
+	if e.Missing != "" {
+		return fmt.Sprintf("%s: %s is missing", version, e.Missing)
+	}
+	return fmt.Sprintf("%s: %v\nin query: %s", version, e.Err, e.Query)
+}
+
+func (e *SchemaError) Unwrap() error {
+	return e.Err
+}
+
+// readSchema reads the schema version of a Plex database and the columns of
+// the tables read, and checks that the required ones are there
+func readSchema(ctx context.Context, db *sql.DB) (*plexSchema, error) {
+	s := &plexSchema{columns: map[string]map[string]bool{}}
+	// Plex records each migration it ran; the table may be missing from
+	// copies made by other tools
+	var version sql.NullString
+	if err := db.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&version); err == nil {
+		s.version = version.String
+	}
+
+	for _, table := range slices.Sorted(maps.Keys(plexTables)) {
+		rows, err := db.QueryContext(ctx, `SELECT name FROM pragma_table_info(?)`, table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the columns of %s: %w", table, err)
+		}
+		columns := map[string]bool{}
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to read the columns of %s: %w", table, err)
+			}
+			columns[strings.ToLower(name)] = true
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the columns of %s: %w", table, err)
+		}
+
+		if len(columns) == 0 {
+			return nil, &SchemaError{Version: s.version, Missing: "table " + table}
+		}
+		for _, column := range plexTables[table] {
+			if !columns[column] {
+				return nil, &SchemaError{Version: s.version, Missing: table + "." + column}
+			}
+		}
+		s.columns[table] = columns
+	}
+	return s, nil
+}
+
+// SchemaVersion returns the latest schema migration of the database, which
+// changes with Plex Media Server versions, or "" if it isn't recorded
+func (p *PlexDB) SchemaVersion() string {
+	return p.schema.version
+}
+
+// column returns a column of the table with alias t, or NULL if the
+// database's schema doesn't have it
+func (p *PlexDB) column(t, table, column string) string {
+	if !p.schema.columns[table][column] {
+		return "NULL"
+	}
+	return t + `."` + column + `"`
+}
+
+// query runs a query, turning errors about tables or columns that don't
+// exist into a *SchemaError that shows the query, and those about Plex's
+// SQLite extensions into a *FormatError
+func (p *PlexDB) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil && (strings.Contains(err.Error(), "no such column") || strings.Contains(err.Error(), "no such table")) {
+		return nil, &SchemaError{Version: p.schema.version, Query: strings.Join(strings.Fields(query), " "), Err: err}
+	}
+	if isFormatError(err) {
+		return nil, &FormatError{Path: p.path, Err: err}
+	}
+	return rows, err
+}
@@ -0,0 +1,173 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// ForEachMovie calls fn for every movie in a section, in title order, reading
+// the movies and their files as it goes instead of loading the whole section
+// first. The database connection is busy until ForEachMovie returns, so fn
+// must not query the database. Returning an error from fn stops the iteration.
+func (p *PlexDB) ForEachMovie(ctx context.Context, sectionID int64, fn func(MovieInfo) error) error {
+	genres, err := p.getSectionTags(ctx, sectionID, MediaTypeMovie, TagTypeGenre)
+	if err != nil {
+		return err
+	}
+	ids, err := p.getSectionTags(ctx, sectionID, MediaTypeMovie, TagTypeGUID)
+	if err != nil {
+		return err
+	}
+
+	query := `SELECT` + p.metadataColumns("m") + `,
+` + p.partColumns() + `
+		FROM metadata_items m
+		LEFT JOIN media_items mi ON mi.metadata_item_id = m.id` + p.present("media_items", "mi") + `
+		LEFT JOIN media_parts mp ON mp.media_item_id = mi.id` + p.present("media_parts", "mp") + `
+		WHERE m.library_section_id = ? AND m.metadata_type = ?` + p.present("metadata_items", "m") + `
+		ORDER BY m.title_sort, m.id, mi.id, mp.id
+	`
+
+	rows, err := p.query(ctx, query, sectionID, MediaTypeMovie)
+	if err != nil {
+		return fmt.Errorf("failed to query movies: %w", err)
+	}
+	defer rows.Close()
+
+	var movie *MovieInfo
+	for rows.Next() {
+		var m MetadataItem
+		var part nullMediaPart
+		if err := rows.Scan(append(m.scanDest(), part.scanDest()...)...); err != nil {
+			return fmt.Errorf("failed to scan movie: %w", err)
+		}
+
+		if movie == nil || movie.Metadata.ID != m.ID {
+			if movie != nil {
+				if err := fn(*movie); err != nil {
+					return err
+				}
+			}
+			m.Genres = genres[m.ID]
+			m.ExternalIDs = ids[m.ID]
+			movie = &MovieInfo{Metadata: m}
+		}
+		if part.ID.Valid {
+			movie.Files = append(movie.Files, part.mediaPart())
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read movies: %w", err)
+	}
+
+	if movie != nil {
+		return fn(*movie)
+	}
+	return nil
+}
+
+// ForEachEpisode calls fn for every episode in a show section, ordered by
+// show title, season, and episode, reading them as it goes like ForEachMovie.
+// Consecutive episodes of the same show and season share the show and season
+// pointers. Episodes stored directly under their show are put in a season
+// like GetLibraryContent does. fn must not query the database.
+func (p *PlexDB) ForEachEpisode(ctx context.Context, sectionID int64, fn func(show, season *MetadataItem, episode EpisodeInfo) error) error {
+	genres, err := p.getSectionTags(ctx, sectionID, MediaTypeShow, TagTypeGenre)
+	if err != nil {
+		return err
+	}
+	ids, err := p.getSectionTags(ctx, sectionID, MediaTypeShow, TagTypeGUID)
+	if err != nil {
+		return err
+	}
+	aired, err := p.getAiredYears(ctx, sectionID)
+	if err != nil {
+		return err
+	}
+
+	query := `SELECT` + p.metadataColumns("sh") + `,` + p.metadataColumns("s") + `,` + p.metadataColumns("e") + `,
+` + p.partColumns() + `
+		FROM metadata_items e
+		JOIN metadata_items s ON e.parent_id = s.id
+		JOIN metadata_items sh ON sh.id = CASE WHEN s.metadata_type = ? THEN s.id ELSE s.parent_id END
+		LEFT JOIN media_items mi ON mi.metadata_item_id = e.id` + p.present("media_items", "mi") + `
+		LEFT JOIN media_parts mp ON mp.media_item_id = mi.id` + p.present("media_parts", "mp") + `
+		WHERE e.library_section_id = ? AND e.metadata_type = ?` + p.present("metadata_items", "e") + p.present("metadata_items", "s") + p.present("metadata_items", "sh") + `
+		ORDER BY sh.title_sort, sh.id, s."index", s.id, e."index", e.id, mi.id, mp.id
+	`
+
+	rows, err := p.query(ctx, query, MediaTypeShow, sectionID, MediaTypeEpisode)
+	if err != nil {
+		return fmt.Errorf("failed to query episodes: %w", err)
+	}
+	defer rows.Close()
+
+	var show, season *MetadataItem
+	var episode *EpisodeInfo
+	for rows.Next() {
+		var sh, s, e MetadataItem
+		var part nullMediaPart
+		dest := append(sh.scanDest(), s.scanDest()...)
+		dest = append(dest, e.scanDest()...)
+		if err := rows.Scan(append(dest, part.scanDest()...)...); err != nil {
+			return fmt.Errorf("failed to scan episode: %w", err)
+		}
+
+		if episode == nil || episode.Metadata.ID != e.ID {
+			if episode != nil {
+				if err := fn(show, season, *episode); err != nil {
+					return err
+				}
+			}
+			if show == nil || show.ID != sh.ID {
+				sh.Genres = genres[sh.ID]
+				sh.ExternalIDs = ids[sh.ID]
+				sh.FirstYear, sh.LastYear = aired[sh.ID][0], aired[sh.ID][1]
+				show = &sh
+			}
+			if s.MetadataType == MediaTypeShow {
+				s = looseSeason(show, &e)
+			}
+			if season == nil || season.ID != s.ID {
+				season = &s
+			}
+			episode = &EpisodeInfo{Metadata: e}
+		}
+		if part.ID.Valid {
+			episode.Files = append(episode.Files, part.mediaPart())
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read episodes: %w", err)
+	}
+
+	if episode != nil {
+		return fn(show, season, *episode)
+	}
+	return nil
+}
+
+// nullMediaPart holds media part columns from a LEFT JOIN, which are NULL
+// for items without files
+type nullMediaPart struct {
+	ID          sql.NullInt64
+	MediaItemID sql.NullInt64
+	File        sql.NullString
+	Size        int64
+	Width       int
+	Height      int
+	Bitrate     int
+	VideoCodec  string
+	AudioCodec  string
+	Channels    int
+}
+
+func (p *nullMediaPart) scanDest() []any {
+	return []any{&p.ID, &p.MediaItemID, &p.File, &p.Size, &p.Width, &p.Height, &p.Bitrate, &p.VideoCodec, &p.AudioCodec, &p.Channels}
+}
+
+func (p *nullMediaPart) mediaPart() MediaPart {
+	return MediaPart{ID: p.ID.Int64, MediaItemID: p.MediaItemID.Int64, File: p.File.String, Size: p.Size, Width: p.Width, Height: p.Height, Bitrate: p.Bitrate,
+		VideoCodec: p.VideoCodec, AudioCodec: p.AudioCodec, Channels: p.Channels}
+}
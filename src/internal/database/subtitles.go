@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Subtitle is an external subtitle file Plex found next to a video, as kept
+// in media_streams
+type Subtitle struct {
+	File     string
+	Language string // ISO 639 code, e.g. en or eng, if Plex knows it
+	Forced   bool
+	SDH      bool // For the deaf and hard of hearing
+}
+
+// streamTypeSubtitle is the media_streams stream_type_id of subtitles
+const streamTypeSubtitle = 3
+
+// hearingImpaired matches the SDH flag in a stream's extra_data, which Plex
+// writes as a query string or as JSON depending on its version
+var hearingImpaired = regexp.MustCompile(`hearingImpaired"?\s*[:=]\s*"?(?:1|true)`)
+
+// GetSubtitles returns the external subtitles Plex knows, keyed by file path
+func (p *PlexDB) GetSubtitles(ctx context.Context) (map[string]Subtitle, error) {
+	query := `
+		SELECT url, COALESCE(language, ''), COALESCE(forced, 0), COALESCE(extra_data, '')
+		FROM media_streams
+		WHERE stream_type_id = ? AND url LIKE 'file://%'
+	`
+	rows, err := p.query(ctx, query, streamTypeSubtitle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query subtitles: %w", err)
+	}
+	defer rows.Close()
+
+	subtitles := map[string]Subtitle{}
+	for rows.Next() {
+		var link, extra string
+		var s Subtitle
+		if err := rows.Scan(&link, &s.Language, &s.Forced, &extra); err != nil {
+			return nil, fmt.Errorf("failed to scan subtitle: %w", err)
+		}
+		s.File = subtitlePath(link)
+		s.SDH = hearingImpaired.MatchString(extra)
+		subtitles[s.File] = s
+	}
+	return subtitles, rows.Err()
+}
+
+// subtitlePath returns the path of a file:// URL, as Plex stores them for
+// external subtitles: percent-encoded, and on Windows with the drive letter
+// after the slash (file:///C:/Movies/...)
+func subtitlePath(link string) string {
+	path := strings.TrimPrefix(link, "file://")
+	if unescaped, err := url.PathUnescape(path); err == nil {
+		path = unescaped
+	}
+	if len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return path
+}
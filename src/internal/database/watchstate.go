@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// WatchState is how far an account got with an item, and its rating, as kept
+// in metadata_item_settings
+type WatchState struct {
+	AccountID    int64    `json:"account_id"`
+	ViewCount    int      `json:"view_count,omitempty"`
+	ViewOffset   int64    `json:"view_offset,omitempty"`    // Milliseconds into a partly watched item
+	LastViewedAt int64    `json:"last_viewed_at,omitempty"` // Unix time
+	Rating       *float64 `json:"rating,omitempty"`         // User rating, 0-10
+}
+
+// watchStateBatch is how many GUIDs are looked up per query
+const watchStateBatch = 500
+
+// GetWatchState returns the watch state of every account for the items with
+// the given GUIDs, keyed by GUID. Items nobody watched or rated are left out.
+func (p *PlexDB) GetWatchState(ctx context.Context, guids []string) (map[string][]WatchState, error) {
+	states := map[string][]WatchState{}
+	for start := 0; start < len(guids); start += watchStateBatch {
+		batch := guids[start:min(start+watchStateBatch, len(guids))]
+		args := make([]any, len(batch))
+		for i, guid := range batch {
+			args[i] = guid
+		}
+
+		// Plex stores last_viewed_at as Unix time; older databases may hold
+		// date strings
+		query := `
+			SELECT guid, account_id, COALESCE(view_count, 0), COALESCE(view_offset, 0),
+			       COALESCE(CASE WHEN typeof(last_viewed_at) = 'text'
+			                     THEN CAST(strftime('%s', last_viewed_at) AS INTEGER)
+			                     ELSE last_viewed_at END, 0),
+			       rating
+			FROM metadata_item_settings
+			WHERE guid IN (?` + strings.Repeat(",?", len(batch)-1) + `)
+			  AND (view_count > 0 OR view_offset > 0 OR rating IS NOT NULL)
+			ORDER BY guid, account_id
+		`
+		rows, err := p.query(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query watch state: %w", err)
+		}
+		for rows.Next() {
+			var guid string
+			var s WatchState
+			if err := rows.Scan(&guid, &s.AccountID, &s.ViewCount, &s.ViewOffset, &s.LastViewedAt, &s.Rating); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan watch state: %w", err)
+			}
+			states[guid] = append(states[guid], s)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read watch state: %w", err)
+		}
+	}
+	return states, nil
+}
+
+// GetWatched returns the GUIDs of the items any account has watched
+func (p *PlexDB) GetWatched(ctx context.Context) (map[string]bool, error) {
+	rows, err := p.query(ctx, `SELECT DISTINCT guid FROM metadata_item_settings WHERE view_count > 0`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query watched items: %w", err)
+	}
+	defer rows.Close()
+
+	watched := map[string]bool{}
+	for rows.Next() {
+		var guid string
+		if err := rows.Scan(&guid); err != nil {
+			return nil, fmt.Errorf("failed to scan watched item: %w", err)
+		}
+		watched[guid] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read watched items: %w", err)
+	}
+	return watched, nil
+}
+
+// ShowProgress is how many of a show's episodes were watched
+type ShowProgress struct {
+	Episodes int
+	Watched  int // Episodes any account has watched
+}
+
+// GetShowProgress returns how many episodes each show has and how many of
+// them were watched, keyed by show ID
+func (p *PlexDB) GetShowProgress(ctx context.Context) (map[int64]ShowProgress, error) {
+	// Episodes are in a season, or directly under their show
+	query := `
+		SELECT CASE WHEN parent.metadata_type = 3 THEN parent.parent_id ELSE parent.id END AS show_id,
+		       COUNT(*),
+		       SUM(CASE WHEN e.guid IN (SELECT guid FROM metadata_item_settings WHERE view_count > 0) THEN 1 ELSE 0 END)
+		FROM metadata_items e
+		JOIN metadata_items parent ON parent.id = e.parent_id
+		WHERE e.metadata_type = 4` + p.present("metadata_items", "e") + `
+		GROUP BY show_id
+	`
+	rows, err := p.query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query show progress: %w", err)
+	}
+	defer rows.Close()
+
+	progress := map[int64]ShowProgress{}
+	for rows.Next() {
+		var id int64
+		var sp ShowProgress
+		if err := rows.Scan(&id, &sp.Episodes, &sp.Watched); err != nil {
+			return nil, fmt.Errorf("failed to scan show progress: %w", err)
+		}
+		progress[id] = sp
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read show progress: %w", err)
+	}
+	return progress, nil
+}
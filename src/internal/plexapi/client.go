@@ -0,0 +1,156 @@
+package plexapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client talks to the HTTP API of a Plex Media Server
+type Client struct {
+	BaseURL string // e.g. http://192.168.1.10:32400
+	Token   string // X-Plex-Token of an account with access to the server
+	HTTP    *http.Client
+}
+
+// NewClient creates a client for the server at baseURL
+func NewClient(baseURL, token string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid Plex URL %q, use e.g. http://localhost:32400", baseURL)
+	}
+	return &Client{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Token:   token,
+		HTTP:    &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// ScanPath asks Plex to scan a folder of a library section (a partial scan),
+// so files added or renamed there show up without a full library scan
+func (c *Client) ScanPath(ctx context.Context, sectionID int64, path string) error {
+	query := url.Values{"path": {path}}
+	return c.do(ctx, http.MethodGet, fmt.Sprintf("/library/sections/%d/refresh?%s", sectionID, query.Encode()), nil)
+}
+
+// Section is a library section of the server
+type Section struct {
+	Key   string `json:"key"`
+	Type  string `json:"type"` // "movie" or "show" (others are ignored)
+	Title string `json:"title"`
+}
+
+// Sections returns the library sections of the server
+func (c *Client) Sections(ctx context.Context) ([]Section, error) {
+	var resp struct {
+		MediaContainer struct {
+			Directory []Section `json:"Directory"`
+		} `json:"MediaContainer"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/library/sections", &resp); err != nil {
+		return nil, err
+	}
+	return resp.MediaContainer.Directory, nil
+}
+
+// Item is a movie or episode of the server
+type Item struct {
+	RatingKey string `json:"ratingKey"`
+	ViewCount int    `json:"viewCount"` // Times the token's account watched it
+}
+
+// SectionFiles returns the movie or episode each file of a section belongs
+// to, keyed by the file path as Plex sees it
+func (c *Client) SectionFiles(ctx context.Context, section Section) (map[string]Item, error) {
+	itemType := 1 // Movies
+	if section.Type == "show" {
+		itemType = 4 // Episodes
+	}
+	var resp struct {
+		MediaContainer struct {
+			Metadata []struct {
+				Item
+				Media []struct {
+					Part []struct {
+						File string `json:"file"`
+					} `json:"Part"`
+				} `json:"Media"`
+			} `json:"Metadata"`
+		} `json:"MediaContainer"`
+	}
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/library/sections/%s/all?type=%d", url.PathEscape(section.Key), itemType), &resp); err != nil {
+		return nil, err
+	}
+
+	files := map[string]Item{}
+	for _, m := range resp.MediaContainer.Metadata {
+		for _, media := range m.Media {
+			for _, part := range media.Part {
+				files[part.File] = m.Item
+			}
+		}
+	}
+	return files, nil
+}
+
+// Scrobble marks an item as watched once more for the token's account
+func (c *Client) Scrobble(ctx context.Context, ratingKey string) error {
+	return c.do(ctx, http.MethodGet, "/:/scrobble?"+itemQuery(ratingKey).Encode(), nil)
+}
+
+// SetProgress sets how far into an item the token's account got
+func (c *Client) SetProgress(ctx context.Context, ratingKey string, offset time.Duration) error {
+	query := itemQuery(ratingKey)
+	query.Set("time", fmt.Sprint(offset.Milliseconds()))
+	query.Set("state", "stopped")
+	return c.do(ctx, http.MethodGet, "/:/progress?"+query.Encode(), nil)
+}
+
+// Rate sets the token's account's rating of an item, from 0 to 10
+func (c *Client) Rate(ctx context.Context, ratingKey string, rating float64) error {
+	query := itemQuery(ratingKey)
+	query.Set("rating", fmt.Sprint(rating))
+	return c.do(ctx, http.MethodPut, "/:/rate?"+query.Encode(), nil)
+}
+
+// itemQuery returns the query parameters that identify a library item
+func itemQuery(ratingKey string) url.Values {
+	return url.Values{"key": {ratingKey}, "identifier": {"com.plexapp.plugins.library"}}
+}
+
+// do sends a request for path and checks the response status, decoding the
+// JSON response into out unless it is nil
+func (c *Client) do(ctx context.Context, method, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-Plex-Token", c.Token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Plex: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return fmt.Errorf("plex rejected the token (401 Unauthorized)")
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("plex returned %s", resp.Status)
+	}
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to read Plex response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package renamer
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// checkWritable reports whether the current user may create files in dir
+func checkWritable(dir string) error {
+	// W_OK | X_OK: create entries in, and traverse, the directory
+	if err := syscall.Access(dir, 0x2|0x1); err != nil {
+		return fmt.Errorf("%s: %w", dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package renamer
+
+// checkWritable reports whether the current user may create files in dir.
+// Windows ACLs can't be evaluated cheaply, so this always succeeds and
+// permission problems surface when the operation is executed.
+func checkWritable(dir string) error {
+	return nil
+}
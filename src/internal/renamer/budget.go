@@ -0,0 +1,129 @@
+package renamer
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// BudgetOrder decides which operations are planned first when a budget
+// can't fit them all
+type BudgetOrder string
+
+const (
+	BudgetLargest BudgetOrder = "largest" // Largest files first
+	BudgetOldest  BudgetOrder = "oldest"  // Files added to Plex longest ago first
+)
+
+// ParseBudgetOrder parses a --budget-order value
+func ParseBudgetOrder(s string) (BudgetOrder, error) {
+	switch o := BudgetOrder(s); o {
+	case BudgetLargest, BudgetOldest:
+		return o, nil
+	case "":
+		return BudgetLargest, nil
+	}
+	return "", fmt.Errorf("invalid budget order: %s (use largest or oldest)", s)
+}
+
+// Budget limits how much a run writes, e.g. to fill a new disk
+type Budget struct {
+	MaxBytes int64 // 0 = no limit
+	MaxFiles int   // 0 = no limit
+	Order    BudgetOrder
+}
+
+// Limited reports whether the budget limits anything
+func (b Budget) Limited() bool {
+	return b.MaxBytes > 0 || b.MaxFiles > 0
+}
+
+// Apply picks the operations that fit in the budget, going through them in
+// the budget's order and skipping those that would go over it. Both the
+// picked and the remaining operations keep their original order.
+func (b Budget) Apply(operations []Operation) (planned, left []Operation) {
+	if !b.Limited() {
+		return operations, nil
+	}
+
+	order := make([]int, len(operations))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(i, j int) int {
+		a, o := operations[i], operations[j]
+		if b.Order == BudgetOldest {
+			// Unknown dates go last
+			if a.Added.IsZero() != o.Added.IsZero() {
+				if a.Added.IsZero() {
+					return 1
+				}
+				return -1
+			}
+			return a.Added.Compare(o.Added)
+		}
+		return cmp.Compare(o.Size, a.Size)
+	})
+
+	fits := make([]bool, len(operations))
+	var bytes int64
+	var files int
+	for _, i := range order {
+		size := operations[i].Size
+		if b.MaxFiles > 0 && files >= b.MaxFiles {
+			break
+		}
+		if b.MaxBytes > 0 && bytes+size > b.MaxBytes {
+			continue
+		}
+		fits[i] = true
+		bytes += size
+		files++
+	}
+
+	for i, op := range operations {
+		if fits[i] {
+			planned = append(planned, op)
+		} else {
+			left = append(left, op)
+		}
+	}
+	return planned, left
+}
+
+// byteUnits are the size suffixes ParseByteSize accepts. Plain units are
+// decimal, as disk sizes are sold; the "i" units are binary.
+var byteUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   1e3,
+	"kb":  1e3,
+	"m":   1e6,
+	"mb":  1e6,
+	"g":   1e9,
+	"gb":  1e9,
+	"t":   1e12,
+	"tb":  1e12,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// ParseByteSize parses a size such as 2TB, 500GiB, or 1.5T
+func ParseByteSize(s string) (int64, error) {
+	text := strings.ToLower(strings.TrimSpace(s))
+	end := strings.IndexFunc(text, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if end < 0 {
+		end = len(text)
+	}
+	n, err := strconv.ParseFloat(text[:end], 64)
+	unit, ok := byteUnits[strings.TrimSpace(text[end:])]
+	if err != nil || !ok || n <= 0 || n*unit > math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size: %s (use e.g. 2TB, 500GB, or 750GiB)", s)
+	}
+	return int64(n * unit), nil
+}
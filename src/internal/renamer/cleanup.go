@@ -0,0 +1,70 @@
+package renamer
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// RemoveEmptyDirs removes source directories left empty by successful moves,
+// walking up through parents that become empty in turn. Only directories
+// strictly inside one of roots are removed: the walk stops at the root itself,
+// and at any directory in protected. Returns the removed directories.
+func RemoveEmptyDirs(results []Result, roots, protected []string) []string {
+	stop := make(map[string]bool, len(roots)+len(protected))
+	for _, p := range append(append([]string{}, roots...), protected...) {
+		stop[pathKey(p)] = true
+	}
+
+	// Deepest directories first, so children are removed before their parents
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, r := range results {
+		if !r.Success || r.Skipped || r.Operation.Mode != ModeMove {
+			continue
+		}
+		dir := filepath.Dir(r.Operation.Source)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i]) > len(dirs[j]) })
+
+	var removed []string
+	for _, dir := range dirs {
+		if !insideAny(dir, roots) {
+			continue
+		}
+		for !stop[pathKey(dir)] {
+			entries, err := os.ReadDir(dir)
+			if err != nil || len(entries) > 0 {
+				break
+			}
+			if err := os.Remove(dir); err != nil {
+				break
+			}
+			removed = append(removed, dir)
+
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+	}
+	return removed
+}
+
+// insideAny reports whether path is strictly inside one of the directories in roots
+func insideAny(path string, roots []string) bool {
+	key := pathKey(path)
+	for _, root := range roots {
+		rootKey := pathKey(root)
+		if strings.HasPrefix(key, strings.TrimSuffix(rootKey, string(filepath.Separator))+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
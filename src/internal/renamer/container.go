@@ -0,0 +1,97 @@
+package renamer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// containerHeaderSize is how much of a file is read to tell its container
+const containerHeaderSize = 16
+
+// container is a file format and how to tell it from its first bytes
+type container struct {
+	name  string
+	match func(header []byte) bool
+}
+
+// at returns a test for magic at offset in a header
+func at(offset int, magic string) func([]byte) bool {
+	return func(header []byte) bool {
+		return len(header) >= offset+len(magic) && bytes.Equal(header[offset:offset+len(magic)], []byte(magic))
+	}
+}
+
+var (
+	matroska = container{"Matroska", at(0, "\x1a\x45\xdf\xa3")}
+	isoMedia = container{"MP4/QuickTime", isISOMedia}
+	riffAVI  = container{"AVI", func(h []byte) bool { return at(0, "RIFF")(h) && at(8, "AVI ")(h) }}
+	asf      = container{"ASF", at(0, "\x30\x26\xb2\x75\x8e\x66\xcf\x11")}
+	mpegTS   = container{"MPEG-TS", func(h []byte) bool { return at(0, "\x47")(h) || at(4, "\x47")(h) }}
+	mpegPS   = container{"MPEG-PS", func(h []byte) bool { return at(0, "\x00\x00\x01\xba")(h) || at(0, "\x00\x00\x01\xb3")(h) }}
+	flv      = container{"FLV", at(0, "FLV")}
+	ogg      = container{"Ogg", at(0, "OggS")}
+)
+
+// containers are the containers files with these extensions should be.
+// Files with other extensions, such as subtitles, aren't checked.
+var containers = map[string]container{
+	".mkv": matroska, ".mk3d": matroska, ".mka": matroska, ".webm": matroska,
+	".mp4": isoMedia, ".m4v": isoMedia, ".mov": isoMedia, ".m4a": isoMedia, ".3gp": isoMedia,
+	".avi": riffAVI, ".divx": riffAVI,
+	".wmv": asf, ".asf": asf, ".wma": asf,
+	".ts": mpegTS, ".m2ts": mpegTS, ".mts": mpegTS,
+	".mpg": mpegPS, ".mpeg": mpegPS, ".vob": mpegPS,
+	".flv": flv,
+	".ogm": ogg, ".ogv": ogg, ".ogg": ogg,
+}
+
+// knownContainers are tried in order to tell what a file is instead.
+// MPEG-TS is last, as a single sync byte is the easiest to match by chance.
+var knownContainers = []container{matroska, isoMedia, riffAVI, asf, mpegPS, flv, ogg, mpegTS}
+
+// isISOMedia reports whether a header starts an MP4 or QuickTime file: a
+// box whose type is that of the boxes these files start with
+func isISOMedia(header []byte) bool {
+	for _, box := range []string{"ftyp", "moov", "mdat", "free", "skip", "wide", "pnot"} {
+		if at(4, box)(header) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkContainer returns why the file at path isn't the container its
+// extension says, or nil if it is or its extension isn't one checked
+func checkContainer(path string) error {
+	want, ok := containers[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot read source: %w", err)
+	}
+	defer f.Close()
+	header := make([]byte, containerHeaderSize)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("cannot read source: %w", err)
+	}
+	header = header[:n]
+	if want.match(header) {
+		return nil
+	}
+	if bytes.Count(header, []byte{0}) == len(header) {
+		return fmt.Errorf("starts with zeros, not a %s header", want.name)
+	}
+	for _, c := range knownContainers {
+		if c.match(header) {
+			return fmt.Errorf("looks like %s, not %s as its extension says", c.name, want.name)
+		}
+	}
+	return fmt.Errorf("isn't a %s file", want.name)
+}
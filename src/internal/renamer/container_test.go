@@ -0,0 +1,66 @@
+package renamer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckContainer(t *testing.T) {
+	const (
+		mkv  = "\x1a\x45\xdf\xa3\x01\x00\x00\x00\x00\x00\x00\x23\x42\x86\x81\x01"
+		mp4  = "\x00\x00\x00\x20ftypisom\x00\x00\x02\x00"
+		mov  = "\x00\x00\x00\x14ftypqt  \x00\x00\x00\x00"
+		avi  = "RIFF\x24\x10\x00\x00AVI LIST"
+		m2ts = "\x00\x0b\xa2\x7e\x47\x40\x00\x10\x00\x00\xb0\x11\x00\x00\xc1\x00" // 4-byte timestamp before the sync byte
+		ts   = "\x47\x40\x00\x10\x00\x00\xb0\x0d\x00\x01\xc1\x00\x00\x00\x01\xf0"
+		mpg  = "\x00\x00\x01\xba\x44\x00\x04\x00\x04\x01\x01\x89\xc3\xf8\x00\x00"
+	)
+	tests := []struct {
+		name, header string
+		err          string // Part of the error, or "" if the file passes
+	}{
+		{"movie.mkv", mkv, ""},
+		{"movie.MKV", mkv, ""},
+		{"movie.mp4", mp4, ""},
+		{"movie.mov", mov, ""},
+		{"movie.avi", avi, ""},
+		{"episode.m2ts", m2ts, ""},
+		{"episode.ts", ts, ""},
+		{"movie.mpg", mpg, ""},
+		{"movie.vob", mpg, ""},
+		// Files with other extensions aren't checked
+		{"movie.en.srt", "1\n00:00:01,000 --> 00:00:02,000\n", ""},
+		{"movie.mkv", strings.Repeat("\x00", 4096), "starts with zeros"},
+		{"movie.mp4", mkv, "looks like Matroska, not MP4/QuickTime"},
+		{"movie.mkv", mp4, "looks like MP4/QuickTime, not Matroska"},
+		{"movie.avi", mpg, "looks like MPEG-PS, not AVI"},
+		// A RIFF file that isn't an AVI, such as a WAV
+		{"movie.avi", "RIFF\x24\x10\x00\x00WAVEfmt ", "AVI file"},
+		{"movie.mkv", "<html><body>Not found</body></html>", "isn't a Matroska file"},
+		// Files shorter than a header are checked with what they have
+		{"movie.mkv", mkv[:4], ""},
+		{"movie.mkv", mkv[:3], "isn't a Matroska file"},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), tt.name)
+		if err := os.WriteFile(path, []byte(tt.header), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		err := checkContainer(path)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("checkContainer(%s with %q) = %v, want no error", tt.name, tt.header, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("checkContainer(%s with %q) = %v, want an error with %q", tt.name, tt.header, err, tt.err)
+		}
+	}
+}
+
+func TestCheckContainerMissingFile(t *testing.T) {
+	if err := checkContainer(filepath.Join(t.TempDir(), "movie.mkv")); err == nil || !strings.Contains(err.Error(), "cannot read source") {
+		t.Errorf("checkContainer of a missing file = %v, want a read error", err)
+	}
+}
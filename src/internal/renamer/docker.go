@@ -0,0 +1,77 @@
+package renamer
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// DockerPreset describes the container paths used by a common Plex image
+type DockerPreset struct {
+	Name           string
+	Description    string
+	ContainerPaths []string // Media mount points inside the container
+}
+
+var dockerPresets = map[string]DockerPreset{
+	"pms": {
+		Name:           "pms",
+		Description:    "Official plexinc/pms-docker image (/data)",
+		ContainerPaths: []string{"/data"},
+	},
+	"linuxserver": {
+		Name:           "linuxserver",
+		Description:    "linuxserver/plex image (/tv, /movies, /data)",
+		ContainerPaths: []string{"/tv", "/movies", "/data"},
+	},
+	"hotio": {
+		Name:           "hotio",
+		Description:    "hotio/plex image (/data)",
+		ContainerPaths: []string{"/data"},
+	},
+}
+
+// LookupDockerPreset returns the Docker preset with the given name
+func LookupDockerPreset(name string) (DockerPreset, bool) {
+	p, ok := dockerPresets[strings.ToLower(name)]
+	return p, ok
+}
+
+// DockerPresetNames returns the names of all Docker presets, sorted
+func DockerPresetNames() []string {
+	names := make([]string, 0, len(dockerPresets))
+	for name := range dockerPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PathMaps maps the preset's container paths onto hostDir. A single mount
+// point maps to hostDir itself; with several, each maps to the directory of
+// the same name below hostDir (e.g. /tv to hostDir/tv).
+func (p DockerPreset) PathMaps(hostDir string) []PathMap {
+	if len(p.ContainerPaths) == 1 {
+		return []PathMap{{From: p.ContainerPaths[0], To: hostDir}}
+	}
+	maps := make([]PathMap, 0, len(p.ContainerPaths))
+	for _, cp := range p.ContainerPaths {
+		maps = append(maps, PathMap{From: cp, To: strings.TrimSuffix(hostDir, "/") + cp})
+	}
+	return maps
+}
+
+// DockerPresetsFor returns the names of presets with a container path that
+// contains the given Plex path, suggesting Plex runs in that container
+func DockerPresetsFor(plexPath string) []string {
+	var names []string
+	for _, name := range DockerPresetNames() {
+		for _, cp := range dockerPresets[name].ContainerPaths {
+			if hasPathPrefix(path.Clean(toSlash(plexPath)), cp) {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	return names
+}
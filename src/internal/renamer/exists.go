@@ -0,0 +1,40 @@
+package renamer
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// ExistsPolicy decides what happens when a destination already exists
+type ExistsPolicy string
+
+const (
+	ExistsSkip   ExistsPolicy = "skip"             // Leave the destination and skip the operation
+	ExistsBackup ExistsPolicy = "overwrite-backup" // Rename the destination to a backup, then write
+	ExistsBetter ExistsPolicy = "better"           // Like ExistsBackup, if the source is better (--prefer better)
+)
+
+// ParseExistsPolicy parses an --on-exists value
+func ParseExistsPolicy(s string) (ExistsPolicy, error) {
+	switch p := ExistsPolicy(s); p {
+	case ExistsSkip, ExistsBackup:
+		return p, nil
+	case "":
+		return ExistsSkip, nil
+	}
+	return "", fmt.Errorf("invalid on-exists policy: %s (use skip or overwrite-backup)", s)
+}
+
+// backupDestination renames the existing destination of op out of the way,
+// to <name>.bak-<timestamp>, and returns the backup's path
+func backupDestination(op *Operation) (string, error) {
+	backup := op.Destination + ".bak-" + time.Now().Format("20060102-150405")
+	if _, err := os.Lstat(backup); err == nil {
+		return "", fmt.Errorf("backup %s already exists", backup)
+	}
+	if err := os.Rename(op.Destination, backup); err != nil {
+		return "", fmt.Errorf("failed to back up existing destination: %w", err)
+	}
+	return backup, nil
+}
@@ -0,0 +1,40 @@
+package renamer
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// FoldPath returns path in the form paths are compared in when case doesn't
+// matter: composed (NFC), as macOS may store names decomposed where Plex
+// has them composed, and with each letter folded to one of its cases.
+// Unlike strings.ToLower, folding follows no locale and only joins letters
+// that are cases of each other, as filesystems do: Σ, σ, and ς are one
+// letter and so are ß and ẞ, while the Turkish İ and ı stay apart from I
+// and i, as they do on NTFS.
+func FoldPath(path string) string {
+	for i := 0; i < len(path); i++ {
+		if path[i] >= utf8.RuneSelf {
+			return strings.Map(foldRune, norm.NFC.String(path))
+		}
+	}
+	return strings.ToLower(path)
+}
+
+// foldRune returns the same rune for all runes strings.EqualFold takes for
+// equal: the first of their lowercased uppercases, as σ for Σ, σ, and ς.
+// Runes that fold to no other, such as İ and ı, are kept, where ToLower
+// would turn İ into i.
+func foldRune(r rune) rune {
+	if unicode.SimpleFold(r) == r {
+		return r
+	}
+	folded := unicode.ToLower(unicode.ToUpper(r))
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		folded = min(folded, unicode.ToLower(unicode.ToUpper(f)))
+	}
+	return folded
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"unicode"
 
@@ -20,6 +21,13 @@ const DefaultMovieFormat = "{title} ({year}){ext}"
 type Formatter struct {
 	TVFormat    string
 	MovieFormat string
+	ShowFormats map[string]string // TV formats for specific shows, keyed by lowercase title or GUID
+	FolderIDs   bool              // Add the stable ID of the show or movie to its folder name
+	SubFormat   string            // Names of subtitles moved with their video, see FormatSidecar
+	SideFormat  string            // Names of other sidecars moved with their video
+	Reserved    string            // Appended to names Windows reserves, e.g. CON_ for CON ("" = off)
+	SortFolders SortMode          // Name show and movie folders by sort title or without the article
+	Library     string            // Name of the library being named, for {library}
 }
 
 // NewFormatter creates a new formatter with the specified formats
@@ -33,65 +41,224 @@ func NewFormatter(tvFormat, movieFormat string) *Formatter {
 	return &Formatter{
 		TVFormat:    tvFormat,
 		MovieFormat: movieFormat,
+		SubFormat:   DefaultSubtitleFormat,
+		SideFormat:  DefaultSidecarFormat,
+		Reserved:    DefaultReservedSuffix,
 	}
 }
 
-// FormatEpisode generates a filename for a TV episode
-func (f *Formatter) FormatEpisode(show, season *database.MetadataItem, episode *database.EpisodeInfo, ext string) string {
-	result := f.TVFormat
+// ForShow returns the formatter to use for the episodes of show: a copy using
+// its entry in ShowFormats, matched by GUID and then by title, or f itself
+func (f *Formatter) ForShow(show *database.MetadataItem) *Formatter {
+	format, ok := "", false
+	if show.GUID != "" {
+		format, ok = f.ShowFormats[strings.ToLower(show.GUID)]
+	}
+	if !ok {
+		format, ok = f.ShowFormats[strings.ToLower(show.Title)]
+	}
+	if !ok {
+		return f
+	}
+	showFormatter := *f
+	showFormatter.TVFormat = format
+	return &showFormatter
+}
 
-	// Show title
-	result = strings.ReplaceAll(result, "{show}", sanitizeFilename(show.Title))
+// ForLibrary returns a copy of f naming the items of the library called name
+func (f *Formatter) ForLibrary(name string) *Formatter {
+	libraryFormatter := *f
+	libraryFormatter.Library = name
+	return &libraryFormatter
+}
 
-	// Season number
+// FormatEpisode generates a filename for a file of a TV episode. version is
+// the {version} of the file for episodes with several versions, or "".
+func (f *Formatter) FormatEpisode(show, season *database.MetadataItem, episode *database.EpisodeInfo, file database.MediaPart, version, ext string) string {
 	seasonNum := 0
 	if season.Index != nil {
 		seasonNum = *season.Index
 	}
-	result = strings.ReplaceAll(result, "{season}", fmt.Sprintf("%d", seasonNum))
-	result = strings.ReplaceAll(result, "{snum}", fmt.Sprintf("%02d", seasonNum))
-
-	// Episode number
 	episodeNum := 0
 	if episode.Metadata.Index != nil {
 		episodeNum = *episode.Metadata.Index
 	}
-	result = strings.ReplaceAll(result, "{enum}", fmt.Sprintf("%02d", episodeNum))
 
-	// Episode title
-	result = strings.ReplaceAll(result, "{title}", sanitizeFilename(episode.Metadata.Title))
+	format := f.TVFormat
+	if f.FolderIDs {
+		format = withFolderID(format, "show")
+	}
+	format = withSortFolders(format, "show", f.SortFolders)
+	id := StableID(show)
+	info := readMediaInfo(file)
+	values := map[string]string{
+		"library":       sanitizeFilename(f.Library),
+		"show":          sanitizeFilename(show.Title),
+		"show_sort":     sanitizeFilename(sortTitle(show)),
+		"show_clean":    sanitizeFilename(titleWithoutArticle(show)),
+		"letter":        letter(show),
+		"season":        strconv.Itoa(seasonNum),
+		"snum":          strconv.Itoa(seasonNum),
+		"season_folder": seasonFolder(seasonNum),
+		"enum":          strconv.Itoa(episodeNum),
+		"date":          airDate(episode.Metadata.OriginallyAvailable), // Air date, for daily shows
+		"title":         sanitizeFilename(episode.Metadata.Title),
+		"year":          year(show.Year),
+		"show_year":     showYear(show),
+		"year_range":    yearRange(show),
+		"genre":         primaryGenre(show), // Genre and decade of the show
+		"decade":        decade(show.Year),
+		"version":       version,
+		"id":            id,
+		"folder_id":     folderIDValue(id),
+		"quality":       info.quality, // Media info, as the TRaSH guides name it
+		"hdr":           info.hdr,
+		"audio":         info.audio,
+		"vcodec":        info.video,
+		"group":         sanitizeFilename(info.group),
+		"size":          fileSize(file.Size),
+		"ext":           ext,
+	}
+	addProvidedValues(values, TokenItem{Show: show, Season: season, Episode: &episode.Metadata, File: file})
+	name := expandFormat(format, values, tvFallbacks)
+	return protectReserved(addVersion(name, f.TVFormat, ext, version), f.Reserved)
+}
+
+// FormatMovie generates a filename for a file of a movie. version is as for
+// FormatEpisode.
+func (f *Formatter) FormatMovie(movie *database.MovieInfo, file database.MediaPart, version, ext string) string {
+	format := f.MovieFormat
+	if f.FolderIDs {
+		format = withFolderID(format, "title")
+	}
+	format = withSortFolders(format, "title", f.SortFolders)
+	id := StableID(&movie.Metadata)
+	info := readMediaInfo(file)
+	values := map[string]string{
+		"library":     sanitizeFilename(f.Library),
+		"title":       sanitizeFilename(movie.Metadata.Title),
+		"title_sort":  sanitizeFilename(sortTitle(&movie.Metadata)),
+		"title_clean": sanitizeFilename(titleWithoutArticle(&movie.Metadata)),
+		"letter":      letter(&movie.Metadata),
+		"year":        year(movie.Metadata.Year),
+		"genre":       primaryGenre(&movie.Metadata),
+		"decade":      decade(movie.Metadata.Year),
+		"version":     version,
+		"id":          id,
+		"imdb_id":     SourceID(&movie.Metadata, "imdb"),
+		"folder_id":   folderIDValue(id),
+		"quality":     info.quality,
+		"hdr":         info.hdr,
+		"audio":       info.audio,
+		"vcodec":      info.video,
+		"group":       sanitizeFilename(info.group),
+		"size":        fileSize(file.Size),
+		"ext":         ext,
+	}
+	addProvidedValues(values, TokenItem{Movie: &movie.Metadata, File: file})
+	name := expandFormat(format, values, movieFallbacks)
+	return protectReserved(addVersion(name, f.MovieFormat, ext, version), f.Reserved)
+}
+
+// tvFallbacks and movieFallbacks replace missing values of placeholders
+// that have no default of their own
+var (
+	tvFallbacks    = map[string]string{"date": "Unknown", "genre": "Unknown", "decade": "Unknown", "quality": "Unknown"}
+	movieFallbacks = map[string]string{"year": "Unknown", "genre": "Unknown", "decade": "Unknown", "quality": "Unknown"}
+)
+
+// year returns a year as text, or "" if it is unknown
+func year(y *int) string {
+	if y == nil {
+		return ""
+	}
+	return strconv.Itoa(*y)
+}
+
+// startYear returns the year a show started: its own year, or else the
+// year its first episode aired, or 0 if neither is known
+func startYear(show *database.MetadataItem) int {
+	if show.Year != nil && *show.Year > 0 {
+		return *show.Year
+	}
+	return show.FirstYear
+}
 
-	// Year (if available)
-	year := ""
-	if show.Year != nil {
-		year = fmt.Sprintf("%d", *show.Year)
+// showYear returns the {show_year} of a show, or "" if it is unknown
+func showYear(show *database.MetadataItem) string {
+	if start := startYear(show); start > 0 {
+		return strconv.Itoa(start)
 	}
-	result = strings.ReplaceAll(result, "{year}", year)
+	return ""
+}
 
-	// Extension
-	result = strings.ReplaceAll(result, "{ext}", ext)
+// yearRange returns the years a show aired, e.g. 2008-2013 from its start
+// to its last episode, or only its start if that is all there is
+func yearRange(show *database.MetadataItem) string {
+	start := startYear(show)
+	if start == 0 || show.LastYear <= start {
+		return showYear(show)
+	}
+	return fmt.Sprintf("%d-%d", start, show.LastYear)
+}
 
-	return result
+// airDate returns the date part (YYYY-MM-DD) of an originally available
+// timestamp, or "" if there is none
+func airDate(available string) string {
+	if len(available) < len("2006-01-02") {
+		return ""
+	}
+	return available[:len("2006-01-02")]
 }
 
-// FormatMovie generates a filename for a movie
-func (f *Formatter) FormatMovie(movie *database.MovieInfo, ext string) string {
-	result := f.MovieFormat
+// seasonFolder returns the conventional folder name for a season,
+// using "Specials" for season 0 as all major media servers expect
+func seasonFolder(seasonNum int) string {
+	if seasonNum == 0 {
+		return "Specials"
+	}
+	return fmt.Sprintf("Season %02d", seasonNum)
+}
 
-	// Movie title
-	result = strings.ReplaceAll(result, "{title}", sanitizeFilename(movie.Metadata.Title))
+// primaryGenre returns the first genre of an item, or "" if it has none
+func primaryGenre(item *database.MetadataItem) string {
+	for _, genre := range item.Genres {
+		if name := sanitizeFilename(genre); name != "" {
+			return name
+		}
+	}
+	return ""
+}
 
-	// Year
-	year := "Unknown"
-	if movie.Metadata.Year != nil {
-		year = fmt.Sprintf("%d", *movie.Metadata.Year)
+// decade returns the decade for a year (e.g. 1987 -> "1980s"), or ""
+func decade(year *int) string {
+	if year == nil || *year <= 0 {
+		return ""
 	}
-	result = strings.ReplaceAll(result, "{year}", year)
+	return fmt.Sprintf("%ds", *year/10*10)
+}
 
-	// Extension
-	result = strings.ReplaceAll(result, "{ext}", ext)
+// fileSize returns a file's size in binary units (e.g. "1.4 GiB"), or "" if
+// it is unknown
+func fileSize(n int64) string {
+	if n <= 0 {
+		return ""
+	}
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(1024), 0
+	for m := n / 1024; m >= 1024; m /= 1024 {
+		div *= 1024
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
 
-	return result
+// FolderName returns title as the name of a folder, without the characters
+// file names can't have
+func FolderName(title string) string {
+	return sanitizeFilename(title)
 }
 
 // sanitizeFilename removes or replaces characters that are invalid in filenames
@@ -135,25 +302,6 @@ func sanitizeFilename(name string) string {
 	return strings.TrimSpace(result)
 }
 
-// ApplyPathMapping replaces the source path prefix with destination prefix
-func ApplyPathMapping(path, srcPrefix, dstPrefix string) string {
-	if srcPrefix == "" || dstPrefix == "" {
-		return path
-	}
-
-	// Normalize path separators for comparison
-	normalizedPath := filepath.ToSlash(path)
-	normalizedSrc := filepath.ToSlash(srcPrefix)
-
-	if strings.HasPrefix(normalizedPath, normalizedSrc) {
-		newPath := dstPrefix + normalizedPath[len(normalizedSrc):]
-		// Convert back to OS-specific path
-		return filepath.FromSlash(newPath)
-	}
-
-	return path
-}
-
 // GetExtension extracts the file extension including the dot
 func GetExtension(path string) string {
 	return filepath.Ext(path)
@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package renamer
+
+import "fmt"
+
+// FreeSpace is not supported on the remaining platforms
+func FreeSpace(path string) (int64, error) {
+	return 0, fmt.Errorf("failed to get free space of %s: not supported on this platform", path)
+}
+
+// SameFilesystem can't be told on the remaining platforms, so moves are
+// taken to need space of their own
+func SameFilesystem(source, destination string) bool {
+	return false
+}
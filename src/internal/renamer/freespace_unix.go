@@ -0,0 +1,37 @@
+//go:build linux || darwin || freebsd
+
+package renamer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// FreeSpace returns the bytes available to the current user on the
+// filesystem holding path
+func FreeSpace(path string) (int64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, fmt.Errorf("failed to get free space of %s: %w", path, err)
+	}
+	return int64(uint64(st.Bavail) * uint64(st.Bsize)), nil
+}
+
+// SameFilesystem reports whether a move of source to destination would be a
+// rename, taking no new space. destination need not exist yet; the closest
+// of its directories that does is checked.
+func SameFilesystem(source, destination string) bool {
+	var src, dst syscall.Stat_t
+	if err := syscall.Stat(source, &src); err != nil {
+		return false
+	}
+	for dir := destination; ; dir = filepath.Dir(dir) {
+		if err := syscall.Stat(dir, &dst); err == nil {
+			return src.Dev == dst.Dev
+		} else if !os.IsNotExist(err) || filepath.Dir(dir) == dir {
+			return false
+		}
+	}
+}
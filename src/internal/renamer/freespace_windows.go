@@ -0,0 +1,35 @@
+//go:build windows
+
+package renamer
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var procGetDiskFreeSpaceExW = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// FreeSpace returns the bytes available to the current user on the volume
+// holding path
+func FreeSpace(path string) (int64, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get free space of %s: %w", path, err)
+	}
+	var available uint64
+	ok, _, callErr := procGetDiskFreeSpaceExW.Call(uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&available)), 0, 0)
+	if ok == 0 {
+		return 0, fmt.Errorf("failed to get free space of %s: %w", path, callErr)
+	}
+	return int64(available), nil
+}
+
+// SameFilesystem reports whether a move of source to destination would be a
+// rename, taking no new space: whether both are on the same drive or share
+func SameFilesystem(source, destination string) bool {
+	volume := filepath.VolumeName(source)
+	return volume != "" && strings.EqualFold(volume, filepath.VolumeName(destination))
+}
@@ -0,0 +1,187 @@
+package renamer
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LeftoverAction is what to do with a file left behind in a source directory
+type LeftoverAction string
+
+const (
+	LeftoverReport LeftoverAction = "report" // List the file, leave it in place
+	LeftoverDelete LeftoverAction = "delete" // Delete the file
+	LeftoverTrash  LeftoverAction = "trash"  // Move the file into the trash directory
+	LeftoverIgnore LeftoverAction = "ignore" // Leave the file in place without listing it
+)
+
+// LeftoverTrashDir is the directory, relative to the library root, that
+// trashed leftovers are moved into
+const LeftoverTrashDir = ".plexrenamer-trash"
+
+// LeftoverRule applies an action to leftovers whose name matches a glob pattern
+type LeftoverRule struct {
+	Pattern string
+	Action  LeftoverAction
+}
+
+// Leftover is a file remaining in a source directory after moves
+type Leftover struct {
+	Path   string
+	Size   int64
+	Action LeftoverAction
+	Error  error // Set if the action failed
+}
+
+// ParseLeftoverRules parses a comma-separated list of pattern=action rules,
+// such as "*.nfo=delete,sample*=trash,*.parts=ignore". A bare action applies
+// to all files. Patterns match file names case-insensitively and the first
+// matching rule wins; unmatched files are reported.
+func ParseLeftoverRules(s string) ([]LeftoverRule, error) {
+	var rules []LeftoverRule
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		pattern, action := "*", entry
+		if i := strings.LastIndex(entry, "="); i >= 0 {
+			pattern, action = entry[:i], entry[i+1:]
+		}
+		switch a := LeftoverAction(strings.ToLower(action)); a {
+		case LeftoverReport, LeftoverDelete, LeftoverTrash, LeftoverIgnore:
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+			rules = append(rules, LeftoverRule{Pattern: strings.ToLower(pattern), Action: a})
+		default:
+			return nil, fmt.Errorf("invalid action %q (use report, delete, trash, or ignore)", action)
+		}
+	}
+	return rules, nil
+}
+
+// actionFor returns the action of the first rule matching name. Videos are
+// only deleted or trashed by rules naming their extension, so "*=delete"
+// or "sample*=trash" never take a video with them.
+func actionFor(name string, rules []LeftoverRule) LeftoverAction {
+	name = strings.ToLower(name)
+	ext := filepath.Ext(name)
+	for _, rule := range rules {
+		if ok, _ := filepath.Match(rule.Pattern, name); !ok {
+			continue
+		}
+		destructive := rule.Action == LeftoverDelete || rule.Action == LeftoverTrash
+		if destructive && videoExtensions[ext] && !strings.HasSuffix(rule.Pattern, ext) {
+			continue
+		}
+		return rule.Action
+	}
+	return LeftoverReport
+}
+
+// FindLeftovers lists the files remaining under the source directories of
+// successful moves. Only directories strictly inside one of roots are
+// scanned, so a flat library root is never listed as a whole. The files of
+// the run and media are never leftovers: media are the files Plex knows,
+// such as the videos of items left out of the run.
+func FindLeftovers(results []Result, roots []string, rules []LeftoverRule, media []string) []Leftover {
+	keep := map[string]bool{}
+	for _, path := range media {
+		keep[pathKey(path)] = true
+	}
+	for _, r := range results {
+		keep[pathKey(r.Operation.Source)] = true
+		keep[pathKey(r.Operation.Destination)] = true
+	}
+
+	var dirs []string
+	for _, r := range results {
+		if r.Success && !r.Skipped && r.Operation.Mode == ModeMove {
+			if dir := filepath.Dir(r.Operation.Source); insideAny(dir, roots) {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+
+	// Scan parents before their subdirectories, skipping already scanned trees
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i]) < len(dirs[j]) })
+	var leftovers []Leftover
+	var scanned []string
+	for _, dir := range dirs {
+		if insideAny(dir, scanned) || containsPath(scanned, dir) {
+			continue
+		}
+		scanned = append(scanned, dir)
+
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || keep[pathKey(path)] {
+				return nil
+			}
+			action := actionFor(d.Name(), rules)
+			if action == LeftoverIgnore {
+				return nil
+			}
+			var size int64
+			if info, err := d.Info(); err == nil {
+				size = info.Size()
+			}
+			leftovers = append(leftovers, Leftover{Path: path, Size: size, Action: action})
+			return nil
+		})
+	}
+	return leftovers
+}
+
+// containsPath reports whether paths contains path
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if pathKey(p) == pathKey(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleLeftovers performs the delete and trash actions of leftovers, recording
+// any failure on the leftover. Trashed files keep their path relative to the
+// library root inside LeftoverTrashDir.
+func HandleLeftovers(leftovers []Leftover, roots []string) {
+	for i := range leftovers {
+		l := &leftovers[i]
+		switch l.Action {
+		case LeftoverDelete:
+			if err := os.Remove(l.Path); err != nil {
+				l.Error = fmt.Errorf("failed to delete: %w", err)
+			}
+		case LeftoverTrash:
+			l.Error = trashFile(l.Path, roots)
+		}
+	}
+}
+
+// trashFile moves path into the trash directory of its library root
+func trashFile(path string, roots []string) error {
+	for _, root := range roots {
+		if !insideAny(path, []string{root}) {
+			continue
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			break
+		}
+		dst := filepath.Join(root, LeftoverTrashDir, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return fmt.Errorf("failed to create trash directory: %w", err)
+		}
+		if err := os.Rename(path, dst); err != nil {
+			return fmt.Errorf("failed to move to trash: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("no library root for %s", path)
+}
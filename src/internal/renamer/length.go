@@ -0,0 +1,92 @@
+package renamer
+
+import (
+	"fmt"
+	"strings"
+
+	"plexrenamer/internal/database"
+)
+
+// LengthMode decides what happens to destinations too long for the
+// filesystem (see CheckPathLength)
+type LengthMode string
+
+const (
+	LengthTruncate LengthMode = "truncate" // Shorten the episode title, then the show or movie title
+	LengthError    LengthMode = "error"    // Stop before anything runs
+)
+
+// ParseLengthMode parses a --long-names value
+func ParseLengthMode(s string) (LengthMode, error) {
+	switch m := LengthMode(s); m {
+	case LengthTruncate, LengthError:
+		return m, nil
+	case "":
+		return LengthTruncate, nil
+	}
+	return "", fmt.Errorf("invalid long-names mode: %s (use truncate or error)", s)
+}
+
+// FitEpisode is FormatEpisode with the episode title, and then the show
+// title, shortened as far as needed for fits to accept the name. It reports
+// whether the name fits; if not, it is as short as the titles allow.
+func (f *Formatter) FitEpisode(fits func(name string) bool, show, season *database.MetadataItem, episode *database.EpisodeInfo, file database.MediaPart, version, ext string) (string, bool) {
+	name := f.FormatEpisode(show, season, episode, file, version, ext)
+	if fits(name) {
+		return name, true
+	}
+	shortShow, shortEpisode := *show, *episode
+	shortShow.Title = f.FitShowTitle(fits, show, season, episode, file, version, ext)
+	name = shorten(&shortEpisode.Metadata.Title, func() string {
+		return f.FormatEpisode(&shortShow, season, &shortEpisode, file, version, ext)
+	}, fits)
+	return name, fits(name)
+}
+
+// FitShowTitle returns the show title FitEpisode names the episode with:
+// the show's own if shortening the episode title is enough, or else
+// shortened as little as the shortest episode title allows, e.g. for its
+// folder. The episode title then gets back what room is left.
+func (f *Formatter) FitShowTitle(fits func(name string) bool, show, season *database.MetadataItem, episode *database.EpisodeInfo, file database.MediaPart, version, ext string) string {
+	shortShow, shortEpisode := *show, *episode
+	format := func() string {
+		return f.FormatEpisode(&shortShow, season, &shortEpisode, file, version, ext)
+	}
+	if fits(shorten(&shortEpisode.Metadata.Title, format, fits)) {
+		return show.Title
+	}
+	shorten(&shortShow.Title, format, fits)
+	return shortShow.Title
+}
+
+// FitMovie is FormatMovie with the movie title shortened as far as needed
+// for fits to accept the name, as for FitEpisode
+func (f *Formatter) FitMovie(fits func(name string) bool, movie *database.MovieInfo, file database.MediaPart, version, ext string) (string, bool) {
+	name := f.FormatMovie(movie, file, version, ext)
+	if fits(name) {
+		return name, true
+	}
+	short := *movie
+	name = shorten(&short.Metadata.Title, func() string { return f.FormatMovie(&short, file, version, ext) }, fits)
+	return name, fits(name)
+}
+
+// shorten cuts *title to the longest start for which fits accepts the name
+// format returns, keeping at least one character, and returns that name
+func shorten(title *string, format func() string, fits func(string) bool) string {
+	runes := []rune(*title)
+	cut := func(n int) string {
+		return strings.TrimRight(string(runes[:n]), " .,-")
+	}
+	lo, hi := min(1, len(runes)), len(runes)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if *title = cut(mid); fits(format()) {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	*title = cut(lo)
+	return format()
+}
@@ -0,0 +1,21 @@
+package renamer
+
+import (
+	"errors"
+	"syscall"
+)
+
+// IsLocked reports whether err means a file is in use by another program,
+// such as a sharing violation on Windows while Plex is reading the file
+func IsLocked(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	for _, e := range lockedErrnos {
+		if errno == e {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package renamer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LockHolders describes the processes that have path open, by scanning the
+// open files in /proc. Processes of other users are only visible to root.
+func LockHolders(path string) []string {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil
+	}
+	procs, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+
+	var holders []string
+	for _, proc := range procs {
+		pid, err := strconv.Atoi(proc.Name())
+		if err != nil {
+			continue
+		}
+		fds, err := os.ReadDir(filepath.Join("/proc", proc.Name(), "fd"))
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join("/proc", proc.Name(), "fd", fd.Name()))
+			if err != nil || target != absPath {
+				continue
+			}
+			comm, _ := os.ReadFile(filepath.Join("/proc", proc.Name(), "comm"))
+			holders = append(holders, fmt.Sprintf("%s (PID %d)", strings.TrimSpace(string(comm)), pid))
+			break
+		}
+	}
+	return holders
+}
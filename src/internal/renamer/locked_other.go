@@ -0,0 +1,9 @@
+//go:build !linux && !windows
+
+package renamer
+
+// LockHolders describes the processes that have path open. This isn't
+// supported on this platform.
+func LockHolders(path string) []string {
+	return nil
+}
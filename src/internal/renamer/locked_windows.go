@@ -0,0 +1,93 @@
+//go:build windows
+
+package renamer
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// Restart Manager, which can tell which processes have a file open
+var (
+	modrstrtmgr             = syscall.NewLazyDLL("rstrtmgr.dll")
+	procRmStartSession      = modrstrtmgr.NewProc("RmStartSession")
+	procRmRegisterResources = modrstrtmgr.NewProc("RmRegisterResources")
+	procRmGetList           = modrstrtmgr.NewProc("RmGetList")
+	procRmEndSession        = modrstrtmgr.NewProc("RmEndSession")
+)
+
+const (
+	cchRmSessionKey = 32  // CCH_RM_SESSION_KEY
+	cchRmMaxAppName = 255 // CCH_RM_MAX_APP_NAME
+	cchRmMaxSvcName = 63  // CCH_RM_MAX_SVC_NAME
+)
+
+// rmProcessInfo is RM_PROCESS_INFO
+type rmProcessInfo struct {
+	ProcessID        uint32
+	ProcessStartTime syscall.Filetime
+	AppName          [cchRmMaxAppName + 1]uint16
+	ServiceShortName [cchRmMaxSvcName + 1]uint16
+	ApplicationType  uint32
+	AppStatus        uint32
+	TSSessionID      uint32
+	Restartable      int32
+}
+
+// rmAppTypes names the RM_APP_TYPE values
+var rmAppTypes = map[uint32]string{
+	1:    "application",
+	2:    "application",
+	3:    "service",
+	4:    "Explorer",
+	5:    "console application",
+	1000: "system process",
+}
+
+// LockHolders describes the processes that have path open, using the
+// Windows Restart Manager, e.g. "Plex Media Server (service, PID 4242)"
+func LockHolders(path string) []string {
+	if procRmStartSession.Find() != nil {
+		return nil
+	}
+
+	var session uint32
+	var key [cchRmSessionKey + 1]uint16
+	if r, _, _ := procRmStartSession.Call(uintptr(unsafe.Pointer(&session)), 0, uintptr(unsafe.Pointer(&key[0]))); r != 0 {
+		return nil
+	}
+	defer procRmEndSession.Call(uintptr(session))
+
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil
+	}
+	files := []*uint16{name}
+	if r, _, _ := procRmRegisterResources.Call(uintptr(session), 1, uintptr(unsafe.Pointer(&files[0])), 0, 0, 0, 0); r != 0 {
+		return nil
+	}
+
+	var needed, reasons uint32
+	infos := make([]rmProcessInfo, 16)
+	count := uint32(len(infos))
+	if r, _, _ := procRmGetList.Call(
+		uintptr(session),
+		uintptr(unsafe.Pointer(&needed)),
+		uintptr(unsafe.Pointer(&count)),
+		uintptr(unsafe.Pointer(&infos[0])),
+		uintptr(unsafe.Pointer(&reasons)),
+	); r != 0 {
+		return nil
+	}
+
+	var holders []string
+	for _, info := range infos[:count] {
+		kind, ok := rmAppTypes[info.ApplicationType]
+		if !ok {
+			kind = "process"
+		}
+		holders = append(holders, fmt.Sprintf("%s (%s, PID %d)", syscall.UTF16ToString(info.AppName[:]), kind, info.ProcessID))
+	}
+	return holders
+}
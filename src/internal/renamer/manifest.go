@@ -0,0 +1,63 @@
+package renamer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// A manifest is a binary-safe list of operations: each operation is stored as
+// three NUL-terminated fields (mode, source, destination). NUL is the only byte
+// that cannot appear in a path, so no quoting or escaping is needed. It can be
+// viewed or diffed with `tr '\0' '\n' < rename.manifest`.
+
+// WriteManifest writes operations in manifest format
+func WriteManifest(w io.Writer, operations []Operation) error {
+	bw := bufio.NewWriter(w)
+	for _, op := range operations {
+		for _, field := range []string{string(op.Mode), op.Source, op.Destination} {
+			if _, err := bw.WriteString(field); err != nil {
+				return err
+			}
+			if err := bw.WriteByte(0); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadManifest reads operations in manifest format
+func ReadManifest(r io.Reader) ([]Operation, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if data[len(data)-1] != 0 {
+		return nil, fmt.Errorf("invalid manifest: last field is not NUL-terminated")
+	}
+
+	fields := bytes.Split(data[:len(data)-1], []byte{0})
+	if len(fields)%3 != 0 {
+		return nil, fmt.Errorf("invalid manifest: %d fields is not a multiple of 3 (mode, source, destination)", len(fields))
+	}
+
+	operations := make([]Operation, 0, len(fields)/3)
+	for i := 0; i < len(fields); i += 3 {
+		mode := OperationMode(fields[i])
+		if mode != ModeCopy && mode != ModeMove {
+			return nil, fmt.Errorf("invalid manifest: operation %d has unknown mode %q", i/3+1, mode)
+		}
+		operations = append(operations, Operation{
+			Source:      string(fields[i+1]),
+			Destination: string(fields[i+2]),
+			Mode:        mode,
+		})
+	}
+
+	return operations, nil
+}
@@ -0,0 +1,82 @@
+package renamer
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// MatchIndex finds files in a directory tree by their size and name, to
+// match files that were renamed or moved since Plex saw them, e.g. after
+// the server died and only a backup of its database is left
+type MatchIndex struct {
+	bySize map[int64][]string
+	byName map[string][]string // By lowercase file name
+	used   map[string]bool     // Files already matched, which aren't matched again
+}
+
+// NewMatchIndex indexes the regular files under root
+func NewMatchIndex(root string) (*MatchIndex, error) {
+	m := &MatchIndex{bySize: map[int64][]string{}, byName: map[string][]string{}, used: map[string]bool{}}
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		m.bySize[info.Size()] = append(m.bySize[info.Size()], p)
+		name := FoldPath(d.Name())
+		m.byName[name] = append(m.byName[name], p)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to index %s: %w", root, err)
+	}
+	return m, nil
+}
+
+// Match returns the file that is the file Plex knows at plexPath with size,
+// and marks it as matched. Files of the same size and extension match; when
+// there are several, the one with the same name does. Without a size, only
+// the name is compared. ok is false when no file, or more than one, fits.
+func (m *MatchIndex) Match(plexPath string, size int64) (match string, ok bool) {
+	name := FoldPath(path.Base(toSlash(plexPath)))
+	var candidates []string
+	if size > 0 {
+		for _, p := range m.bySize[size] {
+			if !m.used[p] && strings.EqualFold(filepath.Ext(p), path.Ext(name)) {
+				candidates = append(candidates, p)
+			}
+		}
+		if len(candidates) > 1 {
+			var named []string
+			for _, p := range candidates {
+				if FoldPath(filepath.Base(p)) == name {
+					named = append(named, p)
+				}
+			}
+			if len(named) > 0 {
+				candidates = named
+			}
+		}
+	} else {
+		for _, p := range m.byName[name] {
+			if !m.used[p] {
+				candidates = append(candidates, p)
+			}
+		}
+	}
+
+	if len(candidates) != 1 {
+		return "", false
+	}
+	m.used[candidates[0]] = true
+	return candidates[0], true
+}
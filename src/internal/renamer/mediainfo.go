@@ -0,0 +1,181 @@
+package renamer
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"plexrenamer/internal/database"
+)
+
+// mediaInfo describes a file the way release names, and the TRaSH guides'
+// naming that Sonarr and Radarr users follow, do: from what Plex recorded
+// about it and what its own name says
+type mediaInfo struct {
+	quality string // Source and resolution, e.g. Bluray-1080p or WEBDL-2160p Proper
+	hdr     string // Dynamic range, e.g. DV HDR10 or HDR10Plus, or "" for SDR
+	audio   string // Codec and channels, e.g. DTS-HD MA 7.1
+	video   string // Codec, e.g. x265 or h264
+	group   string // Release group
+}
+
+// releaseTag matches a tag of a release name, between separators. Digits
+// may follow, as in DDP5.1.
+func releaseTag(pattern string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)(?:^|[^a-z0-9])(?:` + pattern + `)(?:[^a-z]|$)`)
+}
+
+// namedTag is a tag of release names and the name it is given
+type namedTag struct {
+	re   *regexp.Regexp
+	name string
+}
+
+// sourceTags, audioTags, and videoTags are checked in order, so the more
+// specific tags come first
+var (
+	sourceTags = []namedTag{
+		{releaseTag(`remux`), "Remux"},
+		{releaseTag(`blu-?ray|bdrip|brrip|bd25|bd50`), "Bluray"},
+		{releaseTag(`web[-. ]?rip`), "WEBRip"},
+		{releaseTag(`web[-. ]?dl|web`), "WEBDL"},
+		{releaseTag(`hdtv`), "HDTV"},
+		{releaseTag(`dvd(?:rip|r|9|5)?`), "DVD"},
+	}
+	audioTags = []namedTag{
+		{releaseTag(`true-?hd`), "TrueHD"},
+		{releaseTag(`dts[-. ]?hd[-. ]?ma`), "DTS-HD MA"},
+		{releaseTag(`dts[-. ]?x`), "DTS-X"},
+		{releaseTag(`dts[-. ]?hd`), "DTS-HD"},
+		{releaseTag(`dts`), "DTS"},
+		{releaseTag(`ddp|dd\+|e-?ac-?3`), "EAC3"},
+		{releaseTag(`dd|ac-?3`), "AC3"},
+		{releaseTag(`aac`), "AAC"},
+		{releaseTag(`flac`), "FLAC"},
+		{releaseTag(`opus`), "Opus"},
+	}
+	videoTags = []namedTag{
+		{releaseTag(`x265`), "x265"},
+		{releaseTag(`x264`), "x264"},
+	}
+	otherVideoTags = []namedTag{
+		{releaseTag(`hevc|h\.?265`), "h265"},
+		{releaseTag(`avc|h\.?264`), "h264"},
+	}
+)
+
+var (
+	dolbyVisionTag = releaseTag(`dv|dovi|dolby[ .]?vision`)
+	hdr10PlusTag   = releaseTag(`hdr10(?:\+|plus)`)
+	hdr10Tag       = releaseTag(`hdr(?:10)?`)
+	hlgTag         = releaseTag(`hlg`)
+	atmosTag       = releaseTag(`atmos`)
+	properTag      = releaseTag(`proper|repack`)
+	resolutionTag  = regexp.MustCompile(`(?i)(?:^|[^a-z0-9])(2160|1080|720|576|480)[pi](?:[^a-z0-9]|$)`)
+	groupSuffix    = regexp.MustCompile(`[^\s-]-([A-Za-z0-9]+)(?:\[[^\]]*\])?$`)
+)
+
+// plexAudioCodecs and plexVideoCodecs name the codecs Plex records the way
+// release names do
+var (
+	plexAudioCodecs = map[string]string{
+		"aac": "AAC", "ac3": "AC3", "eac3": "EAC3", "dca": "DTS", "dts": "DTS", "truehd": "TrueHD",
+		"flac": "FLAC", "opus": "Opus", "mp3": "MP3", "pcm": "PCM", "vorbis": "Vorbis",
+	}
+	plexVideoCodecs = map[string]string{
+		"h264": "h264", "hevc": "h265", "av1": "AV1", "vc1": "VC1", "mpeg2video": "MPEG2", "mpeg4": "XviD", "vp9": "VP9",
+	}
+)
+
+// readMediaInfo describes file. Plex knows the resolution, codecs, and
+// channels; the source, dynamic range, and group come from the file's name.
+func readMediaInfo(file database.MediaPart) mediaInfo {
+	name := strings.TrimSuffix(filepath.Base(file.File), filepath.Ext(file.File))
+	// Names with spaces and no resolution are titles rather than releases,
+	// and words such as Web in them aren't tags
+	if strings.Contains(name, " ") && !resolutionTag.MatchString(name) {
+		name = ""
+	}
+	var info mediaInfo
+
+	source := findTag(name, sourceTags)
+	resolution := ""
+	if r := Resolution(file.Width, file.Height); r >= 480 {
+		resolution = fmt.Sprintf("%dp", r)
+	} else if m := resolutionTag.FindStringSubmatch(name); m != nil {
+		resolution = m[1] + "p"
+	}
+	switch {
+	case source == "DVD" || resolution == "":
+		info.quality = source
+	case source != "":
+		info.quality = source + "-" + resolution
+	default:
+		info.quality = resolution
+	}
+	if info.quality != "" && properTag.MatchString(name) {
+		info.quality += " Proper"
+	}
+
+	var hdr []string
+	if dolbyVisionTag.MatchString(name) {
+		hdr = append(hdr, "DV")
+	}
+	switch {
+	case hdr10PlusTag.MatchString(name):
+		hdr = append(hdr, "HDR10Plus")
+	case hdr10Tag.MatchString(name):
+		hdr = append(hdr, "HDR10")
+	case hlgTag.MatchString(name):
+		hdr = append(hdr, "HLG")
+	}
+	info.hdr = strings.Join(hdr, " ")
+
+	codec := findTag(name, audioTags)
+	if codec == "" {
+		codec = plexAudioCodecs[strings.ToLower(file.AudioCodec)]
+	}
+	if codec != "" && atmosTag.MatchString(name) {
+		codec += " Atmos"
+	}
+	info.audio = strings.TrimSpace(codec + " " + channelLayout(file.Channels))
+
+	info.video = findTag(name, videoTags)
+	if info.video == "" {
+		info.video = plexVideoCodecs[strings.ToLower(file.VideoCodec)]
+	}
+	if info.video == "" {
+		info.video = findTag(name, otherVideoTags)
+	}
+
+	// Only names that look like releases end in a group
+	if source != "" || resolutionTag.MatchString(name) {
+		if m := groupSuffix.FindStringSubmatch(name); m != nil && !strings.EqualFold(m[1], "DL") {
+			info.group = m[1]
+		}
+	}
+	return info
+}
+
+// findTag returns the name of the first of tags found in name, or ""
+func findTag(name string, tags []namedTag) string {
+	for _, t := range tags {
+		if t.re.MatchString(name) {
+			return t.name
+		}
+	}
+	return ""
+}
+
+// channelLayout names a number of audio channels as releases do, e.g. 6 is
+// 5.1, or returns "" if it is unknown
+func channelLayout(channels int) string {
+	switch {
+	case channels <= 0:
+		return ""
+	case channels >= 6:
+		return fmt.Sprintf("%d.1", channels-1)
+	}
+	return fmt.Sprintf("%d.0", channels)
+}
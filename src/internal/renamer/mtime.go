@@ -0,0 +1,39 @@
+package renamer
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// MtimeMode is what --set-mtime sets the modification time of destinations to
+type MtimeMode string
+
+const (
+	MtimeKeep    MtimeMode = ""        // Whatever the operation leaves it at
+	MtimeSource  MtimeMode = "source"  // That of the source
+	MtimeAirdate MtimeMode = "airdate" // The day the episode aired or the movie was released
+)
+
+// ParseMtimeMode parses a --set-mtime value
+func ParseMtimeMode(s string) (MtimeMode, error) {
+	switch m := MtimeMode(s); m {
+	case MtimeKeep, MtimeSource, MtimeAirdate:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid set-mtime mode: %s (use source or airdate)", s)
+}
+
+// mtime returns the modification time to give op's destination, or the zero
+// time to leave it as it is. source describes the source before the
+// operation (nil if unknown). Files whose air date is unknown get the time
+// of their source.
+func (op *Operation) mtime(mode MtimeMode, source os.FileInfo) time.Time {
+	if mode == MtimeAirdate && !op.Aired.IsZero() {
+		return op.Aired
+	}
+	if mode == MtimeKeep || source == nil {
+		return time.Time{}
+	}
+	return source.ModTime()
+}
@@ -1,10 +1,14 @@
 package renamer
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"time"
 )
 
 // OperationMode defines how files should be processed
@@ -20,6 +24,68 @@ type Operation struct {
 	Source      string
 	Destination string
 	Mode        OperationMode
+	Title       string    // Show or movie title, if known
+	Item        int64     // Plex metadata ID of the show or movie, if known
+	Library     string    // Name of the library of the show or movie, if known
+	GUID        string    // Plex GUID of the movie or episode, if known
+	Size        int64     // Source size as recorded by Plex, if known
+	Added       time.Time // When Plex added the movie or episode, if known
+	Aired       time.Time // When the episode aired or the movie was released, if known
+	Staging     string    // Temporary name the source goes through, see StageOverlaps
+	Quality     Quality   // Of the source, if known
+	Existing    Quality   // Of the file at the destination, if Plex knows it
+}
+
+// ExecOptions controls how operations are executed
+type ExecOptions struct {
+	DryRun    bool
+	Preserve  Preserve      // Attributes carried over when a file is copied
+	Reflink   ReflinkMode   // Whether copies may share data blocks with the source
+	Executor  Executor      // Performs operations elsewhere, e.g. over SSH (nil = locally)
+	Retries   int           // Times an operation is retried after a transient error
+	RetryWait time.Duration // Wait before the first retry, doubled for each one after
+	OnExists  ExistsPolicy  // What to do when the destination exists ("" = skip)
+	Fsync     bool          // Flush files and directories to disk before an operation counts as done
+	SetMtime  MtimeMode     // Modification time given to destinations ("" = as the operation leaves it)
+}
+
+// Executor performs operations somewhere other than the local filesystem
+type Executor interface {
+	Execute(ctx context.Context, op Operation, opts ExecOptions) Result
+}
+
+// SkipReason is why a file was skipped: by an operation, or by being left
+// out of the plan
+type SkipReason string
+
+const (
+	SkipExists    SkipReason = "exists"     // The destination already exists
+	SkipNotBetter SkipReason = "not-better" // The destination exists and is as good or better (--prefer better)
+	SkipMissing   SkipReason = "missing"    // The source can't be reached (--check-sources)
+	SkipSuspect   SkipReason = "suspect"    // The source is empty or not the container its extension says
+	SkipFiltered  SkipReason = "filtered"   // Left out by a filter or the budget
+	SkipDeclined  SkipReason = "declined"   // Declined at the prompts
+)
+
+// SkipReasons are the reasons for skipping, in the order they are reported.
+// What was declined is counted on its own, as libraries, shows, and movies
+// rather than files, so it isn't among them.
+var SkipReasons = []SkipReason{SkipExists, SkipNotBetter, SkipMissing, SkipSuspect, SkipFiltered}
+
+// CountSkips counts the skipped results by reason. Skips without a reason
+// count as SkipExists, which is what executors skip for.
+func CountSkips(results []Result) map[SkipReason]int {
+	counts := map[SkipReason]int{}
+	for _, r := range results {
+		if r.Skipped && r.Error == nil {
+			reason := r.Reason
+			if reason == "" {
+				reason = SkipExists
+			}
+			counts[reason]++
+		}
+	}
+	return counts
 }
 
 // Result represents the outcome of an operation
@@ -27,132 +93,362 @@ type Result struct {
 	Operation Operation
 	Success   bool
 	Skipped   bool
+	Reason    SkipReason // Why it was skipped, if it was
 	Error     error
 	Message   string
+	Attempts  int      // How many times the operation was tried
+	LockedBy  []string // Programs holding the file open, if it was locked
+	Backup    string   // Where the existing destination was moved, with OnExists overwrite-backup or better
+	Warning   string   // What went wrong after the operation succeeded, if anything
+	Copied    bool     // Whether the data was copied, rather than renamed in place
+}
+
+// Execute performs the file operation, retrying transient errors as set in
+// opts. Cancelling ctx stops a copy in progress and removes the partial
+// destination.
+func (op *Operation) Execute(ctx context.Context, opts ExecOptions) Result {
+	if op.Staging != "" {
+		return op.finishStaged(ctx, opts)
+	}
+	return executeWithRetry(ctx, opts, func() Result {
+		if opts.Executor != nil {
+			return opts.Executor.Execute(ctx, *op, opts)
+		}
+		return op.execute(ctx, opts)
+	})
 }
 
-// Execute performs the file operation
-func (op *Operation) Execute(dryRun bool) Result {
+// execute makes a single attempt at performing the file operation
+func (op *Operation) execute(ctx context.Context, opts ExecOptions) Result {
 	result := Result{Operation: *op}
+	if err := ctx.Err(); err != nil {
+		result.Error = err
+		return result
+	}
 
 	// In dry-run mode, just report success without checking files
-	if dryRun {
+	if opts.DryRun {
 		result.Success = true
 		result.Message = "dry run - no changes made"
 		return result
 	}
 
 	// Check if source exists (only when actually executing)
-	if _, err := os.Stat(op.Source); os.IsNotExist(err) {
+	sourceInfo, err := os.Stat(op.Source)
+	if os.IsNotExist(err) {
 		result.Error = fmt.Errorf("source file does not exist: %s", op.Source)
 		return result
 	}
 
-	// Check if destination exists (skip if it does)
-	if _, err := os.Stat(op.Destination); err == nil {
-		result.Skipped = true
-		result.Success = true
-		result.Message = "destination already exists, skipped"
-		return result
+	// Check if destination exists (skip it, or back it up). A source that
+	// is the destination, e.g. in a case-only rename, is never backed up.
+	if destInfo, err := os.Stat(op.Destination); err == nil {
+		srcInfo, srcErr := os.Stat(op.Source)
+		same := srcErr == nil && os.SameFile(srcInfo, destInfo)
+		if opts.OnExists == ExistsBetter && !same && !op.replacesExisting(destInfo) {
+			result.Skipped = true
+			result.Reason = SkipNotBetter
+			result.Success = true
+			result.Message = "existing file is as good or better, skipped"
+			removeStalePartial(op.Destination)
+			return result
+		}
+		if (opts.OnExists != ExistsBackup && opts.OnExists != ExistsBetter) || same {
+			result.Skipped = true
+			result.Reason = SkipExists
+			result.Success = true
+			result.Message = "destination already exists, skipped"
+			removeStalePartial(op.Destination)
+			return result
+		}
+		if result.Backup, err = backupDestination(op); err != nil {
+			result.Error = err
+			return result
+		}
 	}
 
-	// Create destination directory
+	// Create destination directory. With Fsync, each folder created is
+	// flushed into its parent, so the destination can still be found after
+	// a power loss once the source of a move is gone.
 	destDir := filepath.Dir(op.Destination)
+	var created []string
+	if opts.Fsync {
+		created = missingDirs(destDir)
+	}
 	if err := os.MkdirAll(destDir, 0755); err != nil {
 		result.Error = fmt.Errorf("failed to create directory %s: %w", destDir, err)
 		return result
 	}
+	for _, dir := range created {
+		if err := syncDir(filepath.Dir(dir)); err != nil {
+			result.Error = err
+			return result
+		}
+	}
 
 	// Perform the operation
-	var err error
 	switch op.Mode {
 	case ModeCopy:
-		err = copyFile(op.Source, op.Destination)
+		err = copyFile(ctx, op.Source, op.Destination, opts)
+		result.Copied = err == nil
 	case ModeMove:
-		err = moveFile(op.Source, op.Destination)
+		result.Copied, err = moveFile(ctx, op.Source, op.Destination, opts)
 	default:
 		err = fmt.Errorf("unknown operation mode: %s", op.Mode)
 	}
 
 	if err != nil {
 		result.Error = err
+		if result.Backup != "" {
+			// Put the existing file back, as nothing replaced it
+			if rerr := os.Rename(result.Backup, op.Destination); rerr == nil {
+				result.Backup = ""
+			}
+		}
+		if IsLocked(err) {
+			result.Error = fmt.Errorf("file is in use by another program: %w", err)
+			result.LockedBy = LockHolders(op.Source)
+		}
 		return result
 	}
 
+	// The file is where it belongs either way, so a time that can't be set
+	// only warns
+	if mtime := op.mtime(opts.SetMtime, sourceInfo); !mtime.IsZero() {
+		if err := os.Chtimes(op.Destination, time.Time{}, mtime); err != nil {
+			result.Warning = fmt.Sprintf("failed to set the modification time: %v", err)
+		}
+	}
+
 	result.Success = true
 	result.Message = fmt.Sprintf("%s completed", op.Mode)
+	if result.Backup != "" {
+		result.Message += ", existing file kept as " + filepath.Base(result.Backup)
+	}
+	if result.Warning != "" {
+		result.Message += ", but " + result.Warning
+	}
 	return result
 }
 
-// copyFile copies a file from src to dst
-func copyFile(src, dst string) error {
+// PartialSuffix is added to the name of a destination while it is written,
+// so a copy cut short never looks like a finished file. A copy interrupted by
+// a crash leaves it behind, and the next copy of the same source resumes it.
+const PartialSuffix = ".plexrenamer.partial"
+
+// copyFile copies a file from src to dst, carrying over the attributes in
+// opts.Preserve. The data is written to dst's partial file, which is renamed
+// to dst once it is complete.
+func copyFile(ctx context.Context, src, dst string, opts ExecOptions) error {
 	sourceFile, err := os.Open(src)
 	if err != nil {
 		return fmt.Errorf("failed to open source: %w", err)
 	}
 	defer sourceFile.Close()
+	sourceInfo, err := sourceFile.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat source: %w", err)
+	}
 
-	destFile, err := os.Create(dst)
+	partial := dst + PartialSuffix
+	destFile, offset, err := openPartial(partial, sourceFile, sourceInfo)
 	if err != nil {
 		return fmt.Errorf("failed to create destination: %w", err)
 	}
 	defer destFile.Close()
 
-	if _, err := io.Copy(destFile, sourceFile); err != nil {
+	if offset > 0 {
+		if _, err = sourceFile.Seek(offset, io.SeekStart); err == nil {
+			err = copyRange(ctx, destFile, sourceFile, -1)
+		}
+	} else {
+		err = copyContents(ctx, destFile, sourceFile, opts.Reflink)
+	}
+	if err != nil {
 		// Try to clean up partial file
-		os.Remove(dst)
+		destFile.Close()
+		os.Remove(partial)
 		return fmt.Errorf("failed to copy: %w", err)
 	}
 
-	// Preserve file permissions
-	sourceInfo, err := os.Stat(src)
-	if err == nil {
-		os.Chmod(dst, sourceInfo.Mode())
+	// The data is on disk before the name is, so a crash never leaves a
+	// truncated file under the destination's name
+	if err := destFile.Sync(); err != nil {
+		destFile.Close()
+		os.Remove(partial)
+		return fmt.Errorf("failed to flush destination: %w", err)
 	}
 
+	// Close before applying attributes, as closing may update the times
+	if err := destFile.Close(); err != nil {
+		os.Remove(partial)
+		return fmt.Errorf("failed to write destination: %w", err)
+	}
+	if err := applyPreserve(src, partial, sourceInfo, opts.Preserve); err != nil {
+		os.Remove(partial)
+		return err
+	}
+	if err := os.Rename(partial, dst); err != nil {
+		os.Remove(partial)
+		return fmt.Errorf("failed to move the copy into place: %w", err)
+	}
+	if opts.Fsync {
+		return syncDir(filepath.Dir(dst))
+	}
 	return nil
 }
 
-// moveFile moves a file from src to dst
-func moveFile(src, dst string) error {
-	// Try rename first (works if same filesystem)
-	if err := os.Rename(src, dst); err == nil {
-		return nil
+// partialCheck is how much of the end of a partial file is compared with the
+// source before a copy is resumed
+const partialCheck = 1 << 20
+
+// openPartial opens the partial file of a copy of source for writing. One
+// left by an interrupted copy of the same source, which is no larger than it,
+// was written after it last changed, and ends with the same bytes as that
+// part of it, is resumed at its end; any other is started over. It returns
+// the file and where writing continues.
+func openPartial(partial string, source *os.File, sourceInfo os.FileInfo) (*os.File, int64, error) {
+	if info, err := os.Stat(partial); err == nil && info.Mode().IsRegular() && info.Size() <= sourceInfo.Size() && info.ModTime().After(sourceInfo.ModTime()) {
+		if f, err := os.OpenFile(partial, os.O_RDWR, 0); err == nil {
+			if sameTail(f, source, info.Size()) {
+				if _, err := f.Seek(info.Size(), io.SeekStart); err == nil {
+					return f, info.Size(), nil
+				}
+			}
+			f.Close()
+		}
+	}
+	f, err := os.Create(partial)
+	return f, 0, err
+}
+
+// sameTail reports whether the last partialCheck bytes before size are the
+// same in both files
+func sameTail(a, b *os.File, size int64) bool {
+	n := min(size, partialCheck)
+	bufA, bufB := make([]byte, n), make([]byte, n)
+	if _, err := a.ReadAt(bufA, size-n); err != nil {
+		return false
+	}
+	if _, err := b.ReadAt(bufB, size-n); err != nil {
+		return false
+	}
+	return bytes.Equal(bufA, bufB)
+}
+
+// removeStalePartial removes the partial file of dst left by an interrupted
+// copy, once dst is kept as it is and it will never be resumed
+func removeStalePartial(dst string) {
+	if info, err := os.Lstat(dst + PartialSuffix); err == nil && info.Mode().IsRegular() {
+		os.Remove(dst + PartialSuffix)
+	}
+}
+
+// moveFile moves a file from src to dst. A rename keeps all attributes, while
+// the copy fallback carries over the attributes in opts.Preserve. It reports
+// whether the data was copied.
+func moveFile(ctx context.Context, src, dst string, opts ExecOptions) (bool, error) {
+	// Try rename first (works if same filesystem). A source locked by
+	// another program can't be copied and removed either, so it is left to
+	// be retried.
+	err := os.Rename(src, dst)
+	if err == nil {
+		if opts.Fsync {
+			return false, syncDirs(filepath.Dir(dst), filepath.Dir(src))
+		}
+		return false, nil
+	}
+	if IsLocked(err) {
+		return false, fmt.Errorf("failed to move: %w", err)
 	}
 
 	// Fall back to copy + delete
-	if err := copyFile(src, dst); err != nil {
-		return err
+	if err := copyFile(ctx, src, dst, opts); err != nil {
+		return false, err
 	}
 
 	// Verify the copy before deleting source
 	srcInfo, _ := os.Stat(src)
 	dstInfo, err := os.Stat(dst)
 	if err != nil {
-		return fmt.Errorf("failed to verify copy: %w", err)
+		return true, fmt.Errorf("failed to verify copy: %w", err)
 	}
 
 	if srcInfo.Size() != dstInfo.Size() {
 		os.Remove(dst)
-		return fmt.Errorf("copy verification failed: size mismatch")
+		return true, fmt.Errorf("copy verification failed: size mismatch")
 	}
 
-	// Delete source
+	// Delete source. If it can't be, the copy is removed instead, so the file
+	// is never left in both places and the move can be retried.
 	if err := os.Remove(src); err != nil {
-		return fmt.Errorf("copied successfully but failed to remove source: %w", err)
+		os.Remove(dst)
+		return true, fmt.Errorf("failed to remove source, the copy was removed: %w", err)
+	}
+	if opts.Fsync {
+		return true, syncDir(filepath.Dir(src))
 	}
 
+	return true, nil
+}
+
+// missingDirs returns dir and the folders above it that don't exist, up to
+// the first one that does
+func missingDirs(dir string) []string {
+	var missing []string
+	for ; ; dir = filepath.Dir(dir) {
+		if _, err := os.Lstat(dir); !os.IsNotExist(err) || filepath.Dir(dir) == dir {
+			return missing
+		}
+		missing = append(missing, dir)
+	}
+}
+
+// syncDirs flushes the entries of each directory to disk, so that files
+// created, renamed, or removed in it stay that way after a power loss
+func syncDirs(dirs ...string) error {
+	for i, dir := range dirs {
+		if i > 0 && dir == dirs[0] {
+			continue
+		}
+		if err := syncDir(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncDir flushes the entries of dir to disk. Windows has no way to flush a
+// directory, as NTFS commits them itself, so it does nothing there.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to flush directory: %w", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to flush directory: %w", err)
+	}
 	return nil
 }
 
-// BatchExecute executes multiple operations and returns results
-func BatchExecute(operations []Operation, dryRun bool, progressFn func(current, total int, op Operation)) []Result {
-	results := make([]Result, len(operations))
+// BatchExecute executes multiple operations and returns results. If ctx is
+// cancelled, it stops and returns the results of the operations attempted so far.
+func BatchExecute(ctx context.Context, operations []Operation, opts ExecOptions, progressFn func(current, total int, op Operation)) []Result {
+	StageSources(ctx, operations, opts)
+	results := make([]Result, 0, len(operations))
 	for i, op := range operations {
+		if ctx.Err() != nil {
+			break
+		}
 		if progressFn != nil {
 			progressFn(i+1, len(operations), op)
 		}
-		results[i] = op.Execute(dryRun)
+		results = append(results, op.Execute(ctx, opts))
 	}
+	UnstageSources(operations[len(results):], opts)
 	return results
 }
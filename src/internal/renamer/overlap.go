@@ -0,0 +1,92 @@
+package renamer
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+)
+
+// StageOverlaps finds the moves whose source is the destination of another
+// operation, as when renaming in place swaps or shifts names, or that only
+// change the case of a name. It gives them a temporary name to go through,
+// so they move out of the way before the other operations run and are
+// finished in their turn. It returns how many moves were staged.
+func StageOverlaps(operations []Operation) int {
+	destinations := make(map[string]int, len(operations))
+	for i, op := range operations {
+		destinations[pathKey(op.Destination)] = i
+	}
+
+	staged := 0
+	for i := range operations {
+		op := &operations[i]
+		if op.Mode != ModeMove {
+			continue
+		}
+		j, overlaps := destinations[pathKey(op.Source)]
+		caseOnly := j == i && op.Source != op.Destination
+		if !overlaps || (j == i && !caseOnly) {
+			continue
+		}
+		op.Staging = filepath.Join(filepath.Dir(op.Source), fmt.Sprintf(".plexrenamer-%d-%s", i+1, filepath.Base(op.Source)))
+		staged++
+	}
+	return staged
+}
+
+// StageSources is the first phase of a two-phase move: it renames the
+// sources of staged operations to their temporary names. Operations whose
+// source couldn't be staged run as ordinary moves.
+func StageSources(ctx context.Context, operations []Operation, opts ExecOptions) {
+	if opts.DryRun {
+		return
+	}
+	for i := range operations {
+		op := &operations[i]
+		if op.Staging == "" || ctx.Err() != nil {
+			continue
+		}
+		stage := Operation{Source: op.Source, Destination: op.Staging, Mode: ModeMove}
+		if r := stage.Execute(ctx, opts); r.Error != nil || r.Skipped {
+			op.Staging = ""
+		}
+	}
+}
+
+// UnstageSources moves the staged sources of operations that didn't run,
+// e.g. after the run was cancelled, back to where they came from
+func UnstageSources(operations []Operation, opts ExecOptions) {
+	if opts.DryRun {
+		return
+	}
+	for _, op := range operations {
+		if op.Staging != "" {
+			back := Operation{Source: op.Staging, Destination: op.Source, Mode: ModeMove}
+			back.Execute(context.Background(), opts)
+		}
+	}
+}
+
+// finishStaged is the second phase of a two-phase move: it moves the file
+// from its temporary name to the destination, or back to where it came
+// from if that fails
+func (op *Operation) finishStaged(ctx context.Context, opts ExecOptions) Result {
+	final := *op
+	final.Source, final.Staging = op.Staging, ""
+	result := final.Execute(ctx, opts)
+	result.Operation = *op
+
+	if result.Error == nil && !result.Skipped {
+		return result
+	}
+	back := Operation{Source: op.Staging, Destination: op.Source, Mode: ModeMove}
+	if r := back.Execute(context.WithoutCancel(ctx), opts); r.Error == nil && !r.Skipped {
+		// Back in place, so a retry is an ordinary move
+		result.Operation.Staging = ""
+	} else if result.Error != nil {
+		result.Error = fmt.Errorf("%w; the file was left at %s", result.Error, op.Staging)
+	} else {
+		result.Message = fmt.Sprintf("%s; the file was left at %s", result.Message, op.Staging)
+	}
+	return result
+}
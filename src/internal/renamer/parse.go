@@ -0,0 +1,150 @@
+package renamer
+
+import (
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SeasonFolder matches folder names that hold one season of a show
+var SeasonFolder = regexp.MustCompile(`(?i)^(season|series|saison|staffel|temporada|stagione|specials|s\d+$)`)
+
+// videoExtensions are the extensions of the files ScanVideos finds
+var videoExtensions = map[string]bool{
+	".mkv": true, ".mp4": true, ".m4v": true, ".avi": true, ".mov": true, ".wmv": true,
+	".ts": true, ".m2ts": true, ".webm": true, ".mpg": true, ".mpeg": true, ".flv": true,
+}
+
+var (
+	episodeName  = regexp.MustCompile(`(?i)(?:^|[^a-z0-9])s(\d{1,2})[ ._-]?e(\d{1,3})(?:[^0-9]|$)`)
+	crossedName  = regexp.MustCompile(`(?i)(?:^|[^a-z0-9])(\d{1,2})x(\d{2,3})(?:[^0-9]|$)`)
+	yearName     = regexp.MustCompile(`(?:^|[^a-zA-Z0-9])((?:19|20)\d{2})(?:[^0-9]|$)`)
+	releaseStart = releaseTag(`2160p|1080p|720p|576p|480p|remux|blu-?ray|bdrip|brrip|web[-. ]?(?:dl|rip)|hdtv|dvdrip|x26[45]|h\.?26[45]|hevc|proper|repack`)
+	sampleName   = regexp.MustCompile(`(?i)(?:^|[^a-z])sample(?:[^a-z]|$)`)
+)
+
+// ParsedName is what a file name, or the folders it is in, says the file is
+type ParsedName struct {
+	Title   string
+	Year    int // 0 if unknown
+	Season  int
+	Episode int // 0 for movies
+	Height  int // Of a resolution tag such as 1080p, 0 if there is none
+}
+
+// IsEpisode reports whether the name is that of an episode
+func (p ParsedName) IsEpisode() bool {
+	return p.Episode > 0
+}
+
+// ParseName parses a video file name in the common release styles, e.g.
+// Show.Name.S01E02.720p.mkv, Show Name - 1x02.mkv, or Movie.Name.1999.mkv.
+// An episode named by its number only takes the show's name from its
+// folder, above a season folder, as does a movie without a year in its name.
+func ParseName(path string) ParsedName {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	height := 0
+	if m := resolutionTag.FindStringSubmatch(name); m != nil {
+		height, _ = strconv.Atoi(m[1])
+	}
+
+	m := episodeName.FindStringSubmatchIndex(name)
+	if m == nil {
+		m = crossedName.FindStringSubmatchIndex(name)
+	}
+	if m != nil {
+		p := ParsedName{Height: height}
+		p.Season, _ = strconv.Atoi(name[m[2]:m[3]])
+		p.Episode, _ = strconv.Atoi(name[m[4]:m[5]])
+		p.Title, p.Year = titleYear(name[:m[0]])
+		if p.Title == "" {
+			dir := filepath.Dir(path)
+			if SeasonFolder.MatchString(filepath.Base(dir)) {
+				dir = filepath.Dir(dir)
+			}
+			p.Title, p.Year = titleYear(filepath.Base(dir))
+		}
+		return p
+	}
+
+	title, year := titleYear(name)
+	if year == 0 {
+		if folderTitle, folderYear := titleYear(filepath.Base(filepath.Dir(path))); folderYear != 0 {
+			title, year = folderTitle, folderYear
+		}
+	}
+	return ParsedName{Title: title, Year: year, Height: height}
+}
+
+// titleYear splits a name into a title and the year that follows it, and
+// drops the release tags after them
+func titleYear(name string) (string, int) {
+	if m := releaseStart.FindStringIndex(name); m != nil && m[0] > 0 {
+		name = name[:m[0]]
+	}
+	year := 0
+	// The last year is the release's, as in 2001.A.Space.Odyssey.1968
+	matches := yearName.FindAllStringSubmatchIndex(name, -1)
+	for i := len(matches) - 1; i >= 0; i-- {
+		if m := matches[i]; m[2] > 0 {
+			year, _ = strconv.Atoi(name[m[2]:m[3]])
+			name = name[:m[2]]
+			break
+		}
+	}
+	return cleanTitle(name), year
+}
+
+// cleanTitle turns the separators of a release name into spaces. Dots are
+// kept in names that have spaces, as in Mr. Robot.
+func cleanTitle(name string) string {
+	if !strings.Contains(name, " ") {
+		name = strings.ReplaceAll(name, ".", " ")
+	}
+	name = strings.ReplaceAll(name, "_", " ")
+	name = strings.Join(strings.Fields(name), " ")
+	return strings.TrimRight(strings.TrimLeft(name, " -([."), " -([.")
+}
+
+// ScannedVideo is a video file found by ScanVideos
+type ScannedVideo struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+	Name    ParsedName
+}
+
+// ScanVideos finds the video files under root and parses their names,
+// leaving out samples, hidden folders (such as the leftovers' trash), and
+// files whose name has no title, in the order of their paths.
+func ScanVideos(root string) ([]ScannedVideo, error) {
+	var videos []ScannedVideo
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !videoExtensions[strings.ToLower(filepath.Ext(path))] || sampleName.MatchString(d.Name()) {
+			return nil
+		}
+		name := ParseName(path)
+		if name.Title == "" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		videos = append(videos, ScannedVideo{Path: path, Size: info.Size(), ModTime: info.ModTime(), Name: name})
+		return nil
+	})
+	return videos, err
+}
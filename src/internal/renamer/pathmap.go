@@ -0,0 +1,95 @@
+package renamer
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// PathMap translates paths as Plex sees them to paths on this machine
+type PathMap struct {
+	From string `json:"from"` // Path prefix as stored in the Plex database
+	To   string `json:"to"`   // Equivalent prefix on this machine
+}
+
+// ParsePathMap parses an "old:new" mapping. Colons after a Windows drive
+// letter (e.g. "F:\Media:/mnt/media") or a URL scheme (smb://nas/media) are
+// not treated as the separator.
+func ParsePathMap(s string) (PathMap, error) {
+	for i := 0; i < len(s); i++ {
+		// Skip the colon of a leading drive letter, or of a URL scheme as
+		// in smb://nas/media, which Kodi stores
+		if s[i] != ':' || (i == 1 && isLetter(s[0])) || (isScheme(s[:i]) && strings.HasPrefix(s[i+1:], "//")) {
+			continue
+		}
+		from, to := s[:i], s[i+1:]
+		if from == "" || to == "" {
+			break
+		}
+		return PathMap{From: from, To: to}, nil
+	}
+	return PathMap{}, fmt.Errorf("invalid path mapping %q, use old:new", s)
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// isScheme reports whether s is the scheme of a URL, such as smb or nfs
+func isScheme(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isLetter(s[i]) {
+			return false
+		}
+	}
+	return len(s) > 1
+}
+
+// String formats the mapping as accepted by ParsePathMap
+func (m PathMap) String() string {
+	return m.From + ":" + m.To
+}
+
+// MapPath translates path with the mapping whose From is the longest matching
+// prefix. Both separators are accepted, so Windows paths from a database
+// copied off a Windows server map onto Unix mounts and vice versa.
+func MapPath(path string, maps []PathMap) string {
+	normalizedPath := toSlash(path)
+	best := -1
+	for i, m := range maps {
+		from := strings.TrimSuffix(toSlash(m.From), "/")
+		if from == "" || !hasPathPrefix(normalizedPath, from) {
+			continue
+		}
+		if best < 0 || len(m.From) > len(maps[best].From) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return path
+	}
+
+	m := maps[best]
+	rest := normalizedPath[len(strings.TrimSuffix(toSlash(m.From), "/")):]
+	return filepath.FromSlash(strings.TrimSuffix(toSlash(m.To), "/") + rest)
+}
+
+// UnmapPath translates a path on this machine back to the path Plex sees,
+// undoing MapPath. Separators are those of this machine.
+func UnmapPath(path string, maps []PathMap) string {
+	reversed := make([]PathMap, len(maps))
+	for i, m := range maps {
+		reversed[i] = PathMap{From: m.To, To: m.From}
+	}
+	return MapPath(path, reversed)
+}
+
+// hasPathPrefix reports whether path is prefix or lies below it
+func hasPathPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// toSlash converts both Windows and Unix separators to forward slashes
+func toSlash(path string) string {
+	return strings.ReplaceAll(path, `\`, "/")
+}
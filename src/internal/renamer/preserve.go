@@ -0,0 +1,99 @@
+package renamer
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Preserve is a set of file attributes to carry over from source to destination
+type Preserve uint8
+
+const (
+	PreserveMode  Preserve = 1 << iota // Permission bits
+	PreserveTimes                      // Modification and access times
+	PreserveOwner                      // User and group (Unix, only when running as root)
+	PreserveXattr                      // Extended attributes (Linux) or alternate data streams (Windows)
+)
+
+// DefaultPreserve matches the behavior of earlier versions, which only kept permissions
+const DefaultPreserve = PreserveMode
+
+var preserveNames = map[string]Preserve{
+	"mode":  PreserveMode,
+	"times": PreserveTimes,
+	"owner": PreserveOwner,
+	"xattr": PreserveXattr,
+}
+
+// ParsePreserve parses a comma-separated attribute list such as "mode,times".
+// "all" selects every attribute and "none" (or an empty string) selects none.
+func ParsePreserve(s string) (Preserve, error) {
+	var p Preserve
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "", "none":
+			continue
+		case "all":
+			p |= PreserveMode | PreserveTimes | PreserveOwner | PreserveXattr
+			continue
+		}
+		flag, ok := preserveNames[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown attribute %q (valid: %s, all, none)", name, strings.Join(preserveAttributeNames(), ", "))
+		}
+		p |= flag
+	}
+	return p, nil
+}
+
+// preserveAttributeNames returns the valid attribute names, sorted
+func preserveAttributeNames() []string {
+	names := make([]string, 0, len(preserveNames))
+	for name := range preserveNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyPreserve carries the attributes in p over from src (described by info) to dst
+func applyPreserve(src, dst string, info os.FileInfo, p Preserve) error {
+	if p&PreserveMode != 0 {
+		if err := os.Chmod(dst, info.Mode().Perm()); err != nil && !refused(err) {
+			return fmt.Errorf("copied but failed to preserve permissions: %w", err)
+		}
+	}
+
+	// Ownership goes before xattrs and times, as chown can clear some of them
+	if p&PreserveOwner != 0 {
+		if err := copyOwner(dst, info); err != nil {
+			return fmt.Errorf("copied but failed to preserve owner: %w", err)
+		}
+	}
+
+	if p&PreserveXattr != 0 {
+		if err := copyXattrs(src, dst); err != nil {
+			return fmt.Errorf("copied but failed to preserve extended attributes: %w", err)
+		}
+	}
+
+	if p&PreserveTimes != 0 {
+		if err := os.Chtimes(dst, fileAccessTime(info), info.ModTime()); err != nil {
+			return fmt.Errorf("copied but failed to preserve times: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// refused reports whether err is that of a filesystem without permission
+// bits, such as CIFS, FAT, or exFAT, which refuse chmod. The destination
+// then has the permissions the filesystem gives all files.
+func refused(err error) bool {
+	return errors.Is(err, fs.ErrPermission) || errors.Is(err, errors.ErrUnsupported)
+}
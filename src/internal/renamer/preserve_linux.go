@@ -0,0 +1,72 @@
+package renamer
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+// fileAccessTime returns the last access time of a file
+func fileAccessTime(info os.FileInfo) time.Time {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(st.Atim.Unix())
+	}
+	return info.ModTime()
+}
+
+// copyOwner sets the owner of dst to that of info. Only root may give files
+// away, so this is a no-op for other users.
+func copyOwner(dst string, info os.FileInfo) error {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || os.Geteuid() != 0 {
+		return nil
+	}
+	return os.Lchown(dst, int(st.Uid), int(st.Gid))
+}
+
+// copyXattrs copies the extended attributes of src to dst. Filesystems
+// without xattr support are silently skipped.
+func copyXattrs(src, dst string) error {
+	size, err := syscall.Listxattr(src, nil)
+	if err != nil || size == 0 {
+		return ignoreUnsupported(err)
+	}
+	buf := make([]byte, size)
+	size, err = syscall.Listxattr(src, buf)
+	if err != nil {
+		return ignoreUnsupported(err)
+	}
+
+	for _, name := range bytes.Split(buf[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		attr := string(name)
+		n, err := syscall.Getxattr(src, attr, nil)
+		if err != nil {
+			return err
+		}
+		value := make([]byte, n)
+		if n, err = syscall.Getxattr(src, attr, value); err != nil {
+			return err
+		}
+		if err := syscall.Setxattr(dst, attr, value[:n], 0); err != nil {
+			// Attributes in the security and trusted namespaces need privileges
+			if errors.Is(err, syscall.EPERM) {
+				continue
+			}
+			return ignoreUnsupported(err)
+		}
+	}
+	return nil
+}
+
+// ignoreUnsupported drops errors caused by a filesystem not supporting xattrs
+func ignoreUnsupported(err error) error {
+	if errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.EOPNOTSUPP) {
+		return nil
+	}
+	return err
+}
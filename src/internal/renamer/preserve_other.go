@@ -0,0 +1,30 @@
+//go:build !linux && !windows
+
+package renamer
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// fileAccessTime returns the modification time, as the access time field
+// differs between the remaining platforms
+func fileAccessTime(info os.FileInfo) time.Time {
+	return info.ModTime()
+}
+
+// copyOwner sets the owner of dst to that of info. Only root may give files
+// away, so this is a no-op for other users.
+func copyOwner(dst string, info os.FileInfo) error {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || os.Geteuid() != 0 {
+		return nil
+	}
+	return os.Lchown(dst, int(st.Uid), int(st.Gid))
+}
+
+// copyXattrs is a no-op on this platform
+func copyXattrs(src, dst string) error {
+	return nil
+}
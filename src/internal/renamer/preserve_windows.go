@@ -0,0 +1,96 @@
+package renamer
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// fileAccessTime returns the last access time of a file
+func fileAccessTime(info os.FileInfo) time.Time {
+	if data, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		return time.Unix(0, data.LastAccessTime.Nanoseconds())
+	}
+	return info.ModTime()
+}
+
+// copyOwner is a no-op on Windows, where ownership follows the destination's ACLs
+func copyOwner(dst string, info os.FileInfo) error {
+	return nil
+}
+
+var (
+	procFindFirstStreamW = syscall.NewLazyDLL("kernel32.dll").NewProc("FindFirstStreamW")
+	procFindNextStreamW  = syscall.NewLazyDLL("kernel32.dll").NewProc("FindNextStreamW")
+)
+
+// Errors of FindFirstStreamW and FindNextStreamW
+const (
+	errorInvalidFunction syscall.Errno = 1  // The filesystem has no streams
+	errorHandleEOF       syscall.Errno = 38 // No more streams
+	errorNotSupported    syscall.Errno = 50
+)
+
+// win32FindStreamData is WIN32_FIND_STREAM_DATA
+type win32FindStreamData struct {
+	StreamSize int64
+	StreamName [syscall.MAX_PATH + 36]uint16
+}
+
+// copyXattrs copies the alternate data streams of src to dst, such as the
+// Zone.Identifier of downloaded files. Filesystems without streams, such
+// as FAT, are silently skipped.
+func copyXattrs(src, dst string) error {
+	path, err := syscall.UTF16PtrFromString(src)
+	if err != nil {
+		return err
+	}
+	var data win32FindStreamData
+	h, _, err := procFindFirstStreamW.Call(uintptr(unsafe.Pointer(path)), 0, uintptr(unsafe.Pointer(&data)), 0)
+	if syscall.Handle(h) == syscall.InvalidHandle {
+		return ignoreNoStreams(err)
+	}
+	defer syscall.FindClose(syscall.Handle(h))
+
+	for {
+		// Names are ":name:$DATA"; the unnamed stream "::$DATA" is the file
+		if name := syscall.UTF16ToString(data.StreamName[:]); name != "::$DATA" {
+			if err := copyStream(src+name, dst+name); err != nil {
+				return err
+			}
+		}
+		if ok, _, err := procFindNextStreamW.Call(h, uintptr(unsafe.Pointer(&data))); ok == 0 {
+			return ignoreNoStreams(err)
+		}
+	}
+}
+
+// copyStream copies the alternate data stream src to dst
+func copyStream(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// ignoreNoStreams drops the errors for the end of the streams, or for a
+// filesystem that has none
+func ignoreNoStreams(err error) error {
+	switch err {
+	case syscall.Errno(0), errorHandleEOF, errorInvalidFunction, errorNotSupported:
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,64 @@
+package renamer
+
+import (
+	"sort"
+	"strings"
+)
+
+// Preset is a named pair of formats following a media server's naming conventions
+type Preset struct {
+	Name        string
+	Description string
+	TVFormat    string
+	MovieFormat string
+}
+
+// presets holds the built-in naming presets, keyed by lowercase name
+var presets = map[string]Preset{
+	"plex": {
+		Name:        "plex",
+		Description: "Plex naming guidelines",
+		TVFormat:    "{show} ({year}){id:braces:space}/{season_folder}/{show} ({year}) - s{snum}e{enum} - {title}{ext}",
+		MovieFormat: "{title} ({year}){id:braces:space}/{title} ({year}){ext}",
+	},
+	"jellyfin": {
+		Name:        "jellyfin",
+		Description: "Jellyfin naming guidelines",
+		TVFormat:    "{show} ({year}){id:bracket:space}/{season_folder}/{show} S{snum}E{enum} - {title}{ext}",
+		MovieFormat: "{title} ({year}){id:bracket:space}/{title} ({year}){ext}",
+	},
+	"emby": {
+		Name:        "emby",
+		Description: "Emby naming guidelines",
+		TVFormat:    "{show} ({year}){id:bracket:space}/{season_folder}/{show} - S{snum}E{enum} - {title}{ext}",
+		MovieFormat: "{title} ({year}){id:bracket:space}/{title} ({year}){ext}",
+	},
+	"trash": {
+		Name:        "trash",
+		Description: "TRaSH guides naming for Plex, as Sonarr and Radarr users set it up",
+		TVFormat:    "{show} ({year}){id:braces:space}/Season {snum}/{show} ({year}) - S{snum}E{enum} - {title} {quality:bracket}{hdr:bracket}{audio:bracket}{vcodec:bracket}{group:dash}{ext}",
+		MovieFormat: "{title} ({year}){imdb_id:braces:space|{id}}/{title} ({year}) {quality:bracket}{hdr:bracket}{audio:bracket}{vcodec:bracket}{group:dash}{ext}",
+	},
+	"kodi": {
+		Name:        "kodi",
+		Description: "Kodi naming guidelines",
+		TVFormat:    "{show}/{season_folder}/{show} S{snum}E{enum}{ext}",
+		MovieFormat: "{title} ({year})/{title} ({year}){ext}",
+	},
+}
+
+// LookupPreset returns the built-in preset with the given name (case-insensitive)
+func LookupPreset(name string) (Preset, bool) {
+	p, ok := presets[strings.ToLower(strings.TrimSpace(name))]
+	return p, ok
+}
+
+// PresetNames returns the names of all built-in presets in sorted order
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,41 @@
+//go:build linux
+
+package renamer
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// ioprio_set arguments for the lowest best-effort disk priority, which still
+// gets a share of a busy disk, unlike the idle class
+const (
+	ioprioWhoProcess = 1
+	ioprioLowest     = 2<<13 | 7
+)
+
+// LowerPriority gives the process the lowest CPU priority (nice 19) and the
+// lowest best-effort disk priority, so a long run doesn't slow down Plex.
+// Linux sets both per thread, so every thread of the process is lowered;
+// threads started later inherit it.
+func LowerPriority() error {
+	tasks, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return fmt.Errorf("failed to lower priority: %w", err)
+	}
+	for _, task := range tasks {
+		tid, err := strconv.Atoi(task.Name())
+		if err != nil {
+			continue
+		}
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, 19); err != nil {
+			return fmt.Errorf("failed to lower CPU priority: %w", err)
+		}
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), ioprioLowest); errno != 0 {
+			return fmt.Errorf("failed to lower disk priority: %w", errno)
+		}
+	}
+	return nil
+}
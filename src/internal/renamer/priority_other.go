@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package renamer
+
+import "fmt"
+
+// LowerPriority is not supported on the remaining platforms
+func LowerPriority() error {
+	return fmt.Errorf("failed to lower priority: not supported on this platform")
+}
@@ -0,0 +1,17 @@
+//go:build darwin || freebsd
+
+package renamer
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// LowerPriority gives the process the lowest CPU priority (nice 19), so a
+// long run doesn't slow down Plex. Disk priority is left to the OS.
+func LowerPriority() error {
+	if err := syscall.Setpriority(syscall.PRIO_PROCESS, 0, 19); err != nil {
+		return fmt.Errorf("failed to lower CPU priority: %w", err)
+	}
+	return nil
+}
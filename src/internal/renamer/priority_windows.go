@@ -0,0 +1,27 @@
+//go:build windows
+
+package renamer
+
+import (
+	"fmt"
+	"syscall"
+)
+
+var procSetPriorityClass = syscall.NewLazyDLL("kernel32.dll").NewProc("SetPriorityClass")
+
+// processModeBackgroundBegin lowers the CPU, disk, and memory priority of the
+// current process
+const processModeBackgroundBegin = 0x00100000
+
+// LowerPriority puts the process in background mode, lowering its CPU and
+// disk priority so a long run doesn't slow down Plex
+func LowerPriority() error {
+	process, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return fmt.Errorf("failed to lower priority: %w", err)
+	}
+	if ok, _, callErr := procSetPriorityClass.Call(uintptr(process), processModeBackgroundBegin); ok == 0 {
+		return fmt.Errorf("failed to lower priority: %w", callErr)
+	}
+	return nil
+}
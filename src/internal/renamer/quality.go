@@ -0,0 +1,55 @@
+package renamer
+
+import (
+	"os"
+
+	"plexrenamer/internal/database"
+)
+
+// Quality describes a version of a file for telling which of two is better.
+// Zero fields are unknown.
+type Quality struct {
+	Resolution int   // Vertical resolution class, see Resolution
+	Bitrate    int   // Overall bitrate in bit/s
+	Size       int64 // Bytes
+}
+
+// FileQuality returns the quality of a file as Plex recorded it
+func FileQuality(file database.MediaPart) Quality {
+	return Quality{
+		Resolution: Resolution(file.Width, file.Height),
+		Bitrate:    file.Bitrate,
+		Size:       file.Size,
+	}
+}
+
+// Better reports whether q is better than o: a higher resolution, or at the
+// same resolution a higher bitrate, or at the same bitrate a larger size.
+// Only what is known of both is compared, and equal files aren't better.
+func (q Quality) Better(o Quality) bool {
+	if q.Resolution > 0 && o.Resolution > 0 && q.Resolution != o.Resolution {
+		return q.Resolution > o.Resolution
+	}
+	if q.Bitrate > 0 && o.Bitrate > 0 && q.Bitrate != o.Bitrate {
+		return q.Bitrate > o.Bitrate
+	}
+	return q.Size > 0 && o.Size > 0 && q.Size > o.Size
+}
+
+// replacesExisting reports whether the source of op is better than the
+// existing destination, whose size is taken from destInfo if Plex doesn't
+// know the file. An empty destination, e.g. left by a failed copy, is
+// always replaced.
+func (op *Operation) replacesExisting(destInfo os.FileInfo) bool {
+	if destInfo.Size() == 0 {
+		return true
+	}
+	src, existing := op.Quality, op.Existing
+	if src.Size == 0 {
+		src.Size = op.Size
+	}
+	if existing.Size == 0 {
+		existing.Size = destInfo.Size()
+	}
+	return src.Better(existing)
+}
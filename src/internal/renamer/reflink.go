@@ -0,0 +1,77 @@
+package renamer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ReflinkMode controls whether copies are made as copy-on-write clones
+type ReflinkMode string
+
+const (
+	ReflinkAuto   ReflinkMode = "auto"   // Clone when the filesystem supports it, otherwise copy
+	ReflinkAlways ReflinkMode = "always" // Clone, failing the operation if not supported
+	ReflinkNever  ReflinkMode = "never"  // Always copy the data
+)
+
+// copyChunk is how much data is copied between cancellation checks
+const copyChunk = 32 << 20
+
+// errReflinkUnsupported is returned by cloneFile on platforms without clone support
+var errReflinkUnsupported = errors.New("not supported on this platform")
+
+// ParseReflinkMode parses a --reflink value
+func ParseReflinkMode(s string) (ReflinkMode, error) {
+	switch m := ReflinkMode(s); m {
+	case ReflinkAuto, ReflinkAlways, ReflinkNever:
+		return m, nil
+	case "":
+		return ReflinkAuto, nil
+	}
+	return "", fmt.Errorf("invalid reflink mode: %s (use auto, always, or never)", s)
+}
+
+// copyContents writes the data of src to dst. On filesystems with
+// copy-on-write support (btrfs, XFS) the destination shares the source's
+// blocks, which is instant regardless of file size.
+func copyContents(ctx context.Context, dst, src *os.File, mode ReflinkMode) error {
+	if mode != ReflinkNever {
+		err := cloneFile(dst, src)
+		if err == nil {
+			return nil
+		}
+		if mode == ReflinkAlways {
+			return fmt.Errorf("reflink failed: %w", err)
+		}
+	}
+	return copyData(ctx, dst, src)
+}
+
+// copyRange copies n bytes from src to dst, or everything up to EOF if n is
+// negative, checking ctx between chunks. Chunks are copied with io.CopyN,
+// which still lets the kernel copy the data directly where possible.
+func copyRange(ctx context.Context, dst, src *os.File, n int64) error {
+	for n != 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		chunk := int64(copyChunk)
+		if n > 0 && n < chunk {
+			chunk = n
+		}
+		written, err := io.CopyN(dst, src, chunk)
+		if err == io.EOF && n < 0 {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			n -= written
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package renamer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"syscall"
+)
+
+const (
+	ficlone  = 0x40049409 // FICLONE ioctl, from linux/fs.h
+	seekData = 3          // SEEK_DATA
+	seekHole = 4          // SEEK_HOLE
+)
+
+// cloneFile makes dst a copy-on-write clone of src with the FICLONE ioctl
+func cloneFile(dst, src *os.File) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dst.Fd(), ficlone, src.Fd())
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// copyData copies src to dst. Sparse files are copied one data region at a
+// time so holes stay holes. Otherwise the file is copied in chunks through
+// copy_file_range, which lets the kernel copy server-side where possible.
+func copyData(ctx context.Context, dst, src *os.File) error {
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || st.Blocks*512 >= info.Size() {
+		return copyRange(ctx, dst, src, -1)
+	}
+
+	size := info.Size()
+	var offset int64
+	for offset < size {
+		start, err := src.Seek(offset, seekData)
+		if err != nil {
+			// ENXIO: no data after offset, the rest of the file is a hole
+			if errors.Is(err, syscall.ENXIO) {
+				break
+			}
+			return err
+		}
+		end, err := src.Seek(start, seekHole)
+		if err != nil {
+			return err
+		}
+		if _, err := src.Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := dst.Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+		if err := copyRange(ctx, dst, src, end-start); err != nil {
+			return err
+		}
+		offset = end
+	}
+
+	// Extend the file over a trailing hole
+	return dst.Truncate(size)
+}
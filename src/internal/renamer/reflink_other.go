@@ -0,0 +1,18 @@
+//go:build !linux
+
+package renamer
+
+import (
+	"context"
+	"os"
+)
+
+// cloneFile is not supported on this platform
+func cloneFile(dst, src *os.File) error {
+	return errReflinkUnsupported
+}
+
+// copyData copies src to dst
+func copyData(ctx context.Context, dst, src *os.File) error {
+	return copyRange(ctx, dst, src, -1)
+}
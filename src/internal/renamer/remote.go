@@ -0,0 +1,174 @@
+package renamer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	// remoteSkipped is the exit status of the remote command when the
+	// destination already exists
+	remoteSkipped = 3
+
+	// sshConnectionFailed is the exit status of ssh when the connection fails
+	sshConnectionFailed = 255
+)
+
+// Remote performs operations on another host by running cp/mv over SSH. The
+// system ssh client is used, so ~/.ssh/config, keys and agents all apply.
+type Remote struct {
+	Host        string // SSH destination, e.g. user@nas
+	controlPath string // Shared connection socket (empty if unsupported)
+	LowPriority bool   // Run cp and mv with the lowest CPU and disk priority
+}
+
+// NewRemote returns a Remote for host. Where OpenSSH supports it, all
+// operations share one connection instead of reconnecting for each file.
+func NewRemote(host string) *Remote {
+	r := &Remote{Host: host}
+	if runtime.GOOS != "windows" {
+		r.controlPath = filepath.Join(os.TempDir(), fmt.Sprintf("plexrenamer-ssh-%d-%%C", os.Getpid()))
+	}
+	return r
+}
+
+// Check verifies that the host can be reached and runs a POSIX shell
+func (r *Remote) Check() error {
+	if out, err := r.run(context.Background(), "true"); err != nil {
+		return fmt.Errorf("cannot connect to %s: %s", r.Host, remoteError(out, err))
+	}
+	return nil
+}
+
+// Execute performs op on the remote host. Cancelling ctx disconnects the
+// command, although the remote cp or mv may still run to completion.
+func (r *Remote) Execute(ctx context.Context, op Operation, opts ExecOptions) Result {
+	result := Result{Operation: op}
+
+	if opts.DryRun {
+		result.Success = true
+		result.Message = "dry run - no changes made"
+		return result
+	}
+
+	var command string
+	switch op.Mode {
+	case ModeCopy:
+		command = "cp"
+		if opts.Preserve&(PreserveTimes|PreserveOwner) != 0 {
+			command = "cp -p"
+		}
+	case ModeMove:
+		command = "mv"
+	default:
+		result.Error = fmt.Errorf("unknown operation mode: %s", op.Mode)
+		return result
+	}
+
+	var low string
+	if r.LowPriority {
+		// ionice only exists on Linux, so it is used where the host has it
+		low = `low="nice -n 19"; command -v ionice >/dev/null 2>&1 && low="ionice -c 2 -n 7 $low"` + "\n"
+		command = "$low " + command
+	}
+
+	// The file is written to its partial file, which is renamed into place
+	// once it is complete and flushed, as for local operations. A failed copy
+	// removes it; a failed move leaves it, as it may hold the only copy.
+	script := low + fmt.Sprintf(`src=%s; dst=%s; part="$dst"%s
+[ -e "$src" ] || { echo "source file does not exist: $src" >&2; exit 4; }
+[ -e "$dst" ] && exit %d
+mkdir -p -- "$(dirname -- "$dst")" && %s -- "$src" "$part"`,
+		shQuote(op.Source), shQuote(op.Destination), shQuote(PartialSuffix), remoteSkipped, command)
+	// A time that can't be set only warns, as for local operations
+	var touch string
+	switch {
+	case opts.SetMtime == MtimeKeep:
+	case opts.SetMtime == MtimeAirdate && !op.Aired.IsZero():
+		// The air date is midnight in local time, which the host's touch -t takes
+		touch = `touch -m -t ` + op.Aired.Format("200601021504.05") + ` -- "$part"`
+	case op.Mode == ModeCopy:
+		// A move keeps the time of its source
+		touch = `touch -m -r "$src" -- "$part"`
+	}
+	if touch != "" {
+		script += ` && { ` + touch + ` 2>/dev/null || echo "failed to set the modification time" >&2; }`
+	}
+	// sync only takes files with GNU coreutils 8.24 or later
+	script += ` && { sync -- "$part" 2>/dev/null || sync; } && mv -f -- "$part" "$dst"`
+	if opts.Fsync {
+		script += ` && { sync -- "$(dirname -- "$dst")" "$(dirname -- "$src")" 2>/dev/null || sync; }`
+	}
+	if op.Mode == ModeCopy {
+		script = "{ " + script + `; } || { rc=$?; rm -f -- "$part"; exit $rc; }`
+	}
+
+	out, err := r.run(ctx, "sh -c "+shQuote(script))
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		result.Success = true
+		// A remote mv doesn't tell whether it renamed or copied the file
+		result.Copied = op.Mode == ModeCopy
+		result.Message = fmt.Sprintf("%s completed on %s", op.Mode, r.Host)
+		if warning := strings.TrimSpace(string(out)); warning != "" {
+			result.Warning = warning
+			result.Message += ", but " + warning
+		}
+	case ctx.Err() != nil:
+		result.Error = ctx.Err()
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == remoteSkipped:
+		result.Success = true
+		result.Skipped = true
+		result.Reason = SkipExists
+		result.Message = "destination already exists, skipped"
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == sshConnectionFailed:
+		result.Error = &transientError{fmt.Errorf("remote %s failed: %s", op.Mode, remoteError(out, err))}
+	default:
+		result.Error = fmt.Errorf("remote %s failed: %s", op.Mode, remoteError(out, err))
+	}
+	return result
+}
+
+// Close shuts down the shared SSH connection, if any
+func (r *Remote) Close() error {
+	if r.controlPath == "" {
+		return nil
+	}
+	return exec.Command("ssh", "-o", "ControlPath="+r.controlPath, "-O", "exit", r.Host).Run()
+}
+
+// run executes command on the remote host and returns its stderr
+func (r *Remote) run(ctx context.Context, command string) ([]byte, error) {
+	args := []string{"-o", "BatchMode=yes"}
+	if r.controlPath != "" {
+		args = append(args, "-o", "ControlMaster=auto", "-o", "ControlPath="+r.controlPath, "-o", "ControlPersist=60")
+	}
+	args = append(args, r.Host, command)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ssh", args...)
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	return stderr.Bytes(), err
+}
+
+// remoteError describes a failed remote command, preferring its stderr output
+func remoteError(stderr []byte, err error) string {
+	if msg := strings.TrimSpace(string(stderr)); msg != "" {
+		return msg
+	}
+	return err.Error()
+}
+
+// shQuote quotes s as a single POSIX shell word
+func shQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
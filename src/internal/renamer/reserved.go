@@ -0,0 +1,54 @@
+package renamer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultReservedSuffix is appended to names Windows reserves
+const DefaultReservedSuffix = "_"
+
+// reservedNames are the device names Windows reserves: a file or folder
+// can't be called one of them, with or without an extension
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+	"COM¹": true, "COM²": true, "COM³": true, "LPT¹": true, "LPT²": true, "LPT³": true,
+}
+
+// ValidateReservedSuffix checks that suffix can be part of a file name
+func ValidateReservedSuffix(suffix string) error {
+	if sanitizeFilename(suffix) != suffix || strings.Contains(suffix, ".") {
+		return fmt.Errorf("invalid reserved-name suffix %q: it can't contain dots or characters invalid in file names", suffix)
+	}
+	return nil
+}
+
+// protectReserved appends suffix to each folder or file of path whose name
+// Windows reserves, before its extension, e.g. CON/NUL.mkv becomes
+// CON_/NUL_.mkv. Names are reserved whatever their case and trailing
+// spaces. An empty suffix leaves path as it is.
+func protectReserved(path, suffix string) string {
+	if suffix == "" {
+		return path
+	}
+	var b strings.Builder
+	start := 0
+	for i := 0; i <= len(path); i++ {
+		if i < len(path) && path[i] != '/' && path[i] != '\\' {
+			continue
+		}
+		segment := path[start:i]
+		stem, _, _ := strings.Cut(segment, ".")
+		if reservedNames[strings.ToUpper(strings.TrimRight(stem, " "))] {
+			segment = strings.TrimRight(stem, " ") + suffix + segment[len(stem):]
+		}
+		b.WriteString(segment)
+		if i < len(path) {
+			b.WriteByte(path[i])
+		}
+		start = i + 1
+	}
+	return b.String()
+}
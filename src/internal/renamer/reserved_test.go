@@ -0,0 +1,61 @@
+package renamer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestProtectReserved(t *testing.T) {
+	type test struct {
+		path, want string
+	}
+	var tests []test
+
+	// Every reserved name, with and without an extension, in any case
+	names := []string{"CON", "PRN", "AUX", "NUL"}
+	for i := 1; i <= 9; i++ {
+		names = append(names, fmt.Sprintf("COM%d", i), fmt.Sprintf("LPT%d", i))
+	}
+	names = append(names, "COM¹", "COM²", "COM³", "LPT¹", "LPT²", "LPT³")
+	for _, name := range names {
+		for _, cased := range []string{name, strings.ToLower(name), strings.ToUpper(name[:1]) + strings.ToLower(name[1:])} {
+			tests = append(tests,
+				test{cased, cased + "_"},
+				test{cased + ".mkv", cased + "_.mkv"},
+				test{cased + ".en.srt", cased + "_.en.srt"},
+				test{"Show/" + cased + "/" + cased + ".mkv", "Show/" + cased + "_/" + cased + "_.mkv"},
+			)
+		}
+	}
+
+	tests = append(tests,
+		// Trailing spaces are ignored by Windows, and dropped with the suffix
+		test{"CON .mkv", "CON_.mkv"},
+		test{"nul  ", "nul_"},
+		// Backslashes separate folders too
+		test{`Movies\AUX\aux.mkv`, `Movies\AUX_\aux_.mkv`},
+		// Names that only look like reserved ones are left as they are
+		test{"CONSOLE.mkv", "CONSOLE.mkv"},
+		test{"COM0.mkv", "COM0.mkv"},
+		test{"COM10.mkv", "COM10.mkv"},
+		test{"LPT.mkv", "LPT.mkv"},
+		test{"Con Air (1997)/Con Air (1997).mkv", "Con Air (1997)/Con Air (1997).mkv"},
+		test{"Show/Season 01/NUL - S01E01.mkv", "Show/Season 01/NUL - S01E01.mkv"},
+		test{"", ""},
+	)
+
+	for _, tt := range tests {
+		if got := protectReserved(tt.path, DefaultReservedSuffix); got != tt.want {
+			t.Errorf("protectReserved(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestProtectReservedWithoutSuffix(t *testing.T) {
+	for _, path := range []string{"CON.mkv", "Show/nul/LPT1.srt"} {
+		if got := protectReserved(path, ""); got != path {
+			t.Errorf("protectReserved(%q, \"\") = %q, want it unchanged", path, got)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package renamer
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"time"
+)
+
+// transientError marks an error as worth retrying when the underlying cause
+// isn't a system error, e.g. a dropped SSH connection
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string { return e.err.Error() }
+func (e *transientError) Unwrap() error { return e.err }
+
+// IsRetryable reports whether err is likely to go away on its own, such as a
+// busy file or a network share that briefly dropped. Missing files,
+// permission problems and cancellation are permanent.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var t *transientError
+	if errors.As(err, &t) {
+		return true
+	}
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		for _, e := range retryableErrnos {
+			if errno == e {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// executeWithRetry runs attempt until it succeeds, fails permanently, or
+// opts.Retries retries have been made, doubling the wait after each failure
+func executeWithRetry(ctx context.Context, opts ExecOptions, attempt func() Result) Result {
+	wait := opts.RetryWait
+	for n := 1; ; n++ {
+		result := attempt()
+		result.Attempts = n
+		if result.Error == nil || n > opts.Retries || !IsRetryable(result.Error) {
+			return result
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return result
+		case <-timer.C:
+		}
+		wait *= 2
+	}
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+
+package renamer
+
+import "syscall"
+
+// retryableErrnos are the system errors that IsRetryable treats as transient
+var retryableErrnos = []syscall.Errno{
+	syscall.EAGAIN,
+	syscall.EBUSY,
+	syscall.EINTR,
+	syscall.EIO,
+	syscall.ESTALE,
+	syscall.ETIMEDOUT,
+	syscall.ECONNRESET,
+	syscall.ECONNABORTED,
+	syscall.EHOSTDOWN,
+	syscall.EHOSTUNREACH,
+	syscall.ENETDOWN,
+	syscall.ENETRESET,
+	syscall.ENETUNREACH,
+}
+
+// lockedErrnos are the system errors that IsLocked reports as a file in use
+var lockedErrnos = []syscall.Errno{
+	syscall.EBUSY,
+	syscall.ETXTBSY,
+}
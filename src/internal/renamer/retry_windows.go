@@ -0,0 +1,23 @@
+//go:build windows
+
+package renamer
+
+import "syscall"
+
+// retryableErrnos are the system errors that IsRetryable treats as transient
+var retryableErrnos = []syscall.Errno{
+	32,   // ERROR_SHARING_VIOLATION
+	33,   // ERROR_LOCK_VIOLATION
+	51,   // ERROR_REM_NOT_LIST
+	53,   // ERROR_BAD_NETPATH
+	59,   // ERROR_UNEXP_NET_ERR
+	64,   // ERROR_NETNAME_DELETED
+	121,  // ERROR_SEM_TIMEOUT
+	1231, // ERROR_NETWORK_UNREACHABLE
+}
+
+// lockedErrnos are the system errors that IsLocked reports as a file in use
+var lockedErrnos = []syscall.Errno{
+	32, // ERROR_SHARING_VIOLATION
+	33, // ERROR_LOCK_VIOLATION
+}
@@ -0,0 +1,205 @@
+package renamer
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"plexrenamer/internal/database"
+)
+
+// DefaultSubtitleFormat names subtitles after their video, keeping their
+// language and flags the way Plex recognizes them, e.g.
+// Movie (1999).en.sdh.forced.srt
+const DefaultSubtitleFormat = "{base}{lang:dot}{sdh:dot}{forced:dot}{ext}"
+
+// DefaultSidecarFormat names other sidecars after their video, keeping what
+// followed the video's name, e.g. Movie (1999)-poster.jpg
+const DefaultSidecarFormat = "{base}{suffix}{ext}"
+
+// SubtitleTokens are the placeholders available in subtitle formats
+var SubtitleTokens = []string{"base", "lang", "forced", "sdh", "ext"}
+
+// SidecarTokens are the placeholders available in formats of other sidecars
+var SidecarTokens = []string{"base", "suffix", "ext"}
+
+// subtitleExts and sidecarExts are the extensions of the files that go
+// with a video of the same name
+var (
+	subtitleExts = map[string]bool{".srt": true, ".ass": true, ".ssa": true, ".sub": true, ".idx": true, ".vtt": true, ".smi": true, ".sup": true}
+	sidecarExts  = map[string]bool{".nfo": true, ".jpg": true, ".jpeg": true, ".png": true, ".tbn": true}
+)
+
+// Sidecar is a file that goes with a video, such as its subtitles or .nfo,
+// named after it
+type Sidecar struct {
+	Path     string
+	Size     int64
+	Subtitle bool
+	Language string // Of subtitles, e.g. en or eng, if the name has one
+	Forced   bool   // Subtitles only for foreign dialogue
+	SDH      bool   // Subtitles for the deaf and hard of hearing
+	Suffix   string // What follows the video's name before the extension, e.g. .en or -poster
+}
+
+// FindSidecars returns the subtitles next to video whose name is the
+// video's name followed by a dot, e.g. Movie.en.srt for Movie.mkv, and the
+// other sidecars whose name is followed by a dot or dash, e.g.
+// Movie-poster.jpg. Files that go with another video whose name starts
+// with this one's, such as Movie.Part.2.en.srt for Movie.Part.2.mkv, are
+// left to it. The language and flags of subtitles come from known, Plex's
+// external subtitles by local path, and otherwise from their names.
+func FindSidecars(video string, known map[string]database.Subtitle) ([]Sidecar, error) {
+	entries, err := os.ReadDir(filepath.Dir(video))
+	if err != nil {
+		return nil, err
+	}
+	base := strings.TrimSuffix(filepath.Base(video), filepath.Ext(video))
+
+	// The names of the other videos that start with this one's
+	var longer []string
+	for _, entry := range entries {
+		name := entry.Name()
+		stem := strings.TrimSuffix(name, filepath.Ext(name))
+		if len(stem) > len(base) && strings.HasPrefix(stem, base) && videoExtensions[strings.ToLower(filepath.Ext(name))] {
+			longer = append(longer, stem)
+		}
+	}
+
+	var sidecars []Sidecar
+	for _, entry := range entries {
+		name := entry.Name()
+		rest, ok := strings.CutPrefix(name, base)
+		if !ok || rest == "" || (rest[0] != '.' && rest[0] != '-') || !entry.Type().IsRegular() || belongsTo(name, longer) {
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(name))
+		sc := Sidecar{
+			Path:     filepath.Join(filepath.Dir(video), name),
+			Subtitle: subtitleExts[ext],
+			Suffix:   strings.TrimSuffix(rest, filepath.Ext(name)),
+		}
+		if (!sc.Subtitle && !sidecarExts[ext]) || (sc.Subtitle && rest[0] != '.') {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			sc.Size = info.Size()
+		}
+		if sc.Subtitle {
+			sc.Language, sc.Forced, sc.SDH = subtitleTags(sc.Suffix)
+			if sub, ok := known[filepath.Clean(sc.Path)]; ok {
+				sc.Forced, sc.SDH = sub.Forced, sub.SDH
+				if sub.Language != "" {
+					sc.Language = sub.Language
+				}
+			}
+		}
+		sidecars = append(sidecars, sc)
+	}
+	return sidecars, nil
+}
+
+// belongsTo reports whether name is a sidecar of one of the videos named
+// stems: it starts with one of them, followed by a dot or dash
+func belongsTo(name string, stems []string) bool {
+	for _, stem := range stems {
+		if rest, ok := strings.CutPrefix(name, stem); ok && rest != "" && (rest[0] == '.' || rest[0] == '-') {
+			return true
+		}
+	}
+	return false
+}
+
+// subtitleTags reads the language and flags from what follows a video's
+// name in the name of its subtitles, e.g. .en.forced
+func subtitleTags(suffix string) (language string, forced, sdh bool) {
+	for _, part := range strings.FieldsFunc(suffix, func(r rune) bool { return r == '.' || r == '-' }) {
+		switch part = strings.ToLower(part); {
+		case part == "forced":
+			forced = true
+		case part == "sdh" || part == "cc":
+			sdh = true
+		case language == "" && isLanguageCode(part):
+			language = part
+		}
+	}
+	return language, forced, sdh
+}
+
+// isLanguageCode reports whether s looks like an ISO 639 language code
+// (two or three letters), as opposed to a flag such as sdh or cc
+func isLanguageCode(s string) bool {
+	if len(s) < 2 || len(s) > 3 || s == "sdh" || s == "cc" {
+		return false
+	}
+	for _, r := range s {
+		if r < 'a' || r > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatSidecar returns where a sidecar goes when its video goes to
+// videoDest: next to it, named with the subtitle or sidecar format
+func (f *Formatter) FormatSidecar(sc Sidecar, videoDest string) string {
+	base := strings.TrimSuffix(filepath.Base(videoDest), filepath.Ext(videoDest))
+	ext := filepath.Ext(sc.Path)
+	var name string
+	if sc.Subtitle {
+		forced, sdh := "", ""
+		if sc.Forced {
+			forced = "forced"
+		}
+		if sc.SDH {
+			sdh = "sdh"
+		}
+		name = expandFormat(f.SubFormat, map[string]string{
+			"base":   base,
+			"lang":   sc.Language,
+			"forced": forced,
+			"sdh":    sdh,
+			"ext":    ext,
+		}, nil)
+	} else {
+		name = expandFormat(f.SideFormat, map[string]string{
+			"base":   base,
+			"suffix": sc.Suffix,
+			"ext":    ext,
+		}, nil)
+	}
+	return filepath.Join(filepath.Dir(videoDest), protectReserved(name, f.Reserved))
+}
+
+// FormatSidecars returns where each of the sidecars of a video goes when the
+// video goes to videoDest, as FormatSidecar does. Sidecars that would get
+// the same name, such as two subtitles without a language, are numbered
+// before their extension, e.g. Movie (1999).2.srt.
+func (f *Formatter) FormatSidecars(sidecars []Sidecar, videoDest string) []string {
+	destinations := make([]string, len(sidecars))
+	used := map[string]bool{}
+	for i, sc := range sidecars {
+		name := f.FormatSidecar(sc, videoDest)
+		ext := filepath.Ext(name)
+		dest := name
+		for n := 2; used[strings.ToLower(dest)]; n++ {
+			dest = strings.TrimSuffix(name, ext) + "." + strconv.Itoa(n) + ext
+		}
+		used[strings.ToLower(dest)] = true
+		destinations[i] = dest
+	}
+	return destinations
+}
+
+// ValidateSubtitleFormat checks that a subtitle format only uses subtitle
+// placeholders
+func ValidateSubtitleFormat(format string) error {
+	return validateFormat(format, "subtitle", SubtitleTokens, nil)
+}
+
+// ValidateSidecarFormat checks that a sidecar format only uses sidecar
+// placeholders
+func ValidateSidecarFormat(format string) error {
+	return validateFormat(format, "sidecar", SidecarTokens, nil)
+}
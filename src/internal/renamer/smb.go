@@ -0,0 +1,210 @@
+package renamer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// SMBShare writes destinations directly to an SMB share using the smbclient
+// tool, without mounting the share. Destination paths are paths within the share.
+type SMBShare struct {
+	Server   string
+	Share    string
+	Dir      string // Directory within the share that destinations are relative to
+	User     string
+	Password string
+	Domain   string
+}
+
+// ParseSMBURL parses a URL such as smb://user@nas/media/organized. The
+// password is never taken from the URL; use the config file or environment.
+func ParseSMBURL(s string) (*SMBShare, error) {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme != "smb" || u.Host == "" {
+		return nil, fmt.Errorf("invalid SMB URL %q, use smb://server/share[/path]", s)
+	}
+	share, dir, _ := strings.Cut(strings.Trim(u.Path, "/"), "/")
+	if share == "" {
+		return nil, fmt.Errorf("invalid SMB URL %q: missing share name", s)
+	}
+	return &SMBShare{Server: u.Host, Share: share, Dir: "/" + dir, User: u.User.Username()}, nil
+}
+
+// String returns the share as a URL, without credentials
+func (s *SMBShare) String() string {
+	return "smb://" + s.Server + "/" + s.Share + strings.TrimSuffix(s.Dir, "/")
+}
+
+// Check verifies that the share can be reached with the configured credentials
+func (s *SMBShare) Check() error {
+	if out, err := s.run(context.Background(), "ls"); err != nil {
+		return fmt.Errorf("cannot connect to %s: %s", s, smbError(out, err))
+	}
+	return nil
+}
+
+// Execute uploads op.Source to op.Destination on the share. Moves delete the
+// local source after a successful upload. Cancelling ctx aborts the upload
+// and deletes the partial file from the share.
+func (s *SMBShare) Execute(ctx context.Context, op Operation, opts ExecOptions) Result {
+	result := Result{Operation: op}
+
+	if opts.DryRun {
+		result.Success = true
+		result.Message = "dry run - no changes made"
+		return result
+	}
+	if op.Mode != ModeCopy && op.Mode != ModeMove {
+		result.Error = fmt.Errorf("unknown operation mode: %s", op.Mode)
+		return result
+	}
+
+	if _, err := os.Stat(op.Source); os.IsNotExist(err) {
+		result.Error = fmt.Errorf("source file does not exist: %s", op.Source)
+		return result
+	}
+	if strings.ContainsAny(op.Source+op.Destination, "\"\n") {
+		result.Error = fmt.Errorf("paths with quotes or newlines can't be sent to smbclient")
+		return result
+	}
+
+	// Only a destination reported as missing is written; any other failure
+	// to look it up, such as a rejected login, stops the operation
+	dst := smbPath(op.Destination)
+	out, err := s.run(ctx, fmt.Sprintf(`allinfo "%s"`, dst))
+	switch {
+	case ctx.Err() != nil:
+		result.Error = ctx.Err()
+		return result
+	case err != nil && smbTransient(out):
+		result.Error = &transientError{fmt.Errorf("failed to check destination: %s", smbError(out, err))}
+		return result
+	case err != nil && !smbNotFound(out):
+		result.Error = fmt.Errorf("failed to check destination: %s", smbError(out, err))
+		return result
+	case err == nil:
+		result.Skipped = true
+		result.Reason = SkipExists
+		result.Success = true
+		result.Message = "destination already exists, skipped"
+		return result
+	}
+
+	// Create each missing parent directory; errors for existing ones are expected
+	var mkdirs []string
+	parts := strings.Split(dst, `\`)
+	for i := 1; i < len(parts)-1; i++ {
+		mkdirs = append(mkdirs, fmt.Sprintf(`mkdir "%s"`, strings.Join(parts[:i+1], `\`)))
+	}
+	if len(mkdirs) > 0 {
+		s.run(ctx, mkdirs...)
+	}
+
+	// Upload to the partial file and rename it into place once complete, so
+	// an upload cut short never looks like a finished file
+	part := dst + PartialSuffix
+	out, err = s.run(ctx, fmt.Sprintf(`put "%s" "%s"`, op.Source, part))
+	if err == nil {
+		out, err = s.run(ctx, fmt.Sprintf(`rename "%s" "%s"`, part, dst))
+	}
+	if err != nil {
+		s.run(context.Background(), fmt.Sprintf(`del "%s"`, part))
+		switch {
+		case ctx.Err() != nil:
+			result.Error = ctx.Err()
+		case smbTransient(out):
+			result.Error = &transientError{fmt.Errorf("failed to upload: %s", smbError(out, err))}
+		default:
+			result.Error = fmt.Errorf("failed to upload: %s", smbError(out, err))
+		}
+		return result
+	}
+
+	if op.Mode == ModeMove {
+		if err := os.Remove(op.Source); err != nil {
+			result.Error = fmt.Errorf("uploaded successfully but failed to remove source: %w", err)
+			return result
+		}
+	}
+
+	result.Success = true
+	result.Copied = true
+	result.Message = fmt.Sprintf("%s to %s completed", op.Mode, s)
+	return result
+}
+
+// run feeds commands to smbclient on stdin, one per line, and returns its
+// output. smbclient doesn't reliably exit non-zero when a command fails, so
+// NT_STATUS errors in the output are reported as failures too.
+func (s *SMBShare) run(ctx context.Context, commands ...string) ([]byte, error) {
+	args := []string{"//" + s.Server + "/" + s.Share}
+	if s.User != "" {
+		args = append(args, "-U", s.User)
+	} else if s.Password == "" {
+		args = append(args, "-N")
+	}
+	if s.Domain != "" {
+		args = append(args, "-W", s.Domain)
+	}
+
+	cmd := exec.CommandContext(ctx, "smbclient", args...)
+	cmd.Stdin = strings.NewReader(strings.Join(commands, "\n") + "\n")
+	// smbclient reads the password from PASSWD, keeping it off the command line
+	cmd.Env = append(os.Environ(), "PASSWD="+s.Password)
+	out, err := cmd.CombinedOutput()
+	if err == nil && bytes.Contains(out, []byte("NT_STATUS_")) {
+		err = fmt.Errorf("smbclient reported an error")
+	}
+	return out, err
+}
+
+// smbPath converts a destination path to an smbclient path
+func smbPath(path string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(toSlash(path), "/"), "/", `\`)
+}
+
+// smbTransientStatus are NT_STATUS codes for errors that may go away on retry
+var smbTransientStatus = []string{
+	"NT_STATUS_SHARING_VIOLATION",
+	"NT_STATUS_FILE_LOCK_CONFLICT",
+	"NT_STATUS_IO_TIMEOUT",
+	"NT_STATUS_CONNECTION_RESET",
+	"NT_STATUS_CONNECTION_DISCONNECTED",
+	"NT_STATUS_NETWORK_NAME_DELETED",
+	"NT_STATUS_HOST_UNREACHABLE",
+	"NT_STATUS_INSUFF_SERVER_RESOURCES",
+}
+
+// smbTransient reports whether smbclient output shows a transient error
+func smbTransient(out []byte) bool {
+	for _, status := range smbTransientStatus {
+		if bytes.Contains(out, []byte(status)) {
+			return true
+		}
+	}
+	return false
+}
+
+// smbNotFound reports whether smbclient output shows that a path doesn't exist
+func smbNotFound(out []byte) bool {
+	return bytes.Contains(out, []byte("NT_STATUS_OBJECT_NAME_NOT_FOUND")) ||
+		bytes.Contains(out, []byte("NT_STATUS_OBJECT_PATH_NOT_FOUND"))
+}
+
+// smbError describes a failed smbclient call, preferring its NT_STATUS message
+func smbError(out []byte, err error) string {
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.Contains(line, "NT_STATUS_") {
+			return strings.TrimSpace(line)
+		}
+	}
+	if msg := strings.TrimSpace(string(out)); msg != "" {
+		return msg
+	}
+	return err.Error()
+}
@@ -0,0 +1,155 @@
+package renamer
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+	"plexrenamer/internal/database"
+)
+
+// SortMode is how --sort-folders names the folders of shows and movies
+type SortMode string
+
+const (
+	SortNone  SortMode = ""      // By title: The Matrix (1999)
+	SortTitle SortMode = "sort"  // By sort title: Matrix, The (1999)
+	SortClean SortMode = "clean" // By title without its article: Matrix (1999)
+)
+
+// ParseSortMode parses a --sort-folders value
+func ParseSortMode(s string) (SortMode, error) {
+	switch m := SortMode(s); m {
+	case SortNone, SortTitle, SortClean:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid sort-folders mode: %s (use sort or clean)", s)
+}
+
+// englishArticles are the articles taken off titles that have no sort
+// title to tell which one their language has
+var englishArticles = []string{"The", "A", "An"}
+
+// splitArticle splits the leading article off an item's title: the word
+// the media server left out of its sort title, as "The" of "The Matrix"
+// sorted as "Matrix", or for items without a sort title, an English
+// article. article is "" if the title has none. Titles shortened to fit
+// the filesystem still start like their sort title, so they are split too.
+// Title and sort title are compared by foldTitle.
+func splitArticle(item *database.MetadataItem) (article, rest string) {
+	title, sort := item.Title, foldTitle(item.TitleSort)
+	if sort == "" {
+		for _, a := range englishArticles {
+			if len(title) > len(a)+1 && strings.EqualFold(title[:len(a)+1], a+" ") {
+				return title[:len(a)], title[len(a)+1:]
+			}
+		}
+		return "", title
+	}
+	if strings.HasPrefix(sort, foldTitle(title)) {
+		return "", title
+	}
+	// The article is a word of its own, or ends with an apostrophe, as L'
+	end := strings.IndexAny(title, " '’")
+	if end < 0 {
+		return "", title
+	}
+	if title[end] == ' ' {
+		article, rest = title[:end], title[end+1:]
+	} else {
+		_, size := utf8.DecodeRuneInString(title[end:])
+		article, rest = title[:end+size], title[end+size:]
+	}
+	if rest == "" || !strings.HasPrefix(sort, foldTitle(rest)) {
+		return "", title
+	}
+	return article, rest
+}
+
+// foldTitle returns s composed (NFC) and fully case folded, so that a sort
+// title matches its title in any case or Unicode form, and ß matches ss as
+// well as ẞ. A new Caser is used each time, as they can't be shared.
+func foldTitle(s string) string {
+	return cases.Fold().String(norm.NFC.String(s))
+}
+
+// sortTitle returns the title an item is sorted by: its title with the
+// article moved to the end, as "Matrix, The", a sort title of its own that
+// was set in the media server, or else its title
+func sortTitle(item *database.MetadataItem) string {
+	if article, rest := splitArticle(item); article != "" {
+		return rest + ", " + article
+	}
+	sort := item.TitleSort
+	if sort != "" && !strings.HasPrefix(foldTitle(sort), foldTitle(item.Title)) {
+		return sort
+	}
+	return item.Title
+}
+
+// titleWithoutArticle returns an item's title without its leading article
+func titleWithoutArticle(item *database.MetadataItem) string {
+	_, rest := splitArticle(item)
+	return rest
+}
+
+// letter returns the {letter} of an item, for bucket folders: the first
+// letter of its sort title, without accents, from A to Z, 0-9 if it starts
+// with a digit, or # otherwise. Punctuation before it is passed over, so
+// (500) Days of Summer is under 0-9.
+func letter(item *database.MetadataItem) string {
+	for _, r := range norm.NFD.String(sortTitle(item)) {
+		switch r = unicode.ToUpper(r); {
+		case r >= 'A' && r <= 'Z':
+			return string(r)
+		case r >= '0' && r <= '9':
+			return "0-9"
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			return "#"
+		}
+	}
+	return "#"
+}
+
+// withSortFolders returns format with the anchor placeholder ({show} or
+// {title}) in its folders replaced by its sort or clean form, e.g.
+// {title_sort}, leaving the file name as it is
+func withSortFolders(format, anchor string, mode SortMode) string {
+	if mode == SortNone {
+		return format
+	}
+	// The file name starts after the last slash outside placeholders
+	folderEnd := -1
+	inPlaceholder := map[int]bool{}
+	var anchors []int
+	placeholders(format, func(start, end int, p placeholder) {
+		for i := start; i < end; i++ {
+			inPlaceholder[i] = true
+		}
+		if p.name == anchor {
+			anchors = append(anchors, start)
+		}
+	})
+	for i := range format {
+		if format[i] == '/' && !inPlaceholder[i] {
+			folderEnd = i
+		}
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, start := range anchors {
+		if start > folderEnd {
+			break
+		}
+		nameEnd := start + 1 + len(anchor)
+		b.WriteString(format[last:nameEnd])
+		b.WriteString("_" + string(mode))
+		last = nameEnd
+	}
+	b.WriteString(format[last:])
+	return b.String()
+}
@@ -0,0 +1,35 @@
+package renamer
+
+import (
+	"testing"
+
+	"plexrenamer/internal/database"
+)
+
+func TestSortTitle(t *testing.T) {
+	tests := []struct {
+		title, sort, want, clean string
+	}{
+		{"The Matrix", "Matrix", "Matrix, The", "Matrix"},
+		{"The Thing", "", "Thing, The", "Thing"},
+		{"The Office", "The Office", "The Office", "The Office"},
+		{"Die Straße", "strasse", "Straße, Die", "Straße"},
+		{"Die Straße", "STRAẞE", "Straße, Die", "Straße"},
+		{"L’Été", "ÉTÉ", "Été, L’", "Été"},
+		// Sort title decomposed, as macOS writes names
+		{"Le Café", "Cafe\u0301", "Café, Le", "Café"},
+		{"Amélie", "Amélie", "Amélie", "Amélie"},
+		// A sort title of its own is used as it is
+		{"Star Wars", "Star Wars 4", "Star Wars", "Star Wars"},
+		{"Alien³", "Alien 3", "Alien 3", "Alien³"},
+	}
+	for _, tt := range tests {
+		item := &database.MetadataItem{Title: tt.title, TitleSort: tt.sort}
+		if got := sortTitle(item); got != tt.want {
+			t.Errorf("sortTitle(%q, %q) = %q, want %q", tt.title, tt.sort, got, tt.want)
+		}
+		if got := titleWithoutArticle(item); got != tt.clean {
+			t.Errorf("titleWithoutArticle(%q, %q) = %q, want %q", tt.title, tt.sort, got, tt.clean)
+		}
+	}
+}
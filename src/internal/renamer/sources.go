@@ -0,0 +1,99 @@
+package renamer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// sourceCheckWorkers is how many sources are checked at once. Stats on
+// network shares mostly wait for the server, so this is more than the CPUs.
+const sourceCheckWorkers = 16
+
+// CheckSources checks that the source of every operation exists and isn't
+// empty, and with containers, that it is the container its extension says,
+// several at a time. It splits the operations into those that can run and
+// results for those whose source can't be reached and those whose source
+// looks broken, all in their original order.
+func CheckSources(ctx context.Context, operations []Operation, containers bool) (ok []Operation, unreachable, suspect []Result) {
+	errs := make([]error, len(operations))
+	suspects := make([]error, len(operations))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range min(sourceCheckWorkers, len(operations)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errs[i], suspects[i] = checkSource(operations[i].Source, containers)
+			}
+		}()
+	}
+	for i := range operations {
+		if ctx.Err() != nil {
+			break
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	for i, op := range operations {
+		switch {
+		case errs[i] != nil:
+			unreachable = append(unreachable, Result{Operation: op, Error: errs[i]})
+		case suspects[i] != nil:
+			suspect = append(suspect, Result{Operation: op, Error: suspects[i]})
+		default:
+			ok = append(ok, op)
+		}
+	}
+	return ok, unreachable, suspect
+}
+
+// EmptySources splits out the operations whose source is a video of 0
+// bytes, as an interrupted download or copy leaves, from those that can
+// run, keeping their order. A size of 0 can also mean the database doesn't
+// know it, so with local, the file itself is checked too; sizes are only
+// trusted as they are for sources on another host.
+func EmptySources(operations []Operation, local bool) (ok []Operation, suspect []Result) {
+	for _, op := range operations {
+		if op.Size == 0 && videoExtensions[strings.ToLower(filepath.Ext(op.Source))] && (!local || emptyFile(op.Source)) {
+			suspect = append(suspect, Result{Operation: op, Error: fmt.Errorf("source file is empty (0 bytes)")})
+			continue
+		}
+		ok = append(ok, op)
+	}
+	return ok, suspect
+}
+
+// emptyFile reports whether path is a regular file of 0 bytes
+func emptyFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && info.Size() == 0
+}
+
+// checkSource returns why the file at path can't be used as a source, or
+// why it looks broken
+func checkSource(path string, containers bool) (unreachable, suspect error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("source file does not exist"), nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot access source: %w", err), nil
+	}
+	if !info.Mode().IsRegular() {
+		return nil, nil
+	}
+	if info.Size() == 0 {
+		return nil, fmt.Errorf("source file is empty (0 bytes)")
+	}
+	if containers {
+		return nil, checkContainer(path)
+	}
+	return nil, nil
+}
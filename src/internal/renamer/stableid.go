@@ -0,0 +1,129 @@
+package renamer
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"plexrenamer/internal/database"
+)
+
+// legacyAgents maps the agents of Plex's legacy GUIDs, e.g.
+// com.plexapp.agents.thetvdb://81189?lang=en, to their ID prefix
+var legacyAgents = map[string]string{
+	"com.plexapp.agents.thetvdb":    "tvdb",
+	"com.plexapp.agents.themoviedb": "tmdb",
+	"com.plexapp.agents.imdb":       "imdb",
+}
+
+// showIDOrder and movieIDOrder are the databases whose IDs are preferred
+// for shows and movies, following what Sonarr and Radarr use
+var (
+	showIDOrder  = []string{"tvdb", "tmdb", "imdb"}
+	movieIDOrder = []string{"tmdb", "imdb", "tvdb"}
+)
+
+// StableID returns an ID of a show or movie that doesn't change when Plex
+// renames it, in the form media servers recognize in folder names (e.g.
+// tvdbid-81189), or "" if it has none
+func StableID(item *database.MetadataItem) string {
+	ids := externalIDs(item)
+	order := movieIDOrder
+	if item.MetadataType == database.MediaTypeShow {
+		order = showIDOrder
+	}
+	for _, source := range order {
+		if id := ids[source]; id != "" {
+			return source + "id-" + sanitizeFilename(id)
+		}
+	}
+	return ""
+}
+
+// SourceID returns the ID of a show or movie in one database, in the form
+// of StableID (e.g. imdbid-tt0133093), or "" if it has none there
+func SourceID(item *database.MetadataItem, source string) string {
+	if id := externalIDs(item)[source]; id != "" {
+		return source + "id-" + sanitizeFilename(id)
+	}
+	return ""
+}
+
+// externalIDs returns the IDs of the GUIDs of an item by database, e.g.
+// "tvdb" -> "81189"
+func externalIDs(item *database.MetadataItem) map[string]string {
+	ids := map[string]string{}
+	for _, guid := range append([]string{item.GUID}, item.ExternalIDs...) {
+		source, id, ok := strings.Cut(guid, "://")
+		if !ok {
+			continue
+		}
+		if prefix, ok := legacyAgents[source]; ok {
+			source = prefix
+		}
+		id, _, _ = strings.Cut(id, "?")
+		if id != "" && ids[source] == "" {
+			ids[source] = id
+		}
+	}
+	return ids
+}
+
+// folderIDPattern matches a folder name ending in a stable ID, as added by
+// Formatter.FolderIDs, or in Plex's braces, as the plex preset writes it
+var folderIDPattern = regexp.MustCompile(` (?:\[((?:tvdb|tmdb|imdb)id-[^\]]+)\]|\{(tvdb|tmdb|imdb)-([^}]+)\})$`)
+
+// FolderID returns the folder tagged with a stable ID that path is, or is
+// in, and that ID, or "" if there is none
+func FolderID(path string) (folder, id string) {
+	for dir := path; dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		m := folderIDPattern.FindStringSubmatch(filepath.Base(dir))
+		switch {
+		case m == nil:
+		case m[1] != "":
+			return dir, m[1]
+		default:
+			return dir, m[2] + "id-" + m[3]
+		}
+	}
+	return "", ""
+}
+
+// withFolderID returns format with the stable ID added to the name of the
+// folder that holds the anchor placeholder ({show} or {title}). Formats
+// that use {id} or {imdb_id} themselves, or have anchor only in the file
+// name, are returned as they are.
+func withFolderID(format, anchor string) string {
+	if usesPlaceholder(format, "id") || usesPlaceholder(format, "imdb_id") {
+		return format
+	}
+	anchorEnd := -1
+	spanEnds := map[int]int{}
+	placeholders(format, func(start, end int, p placeholder) {
+		spanEnds[start] = end
+		if anchorEnd < 0 && p.name == anchor {
+			anchorEnd = end
+		}
+	})
+	if anchorEnd < 0 {
+		return format
+	}
+	for i := anchorEnd; i < len(format); i++ {
+		if end, ok := spanEnds[i]; ok {
+			i = end - 1
+			continue
+		}
+		if format[i] == '/' {
+			return format[:i] + "{folder_id}" + format[i:]
+		}
+	}
+	return format
+}
+
+// folderIDValue is the {folder_id} added by withFolderID
+func folderIDValue(id string) string {
+	if id == "" {
+		return ""
+	}
+	return " [" + id + "]"
+}
@@ -0,0 +1,233 @@
+package renamer
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// TVTokens are the placeholders available in TV formats, besides those of
+// token providers
+var TVTokens = []string{"library", "show", "show_sort", "show_clean", "letter", "season", "snum", "season_folder", "enum", "date", "title", "year", "show_year", "year_range", "genre", "decade", "version", "id", "quality", "hdr", "audio", "vcodec", "group", "size", "ext"}
+
+// MovieTokens are the placeholders available in movie formats, besides
+// those of token providers
+var MovieTokens = []string{"library", "title", "title_sort", "title_clean", "letter", "year", "genre", "decade", "version", "id", "imdb_id", "quality", "hdr", "audio", "vcodec", "group", "size", "ext"}
+
+// tokenWidths are the number of digits placeholders are zero-padded to
+// unless a width is given, e.g. {enum} is 07 while {enum:1} is 7
+var tokenWidths = map[string]int{"snum": 2, "enum": 2}
+
+// modifiers are the named transformations a placeholder can apply after a
+// colon, e.g. {title:upper}. A number instead zero-pads to that many digits.
+var modifiers = map[string]func(string) string{
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+	"short":   shortYear,
+	"bracket": func(v string) string { return wrapNonEmpty("[", v, "]") },
+	"dash":    func(v string) string { return wrapNonEmpty("-", v, "") },
+	"dot":     func(v string) string { return wrapNonEmpty(".", v, "") },
+	"braces":  plexBraces,
+	"space":   func(v string) string { return wrapNonEmpty(" ", v, "") },
+}
+
+// placeholder is a parsed {name:modifier:...|default} placeholder
+type placeholder struct {
+	name       string
+	modifiers  []string
+	def        string // Format used when the value is empty, e.g. "Episode {enum}"
+	hasDefault bool
+}
+
+// parsePlaceholder parses the text between the braces of a placeholder
+func parsePlaceholder(s string) placeholder {
+	s, def, hasDefault := strings.Cut(s, "|")
+	name, mods, _ := strings.Cut(s, ":")
+	p := placeholder{name: name, def: def, hasDefault: hasDefault}
+	if mods != "" {
+		p.modifiers = strings.Split(mods, ":")
+	}
+	return p
+}
+
+// apply returns value padded and transformed by the placeholder's modifiers
+func (p placeholder) apply(value string) string {
+	width := tokenWidths[p.name]
+	for _, m := range p.modifiers {
+		if n, err := strconv.Atoi(m); err == nil {
+			width = n
+		}
+	}
+	if isDigits(value) && len(value) < width {
+		value = strings.Repeat("0", width-len(value)) + value
+	}
+
+	for _, m := range p.modifiers {
+		if fn, ok := modifiers[m]; ok {
+			value = fn(value)
+		}
+	}
+	return value
+}
+
+// placeholders calls fn for each top-level placeholder in format with its
+// position, stopping at the first unclosed one. Braces inside a placeholder's
+// default belong to that placeholder.
+func placeholders(format string, fn func(start, end int, p placeholder)) {
+	for offset := 0; ; {
+		start := strings.IndexByte(format[offset:], '{')
+		if start < 0 {
+			return
+		}
+		start += offset
+
+		end, depth := -1, 0
+		for i := start; i < len(format) && end < 0; i++ {
+			switch format[i] {
+			case '{':
+				depth++
+			case '}':
+				if depth--; depth == 0 {
+					end = i + 1
+				}
+			}
+		}
+		if end < 0 {
+			return
+		}
+		fn(start, end, parsePlaceholder(format[start+1:end-1]))
+		offset = end
+	}
+}
+
+// expandFormat replaces the placeholders of format with their values.
+// Empty values are replaced by the placeholder's default, or else by their
+// entry in fallbacks. Placeholders without a value are left as they are.
+func expandFormat(format string, values, fallbacks map[string]string) string {
+	var b strings.Builder
+	last := 0
+	placeholders(format, func(start, end int, p placeholder) {
+		value, ok := values[p.name]
+		if !ok {
+			return
+		}
+		if value == "" && p.hasDefault {
+			value = expandFormat(p.def, values, fallbacks)
+		} else if value == "" {
+			value = fallbacks[p.name]
+		}
+		b.WriteString(format[last:start])
+		b.WriteString(p.apply(value))
+		last = end
+	})
+	b.WriteString(format[last:])
+	return b.String()
+}
+
+// ValidateTVFormat checks that a TV format only uses TV placeholders
+func ValidateTVFormat(format string) error {
+	return validateFormat(format, "TV", slices.Concat(TVTokens, providedTokens()), nil)
+}
+
+// ValidateMovieFormat checks that a movie format only uses movie
+// placeholders, pointing out those that only exist for TV shows
+func ValidateMovieFormat(format string) error {
+	return validateFormat(format, "movie", slices.Concat(MovieTokens, providedTokens()), TVTokens)
+}
+
+// validateFormat reports the placeholders of format that are not in known or
+// use unknown modifiers, listing the known ones. Placeholders in other
+// belong to the other media type.
+func validateFormat(format, kind string, known, other []string) error {
+	if format == "" {
+		return fmt.Errorf("%s format is empty", kind)
+	}
+	if strings.Count(format, "{") != strings.Count(format, "}") {
+		return fmt.Errorf("%s format %q has unbalanced braces", kind, format)
+	}
+
+	var problems []string
+	var check func(format string)
+	check = func(format string) {
+		placeholders(format, func(start, end int, p placeholder) {
+			token := format[start:end]
+			if p.hasDefault {
+				head, _, _ := strings.Cut(token, "|")
+				token = head + "|…}"
+				check(p.def)
+			}
+			if problem := placeholderProblem(token, p, known, other); problem != "" && !slices.Contains(problems, problem) {
+				problems = append(problems, problem)
+			}
+		})
+	}
+	check(format)
+	if len(problems) == 0 {
+		return nil
+	}
+
+	var available []string
+	for _, t := range known {
+		available = append(available, "{"+t+"}")
+	}
+	return fmt.Errorf("%s format %q has invalid placeholders %s (available: %s; modifiers: a width such as :3, :upper, :lower, :short, :bracket, :braces, :dash, :dot, :space)",
+		kind, format, strings.Join(problems, ", "), strings.Join(available, " "))
+}
+
+// placeholderProblem describes what is wrong with a placeholder, or returns
+// "" if it is valid
+func placeholderProblem(token string, p placeholder, known, other []string) string {
+	switch {
+	case !slices.Contains(known, p.name) && slices.Contains(other, p.name):
+		return token + " (TV shows only)"
+	case !slices.Contains(known, p.name):
+		return token
+	}
+	for _, m := range p.modifiers {
+		if _, err := strconv.Atoi(m); err != nil && modifiers[m] == nil {
+			return fmt.Sprintf("%s (unknown modifier %q)", token, m)
+		}
+	}
+	return ""
+}
+
+// plexBraces puts a value in braces, giving a stable ID the form Plex
+// recognizes, e.g. tvdbid-81189 -> {tvdb-81189}
+func plexBraces(value string) string {
+	if source, id, ok := strings.Cut(value, "id-"); ok && slices.Contains(showIDOrder, source) {
+		value = source + "-" + id
+	}
+	return wrapNonEmpty("{", value, "}")
+}
+
+// shortYear drops the century from a value starting with a year, e.g.
+// 1999 -> 99 and 1980s -> 80s
+func shortYear(value string) string {
+	if len(value) >= 4 && isDigits(value[:4]) {
+		return value[2:]
+	}
+	return value
+}
+
+// wrapNonEmpty puts value between prefix and suffix, unless it is empty,
+// e.g. {group:dash} is -GROUP or nothing
+func wrapNonEmpty(prefix, value, suffix string) string {
+	if value == "" {
+		return ""
+	}
+	return prefix + value + suffix
+}
+
+// isDigits reports whether s is a non-empty string of ASCII digits
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+package renamer
+
+import (
+	"strings"
+	"testing"
+)
+
+// templateValues are the placeholder values the template tests expand
+var templateValues = map[string]string{
+	"show":  "Breaking Bad",
+	"title": "Pilot",
+	"year":  "1999",
+	"enum":  "7",
+	"group": "",
+	"ext":   ".mkv",
+}
+
+func TestExpandFormat(t *testing.T) {
+	tests := []struct {
+		format, want string
+	}{
+		// Episode numbers are padded to two digits unless a width is given
+		{"{enum}", "07"},
+		{"{enum:3}", "007"},
+		{"{enum:1}", "7"},
+		{"{title:upper}", "PILOT"},
+		{"{title:lower:bracket}", "[pilot]"},
+		{"{year:short}", "99"},
+		// Wrapping modifiers leave nothing for an empty value
+		{"{title}{group:dash}{ext}", "Pilot.mkv"},
+		{"{title}{group:bracket}{ext}", "Pilot.mkv"},
+		// Placeholders without a value are left as they are
+		{"{show} - {missing}", "Breaking Bad - {missing}"},
+		{"s{enum", "s{enum"},
+	}
+
+	for _, tt := range tests {
+		if got := expandFormat(tt.format, templateValues, nil); got != tt.want {
+			t.Errorf("expandFormat(%q) = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestExpandFormatDefaults(t *testing.T) {
+	values := map[string]string{"title": "", "year": "", "enum": "7", "genre": "Drama"}
+	fallbacks := map[string]string{"year": "Unknown", "genre": "Unknown"}
+	tests := []struct {
+		format, want string
+	}{
+		{"{year|Unknown}", "Unknown"},
+		{"{year|}", ""},
+		// Defaults may hold placeholders, with their own modifiers
+		{"{title|Episode {enum}}", "Episode 07"},
+		{"{title|Episode {enum:1}}", "Episode 7"},
+		// The modifiers of the placeholder apply to its default
+		{"{year:bracket|x}", "[x]"},
+		{"{title:upper|Episode {enum}}", "EPISODE 07"},
+		// A value that isn't empty ignores the default
+		{"{genre|None}", "Drama"},
+		// Without a default, the fallback is used
+		{"{year}", "Unknown"},
+		{"{title}", ""},
+	}
+
+	for _, tt := range tests {
+		if got := expandFormat(tt.format, values, fallbacks); got != tt.want {
+			t.Errorf("expandFormat(%q) = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestParsePlaceholder(t *testing.T) {
+	tests := []struct {
+		s    string
+		want placeholder
+	}{
+		{"title", placeholder{name: "title"}},
+		{"enum:3", placeholder{name: "enum", modifiers: []string{"3"}}},
+		{"title:lower:short", placeholder{name: "title", modifiers: []string{"lower", "short"}}},
+		{"year|Unknown", placeholder{name: "year", def: "Unknown", hasDefault: true}},
+		{"year:bracket|x", placeholder{name: "year", modifiers: []string{"bracket"}, def: "x", hasDefault: true}},
+		{"title|Episode {enum}", placeholder{name: "title", def: "Episode {enum}", hasDefault: true}},
+		{"genre|", placeholder{name: "genre", hasDefault: true}},
+	}
+
+	for _, tt := range tests {
+		got := parsePlaceholder(tt.s)
+		if got.name != tt.want.name || strings.Join(got.modifiers, ":") != strings.Join(tt.want.modifiers, ":") ||
+			got.def != tt.want.def || got.hasDefault != tt.want.hasDefault {
+			t.Errorf("parsePlaceholder(%q) = %+v, want %+v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestValidateFormat(t *testing.T) {
+	tests := []struct {
+		format string
+		err    string // Part of the error, or "" if the format is valid
+	}{
+		{"{show}/{show} - s{snum:3}e{enum:1} - {title:upper}{group:dash}{ext}", ""},
+		{"{show} {year:short}{ext}", ""},
+		{"{show} ({year|Unknown})/{title|Episode {enum}}{year:bracket|x}{ext}", ""},
+		{"{title|Episode {episode}}{ext}", "invalid placeholders {episode}"},
+		{"{title|Episode {enum:shout}}{ext}", `unknown modifier "shout"`},
+		{"{title:shout}{ext}", `unknown modifier "shout"`},
+		{"{title:upper:shout}{ext}", `unknown modifier "shout"`},
+		{"{title{ext}", "unbalanced braces"},
+		{"{title}}{ext}", "unbalanced braces"},
+		{"{titel}{ext}", "invalid placeholders {titel}"},
+		{"", "format is empty"},
+	}
+
+	for _, tt := range tests {
+		err := ValidateTVFormat(tt.format)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("ValidateTVFormat(%q) = %v, want no error", tt.format, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("ValidateTVFormat(%q) = %v, want an error with %q", tt.format, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package renamer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// ExecTokenProvider is a TokenProvider that is a program, run once by the
+// shell. For each file it is written a line of JSON describing it, and it
+// answers with a line holding a JSON object of its placeholders' values,
+// e.g. {"rating": "8.1"}. It should exit when its input ends.
+type ExecTokenProvider struct {
+	Command string
+	tokens  []string
+
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Scanner
+	err    error                        // Why the program can't be asked anymore
+	cache  map[string]map[string]string // Values by file and item, as names are formatted more than once
+}
+
+// tokenRequest is the line written to an ExecTokenProvider about a file
+type tokenRequest struct {
+	Type    string     `json:"type"` // movie or episode
+	File    string     `json:"file"`
+	ID      int64      `json:"id"` // Plex metadata ID of the movie or episode
+	GUID    string     `json:"guid,omitempty"`
+	Title   string     `json:"title"`
+	Year    int        `json:"year,omitempty"`
+	IDs     []string   `json:"ids,omitempty"` // e.g. tmdb://603
+	Show    *tokenShow `json:"show,omitempty"`
+	Season  int        `json:"season,omitempty"`
+	Episode int        `json:"episode,omitempty"`
+}
+
+// tokenShow is the show of an episode in a tokenRequest
+type tokenShow struct {
+	ID    int64    `json:"id"`
+	GUID  string   `json:"guid,omitempty"`
+	Title string   `json:"title"`
+	Year  int      `json:"year,omitempty"`
+	IDs   []string `json:"ids,omitempty"`
+}
+
+// NewExecTokenProvider returns a provider of tokens that runs command when
+// it is first asked for values
+func NewExecTokenProvider(command string, tokens []string) *ExecTokenProvider {
+	return &ExecTokenProvider{Command: command, tokens: tokens, cache: map[string]map[string]string{}}
+}
+
+// Tokens returns the names of the placeholders the program supplies
+func (e *ExecTokenProvider) Tokens() []string {
+	return e.tokens
+}
+
+// Values asks the program for the values of its placeholders for a file
+func (e *ExecTokenProvider) Values(item TokenItem) (map[string]string, error) {
+	req := tokenRequest{Type: "movie", File: item.File.File}
+	meta := item.Movie
+	if meta == nil {
+		req.Type = "episode"
+		meta = item.Episode
+		req.Show = &tokenShow{ID: item.Show.ID, GUID: item.Show.GUID, Title: item.Show.Title, Year: intValue(item.Show.Year), IDs: item.Show.ExternalIDs}
+		req.Season = intValue(item.Season.Index)
+		req.Episode = intValue(meta.Index)
+	}
+	req.ID, req.GUID, req.Title, req.Year, req.IDs = meta.ID, meta.GUID, meta.Title, intValue(meta.Year), meta.ExternalIDs
+
+	key := req.File + "\x00" + strconv.FormatInt(req.ID, 10)
+	if values, ok := e.cache[key]; ok {
+		return values, nil
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	values, err := e.ask(req)
+	if err != nil {
+		e.err = fmt.Errorf("%s: %w", e.Command, err)
+		return nil, e.err
+	}
+	e.cache[key] = values
+	return values, nil
+}
+
+// ask writes req to the program, starting it first if needed, and reads
+// its answer
+func (e *ExecTokenProvider) ask(req tokenRequest) (map[string]string, error) {
+	if e.cmd == nil {
+		if err := e.start(); err != nil {
+			return nil, err
+		}
+	}
+	line, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := e.stdin.Write(append(line, '\n')); err != nil {
+		return nil, fmt.Errorf("failed to write to it: %w", err)
+	}
+	if !e.stdout.Scan() {
+		if err := e.stdout.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read its answer: %w", err)
+		}
+		return nil, fmt.Errorf("it exited without answering for %s", req.File)
+	}
+
+	var answer map[string]json.RawMessage
+	if err := json.Unmarshal(e.stdout.Bytes(), &answer); err != nil {
+		return nil, fmt.Errorf("invalid answer for %s: %w", req.File, err)
+	}
+	values := make(map[string]string, len(answer))
+	for token, raw := range answer {
+		// Numbers and booleans are taken as they are written
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			s = strings.TrimSpace(string(raw))
+			if s == "null" {
+				s = ""
+			}
+		}
+		values[token] = s
+	}
+	return values, nil
+}
+
+// start runs the program with the shell, as hooks are run. What it writes
+// to stderr goes to ours.
+func (e *ExecTokenProvider) start() error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", e.Command)
+	} else {
+		cmd = exec.Command("sh", "-c", e.Command)
+	}
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start: %w", err)
+	}
+	e.cmd, e.stdin = cmd, stdin
+	e.stdout = bufio.NewScanner(stdout)
+	e.stdout.Buffer(nil, 1024*1024)
+	return nil
+}
+
+// Close ends the program's input and waits for it to exit. Its answers are
+// forgotten; if asked again, it is started again.
+func (e *ExecTokenProvider) Close() error {
+	e.cache = map[string]map[string]string{}
+	e.err = nil
+	if e.cmd == nil {
+		return nil
+	}
+	e.stdin.Close()
+	err := e.cmd.Wait()
+	e.cmd = nil
+	return err
+}
+
+// intValue returns *n, or 0 if n is nil
+func intValue(n *int) int {
+	if n == nil {
+		return 0
+	}
+	return *n
+}
@@ -0,0 +1,98 @@
+package renamer
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+
+	"plexrenamer/internal/database"
+)
+
+// TokenItem is the file of a movie or episode a TokenProvider is asked
+// about. Movie is set for movies; Show, Season, and Episode for episodes.
+type TokenItem struct {
+	Movie   *database.MetadataItem
+	Show    *database.MetadataItem
+	Season  *database.MetadataItem
+	Episode *database.MetadataItem
+	File    database.MediaPart
+}
+
+// TokenProvider supplies placeholders of its own to the formats, e.g. with
+// data from the user's own database. Providers are compiled in by calling
+// RegisterTokenProvider from an init function, or run as a program with
+// NewExecTokenProvider.
+type TokenProvider interface {
+	// Tokens returns the names of the placeholders it supplies
+	Tokens() []string
+	// Values returns the values of its placeholders for a file. Missing
+	// and empty values use the placeholder's default.
+	Values(item TokenItem) (map[string]string, error)
+}
+
+// tokenName matches the names a provider can give its placeholders
+var tokenName = regexp.MustCompile(`^\w+$`)
+
+var (
+	tokenProviders []TokenProvider
+	providerErr    error // The first error of a provider, see TokenProviderErr
+)
+
+// RegisterTokenProvider adds the placeholders of p to the TV and movie
+// formats. They may not have the name of a built-in or already provided
+// placeholder.
+func RegisterTokenProvider(p TokenProvider) error {
+	provided := providedTokens()
+	for _, token := range p.Tokens() {
+		if !tokenName.MatchString(token) {
+			return fmt.Errorf("invalid token name %q: use letters, digits, and underscores", token)
+		}
+		if slices.Contains(TVTokens, token) || token == "folder_id" || slices.Contains(provided, token) {
+			return fmt.Errorf("token provider can't supply {%s}: it already exists", token)
+		}
+	}
+	tokenProviders = append(tokenProviders, p)
+	return nil
+}
+
+// TokenProviderErr returns the first error a token provider returned, if
+// any. Its placeholders were then left empty, so the names formatted since
+// shouldn't be used.
+func TokenProviderErr() error {
+	return providerErr
+}
+
+// providedTokens returns the placeholders of the registered providers
+func providedTokens() []string {
+	var tokens []string
+	for _, p := range tokenProviders {
+		tokens = append(tokens, p.Tokens()...)
+	}
+	return tokens
+}
+
+// addProvidedValues adds the values of the registered providers for item
+// to values, made safe for file names
+func addProvidedValues(values map[string]string, item TokenItem) {
+	for _, p := range tokenProviders {
+		provided, err := p.Values(item)
+		if err != nil && providerErr == nil {
+			providerErr = err
+		}
+		for _, token := range p.Tokens() {
+			values[token] = sanitizeFilename(provided[token])
+		}
+	}
+}
+
+// CloseTokenProviders closes the registered providers that are programs
+// and forgets their errors, so a later run asks them afresh
+func CloseTokenProviders() {
+	for _, p := range tokenProviders {
+		if c, ok := p.(io.Closer); ok {
+			c.Close()
+		}
+	}
+	providerErr = nil
+}
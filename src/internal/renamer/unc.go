@@ -0,0 +1,42 @@
+package renamer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseUNCShare parses a "Z:=\\server\share" mapping of a drive letter to
+// the share it is mapped to
+func ParseUNCShare(s string) (drive, share string, err error) {
+	drive, share, ok := strings.Cut(s, "=")
+	drive = strings.TrimSuffix(strings.TrimSpace(drive), ":")
+	share = strings.TrimSpace(share)
+	if !ok || len(drive) != 1 || !isLetter(drive[0]) || !isUNC(share) {
+		return "", "", fmt.Errorf(`invalid UNC share %q, use e.g. Z:=\\nas\media`, s)
+	}
+	return strings.ToUpper(drive), share, nil
+}
+
+// isUNC reports whether path is a \\server\share path
+func isUNC(path string) bool {
+	return strings.HasPrefix(toSlash(path), "//") && len(path) > 2
+}
+
+// ToUNC replaces the drive letter of path with the share the drive is mapped
+// to in shares, keyed by uppercase letter. Other paths are returned as they
+// are.
+func ToUNC(path string, shares map[string]string) string {
+	if len(path) < 2 || !isLetter(path[0]) || path[1] != ':' || (len(path) > 2 && path[2] != '\\' && path[2] != '/') {
+		return path
+	}
+	share, ok := shares[strings.ToUpper(path[:1])]
+	if !ok {
+		return path
+	}
+	share = strings.TrimRight(share, `\/`)
+	rest := strings.ReplaceAll(strings.TrimLeft(path[2:], `\/`), "/", `\`)
+	if rest == "" {
+		return share
+	}
+	return share + `\` + rest
+}
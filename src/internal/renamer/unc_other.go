@@ -0,0 +1,8 @@
+//go:build !windows
+
+package renamer
+
+// MappedDrives returns nothing outside Windows, which has no drive letters
+func MappedDrives() map[string]string {
+	return nil
+}
@@ -0,0 +1,26 @@
+//go:build windows
+
+package renamer
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procWNetGetConnectionW = syscall.NewLazyDLL("mpr.dll").NewProc("WNetGetConnectionW")
+
+// MappedDrives returns the shares the drive letters of the current user are
+// mapped to, keyed by uppercase letter
+func MappedDrives() map[string]string {
+	shares := map[string]string{}
+	for c := 'A'; c <= 'Z'; c++ {
+		drive, _ := syscall.UTF16PtrFromString(string(c) + ":")
+		buf := make([]uint16, 1024)
+		size := uint32(len(buf))
+		ret, _, _ := procWNetGetConnectionW.Call(uintptr(unsafe.Pointer(drive)), uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size)))
+		if ret == 0 {
+			shares[string(c)] = syscall.UTF16ToString(buf)
+		}
+	}
+	return shares
+}
@@ -0,0 +1,171 @@
+package renamer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// maxSegmentLength is the longest file or directory name (in bytes) supported
+// by common filesystems (ext4, NTFS, APFS)
+const maxSegmentLength = 255
+
+// maxPathLength returns the longest full path supported on this platform
+func maxPathLength(path string) int {
+	if runtime.GOOS == "windows" && !strings.HasPrefix(path, `\\?\`) {
+		return 259 // MAX_PATH minus the terminating NUL
+	}
+	return 4095 // PATH_MAX minus the terminating NUL
+}
+
+// Validate checks whether the operation could be performed, without modifying
+// anything: the source must be a readable file, the destination must not exist,
+// its directory must be creatable/writable, and the path must not be too long.
+// A destination that already exists is reported as skipped, as Execute would.
+func (op *Operation) Validate() Result {
+	result := Result{Operation: *op}
+
+	srcInfo, err := os.Stat(op.Source)
+	if err != nil {
+		if os.IsNotExist(err) {
+			result.Error = fmt.Errorf("source file does not exist: %s", op.Source)
+		} else {
+			result.Error = fmt.Errorf("cannot access source: %w", err)
+		}
+		return result
+	}
+	// Folders are only renamed, e.g. after a title change with --folder-ids
+	if !srcInfo.Mode().IsRegular() && !(srcInfo.IsDir() && op.Mode == ModeMove) {
+		result.Error = fmt.Errorf("source is not a regular file: %s", op.Source)
+		return result
+	}
+	if !srcInfo.IsDir() {
+		f, err := os.Open(op.Source)
+		if err != nil {
+			result.Error = fmt.Errorf("source is not readable: %w", err)
+			return result
+		}
+		f.Close()
+	}
+
+	// Moving removes the source, which requires write access to its directory
+	if op.Mode == ModeMove {
+		if err := checkWritable(filepath.Dir(op.Source)); err != nil {
+			result.Error = fmt.Errorf("cannot remove source after move: %w", err)
+			return result
+		}
+	}
+
+	if err := CheckPathLength(op.Destination); err != nil {
+		result.Error = err
+		return result
+	}
+
+	if _, err := os.Stat(op.Destination); err == nil {
+		result.Skipped = true
+		result.Reason = SkipExists
+		result.Success = true
+		result.Message = "destination already exists, would skip"
+		return result
+	}
+
+	// The nearest existing ancestor must be a writable directory
+	dir := filepath.Dir(op.Destination)
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				result.Error = fmt.Errorf("cannot create directory, %s is a file", dir)
+				return result
+			}
+			if err := checkWritable(dir); err != nil {
+				result.Error = fmt.Errorf("destination directory is not writable: %w", err)
+				return result
+			}
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			result.Error = fmt.Errorf("destination root does not exist: %s", dir)
+			return result
+		}
+		dir = parent
+	}
+
+	result.Success = true
+	result.Message = fmt.Sprintf("%s would succeed", op.Mode)
+	if _, err := os.Stat(op.Destination + PartialSuffix); err == nil {
+		result.Message += ", resuming or replacing an interrupted copy"
+	}
+	return result
+}
+
+// ValidateBatch validates each operation and additionally reports operations
+// that would write to the same destination, or overwrite another operation's
+// source, before it has been processed (unless that one is staged)
+func ValidateBatch(operations []Operation) []Result {
+	results := make([]Result, len(operations))
+
+	destinations := make(map[string]int, len(operations))
+	sources := make(map[string]int, len(operations))
+	for i, op := range operations {
+		sources[pathKey(op.Source)] = i
+	}
+
+	for i, op := range operations {
+		results[i] = op.Validate()
+		if results[i].Error != nil {
+			continue
+		}
+
+		key := pathKey(op.Destination)
+		if j, ok := sources[key]; ok && results[i].Skipped && operations[j].Staging != "" {
+			// The staged source moves out of the way first
+			results[i].Skipped, results[i].Reason = false, ""
+			results[i].Message = fmt.Sprintf("%s would succeed", op.Mode)
+		}
+		if first, ok := destinations[key]; ok {
+			results[i] = Result{
+				Operation: op,
+				Error:     fmt.Errorf("destination conflicts with operation #%d (%s)", first+1, operations[first].Source),
+			}
+			continue
+		}
+		destinations[key] = i
+
+		if j, ok := sources[key]; ok && j > i && operations[j].Staging == "" {
+			results[i] = Result{
+				Operation: op,
+				Error:     fmt.Errorf("destination is the source of later operation #%d", j+1),
+			}
+		}
+	}
+
+	return results
+}
+
+// CheckPathLength reports paths or path segments too long for common filesystems
+func CheckPathLength(path string) error {
+	if max := maxPathLength(path); len(path) > max {
+		return fmt.Errorf("destination path is %d characters, exceeding the limit of %d", len(path), max)
+	}
+	for _, segment := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if len(segment) > maxSegmentLength {
+			return fmt.Errorf("destination name %q is %d bytes, exceeding the limit of %d", segment, len(segment), maxSegmentLength)
+		}
+	}
+	return nil
+}
+
+// pathKey normalizes a path for conflict detection. Windows and macOS
+// filesystems are case-insensitive by default, and macOS ones also ignore
+// the Unicode form of names, so paths are folded there.
+func pathKey(path string) string {
+	key := filepath.Clean(path)
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		key = FoldPath(key)
+	}
+	return key
+}
@@ -0,0 +1,128 @@
+package renamer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"plexrenamer/internal/database"
+)
+
+// Versions returns the {version} label of each media item of an item that
+// Plex merged from several versions (e.g. a 1080p and a 2160p file), keyed by
+// media item ID. Items with a single version get no labels, so their names
+// don't change. Versions with the same resolution are numbered.
+func Versions(files []database.MediaPart) map[int64]string {
+	var items []database.MediaPart
+	for _, file := range files {
+		sameItem := func(f database.MediaPart) bool { return f.MediaItemID == file.MediaItemID }
+		if !slices.ContainsFunc(items, sameItem) {
+			items = append(items, file)
+		}
+	}
+	if len(items) < 2 {
+		return nil
+	}
+
+	count := map[string]int{}
+	for _, item := range items {
+		count[resolutionLabel(item.Width, item.Height)]++
+	}
+	seen := map[string]int{}
+	versions := make(map[int64]string, len(items))
+	for _, item := range items {
+		label := resolutionLabel(item.Width, item.Height)
+		if seen[label]++; count[label] > 1 {
+			label = fmt.Sprintf("%s %d", label, seen[label])
+		}
+		versions[item.MediaItemID] = label
+	}
+	return versions
+}
+
+// Parts returns the part number of each of files whose media item is split
+// into several files (e.g. a movie on two discs), in the order Plex lists
+// them, or 0 for files that are a whole version
+func Parts(files []database.MediaPart) []int {
+	count := map[int64]int{}
+	for _, file := range files {
+		count[file.MediaItemID]++
+	}
+	seen := map[int64]int{}
+	parts := make([]int, len(files))
+	for i, file := range files {
+		if count[file.MediaItemID] > 1 {
+			seen[file.MediaItemID]++
+			parts[i] = seen[file.MediaItemID]
+		}
+	}
+	return parts
+}
+
+// AddPart puts " - ptN" before the extension of the name of a part, as Plex
+// expects the parts of a stacked movie or episode to be named, or returns
+// name as it is for part 0
+func AddPart(name, ext string, part int) string {
+	if part == 0 {
+		return name
+	}
+	base, hasExt := strings.CutSuffix(name, ext)
+	if !hasExt {
+		return fmt.Sprintf("%s - pt%d", name, part)
+	}
+	return fmt.Sprintf("%s - pt%d%s", base, part, ext)
+}
+
+// Resolution returns the nominal height of a video resolution as releases
+// name it (2160, 1080, 720, 576, or 480), the height itself below that, or 0
+// if unknown. The width decides first, so wide films cropped to e.g. 1920x800
+// are still 1080.
+func Resolution(width, height int) int {
+	switch {
+	case width >= 3800 || height >= 2100:
+		return 2160
+	case width >= 1900 || height >= 1060:
+		return 1080
+	case width >= 1260 || height >= 700:
+		return 720
+	case height >= 570:
+		return 576
+	case height >= 470:
+		return 480
+	}
+	return max(height, 0)
+}
+
+// resolutionLabel names a video resolution the way releases do
+func resolutionLabel(width, height int) string {
+	switch r := Resolution(width, height); {
+	case r >= 480:
+		return fmt.Sprintf("%dp", r)
+	case r > 0:
+		return "SD"
+	default:
+		return "Unknown"
+	}
+}
+
+// addVersion puts " - version" before the extension of a name whose format
+// has no {version} placeholder, so the versions of an item don't collide
+func addVersion(name, format, ext, version string) string {
+	if version == "" || usesPlaceholder(format, "version") {
+		return name
+	}
+	base, hasExt := strings.CutSuffix(name, ext)
+	if !hasExt {
+		return name + " - " + version
+	}
+	return base + " - " + version + ext
+}
+
+// usesPlaceholder reports whether format has a placeholder called name
+func usesPlaceholder(format, name string) bool {
+	found := false
+	placeholders(format, func(_, _ int, p placeholder) {
+		found = found || p.name == name
+	})
+	return found
+}
@@ -0,0 +1,89 @@
+package renamer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Volume is an output directory on a disk of its own, for spreading a
+// library over several disks without pooling them
+type Volume struct {
+	Path    string
+	Free    int64 // Bytes available before the run
+	Planned int64 // Bytes of the operations assigned to it
+	Files   int   // Number of operations assigned to it
+}
+
+// Remaining returns the bytes that will be free after the run
+func (v *Volume) Remaining() int64 {
+	return v.Free - v.Planned
+}
+
+// DistributeVolumes moves the destinations of the operations planned under
+// the first volume to the volume with the most space left. Operations with
+// the same title (a show's episodes, a movie's versions) stay together, on
+// the volume that already has their folder if it has room.
+func DistributeVolumes(operations []Operation, volumes []*Volume) {
+	type group struct {
+		indexes []int
+		rels    []string
+		size    int64
+	}
+	var groups []*group
+	byTitle := map[string]*group{}
+	for i, op := range operations {
+		rel, err := filepath.Rel(volumes[0].Path, op.Destination)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		key := op.Title
+		if key == "" {
+			key = op.Destination
+		}
+		g := byTitle[key]
+		if g == nil {
+			g = &group{}
+			byTitle[key] = g
+			groups = append(groups, g)
+		}
+		g.indexes = append(g.indexes, i)
+		g.rels = append(g.rels, rel)
+		g.size += op.Size
+	}
+
+	for _, g := range groups {
+		target := existingVolume(volumes, g.rels, g.size)
+		if target == nil {
+			target = volumes[0]
+			for _, v := range volumes[1:] {
+				if v.Remaining() > target.Remaining() {
+					target = v
+				}
+			}
+		}
+		for j, i := range g.indexes {
+			operations[i].Destination = filepath.Join(target.Path, g.rels[j])
+		}
+		target.Planned += g.size
+		target.Files += len(g.indexes)
+	}
+}
+
+// existingVolume returns the volume that already has the folder of one of
+// rels, or the folder above it (a show's folder for a new season), and room
+// for size more bytes, or nil
+func existingVolume(volumes []*Volume, rels []string, size int64) *Volume {
+	for _, rel := range rels {
+		dir := filepath.Dir(rel)
+		for depth := 0; depth < 2 && dir != "."; depth++ {
+			for _, v := range volumes {
+				if info, err := os.Stat(filepath.Join(v.Path, dir)); err == nil && info.IsDir() && v.Remaining() >= size {
+					return v
+				}
+			}
+			dir = filepath.Dir(dir)
+		}
+	}
+	return nil
+}
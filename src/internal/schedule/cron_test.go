@@ -0,0 +1,95 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no time zone database: %v", err)
+	}
+	at := func(loc *time.Location, year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, loc)
+	}
+
+	// 2026-03-02 is a Monday
+	tests := []struct {
+		expr       string
+		from, want time.Time
+	}{
+		// Steps, from the start of the range or from a given value
+		{"*/15 * * * *", at(time.UTC, 2026, 3, 2, 10, 7), at(time.UTC, 2026, 3, 2, 10, 15)},
+		{"*/15 * * * *", at(time.UTC, 2026, 3, 2, 10, 45), at(time.UTC, 2026, 3, 2, 11, 0)},
+		{"5/15 * * * *", at(time.UTC, 2026, 3, 2, 10, 7), at(time.UTC, 2026, 3, 2, 10, 20)},
+		{"5/15 * * * *", at(time.UTC, 2026, 3, 2, 10, 50), at(time.UTC, 2026, 3, 2, 11, 5)},
+		{"0-30/10 * * * *", at(time.UTC, 2026, 3, 2, 10, 31), at(time.UTC, 2026, 3, 2, 11, 0)},
+		// The start time itself is never returned
+		{"0 3 * * *", at(time.UTC, 2026, 3, 2, 3, 0), at(time.UTC, 2026, 3, 3, 3, 0)},
+		// Lists, ranges, and names
+		{"0 3,15 * * *", at(time.UTC, 2026, 3, 2, 4, 0), at(time.UTC, 2026, 3, 2, 15, 0)},
+		{"0 9 * * mon-fri", at(time.UTC, 2026, 3, 6, 10, 0), at(time.UTC, 2026, 3, 9, 9, 0)},
+		{"0 9 * * MON-FRI", at(time.UTC, 2026, 3, 7, 10, 0), at(time.UTC, 2026, 3, 9, 9, 0)},
+		{"0 0 1 jan *", at(time.UTC, 2026, 3, 2, 0, 0), at(time.UTC, 2027, 1, 1, 0, 0)},
+		{"0 0 1 jun-aug *", at(time.UTC, 2026, 7, 2, 0, 0), at(time.UTC, 2026, 8, 1, 0, 0)},
+		// Day of week 7 is Sunday, like 0
+		{"0 0 * * 7", at(time.UTC, 2026, 3, 2, 0, 0), at(time.UTC, 2026, 3, 8, 0, 0)},
+		{"0 0 * * 0", at(time.UTC, 2026, 3, 2, 0, 0), at(time.UTC, 2026, 3, 8, 0, 0)},
+		{"0 0 * * 5-7", at(time.UTC, 2026, 3, 2, 0, 0), at(time.UTC, 2026, 3, 6, 0, 0)},
+		// A restricted day of month or a restricted day of week is enough
+		{"0 0 15 * mon", at(time.UTC, 2026, 3, 3, 0, 0), at(time.UTC, 2026, 3, 9, 0, 0)},
+		{"0 0 15 * mon", at(time.UTC, 2026, 3, 10, 0, 0), at(time.UTC, 2026, 3, 15, 0, 0)},
+		// ...but with the other field unrestricted, only the restricted one counts
+		{"0 0 15 * *", at(time.UTC, 2026, 3, 3, 0, 0), at(time.UTC, 2026, 3, 15, 0, 0)},
+		{"0 0 * * mon", at(time.UTC, 2026, 3, 10, 0, 0), at(time.UTC, 2026, 3, 16, 0, 0)},
+		// Macros
+		{"@daily", at(time.UTC, 2026, 3, 2, 10, 0), at(time.UTC, 2026, 3, 3, 0, 0)},
+		{"@weekly", at(time.UTC, 2026, 3, 2, 10, 0), at(time.UTC, 2026, 3, 8, 0, 0)},
+		// Days that never come
+		{"0 0 30 2 *", at(time.UTC, 2026, 3, 2, 0, 0), time.Time{}},
+		{"0 0 31 4,6,9,11 *", at(time.UTC, 2026, 3, 2, 0, 0), time.Time{}},
+		// February 29 is found in the next leap year
+		{"0 0 29 2 *", at(time.UTC, 2026, 3, 2, 0, 0), at(time.UTC, 2028, 2, 29, 0, 0)},
+		// Clocks go forward from 02:00 EST to 03:00 EDT on 2026-03-08, and
+		// back from 02:00 EDT to 01:00 EST on 2026-11-01. Times are on the
+		// wall clock, and one that was skipped comes the next day.
+		{"0 3 * * *", at(newYork, 2026, 3, 7, 12, 0), at(newYork, 2026, 3, 8, 3, 0)},
+		{"0 * * * *", at(newYork, 2026, 3, 8, 1, 30), at(newYork, 2026, 3, 8, 3, 0)},
+		{"30 2 * * *", at(newYork, 2026, 3, 7, 12, 0), at(newYork, 2026, 3, 9, 2, 30)},
+		{"0 12 * * *", at(newYork, 2026, 10, 31, 12, 0), at(newYork, 2026, 11, 1, 12, 0)},
+		{"0 * * * *", at(newYork, 2026, 11, 1, 0, 30), at(newYork, 2026, 11, 1, 1, 0)},
+		{"0 * * * *", at(newYork, 2026, 11, 1, 1, 0), at(newYork, 2026, 11, 1, 1, 0).Add(time.Hour)},
+	}
+
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		if got := s.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("Parse(%q).Next(%v) = %v, want %v", tt.expr, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"30-10 * * * *",
+		"* * * foo *",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", expr)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+
+	"plexrenamer/internal/database"
+	"plexrenamer/internal/renamer"
+)
+
+// Progress is told about each operation Execute carries out, and its result
+type Progress interface {
+	Begin(op Operation)
+	Done(op Operation, result Result)
+}
+
+// StageOverlaps lets moves whose destination is another operation's source
+// go through a temporary name, and returns how many do
+func StageOverlaps(operations []Operation) int {
+	return renamer.StageOverlaps(operations)
+}
+
+// Execute carries out operations in order, telling progress (if not nil)
+// about each. If ctx is cancelled, it stops and returns the results of the
+// operations attempted so far.
+func Execute(ctx context.Context, operations []Operation, opts ExecOptions, progress Progress) []Result {
+	renamer.StageSources(ctx, operations, opts)
+
+	results := make([]Result, 0, len(operations))
+	for _, op := range operations {
+		if ctx.Err() != nil {
+			break
+		}
+		if progress != nil {
+			progress.Begin(op)
+		}
+		result := op.Execute(ctx, opts)
+		results = append(results, result)
+		if progress != nil {
+			progress.Done(op, result)
+		}
+	}
+
+	renamer.UnstageSources(operations[len(results):], opts)
+	return results
+}
+
+// LoadLibraries reads the content of every library of a Plex database, or
+// of a Kodi video database (MyVideos*.db)
+func LoadLibraries(ctx context.Context, dbPath string) ([]*Content, error) {
+	var db interface {
+		GetLibrarySections(ctx context.Context) ([]Section, error)
+		GetLibraryContent(ctx context.Context, section Section) (*Content, error)
+		Close() error
+	}
+	var err error
+	if database.IsKodi(dbPath) {
+		db, err = database.OpenKodi(dbPath)
+	} else {
+		db, err = database.Open(dbPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	sections, err := db.GetLibrarySections(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get library sections: %w", err)
+	}
+	var libraries []*Content
+	for _, section := range sections {
+		content, err := db.GetLibraryContent(ctx, section)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get content for library %s: %w", section.Name, err)
+		}
+		libraries = append(libraries, content)
+	}
+	return libraries, nil
+}
@@ -0,0 +1,113 @@
+package planner
+
+import (
+	"path/filepath"
+	"strings"
+
+	"plexrenamer/internal/renamer"
+)
+
+// PathInLocations checks if a file path is under any of the locations
+func PathInLocations(filePath string, locations []Location) bool {
+	return LocationOf(filePath, locations) != ""
+}
+
+// NormalizePath normalizes a path for comparison by folding its case and
+// Unicode form (see renamer.FoldPath), using forward slashes, and removing
+// Windows long path prefixes
+func NormalizePath(path string) string {
+	normalized := renamer.FoldPath(filepath.ToSlash(path))
+	// Remove Windows long path prefix //?/ or \\?\
+	normalized = strings.TrimPrefix(normalized, "//?/")
+	normalized = strings.TrimPrefix(normalized, "//./")
+	return normalized
+}
+
+// LocationOf returns the root path of the location a file path is under,
+// or "" if it is under none of them
+func LocationOf(filePath string, locations []Location) string {
+	normalizedPath := NormalizePath(filePath)
+	for _, loc := range locations {
+		normalizedLoc := NormalizePath(loc.RootPath)
+		// Remove trailing slash for comparison
+		normalizedLoc = strings.TrimSuffix(normalizedLoc, "/")
+		// Check if the file path starts with the location path followed by /
+		if strings.HasPrefix(normalizedPath, normalizedLoc+"/") {
+			return loc.RootPath
+		}
+	}
+	return ""
+}
+
+// FilesInLocations checks if any file in the list is under any of the locations
+func FilesInLocations(files []MediaFile, locations []Location) bool {
+	for _, file := range files {
+		if PathInLocations(file.File, locations) {
+			return true
+		}
+	}
+	return false
+}
+
+// ShowInLocations checks if any episode in the show is under any of the locations
+func ShowInLocations(show *ShowInfo, locations []Location) bool {
+	for _, season := range show.Seasons {
+		for _, episode := range season.Episodes {
+			if FilesInLocations(episode.Files, locations) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Counts are the numbers of items and files of a library under some of its
+// locations, and the size of those files
+type Counts struct {
+	Movies, Shows, Seasons, Episodes int
+	Files                            int
+	Size                             int64 // Of the files whose size is known
+}
+
+// CountIn counts the items of content with files under locations, and
+// those files
+func CountIn(content *Content, locations []Location) Counts {
+	var c Counts
+	// countFiles counts the files under locations, and returns how many
+	countFiles := func(files []MediaFile) int {
+		n := 0
+		for _, file := range files {
+			if PathInLocations(file.File, locations) {
+				n++
+				c.Size += file.Size
+			}
+		}
+		c.Files += n
+		return n
+	}
+	for _, movie := range content.Movies {
+		if countFiles(movie.Files) > 0 {
+			c.Movies++
+		}
+	}
+	for _, show := range content.Shows {
+		seasons := 0
+		for _, season := range show.Seasons {
+			episodes := 0
+			for _, episode := range season.Episodes {
+				if countFiles(episode.Files) > 0 {
+					episodes++
+				}
+			}
+			if episodes > 0 {
+				seasons++
+				c.Episodes += episodes
+			}
+		}
+		if seasons > 0 {
+			c.Shows++
+			c.Seasons += seasons
+		}
+	}
+	return c
+}
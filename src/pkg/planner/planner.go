@@ -0,0 +1,520 @@
+// Package planner plans how the files of a media library are renamed by
+// their metadata, and carries the plan out. The plexrenamer command is
+// built on it; programs such as a web UI or a NAS app can embed the renamer
+// with it instead of running the command.
+//
+// A plan is made for the content of one library at a time, as read by
+// LoadLibraries:
+//
+//	libraries, err := planner.LoadLibraries(ctx, "com.plexapp.plugins.library.db")
+//	if err != nil {
+//		return err
+//	}
+//	opts := &planner.Options{Formatter: planner.NewFormatter("", ""), Mode: planner.ModeMove, OutputDir: "/media"}
+//	for _, content := range libraries {
+//		plan := opts.PlanLibrary(content, nil, nil)
+//		for _, result := range planner.Execute(ctx, plan.Operations(opts.Mode), planner.ExecOptions{DryRun: true}, nil) {
+//			fmt.Println(result.Operation.Destination, result.Message)
+//		}
+//	}
+package planner
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"plexrenamer/internal/database"
+	"plexrenamer/internal/renamer"
+)
+
+// Types of the libraries that are planned, and of the operations of a plan
+type (
+	Content     = database.LibraryContent
+	Section     = database.LibrarySection
+	Location    = database.SectionLocation
+	MovieInfo   = database.MovieInfo
+	ShowInfo    = database.ShowInfo
+	SeasonInfo  = database.SeasonInfo
+	EpisodeInfo = database.EpisodeInfo
+	MediaFile   = database.MediaPart
+	Metadata    = database.MetadataItem
+	Subtitle    = database.Subtitle
+	Formatter   = renamer.Formatter
+	PathMap     = renamer.PathMap
+	Mode        = renamer.OperationMode
+	LengthMode  = renamer.LengthMode
+	SortMode    = renamer.SortMode
+	MtimeMode   = renamer.MtimeMode
+	Quality     = renamer.Quality
+	Operation   = renamer.Operation
+	Result      = renamer.Result
+	ExecOptions = renamer.ExecOptions
+
+	TokenProvider = renamer.TokenProvider
+	TokenItem     = renamer.TokenItem
+)
+
+// Operation modes
+const (
+	ModeCopy = renamer.ModeCopy
+	ModeMove = renamer.ModeMove
+)
+
+// What happens to destinations too long for the filesystem
+const (
+	LengthTruncate = renamer.LengthTruncate
+	LengthError    = renamer.LengthError
+)
+
+// How the folders of shows and movies are named, see Formatter.SortFolders
+const (
+	SortNone  = renamer.SortNone
+	SortTitle = renamer.SortTitle
+	SortClean = renamer.SortClean
+)
+
+// Modification times given to destinations, see ExecOptions.SetMtime
+const (
+	MtimeKeep    = renamer.MtimeKeep
+	MtimeSource  = renamer.MtimeSource
+	MtimeAirdate = renamer.MtimeAirdate
+)
+
+// NewFormatter creates a formatter for the TV and movie formats, or the
+// default formats where they are ""
+func NewFormatter(tvFormat, movieFormat string) *Formatter {
+	return renamer.NewFormatter(tvFormat, movieFormat)
+}
+
+// RegisterTokenProvider adds the placeholders of p to the formats of every
+// formatter, e.g. {rating} from the program's own database
+func RegisterTokenProvider(p TokenProvider) error {
+	return renamer.RegisterTokenProvider(p)
+}
+
+// Options are how the files of a library are planned
+type Options struct {
+	Formatter   *Formatter
+	Mode        Mode
+	OutputDir   string              // Where files go ("" = the root of their library location)
+	Output4K    string              // Where 2160p files go instead of OutputDir ("" = OutputDir)
+	InPlace     bool                // Only rename files, keeping them in their directory
+	FoldersOnly bool                // Only move files into their folders, keeping their names
+	LongNames   LengthMode          // With LengthTruncate, shorten titles of destinations too long for the filesystem
+	PathMaps    []PathMap           // Translate paths as the media server sees them to paths here
+	UNCShares   map[string]string   // Write paths on these drive letters as \\server\share paths
+	Sidecars    bool                // Also plan the subtitles and other files named after each video
+	Subtitles   map[string]Subtitle // External subtitles the media server knows, by local path, for naming them
+
+	// Keep, if set, decides whether a file, found at srcPath, of a movie or
+	// episode is planned. show is nil for movies.
+	Keep func(srcPath string, file MediaFile, item, show *Metadata) bool
+
+	showTitles map[int64]string // With LengthTruncate, the shortened titles of shows by ID
+}
+
+// File is the planned source and destination of a file
+type File struct {
+	Source      string
+	Destination string
+	GUID        string    // Plex GUID of the movie or episode
+	Library     string    // Name of the library of the movie or episode
+	Size        int64     // Source size as recorded by Plex
+	Added       time.Time // When the movie or episode was added to Plex
+	Aired       time.Time // When the episode aired or the movie was released
+	Quality     Quality
+	Item        int64 // Plex metadata ID of the movie or episode
+	Version     int64 // Plex media ID of the version of the movie or episode the file is, or belongs to
+	Sidecar     bool  // A subtitle or other file named after the video of its version
+}
+
+// LocationOutput is the output directory chosen for a library location
+type LocationOutput struct {
+	Location   Location
+	OutputPath string // Custom output path for this location (empty = use default)
+}
+
+// Item is a movie or a show and its planned files
+type Item struct {
+	Title   string
+	Movie   *MovieInfo // Set for a movie
+	Show    *ShowInfo  // Set for a show
+	Files   []File
+	Seasons []int64 // For a show, the ID of the season of each file
+}
+
+// ID returns the Plex metadata ID of the movie or show
+func (i Item) ID() int64 {
+	if i.Movie != nil {
+		return i.Movie.Metadata.ID
+	}
+	if i.Show != nil {
+		return i.Show.Metadata.ID
+	}
+	return 0
+}
+
+// Operations turns files of the item into operations
+func (i Item) Operations(files []File, mode Mode) []Operation {
+	operations := Operations(i.Title, files, mode)
+	for j := range operations {
+		operations[j].Item = i.ID()
+	}
+	return operations
+}
+
+// InSeasons returns the files of the item that are in the seasons with the
+// given IDs, or all of them if seasons is nil
+func (i Item) InSeasons(seasons []int64) []File {
+	if seasons == nil || i.Show == nil {
+		return i.Files
+	}
+	var files []File
+	for j, f := range i.Files {
+		if slices.Contains(seasons, i.Seasons[j]) {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// Plan is what is planned for the files of a library
+type Plan struct {
+	Section Section
+	Items   []Item
+}
+
+// Operations returns the operations of all items of the plan
+func (p *Plan) Operations(mode Mode) []Operation {
+	var operations []Operation
+	for _, item := range p.Items {
+		operations = append(operations, item.Operations(item.Files, mode)...)
+	}
+	return operations
+}
+
+// Operations turns the planned files of the show or movie with the given
+// title into operations
+func Operations(title string, files []File, mode Mode) []Operation {
+	var operations []Operation
+	for _, f := range files {
+		operations = append(operations, Operation{
+			Source:      f.Source,
+			Destination: f.Destination,
+			Mode:        mode,
+			Title:       title,
+			Library:     f.Library,
+			GUID:        f.GUID,
+			Size:        f.Size,
+			Added:       f.Added,
+			Aired:       f.Aired,
+			Quality:     f.Quality,
+		})
+	}
+	return operations
+}
+
+// PlanLibrary plans the files of the movies or shows of content that are
+// in the selected locations (nil = all), to the output chosen for their
+// location, if any. Items without files to plan are left out.
+func (o *Options) PlanLibrary(content *Content, selected []Location, outputs []LocationOutput) *Plan {
+	o = o.ForLibrary(content.Section)
+	plan := &Plan{Section: content.Section}
+	outputPath := func(filePath string) string {
+		return o.OutputPath(filePath, content.Locations, outputs)
+	}
+
+	switch content.Section.SectionType {
+	case database.SectionTypeMovie:
+		for i := range content.Movies {
+			movie := &content.Movies[i]
+			if selected != nil && !FilesInLocations(movie.Files, selected) {
+				continue
+			}
+			if files := o.MovieFiles(movie, selected, outputPath); len(files) > 0 {
+				plan.Items = append(plan.Items, Item{Title: movie.Metadata.Title, Movie: movie, Files: files})
+			}
+		}
+
+	case database.SectionTypeShow:
+		for i := range content.Shows {
+			show := &content.Shows[i]
+			if selected != nil && !ShowInLocations(show, selected) {
+				continue
+			}
+			item := Item{Title: show.Metadata.Title, Show: show}
+			title := o.showTitles[show.Metadata.ID]
+			item.Files, item.Seasons = o.showFiles(show, selected, outputPath)
+			// An episode that needed a shorter show title than those before
+			// it plans them all again, so they share one folder
+			if o.showTitles[show.Metadata.ID] != title {
+				item.Files, item.Seasons = o.showFiles(show, selected, outputPath)
+			}
+			if len(item.Files) > 0 {
+				plan.Items = append(plan.Items, item)
+			}
+		}
+	}
+	return plan
+}
+
+// showFiles returns the planned files of the episodes of show, with the
+// season of each
+func (o *Options) showFiles(show *ShowInfo, selected []Location, outputPath func(string) string) ([]File, []int64) {
+	var files []File
+	var seasons []int64
+	for _, season := range show.Seasons {
+		for _, episode := range season.Episodes {
+			for _, f := range o.EpisodeFiles(&show.Metadata, &season.Metadata, &episode, selected, outputPath) {
+				files = append(files, f)
+				seasons = append(seasons, season.Metadata.ID)
+			}
+		}
+	}
+	return files, seasons
+}
+
+// ForLibrary returns a copy of o planning the items of section, whose name
+// its formatter fills {library} with
+func (o *Options) ForLibrary(section Section) *Options {
+	library := *o
+	library.Formatter = o.Formatter.ForLibrary(section.Name)
+	library.showTitles = map[int64]string{}
+	return &library
+}
+
+// OutputPath returns the output directory for a file: the output chosen
+// for its location, OutputDir, or else the root of its library location
+func (o *Options) OutputPath(filePath string, locations []Location, outputs []LocationOutput) string {
+	// First check if there's a custom output for this specific location
+	for _, lo := range outputs {
+		if PathInLocations(filePath, []Location{lo.Location}) {
+			return lo.OutputPath
+		}
+	}
+	if o.OutputDir != "" {
+		return o.OutputDir
+	}
+	// Otherwise, use the file's source location root as the output
+	// This keeps files organized in their original library location
+	if locPath := LocationOf(filePath, locations); locPath != "" {
+		return locPath
+	}
+	// Fallback to current directory (shouldn't happen normally)
+	return "."
+}
+
+// MovieFiles returns the planned source and destination of each file of a
+// movie within the selected locations
+func (o *Options) MovieFiles(movie *MovieInfo, selected []Location, outputPath func(string) string) []File {
+	versions, parts := renamer.Versions(movie.Files), renamer.Parts(movie.Files)
+	if o.FoldersOnly {
+		// The files keep their own names, which tell versions and parts apart
+		versions, parts = nil, make([]int, len(parts))
+	}
+	var files []File
+	for i, file := range movie.Files {
+		if selected != nil && !PathInLocations(file.File, selected) {
+			continue
+		}
+		srcPath := renamer.MapPath(file.File, o.PathMaps)
+		if o.Keep != nil && !o.Keep(srcPath, file, &movie.Metadata, nil) {
+			continue
+		}
+		ext := renamer.GetExtension(srcPath)
+		outputDir := o.fileOutputDir(file, outputPath)
+		sidecars := o.findSidecars(srcPath)
+		var destName string
+		if o.LongNames == renamer.LengthTruncate {
+			destName, _ = o.Formatter.FitMovie(o.fitsAt(o.Formatter, srcPath, outputDir, ext, parts[i], sidecars, ""), movie, file, versions[file.MediaItemID], ext)
+		} else {
+			destName = o.Formatter.FormatMovie(movie, file, versions[file.MediaItemID], ext)
+		}
+		destPath := o.destination(srcPath, outputDir, renamer.AddPart(destName, ext, parts[i]), "")
+		files = append(files, File{
+			Source:      renamer.ToUNC(srcPath, o.UNCShares),
+			Destination: renamer.ToUNC(destPath, o.UNCShares),
+			GUID:        movie.Metadata.GUID,
+			Library:     o.Formatter.Library,
+			Size:        file.Size,
+			Added:       movie.Metadata.AddedAt,
+			Aired:       aired(&movie.Metadata),
+			Quality:     renamer.FileQuality(file),
+			Item:        movie.Metadata.ID,
+			Version:     file.MediaItemID,
+		})
+		files = append(files, o.sidecarFiles(o.Formatter, sidecars, destPath, files[len(files)-1])...)
+	}
+	return files
+}
+
+// EpisodeFiles returns the planned source and destination of each file of
+// an episode within the selected locations, named with the show's own
+// format if the formatter has one. With LengthTruncate, a show title
+// shortened for an earlier episode is used for the rest of the show.
+func (o *Options) EpisodeFiles(show, season *Metadata, episode *EpisodeInfo, selected []Location, outputPath func(string) string) []File {
+	formatter := o.Formatter.ForShow(show)
+	versions, parts := renamer.Versions(episode.Files), renamer.Parts(episode.Files)
+	if o.FoldersOnly {
+		parts = make([]int, len(parts))
+	}
+	var files []File
+	for i, file := range episode.Files {
+		if selected != nil && !PathInLocations(file.File, selected) {
+			continue
+		}
+		srcPath := renamer.MapPath(file.File, o.PathMaps)
+		if o.Keep != nil && !o.Keep(srcPath, file, &episode.Metadata, show) {
+			continue
+		}
+		ext := renamer.GetExtension(srcPath)
+		outputDir := o.fileOutputDir(file, outputPath)
+		sidecars := o.findSidecars(srcPath)
+		var showFolder string // For flat formats with FoldersOnly
+		if o.FoldersOnly {
+			showFolder = renamer.FolderName(o.fittedShow(show).Title)
+		}
+		var destName string
+		if o.LongNames == renamer.LengthTruncate {
+			fits := o.fitsAt(formatter, srcPath, outputDir, ext, parts[i], sidecars, showFolder)
+			short := o.fittedShow(show)
+			if destName = formatter.FormatEpisode(short, season, episode, file, versions[file.MediaItemID], ext); !fits(destName) {
+				// The show title is shortened once for all its episodes
+				if title := formatter.FitShowTitle(fits, short, season, episode, file, versions[file.MediaItemID], ext); title != short.Title && o.showTitles != nil {
+					o.showTitles[show.ID] = title
+					short = o.fittedShow(show)
+				}
+				destName, _ = formatter.FitEpisode(fits, short, season, episode, file, versions[file.MediaItemID], ext)
+			}
+		} else {
+			destName = formatter.FormatEpisode(show, season, episode, file, versions[file.MediaItemID], ext)
+		}
+		destPath := o.destination(srcPath, outputDir, renamer.AddPart(destName, ext, parts[i]), showFolder)
+		files = append(files, File{
+			Source:      renamer.ToUNC(srcPath, o.UNCShares),
+			Destination: renamer.ToUNC(destPath, o.UNCShares),
+			GUID:        episode.Metadata.GUID,
+			Library:     formatter.Library,
+			Size:        file.Size,
+			Added:       episode.Metadata.AddedAt,
+			Aired:       aired(&episode.Metadata),
+			Quality:     renamer.FileQuality(file),
+			Item:        episode.Metadata.ID,
+			Version:     file.MediaItemID,
+		})
+		files = append(files, o.sidecarFiles(formatter, sidecars, destPath, files[len(files)-1])...)
+	}
+	return files
+}
+
+// fittedShow returns show with the title it was shortened to for the
+// filesystem, if it was
+func (o *Options) fittedShow(show *Metadata) *Metadata {
+	title, ok := o.showTitles[show.ID]
+	if !ok {
+		return show
+	}
+	short := *show
+	short.Title = title
+	return &short
+}
+
+// findSidecars returns, with Sidecars, the subtitles and other sidecars of
+// the video at srcPath
+func (o *Options) findSidecars(srcPath string) []renamer.Sidecar {
+	if !o.Sidecars {
+		return nil
+	}
+	// A directory that can't be read fails the video's own operation
+	sidecars, err := renamer.FindSidecars(srcPath, o.Subtitles)
+	if err != nil {
+		return nil
+	}
+	return sidecars
+}
+
+// sidecarFiles returns the planned source and destination of the sidecars
+// of a video that goes to destPath as planned in video
+func (o *Options) sidecarFiles(formatter *Formatter, sidecars []renamer.Sidecar, destPath string, video File) []File {
+	destinations := formatter.FormatSidecars(sidecars, destPath)
+	var files []File
+	for i, sc := range sidecars {
+		files = append(files, File{
+			Source:      renamer.ToUNC(sc.Path, o.UNCShares),
+			Destination: renamer.ToUNC(destinations[i], o.UNCShares),
+			GUID:        video.GUID,
+			Library:     video.Library,
+			Size:        sc.Size,
+			Added:       video.Added,
+			Aired:       video.Aired,
+			Item:        video.Item,
+			Version:     video.Version,
+			Sidecar:     true,
+		})
+	}
+	return files
+}
+
+// fileOutputDir returns the output directory of file: Output4K if it is a
+// 4K file and that is set, or else the one outputPath returns
+func (o *Options) fileOutputDir(file MediaFile, outputPath func(string) string) string {
+	if o.Output4K != "" && renamer.Resolution(file.Width, file.Height) >= 2160 {
+		return o.Output4K
+	}
+	return outputPath(file.File)
+}
+
+// fitsAt returns whether a file at srcPath named name by the formats, as
+// the given part, would have a destination short enough for the
+// filesystem, and so would its sidecars, named after it by formatter
+func (o *Options) fitsAt(formatter *Formatter, srcPath, outputDir, ext string, part int, sidecars []renamer.Sidecar, itemFolder string) func(name string) bool {
+	return func(name string) bool {
+		dest := o.destination(srcPath, outputDir, renamer.AddPart(name, ext, part), itemFolder)
+		if renamer.CheckPathLength(dest) != nil {
+			return false
+		}
+		for _, sidecar := range formatter.FormatSidecars(sidecars, dest) {
+			if renamer.CheckPathLength(sidecar) != nil {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// destination returns where a file named destName by the formats goes:
+// under its output directory, or with InPlace next to where it is. With
+// FoldersOnly it keeps its own name, in the folder of the format, or if the
+// format has none (as movie formats often don't), in itemFolder, the show's
+// folder for episodes, or for movies "" for one named like the file would be.
+func (o *Options) destination(srcPath, outputDir, destName, itemFolder string) string {
+	if o.InPlace {
+		return filepath.Join(filepath.Dir(srcPath), filepath.Base(destName))
+	}
+	if o.FoldersOnly {
+		folder := filepath.Dir(destName)
+		if folder == "." {
+			folder = itemFolder
+		}
+		if folder == "" {
+			folder = strings.TrimSuffix(destName, filepath.Ext(destName))
+		}
+		return filepath.Join(outputDir, folder, filepath.Base(srcPath))
+	}
+	return filepath.Join(outputDir, destName)
+}
+
+// aired returns midnight in local time of the day an episode aired or a
+// movie was released, or the zero time if it is unknown
+func aired(item *Metadata) time.Time {
+	if len(item.OriginallyAvailable) < len(time.DateOnly) {
+		return time.Time{}
+	}
+	t, err := time.ParseInLocation(time.DateOnly, item.OriginallyAvailable[:len(time.DateOnly)], time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}